@@ -0,0 +1,72 @@
+package btree
+
+// WriteBatch stages a group of inserts and deletes to be applied to a BTree
+// atomically by Apply. The zero value is an empty WriteBatch ready for use. A
+// WriteBatch is not safe for concurrent use, and may be applied any number of
+// times, to any number of trees.
+type WriteBatch struct {
+	ops []batchOp
+}
+
+// batchOp defines a single staged write, inserting e or deleting the Entry
+// equal to it.
+type batchOp struct {
+	e      Entry
+	delete bool
+}
+
+// Insert stages inserting the given Entry, replacing an equal Entry if one
+// exists. A nil Entry is ignored.
+func (wb *WriteBatch) Insert(e Entry) {
+	if e != nil {
+		wb.ops = append(wb.ops, batchOp{e: e})
+	}
+}
+
+// Delete stages removing the Entry equal to the given Entry, if any. A nil
+// Entry is ignored.
+func (wb *WriteBatch) Delete(e Entry) {
+	if e != nil {
+		wb.ops = append(wb.ops, batchOp{e: e, delete: true})
+	}
+}
+
+// Len returns the number of writes staged in the WriteBatch.
+func (wb *WriteBatch) Len() int {
+	return len(wb.ops)
+}
+
+// Reset discards every write staged in the WriteBatch, retaining its storage
+// for reuse.
+func (wb *WriteBatch) Reset() {
+	clear(wb.ops)
+	wb.ops = wb.ops[:0]
+}
+
+// Apply applies every write staged in the given WriteBatch to the BTree, in the
+// order they were staged, under a single acquisition of the write lock, so that
+// no reader observes only some of them. Every staged insert is validated before
+// any write is applied, so an invalid Entry panics without modifying the BTree.
+// The WriteBatch is left untouched.
+func (bt *BTree) Apply(wb *WriteBatch) {
+	if wb == nil || len(wb.ops) == 0 {
+		return
+	}
+
+	for _, op := range wb.ops {
+		if !op.delete {
+			bt.mustValidate(op.e)
+		}
+	}
+
+	bt.lock()
+	defer bt.unlock()
+
+	for _, op := range wb.ops {
+		if op.delete {
+			bt.delete(op.e)
+		} else {
+			bt.insert(op.e, nil)
+		}
+	}
+}
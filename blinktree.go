@@ -0,0 +1,395 @@
+package btree
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// BLinkTree implements a thread-safe B-link tree, the concurrent B+Tree
+	// variant of Lehman and Yao, which needs far less latching than a
+	// conventional B-Tree so that inserts can proceed highly concurrently. Keys
+	// and values are stored inline in the leaves, while internal nodes only hold
+	// separators. Every node carries a high key, bounding the keys of its
+	// subtree, and a link to its right sibling. Should a concurrent split move
+	// the key an operation looks for out of a node, the operation recovers by
+	// following the right link instead of restarting from the root.
+	//
+	// Consequently, no operation ever holds more than one latch on its way
+	// down. Inserts split full nodes bottom-up, holding a node until its parent
+	// is latched. Deletes remove keys from their leaf without rebalancing, as in
+	// the original design, so nodes emptied by deletes are only refilled by
+	// later inserts. Scans walk the linked leaves, copying one leaf at a time, so
+	// they never block writers for longer than that copy.
+	BLinkTree[K, V any] struct {
+		root atomic.Pointer[blnode[K, V]]
+
+		// grow serializes replacing the root when it splits
+		grow sync.Mutex
+
+		cmp  func(a, b K) int
+		t    int
+		size atomic.Int64
+	}
+
+	// blnode defines a node of a BLinkTree. Leaves, which lie at level 0, store
+	// values[i] under keys[i]. Internal nodes hold len(keys)+1 children, where
+	// children[i] covers the keys k, s.t. keys[i-1] <= k < keys[i]. Every key in
+	// the subtree of a node is less than its high key, unless the node is the
+	// last of its level, while every key under its right sibling is at least the
+	// high key.
+	blnode[K, V any] struct {
+		latch    sync.RWMutex
+		level    int
+		keys     []K
+		values   []V
+		children []*blnode[K, V]
+		right    *blnode[K, V]
+		high     K
+		bounded  bool
+	}
+)
+
+// NewBLinkTree returns a reference to a new, empty BLinkTree with a minimum
+// degree t over keys ordered by cmp. Every node holds at most 2t-1 keys.
+func NewBLinkTree[K, V any](t int, cmp func(a, b K) int) (*BLinkTree[K, V], error) {
+	if t < 2 {
+		return nil, fmt.Errorf("minimum degree must be at least two: %d", t)
+	}
+
+	if cmp == nil {
+		return nil, fmt.Errorf("comparator must not be nil")
+	}
+
+	b := &BLinkTree[K, V]{cmp: cmp, t: t}
+	b.root.Store(&blnode[K, V]{})
+
+	return b, nil
+}
+
+// Len returns the number of keys in the BLinkTree.
+func (b *BLinkTree[K, V]) Len() int {
+	return int(b.size.Load())
+}
+
+// covers returns true if k belongs to n rather than to a right sibling of it.
+func (n *blnode[K, V]) covers(cmp func(a, b K) int, k K) bool {
+	return !n.bounded || cmp(k, n.high) < 0
+}
+
+// child returns the index of the child of n covering k.
+func (n *blnode[K, V]) child(cmp func(a, b K) int, k K) int {
+	return sort.Search(len(n.keys), func(i int) bool {
+		return cmp(n.keys[i], k) > 0
+	})
+}
+
+// search returns the smallest index i, s.t. n.keys[i] >= k, and whether
+// n.keys[i] equals k.
+func (n *blnode[K, V]) search(cmp func(a, b K) int, k K) (int, bool) {
+	i := sort.Search(len(n.keys), func(i int) bool {
+		return cmp(n.keys[i], k) >= 0
+	})
+
+	return i, i < len(n.keys) && cmp(n.keys[i], k) == 0
+}
+
+// rlockCovering read latches n and moves right until reaching the node of its
+// level covering k, which it returns latched.
+func (b *BLinkTree[K, V]) rlockCovering(n *blnode[K, V], k K) *blnode[K, V] {
+	n.latch.RLock()
+	for !n.covers(b.cmp, k) {
+		right := n.right
+		n.latch.RUnlock()
+		right.latch.RLock()
+		n = right
+	}
+
+	return n
+}
+
+// lockCovering exclusively latches n and moves right until reaching the node
+// of its level covering k, which it returns latched. Unlike when reading, the
+// right sibling is latched before n is released, so that no split can slip in
+// between.
+func (b *BLinkTree[K, V]) lockCovering(n *blnode[K, V], k K) *blnode[K, V] {
+	n.latch.Lock()
+	for !n.covers(b.cmp, k) {
+		right := n.right
+		right.latch.Lock()
+		n.latch.Unlock()
+		n = right
+	}
+
+	return n
+}
+
+// descend returns the node at the given level covering k, unlatched, along with
+// the internal nodes passed on the way, from the top down. Every node is
+// released before the next one is latched.
+func (b *BLinkTree[K, V]) descend(k K, level int) (*blnode[K, V], []*blnode[K, V]) {
+	var path []*blnode[K, V]
+
+	n := b.root.Load()
+	for n.level > level {
+		n = b.rlockCovering(n, k)
+		child := n.children[n.child(b.cmp, k)]
+		n.latch.RUnlock()
+
+		path = append(path, n)
+		n = child
+	}
+
+	return n, path
+}
+
+// Get returns the value stored under the given key and whether it exists.
+func (b *BLinkTree[K, V]) Get(key K) (V, bool) {
+	leaf, _ := b.descend(key, 0)
+	leaf = b.rlockCovering(leaf, key)
+	defer leaf.latch.RUnlock()
+
+	var v V
+
+	i, found := leaf.search(b.cmp, key)
+	if found {
+		v = leaf.values[i]
+	}
+
+	return v, found
+}
+
+// Has returns true if a value is stored under the given key.
+func (b *BLinkTree[K, V]) Has(key K) bool {
+	_, ok := b.Get(key)
+	return ok
+}
+
+// Set stores the value under the given key, returning the value it replaced, if
+// any, and whether one was replaced.
+func (b *BLinkTree[K, V]) Set(key K, value V) (old V, replaced bool) {
+	leaf, path := b.descend(key, 0)
+	n := b.lockCovering(leaf, key)
+
+	i, found := n.search(b.cmp, key)
+	if found {
+		old = n.values[i]
+		n.keys[i], n.values[i] = key, value
+		n.latch.Unlock()
+
+		return old, true
+	}
+
+	n.keys = insertAt(n.keys, i, key)
+	n.values = insertAt(n.values, i, value)
+	b.size.Add(1)
+
+	for len(n.keys) > 2*b.t-1 {
+		sep, right := n.split()
+
+		if len(path) == 0 && b.growRoot(n, sep, right) {
+			return old, false
+		}
+
+		// latch the parent before releasing n, so that no operation can reach
+		// the new right sibling other than through the right link of n
+		var parent *blnode[K, V]
+		if len(path) > 0 {
+			parent, path = path[len(path)-1], path[:len(path)-1]
+		} else {
+			// the root has split concurrently, so the parent lies below the new
+			// root
+			parent, _ = b.descend(sep, n.level+1)
+		}
+
+		parent = b.lockCovering(parent, sep)
+		n.latch.Unlock()
+
+		j := parent.child(b.cmp, sep)
+		parent.keys = insertAt(parent.keys, j, sep)
+		parent.children = insertAt(parent.children, j+1, right)
+
+		n = parent
+	}
+
+	n.latch.Unlock()
+	return old, false
+}
+
+// growRoot replaces the root with a new one holding n and its new right sibling
+// if n, which must be latched, is still the root. In that case, n is released
+// and true is returned.
+func (b *BLinkTree[K, V]) growRoot(n *blnode[K, V], sep K, right *blnode[K, V]) bool {
+	b.grow.Lock()
+	defer b.grow.Unlock()
+
+	if b.root.Load() != n {
+		return false
+	}
+
+	b.root.Store(&blnode[K, V]{
+		level:    n.level + 1,
+		keys:     []K{sep},
+		children: []*blnode[K, V]{n, right},
+	})
+
+	n.latch.Unlock()
+	return true
+}
+
+// split moves the upper half of the latched, overfull node n into a new right
+// sibling, which it returns along with the separator between the two.
+func (n *blnode[K, V]) split() (K, *blnode[K, V]) {
+	mid := len(n.keys) / 2
+
+	right := &blnode[K, V]{
+		level:   n.level,
+		right:   n.right,
+		high:    n.high,
+		bounded: n.bounded,
+	}
+
+	var sep K
+	if n.level == 0 {
+		// leaves keep every key, so the separator is copied up
+		right.keys = append(right.keys, n.keys[mid:]...)
+		right.values = append(right.values, n.values[mid:]...)
+		sep = right.keys[0]
+
+		clear(n.values[mid:])
+		n.values = n.values[:mid]
+	} else {
+		// internal nodes move the separator up
+		right.keys = append(right.keys, n.keys[mid+1:]...)
+		right.children = append(right.children, n.children[mid+1:]...)
+		sep = n.keys[mid]
+
+		clear(n.children[mid+1:])
+		n.children = n.children[:mid+1]
+	}
+
+	clear(n.keys[mid:])
+	n.keys = n.keys[:mid]
+
+	n.right, n.high, n.bounded = right, sep, true
+	return sep, right
+}
+
+// Delete removes the given key, returning the value stored under it, if any,
+// and whether it existed.
+func (b *BLinkTree[K, V]) Delete(key K) (V, bool) {
+	leaf, _ := b.descend(key, 0)
+	n := b.lockCovering(leaf, key)
+	defer n.latch.Unlock()
+
+	var v V
+
+	i, found := n.search(b.cmp, key)
+	if !found {
+		return v, false
+	}
+
+	v = n.values[i]
+
+	var (
+		zk K
+		zv V
+	)
+
+	copy(n.keys[i:], n.keys[i+1:])
+	n.keys[len(n.keys)-1] = zk
+	n.keys = n.keys[:len(n.keys)-1]
+
+	copy(n.values[i:], n.values[i+1:])
+	n.values[len(n.values)-1] = zv
+	n.values = n.values[:len(n.values)-1]
+
+	b.size.Add(-1)
+	return v, true
+}
+
+// Ascend calls fn for every key-value pair in ascending key order. No latch is
+// held while fn runs, so fn may modify the BLinkTree. The traversal stops as
+// soon as fn returns false.
+func (b *BLinkTree[K, V]) Ascend(fn func(key K, value V) bool) {
+	b.ascend(nil, nil, fn)
+}
+
+// AscendRange calls fn for every key-value pair whose key k satisfies
+// from <= k < to, in ascending key order, as Ascend does.
+func (b *BLinkTree[K, V]) AscendRange(from, to K, fn func(key K, value V) bool) {
+	b.ascend(&from, &to, fn)
+}
+
+// ascend walks the linked leaves from the one covering lo, or the first one if
+// lo is nil, copying the keys k, s.t. lo <= k < hi, of one leaf at a time and
+// passing them to fn once the leaf is released. A scan is weakly consistent:
+// every key present throughout the scan is visited exactly once, while keys
+// written concurrently may or may not be.
+func (b *BLinkTree[K, V]) ascend(lo, hi *K, fn func(K, V) bool) {
+	var n *blnode[K, V]
+	if lo != nil {
+		n, _ = b.descend(*lo, 0)
+		n = b.rlockCovering(n, *lo)
+	} else {
+		n = b.root.Load()
+		n.latch.RLock()
+
+		for n.level > 0 {
+			child := n.children[0]
+			n.latch.RUnlock()
+			child.latch.RLock()
+			n = child
+		}
+	}
+
+	var (
+		keys   []K
+		values []V
+	)
+
+	for {
+		i := 0
+		if lo != nil {
+			i, _ = n.search(b.cmp, *lo)
+		}
+
+		j := len(n.keys)
+		if hi != nil {
+			j, _ = n.search(b.cmp, *hi)
+		}
+
+		keys = append(keys[:0], n.keys[i:max(i, j)]...)
+		values = append(values[:0], n.values[i:max(i, j)]...)
+
+		right := n.right
+		done := right == nil || j < len(n.keys)
+		n.latch.RUnlock()
+
+		for i := range keys {
+			if !fn(keys[i], values[i]) {
+				return
+			}
+		}
+
+		if done {
+			return
+		}
+
+		n = right
+		n.latch.RLock()
+	}
+}
+
+// insertAt returns s with v inserted at index i.
+func insertAt[T any](s []T, i int, v T) []T {
+	var zero T
+
+	s = append(s, zero)
+	copy(s[i+1:], s[i:])
+	s[i] = v
+
+	return s
+}
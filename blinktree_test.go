@@ -0,0 +1,140 @@
+package btree_test
+
+import (
+	"cmp"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBLinkTree(t *testing.T) {
+	_, err := btree.NewBLinkTree[int, int](1, cmp.Compare[int])
+	require.Error(t, err)
+
+	_, err = btree.NewBLinkTree[int, int](2, nil)
+	require.Error(t, err)
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			b, err := btree.NewBLinkTree[int, int](minDegree, cmp.Compare[int])
+			require.NoError(t, err)
+
+			perm := rng.Perm(1000)
+			for _, k := range perm {
+				_, replaced := b.Set(k, -k)
+				require.False(t, replaced)
+			}
+
+			require.NoError(t, b.Verify())
+			require.Equal(t, 1000, b.Len())
+
+			old, replaced := b.Set(7, 7)
+			require.True(t, replaced)
+			require.Equal(t, -7, old)
+
+			v, ok := b.Get(7)
+			require.True(t, ok)
+			require.Equal(t, 7, v)
+			require.False(t, b.Has(1000))
+
+			var keys []int
+			b.AscendRange(10, 20, func(k, _ int) bool {
+				keys = append(keys, k)
+				return true
+			})
+			require.Equal(t, []int{10, 11, 12, 13, 14, 15, 16, 17, 18, 19}, keys)
+
+			for _, k := range perm[:500] {
+				_, ok := b.Delete(k)
+				require.True(t, ok)
+			}
+
+			_, ok = b.Delete(perm[0])
+			require.False(t, ok)
+			require.NoError(t, b.Verify())
+			require.Equal(t, 500, b.Len())
+
+			// the scan may modify the tree, as no latch is held while fn runs
+			n := 0
+			prev := -1
+			b.Ascend(func(k, _ int) bool {
+				require.Greater(t, k, prev)
+				prev = k
+				n++
+
+				b.Delete(k)
+				return true
+			})
+
+			require.Equal(t, 500, n)
+			require.Zero(t, b.Len())
+			require.NoError(t, b.Verify())
+		})
+	}
+}
+
+func TestBLinkTreeConcurrent(t *testing.T) {
+	b, err := btree.NewBLinkTree[int, int](2, cmp.Compare[int])
+	require.NoError(t, err)
+
+	const (
+		writers = 8
+		n       = 2000
+	)
+
+	// every writer interleaves its keys with those of the others, so that splits
+	// race, while readers scan concurrently
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(2)
+
+		// the shared rng must not be used concurrently
+		perm := rng.Perm(n)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for _, i := range perm {
+				b.Set(i*writers+w, w)
+			}
+
+			for _, i := range perm[:n/2] {
+				if _, ok := b.Delete(i*writers + w); !ok {
+					t.Errorf("missing key %d", i*writers+w)
+				}
+			}
+		}(w)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 10; i++ {
+				prev := -1
+				b.Ascend(func(k, _ int) bool {
+					if k <= prev {
+						t.Errorf("key %d after %d", k, prev)
+					}
+
+					prev = k
+					return true
+				})
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	require.NoError(t, b.Verify())
+	require.Equal(t, writers*n/2, b.Len())
+
+	count := 0
+	b.Ascend(func(k, v int) bool {
+		require.Equal(t, k%writers, v)
+		count++
+		return true
+	})
+	require.Equal(t, writers*n/2, count)
+}
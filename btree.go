@@ -1,10 +1,27 @@
 package btree
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
+// ErrKeyLength is returned when an Entry inserted into a fixed-key BTree yields
+// a key of the wrong length, or raised via panic by writes without an error
+// return.
+var ErrKeyLength = errors.New("invalid key length")
+
+// ErrUnsorted is returned when entries that must be sorted in strictly ascending
+// order, such as those given to BulkLoad, are not.
+var ErrUnsorted = errors.New("entries not sorted")
+
 // BTree implements a thread-safe self-balancing search tree. It maintains sorted
 // data and allows searches, sequential access, insertions, and deletions in
 // logarithmic time. A BTree is specified by having a mimimum degree t, where t
@@ -15,51 +32,312 @@ import (
 // minimum 1 key.
 // - All nodes (including root) may contain at most 2t – 1 keys.
 // - Number of children of a node is equal to the number of keys in it plus 1.
+//
+// A BTree created with WithNoLocking is not thread-safe, leaving
+// synchronization to the caller. A BTree is held in memory; package disk
+// implements one stored in the pages of a file, whose minimum degree follows
+// from the page size.
 type BTree struct {
-	mu sync.RWMutex
+	mu rwLocker
 
 	root      *node
 	minDegree int
 	size      int
 	depth     int
+	cmp       compareFunc
+	validate  func(Entry) error
+	codec     Codec
+	jsonCodec Codec
+	cow       *copyOnWriteContext
+
+	// snapshots is set if writes publish an immutable snapshot of the BTree,
+	// which readers use instead of taking the read lock
+	snapshots bool
+	snapshot  atomic.Pointer[BTree]
+
+	// version counts the writes published as snapshots, of which history, if
+	// not nil, retains the most recent ones
+	version uint64
+	history *history
+
+	// mods counts the writes which may have moved entries between nodes, such
+	// as insertions splitting nodes or deletions, so that an Iterator can detect
+	// that its path no longer reflects the tree
+	mods uint64
+}
+
+// rwLocker defines the locking a BTree performs around every operation.
+type rwLocker interface {
+	sync.Locker
+
+	TryLock() bool
+	RLock()
+	RUnlock()
+}
+
+// nopLocker defines an rwLocker which does not synchronize at all.
+type nopLocker struct{}
+
+func (nopLocker) Lock()         {}
+func (nopLocker) Unlock()       {}
+func (nopLocker) TryLock() bool { return true }
+func (nopLocker) RLock()        {}
+func (nopLocker) RUnlock()      {}
+
+// newLocker returns a new read-write mutex, or a nopLocker if locking is false.
+// If stats is true, the mutex records LockStats.
+func newLocker(locking, stats bool) rwLocker {
+	switch {
+	case !locking:
+		return nopLocker{}
+
+	case stats:
+		return new(statsLocker)
+	}
+
+	return new(sync.RWMutex)
 }
 
-// New returns a reference to a new B-Tree with a minimum degree t.
-func New(t int) (*BTree, error) {
+// newLocker returns a new rwLocker of the same kind as that of the BTree.
+func (bt *BTree) newLocker() rwLocker {
+	_, nop := bt.mu.(nopLocker)
+	_, stats := bt.mu.(*statsLocker)
+	return newLocker(!nop, stats)
+}
+
+// New returns a reference to a new B-Tree with a minimum degree t, configured
+// by the given options.
+func New(t int, opts ...Option) (*BTree, error) {
+	return newBTree(t, compareEntries, opts)
+}
+
+// newBTree returns a reference to a new B-Tree with a minimum degree t that
+// orders entries by cmp, configured by the given options.
+func newBTree(t int, cmp compareFunc, opts []Option) (*BTree, error) {
 	if t < 2 {
 		return nil, fmt.Errorf("minimum degree must be at least two: %d", t)
 	}
 
-	return &BTree{
-		root:      newNode(),
+	o := newOptions(opts)
+	if o.descending {
+		cmp = Reverse(cmp)
+	}
+
+	cow := &copyOnWriteContext{freeList: &freeList{}}
+
+	bt := &BTree{
+		mu:        newLocker(!o.noLocking, o.lockStats),
+		root:      cow.newNode(),
 		minDegree: t,
 		depth:     1,
-	}, nil
+		cmp:       cmp,
+		codec:     o.codec,
+		jsonCodec: o.jsonCodec,
+		cow:       cow,
+		snapshots: o.snapshots || o.versions > 0,
+		history:   newHistory(o.versions),
+	}
+
+	if bt.snapshots {
+		bt.publish()
+	}
+
+	return bt, nil
+}
+
+// NewWithComparator returns a reference to a new B-Tree with a minimum degree t
+// that orders entries by cmp instead of through Entry.Compare, such that 0 is
+// returned if a and b are equal, a negative value if a is less than b and a
+// positive value otherwise. This allows the same Entry type to be indexed by
+// different fields in different trees. The BTree is configured by the given
+// options.
+func NewWithComparator(t int, cmp func(a, b Entry) int, opts ...Option) (*BTree, error) {
+	if cmp == nil {
+		return nil, errors.New("comparator must not be nil")
+	}
+
+	return newBTree(t, cmp, opts)
+}
+
+// NewFixedKey returns a reference to a new B-Tree with a minimum degree t that
+// orders entries by the fixed-length byte keys returned by extract, compared
+// directly via bytes.Compare, instead of through Entry.Compare. Every key must
+// be exactly keyLen bytes long. InsertChecked and InsertCtx return an error
+// wrapping ErrKeyLength for a key of another length, while writes without an
+// error return, such as Insert, Set, InsertBatch or Apply, panic with it, in
+// either case before modifying the BTree. The BTree is configured by the given
+// options.
+func NewFixedKey(t, keyLen int, extract func(Entry) []byte, opts ...Option) (*BTree, error) {
+	if keyLen < 1 {
+		return nil, fmt.Errorf("key length must be positive: %d", keyLen)
+	}
+
+	if extract == nil {
+		return nil, errors.New("key extractor must not be nil")
+	}
+
+	bt, err := newBTree(t, func(a, b Entry) int {
+		return bytes.Compare(extract(a), extract(b))
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	bt.validate = func(e Entry) error {
+		if k := extract(e); len(k) != keyLen {
+			return fmt.Errorf("%w: expected %d bytes, got %d", ErrKeyLength, keyLen, len(k))
+		}
+
+		return nil
+	}
+
+	return bt, nil
+}
+
+// Clone returns an independent copy of the BTree. The copy is lazy: both trees
+// share all existing nodes and each copies a node only once it lies on the path
+// of a write, so cloning itself is constant time. Subsequent writes to either
+// BTree are not visible in the other. Entries themselves are never copied.
+func (bt *BTree) Clone() *BTree {
+	bt.lock()
+	defer bt.unlock()
+
+	// Move the original to a new context as well, so that neither tree owns, and
+	// hence mutates, the nodes they now share.
+	bt.cow = &copyOnWriteContext{freeList: bt.cow.freeList}
+
+	clone := &BTree{
+		mu:        bt.newLocker(),
+		root:      bt.root,
+		minDegree: bt.minDegree,
+		size:      bt.size,
+		depth:     bt.depth,
+		cmp:       bt.cmp,
+		validate:  bt.validate,
+		codec:     bt.codec,
+		jsonCodec: bt.jsonCodec,
+		cow:       &copyOnWriteContext{freeList: &freeList{}},
+		snapshots: bt.snapshots,
+		history:   bt.history.newLike(),
+	}
+
+	if clone.snapshots {
+		clone.publish()
+	}
+
+	return clone
 }
 
 // Size returns the total number of nodes in the BTree.
 func (bt *BTree) Size() int {
-	bt.mu.RLock()
+	bt = bt.rlock()
 	defer bt.mu.RUnlock()
 	return bt.size
 }
 
 // Depth returns the depth or height of the BTree.
 func (bt *BTree) Depth() int {
-	bt.mu.RLock()
+	bt = bt.rlock()
 	defer bt.mu.RUnlock()
 	return bt.depth
 }
 
+// Min returns the smallest Entry in the BTree or nil if the BTree is empty.
+func (bt *BTree) Min() Entry {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+	return bt.root.min()
+}
+
+// Max returns the largest Entry in the BTree or nil if the BTree is empty.
+func (bt *BTree) Max() Entry {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+	return bt.root.max()
+}
+
 // Search performs a lookup of the given Entry in the BTree. If the Entry exists,
 // a non-nil Entry will be returned.
 func (bt *BTree) Search(e Entry) Entry {
-	bt.mu.RLock()
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+	return bt.search(e)
+}
+
+// Has returns true if an Entry equal to the given Entry exists in the BTree.
+// A nil Entry is never contained in the BTree.
+func (bt *BTree) Has(e Entry) bool {
+	if e == nil {
+		return false
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+	return bt.search(e) != nil
+}
+
+// SearchKey returns the Entry equal to the provided Key or nil if no such Entry
+// exists or the Key is nil. Unlike Search, no Entry needs to be constructed to
+// perform the lookup.
+func (bt *BTree) SearchKey(k Key) Entry {
+	if k == nil {
+		return nil
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+	return bt.root.find(k.CompareEntry)
+}
+
+// SearchFunc returns the smallest Entry for which cmp returns 0, or nil if there
+// is none. The function cmp reports how the sought position compares to a given
+// Entry, returning a negative value if it lies before the Entry and a positive
+// value if it lies after it, and must be monotone in the order of the BTree.
+// This allows searching by a derived attribute, such as a prefix of a composite
+// key, without constructing a sentinel Entry.
+func (bt *BTree) SearchFunc(cmp func(Entry) int) Entry {
+	bt = bt.rlock()
 	defer bt.mu.RUnlock()
 
+	if e := bt.root.seek(cmp); e != nil && cmp(e) == 0 {
+		return e
+	}
+
+	return nil
+}
+
+// MultiGet looks up many entries at once under a single acquisition of the read
+// lock. The requested keys are sorted and the tree is descended only once,
+// distributing the keys among the subtrees on the way, so that no node is
+// visited twice. The result holds, for every provided key, the Entry equal to it
+// at the same index, or nil if no such Entry exists or the key is nil.
+func (bt *BTree) MultiGet(es []Entry) []Entry {
+	out := make([]Entry, len(es))
+
+	order := make([]int, 0, len(es))
+	for i, e := range es {
+		if e != nil {
+			order = append(order, i)
+		}
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		return bt.cmp(es[order[i]], es[order[j]]) < 0
+	})
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	bt.root.getAll(bt.cmp, es, order, out)
+	return out
+}
+
+// search returns the entry equal to e or nil if it does not exist. The caller
+// must hold the read lock.
+func (bt *BTree) search(e Entry) Entry {
 	curr := bt.root
 	for curr != nil {
-		found, i := curr.get(e)
+		found, i := curr.get(bt.cmp, e)
 		if found != nil && i >= 0 {
 			return found
 		}
@@ -74,89 +352,1142 @@ func (bt *BTree) Search(e Entry) Entry {
 	return nil
 }
 
+// Rank returns the number of entries in the BTree strictly less than the
+// provided Entry, whether or not the provided Entry exists. This is the index
+// the Entry has, or would have, in the sorted sequence of entries.
+func (bt *BTree) Rank(e Entry) int {
+	if e == nil {
+		return 0
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+	return bt.root.rank(bt.cmp, e)
+}
+
+// At returns the Entry at index i in the sorted sequence of entries, i.e. the
+// i-th smallest Entry counting from zero, in logarithmic time. It returns nil if
+// i is out of bounds.
+func (bt *BTree) At(i int) Entry {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	if i < 0 || i >= bt.size {
+		return nil
+	}
+
+	return bt.root.at(i)
+}
+
+// KthLargest returns the k-th largest Entry in the BTree, counting from one, so
+// that KthLargest(1) returns the same Entry as Max. It runs in logarithmic time
+// and returns nil if k is out of bounds.
+func (bt *BTree) KthLargest(k int) Entry {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	if k < 1 || k > bt.size {
+		return nil
+	}
+
+	return bt.root.at(bt.size - k)
+}
+
+// Percentile returns the Entry at the p-th percentile of the BTree, where p is
+// within [0, 100], using the nearest-rank method: the smallest Entry such that
+// at least p percent of all entries are less than or equal to it. It returns
+// nil if the BTree is empty or p is out of bounds.
+func (bt *BTree) Percentile(p float64) Entry {
+	if math.IsNaN(p) || p < 0 || p > 100 {
+		return nil
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	if bt.size == 0 {
+		return nil
+	}
+
+	i := int(math.Ceil(p/100*float64(bt.size))) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	return bt.root.at(i)
+}
+
+// Median returns the Entry at the 50th percentile of the BTree, as defined by
+// Percentile, or nil if the BTree is empty.
+func (bt *BTree) Median() Entry {
+	return bt.Percentile(50)
+}
+
+// Sample returns n distinct entries chosen uniformly at random from the BTree,
+// in ascending order. Each Entry is located by descending the subtree counts, so
+// the cost is O(n log n) regardless of the size of the BTree. If n exceeds the
+// size of the BTree, every Entry is returned. The provided source of randomness
+// is used to choose the entries, or the global source if rng is nil.
+func (bt *BTree) Sample(n int, rng *rand.Rand) []Entry {
+	intn := rand.Intn
+	if rng != nil {
+		intn = rng.Intn
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	if n > bt.size {
+		n = bt.size
+	}
+
+	if n <= 0 {
+		return nil
+	}
+
+	// Floyd's algorithm chooses n distinct indices in [0, size) using exactly n
+	// random numbers.
+	chosen := make(map[int]struct{}, n)
+	indices := make([]int, 0, n)
+
+	for j := bt.size - n; j < bt.size; j++ {
+		i := intn(j + 1)
+		if _, ok := chosen[i]; ok {
+			i = j
+		}
+
+		chosen[i] = struct{}{}
+		indices = append(indices, i)
+	}
+
+	sort.Ints(indices)
+
+	sample := make([]Entry, n)
+	for k, i := range indices {
+		sample[k] = bt.root.at(i)
+	}
+
+	return sample
+}
+
+// CountRange returns the number of entries e in the BTree, s.t. from <= e < to,
+// in logarithmic time without visiting the entries themselves. A nil from or to
+// leaves the respective end of the range unbounded.
+func (bt *BTree) CountRange(from, to Entry) int {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	return bt.countRange(from, to)
+}
+
+// countRange returns the number of entries e in the BTree, s.t. from <= e < to.
+// The caller must hold the read lock.
+func (bt *BTree) countRange(from, to Entry) int {
+	lo, hi := 0, bt.size
+	if from != nil {
+		lo = bt.root.rank(bt.cmp, from)
+	}
+
+	if to != nil {
+		hi = bt.root.rank(bt.cmp, to)
+	}
+
+	if hi < lo {
+		return 0
+	}
+
+	return hi - lo
+}
+
+// Floor returns the largest Entry in the BTree that is less than or equal to
+// the provided Entry or nil if no such Entry exists.
+func (bt *BTree) Floor(e Entry) Entry {
+	if e == nil {
+		return nil
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+	return bt.root.floor(bt.cmp, e, true)
+}
+
+// Ceil returns the smallest Entry in the BTree that is greater than or equal to
+// the provided Entry or nil if no such Entry exists.
+func (bt *BTree) Ceil(e Entry) Entry {
+	if e == nil {
+		return nil
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+	return bt.root.ceil(bt.cmp, e, true)
+}
+
+// Prev returns the largest Entry in the BTree that is strictly less than the
+// provided Entry, whether or not the provided Entry exists, or nil if no such
+// Entry exists.
+func (bt *BTree) Prev(e Entry) Entry {
+	if e == nil {
+		return nil
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+	return bt.root.floor(bt.cmp, e, false)
+}
+
+// Next returns the smallest Entry in the BTree that is strictly greater than the
+// provided Entry, whether or not the provided Entry exists, or nil if no such
+// Entry exists.
+func (bt *BTree) Next(e Entry) Entry {
+	if e == nil {
+		return nil
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+	return bt.root.ceil(bt.cmp, e, false)
+}
+
+// Adjacent returns the entries immediately surrounding the provided Entry, i.e.
+// the largest Entry strictly less than it and the smallest Entry strictly
+// greater than it, whether or not the provided Entry exists. Both are found in a
+// single descent. Either is nil if no such Entry exists, and both are nil if
+// the provided Entry is nil.
+func (bt *BTree) Adjacent(e Entry) (prev, next Entry) {
+	if e == nil {
+		return nil, nil
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+	return bt.root.adjacent(bt.cmp, e)
+}
+
+// Nearest returns the Entry in the BTree closest to the provided Entry, i.e.
+// whichever of its floor and ceiling is at the smaller distance from it as
+// measured by distance, preferring the floor on ties. If distance is nil, the
+// provided Entry itself is returned if it exists, and otherwise the floor is
+// preferred whenever both exist. It returns nil if the BTree is empty or the
+// provided Entry is nil.
+func (bt *BTree) Nearest(e Entry, distance func(a, b Entry) float64) Entry {
+	if e == nil {
+		return nil
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	floor, ceil := bt.root.floor(bt.cmp, e, true), bt.root.ceil(bt.cmp, e, true)
+
+	switch {
+	case floor == nil:
+		return ceil
+
+	case ceil == nil:
+		return floor
+
+	case distance == nil:
+		return floor
+
+	case distance(e, ceil) < distance(e, floor):
+		return ceil
+
+	default:
+		return floor
+	}
+}
+
 // Insert inserts an Entry into the BTree. If the provided Entry is nil, then
 // the method performs a no-op. If the Entry already exists, it will be replaced
 // with the provided Entry. Otherwise, the new Entry will be inserted.
 func (bt *BTree) Insert(e Entry) {
+	_, _ = bt.Set(e)
+}
+
+// Set inserts an Entry into the BTree just as Insert does, but additionally
+// returns the Entry it replaced, if any, along with a boolean indicating
+// whether a replacement took place. If the provided Entry is nil, then the
+// method performs a no-op.
+func (bt *BTree) Set(e Entry) (old Entry, replaced bool) {
+	if e == nil {
+		return nil, false
+	}
+
+	bt.mustValidate(e)
+
+	bt.lock()
+	defer bt.unlock()
+
+	old = bt.insert(e, nil)
+	if old != nil && sameEntry(old, e) {
+		bt.unchanged()
+	}
+
+	return old, old != nil
+}
+
+// InsertChecked inserts an Entry into the BTree just as Insert does, but returns
+// the error of the BTree's validator, such as one wrapping ErrKeyLength for a
+// BTree created by NewFixedKey, rather than panicking, without having modified
+// the BTree. If the provided Entry is nil, then the method performs a no-op.
+func (bt *BTree) InsertChecked(e Entry) error {
+	if e == nil {
+		return nil
+	}
+
+	if err := bt.validateEntry(e); err != nil {
+		return err
+	}
+
+	bt.lock()
+	defer bt.unlock()
+
+	if old := bt.insert(e, nil); old != nil && sameEntry(old, e) {
+		bt.unchanged()
+	}
+
+	return nil
+}
+
+// InsertCtx inserts an Entry into the BTree just as InsertChecked does, but
+// gives up waiting for the write lock once ctx is done, returning the error of
+// ctx without having modified the BTree.
+func (bt *BTree) InsertCtx(ctx context.Context, e Entry) error {
+	if e == nil {
+		return nil
+	}
+
+	if err := bt.validateEntry(e); err != nil {
+		return err
+	}
+
+	if err := bt.lockCtx(ctx); err != nil {
+		return err
+	}
+	defer bt.unlock()
+
+	if old := bt.insert(e, nil); old != nil && sameEntry(old, e) {
+		bt.unchanged()
+	}
+
+	return nil
+}
+
+// InsertIfAbsent inserts the Entry into the BTree only if no equal Entry exists,
+// leaving any existing Entry untouched. It returns true if the Entry was
+// inserted. If the provided Entry is nil, then the method performs a no-op.
+func (bt *BTree) InsertIfAbsent(e Entry) bool {
+	if e == nil {
+		return false
+	}
+
+	bt.mustValidate(e)
+
+	bt.lock()
+	defer bt.unlock()
+
+	if bt.search(e) != nil {
+		return false
+	}
+
+	bt.insert(e, nil)
+	return true
+}
+
+// GetOrInsert atomically returns the Entry equal to the provided Entry if one
+// exists or otherwise inserts the provided Entry and returns it. The boolean
+// reports whether the Entry was inserted. If the provided Entry is nil, then the
+// method performs a no-op.
+func (bt *BTree) GetOrInsert(e Entry) (Entry, bool) {
+	if e == nil {
+		return nil, false
+	}
+
+	bt.mustValidate(e)
+
+	bt.lock()
+	defer bt.unlock()
+
+	if found := bt.search(e); found != nil {
+		return found, false
+	}
+
+	bt.insert(e, nil)
+	return e, true
+}
+
+// Upsert inserts the Entry into the BTree if no equal Entry exists. Otherwise,
+// the existing Entry is replaced by the result of merge, which is given the
+// existing and the provided Entry and must return a non-nil Entry equal to
+// both. This happens in a single traversal under the write lock. If the
+// provided Entry is nil, then the method performs a no-op.
+func (bt *BTree) Upsert(e Entry, merge func(old, new Entry) Entry) {
 	if e == nil {
 		return
 	}
 
-	bt.mu.Lock()
-	defer bt.mu.Unlock()
+	bt.mustValidate(e)
 
-	curr := bt.root
+	bt.lock()
+	defer bt.unlock()
+
+	if old := bt.insert(e, merge); old != nil && bt.snapshots && sameEntry(old, bt.search(e)) {
+		bt.unchanged()
+	}
+}
+
+// Modify replaces the Entry equal to the provided key by the result of fn, which
+// is given the existing Entry and must return a non-nil Entry equal to it. The
+// lookup and the update happen in a single descent under the write lock, so no
+// other write can interleave. It returns false, without calling fn, if no such
+// Entry exists or the provided key is nil.
+func (bt *BTree) Modify(key Entry, fn func(old Entry) Entry) bool {
+	if key == nil {
+		return false
+	}
+
+	bt.lock()
+	defer bt.unlock()
+
+	n, i := bt.seekMutable(key)
+	if n == nil {
+		bt.unchanged()
+		return false
+	}
+
+	old := n.entries[i]
+	if n.entries[i] = fn(old); sameEntry(old, n.entries[i]) {
+		bt.unchanged()
+	}
+
+	return true
+}
+
+// CompareAndSwap atomically replaces the stored Entry equal to old by new, but
+// only if the stored Entry is also deeply equal to old. The two entries must be
+// equal in the ordering of the BTree. It returns true if the swap took place.
+// If either Entry is nil or the two are not equal, it returns false.
+func (bt *BTree) CompareAndSwap(old, new Entry) bool {
+	if old == nil || new == nil {
+		return false
+	}
+
+	bt.lock()
+	defer bt.unlock()
+
+	if bt.cmp(old, new) != 0 || !reflect.DeepEqual(bt.search(old), old) {
+		return false
+	}
+
+	n, i := bt.seekMutable(old)
+	if n.entries[i] = new; sameEntry(old, new) {
+		bt.unchanged()
+	}
+
+	return true
+}
+
+// seekMutable returns the node holding the Entry equal to the given Entry and
+// its index, making every node on the path to it mutable, or a nil node if no
+// such Entry exists. The caller must hold the write lock.
+func (bt *BTree) seekMutable(e Entry) (*node, int) {
+	curr := bt.mutableRoot()
+	for {
+		found, i := curr.get(bt.cmp, e)
+		if found != nil && i >= 0 {
+			return curr, i
+		}
+
+		if curr.leaf() {
+			return nil, 0
+		}
+
+		curr = curr.mutableChild(i)
+	}
+}
+
+// InsertBatch inserts all of the provided entries into the BTree under a single
+// acquisition of the write lock, so that concurrent readers observe either none
+// or all of them. The batch is sorted first, after which the tree is descended
+// once per run of entries belonging to the same leaf. As with Insert, an
+// existing equal Entry is replaced, and if the batch holds several equal
+// entries, the last one wins. Nil entries are skipped. The provided slice is not
+// modified.
+func (bt *BTree) InsertBatch(es []Entry) {
+	batch := make([]Entry, 0, len(es))
+	for _, e := range es {
+		if e != nil {
+			bt.mustValidate(e)
+			batch = append(batch, e)
+		}
+	}
+
+	if len(batch) == 0 {
+		return
+	}
+
+	sort.SliceStable(batch, func(i, j int) bool {
+		return bt.cmp(batch[i], batch[j]) < 0
+	})
+
+	bt.lock()
+	defer bt.unlock()
+
+	for len(batch) > 0 {
+		batch = batch[bt.insertRun(batch):]
+	}
+}
+
+// insertRun inserts the first entry of the given sorted batch, and then as many
+// of the following entries as fit into the same leaf without descending the
+// tree again. It returns the number of entries consumed. The caller must hold
+// the write lock.
+func (bt *BTree) insertRun(batch []Entry) int {
+	n, i, found, path, hi := bt.seekInsert(batch[0])
+	if found != nil {
+		n.entries[i] = batch[0]
+		return 1
+	}
+
+	if bt.insertLeaf(n, i, batch[0], path) {
+		return 1
+	}
+
+	k := 1
+	for ; k < len(batch); k++ {
+		e := batch[k]
+		if hi != nil && bt.cmp(e, hi) >= 0 {
+			break
+		}
+
+		found, i := n.get(bt.cmp, e)
+		if found != nil {
+			n.entries[i] = e
+			continue
+		}
+
+		if bt.nodeFull(n) {
+			break
+		}
+
+		if bt.insertLeaf(n, i, e, path) {
+			k++
+			break
+		}
+	}
+
+	return k
+}
+
+// insert inserts the given entry, returning the replaced entry or nil if the
+// entry is new. An existing entry is replaced by the result of merge or by the
+// given entry if merge is nil. The caller must hold the write lock.
+func (bt *BTree) insert(e Entry, merge func(old, new Entry) Entry) Entry {
+	n, i, found, path, _ := bt.seekInsert(e)
+	if found != nil {
+		if merge == nil {
+			n.entries[i] = e
+		} else {
+			n.entries[i] = merge(found, e)
+		}
+
+		return found
+	}
+
+	bt.insertLeaf(n, i, e, path)
+	return nil
+}
+
+// seekInsert traverses the tree towards the given entry, splitting every full
+// node on the way in advance. If an equal entry exists, it is returned along
+// with the node holding it and its index. Otherwise, it returns the leaf the
+// entry belongs in, which has space for at least one extra entry, the index to
+// insert at, the internal nodes on the path to the leaf and the separator
+// bounding the leaf from above, which is nil if the leaf is the rightmost one.
+// The caller must hold the write lock.
+func (bt *BTree) seekInsert(e Entry) (n *node, i int, found Entry, path nodes, hi Entry) {
+	curr := bt.mutableRoot()
+
+	// Insertions split a leaf root as soon as it fills up, but one built or cut
+	// directly, as by BulkLoad or Truncate, may rest at capacity.
+	if curr.leaf() && bt.nodeFull(curr) {
+		bt.splitRoot()
+		curr = bt.root
+	}
+
+	// Record the path taken, so that the counts of all nodes on it can be
+	// incremented once we know the entry is new.
+	path = make(nodes, 0, bt.depth+1)
 
 	// Traverse the tree until we've found the given entry or until we've reached
 	// the leaf. When the current node is a leaf, we must have space for one extra
 	// entry as we have been splitting all nodes in advance.
 	for !curr.leaf() {
-		found, i := curr.get(e)
+		path = append(path, curr)
+
+		found, i := curr.get(bt.cmp, e)
 		if found != nil && i >= 0 {
 			// the entry already exists so we simply replace it
-			curr.entries[i] = e
-			return
+			return curr, i, found, nil, nil
 		}
 
 		if curr == bt.root && bt.nodeFull(curr) {
 			left, right, midEntry := bt.splitRoot()
+			path[len(path)-1] = bt.root
 
-			if e.Compare(midEntry) < 0 {
-				curr = left
-			} else {
+			switch c := bt.cmp(e, midEntry); {
+			case c < 0:
+				curr, hi = left, midEntry
+
+			case c > 0:
 				curr = right
+
+			default:
+				// the entry is the mid entry which now solely lives in the new root
+				return bt.root, 0, midEntry, nil, nil
 			}
 		} else {
 			// The entry does not exist in the current node and i denotes the child index
 			// which we should search next.
-			next := curr.children[i]
+			next := curr.mutableChild(i)
 
 			if bt.nodeFull(next) {
-				// Split next into left and right nodes. Change curr to point to either
-				// left or right:
+				// Split next into left and right nodes, where next itself becomes the
+				// left node. Change curr to point to either left or right:
 				//
 				// If the entry is smaller than the mid entry in next, then set curr to
 				// the left node. Else, set it to the right node.
 				//
 				// Finally, when we split next, we move the mid entry from next to its
 				// parent curr.
-				left, right, midEntry := next.split()
+				midEntry, right := next.split()
 
-				curr.insert(midEntry)
-				curr.replaceChildAt(i, left)
+				curr.insertAt(i, midEntry)
 				curr.insertChildAt(i+1, right)
-				next.clear()
+				bt.mods++
 
-				if e.Compare(midEntry) < 0 {
-					curr = left
-				} else {
-					curr = right
+				switch c := bt.cmp(e, midEntry); {
+				case c > 0:
+					i++
+					next = right
+
+				case c == 0:
+					// the entry is the mid entry which was just moved into curr
+					return curr, i, midEntry, nil, nil
 				}
-			} else {
-				curr = next
 			}
+
+			if i < curr.numEntries() {
+				hi = curr.entries[i]
+			}
+
+			curr = next
 		}
 	}
 
-	curr.insert(e)
+	found, i = curr.get(bt.cmp, e)
+	if found != nil {
+		return curr, i, found, nil, nil
+	}
+
+	return curr, i, nil, path, hi
+}
+
+// insertLeaf inserts the given new entry at index i of the leaf n, which must
+// have space for it, and increments the counts of the leaf and of every node on
+// the path to it. It returns true if the root had to be split as a result. The
+// caller must hold the write lock.
+func (bt *BTree) insertLeaf(n *node, i int, e Entry, path nodes) bool {
+	n.insertAt(i, e)
 	bt.size++
+	bt.mods++
+
+	for _, p := range path {
+		p.count++
+	}
 
-	if curr == bt.root && bt.nodeFull(curr) {
+	n.count++
+
+	if n == bt.root && bt.nodeFull(n) {
 		_, _, _ = bt.splitRoot()
+		return true
+	}
+
+	return false
+}
+
+// Delete removes the Entry equal to the provided Entry from the BTree and
+// returns the removed Entry. If no such Entry exists or the provided Entry is
+// nil, nil is returned.
+func (bt *BTree) Delete(e Entry) Entry {
+	if e == nil {
+		return nil
+	}
+
+	bt.lock()
+	defer bt.unlock()
+
+	return bt.deleteOne(e)
+}
+
+// DeleteCtx removes the Entry equal to the provided Entry from the BTree just
+// as Delete does, but gives up waiting for the write lock once ctx is done,
+// returning the error of ctx without having modified the BTree.
+func (bt *BTree) DeleteCtx(ctx context.Context, e Entry) (Entry, error) {
+	if e == nil {
+		return nil, nil
+	}
+
+	if err := bt.lockCtx(ctx); err != nil {
+		return nil, err
+	}
+	defer bt.unlock()
+
+	return bt.deleteOne(e), nil
+}
+
+// DeleteIf removes the Entry equal to the provided Entry only if the stored Entry
+// satisfies pred, evaluated atomically under the write lock. It returns true if
+// the Entry was removed. If the provided Entry is nil, then the method performs
+// a no-op.
+func (bt *BTree) DeleteIf(e Entry, pred func(Entry) bool) bool {
+	if e == nil {
+		return false
+	}
+
+	bt.lock()
+	defer bt.unlock()
+
+	found := bt.search(e)
+	if found == nil || !pred(found) {
+		return false
+	}
+
+	bt.delete(e)
+	return true
+}
+
+// DeleteRange removes every Entry e from the BTree, s.t. from <= e < to, and
+// returns the number of entries removed. A nil from or to leaves the respective
+// end of the range unbounded. Rather than deleting entries one by one, the tree
+// is cut at both bounds and the outer parts are joined back together, so the
+// cost is dominated by the depth of the tree rather than the size of the range.
+func (bt *BTree) DeleteRange(from, to Entry) int {
+	bt.lock()
+	defer bt.unlock()
+
+	if from != nil && to != nil && bt.cmp(from, to) >= 0 {
+		return 0
+	}
+
+	l, hl := bt.cow.newNode(), 1
+	mid, hmid := bt.root, bt.depth
+	if from != nil {
+		l, hl, mid, hmid = cut(bt.cow, bt.minDegree, bt.cmp, mid, hmid, from)
+	}
+
+	r, hr := bt.cow.newNode(), 1
+	if to != nil {
+		mid, hmid, r, hr = cut(bt.cow, bt.minDegree, bt.cmp, mid, hmid, to)
+	}
+
+	removed := mid.count
+	bt.recycle(mid)
+
+	bt.root, bt.depth = join2(bt.cow, bt.minDegree, l, hl, r, hr)
+	bt.size -= removed
+	bt.mods++
+
+	return removed
+}
+
+// Truncate keeps only the n smallest entries of the BTree and discards the
+// rest. Rather than deleting the discarded entries one by one, the tree is cut
+// along the path to the n-th Entry in O(t log n) time, and the discarded nodes
+// are recycled. If n is at least the size of the BTree, it is left untouched.
+func (bt *BTree) Truncate(n int) {
+	if n < 0 {
+		n = 0
+	}
+
+	bt.lock()
+	defer bt.unlock()
+
+	if n >= bt.size {
+		return
+	}
+
+	l, hl, r, _ := cut(bt.cow, bt.minDegree, bt.cmp, bt.root, bt.depth, bt.root.at(n))
+	bt.recycle(r)
+
+	bt.root, bt.depth = l, hl
+	bt.size = n
+	bt.mods++
+}
+
+// DeleteMin removes and returns the smallest Entry in the BTree or nil if the
+// BTree is empty.
+func (bt *BTree) DeleteMin() Entry {
+	bt.lock()
+	defer bt.unlock()
+
+	if bt.size == 0 {
+		return nil
+	}
+
+	return bt.removed(bt.mutableRoot().removeMin(bt.minDegree))
+}
+
+// DeleteMax removes and returns the largest Entry in the BTree or nil if the
+// BTree is empty.
+func (bt *BTree) DeleteMax() Entry {
+	bt.lock()
+	defer bt.unlock()
+
+	if bt.size == 0 {
+		return nil
+	}
+
+	return bt.removed(bt.mutableRoot().removeMax(bt.minDegree))
+}
+
+// PopMin atomically removes and returns the smallest Entry in the BTree. The
+// boolean is false if the BTree was empty. It is equivalent to DeleteMin, but
+// suits priority-queue style consumers that loop until the BTree is drained.
+func (bt *BTree) PopMin() (Entry, bool) {
+	e := bt.DeleteMin()
+	return e, e != nil
+}
+
+// PopMax atomically removes and returns the largest Entry in the BTree. The
+// boolean is false if the BTree was empty. It is equivalent to DeleteMax.
+func (bt *BTree) PopMax() (Entry, bool) {
+	e := bt.DeleteMax()
+	return e, e != nil
+}
+
+// Clear removes all entries from the BTree. If recycle is false, this is done in
+// constant time and the existing nodes are left for the garbage collector.
+// Otherwise, the existing nodes are walked and retained in an internal free
+// list, up to a fixed limit, so that subsequent insertions can reuse them.
+func (bt *BTree) Clear(recycle bool) {
+	bt.lock()
+	defer bt.unlock()
+
+	if recycle {
+		bt.recycle(bt.root)
+	}
+
+	bt.root = bt.cow.newNode()
+	bt.size = 0
+	bt.depth = 1
+	bt.mods++
+}
+
+// Ascend calls fn for every Entry in the BTree in ascending order, under the
+// read lock. The traversal stops as soon as fn returns false.
+func (bt *BTree) Ascend(fn func(Entry) bool) {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	bt.root.ascendRange(bt.cmp, nil, nil, true, fn)
+}
+
+// Descend calls fn for every Entry in the BTree in descending order, under the
+// read lock. The traversal stops as soon as fn returns false.
+func (bt *BTree) Descend(fn func(Entry) bool) {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	bt.root.descendRange(bt.cmp, nil, nil, true, fn)
+}
+
+// AscendRange calls fn for every Entry e in the BTree, s.t. from <= e < to, in
+// ascending order, under the read lock. A nil from or to leaves the respective
+// end of the range unbounded. Subtrees outside of the range are never visited.
+// The traversal stops as soon as fn returns false.
+func (bt *BTree) AscendRange(from, to Entry, fn func(Entry) bool) {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	bt.root.ascendRange(bt.cmp, from, to, false, fn)
+}
+
+// DescendRange calls fn for every Entry e in the BTree, s.t. from <= e < to, in
+// descending order, under the read lock. A nil from or to leaves the respective
+// end of the range unbounded. Subtrees outside of the range are never visited.
+// The traversal stops as soon as fn returns false.
+func (bt *BTree) DescendRange(from, to Entry, fn func(Entry) bool) {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	bt.root.descendRange(bt.cmp, from, to, false, fn)
+}
+
+// AscendGreaterOrEqual calls fn for every Entry e in the BTree, s.t.
+// e >= pivot, in ascending order, under the read lock. The traversal stops as
+// soon as fn returns false.
+func (bt *BTree) AscendGreaterOrEqual(pivot Entry, fn func(Entry) bool) {
+	if pivot == nil {
+		return
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	bt.root.ascendRange(bt.cmp, pivot, nil, true, fn)
+}
+
+// DescendLessOrEqual calls fn for every Entry e in the BTree, s.t. e <= pivot,
+// in descending order, under the read lock. The traversal stops as soon as fn
+// returns false.
+func (bt *BTree) DescendLessOrEqual(pivot Entry, fn func(Entry) bool) {
+	if pivot == nil {
+		return
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	bt.root.descendRange(bt.cmp, nil, pivot, true, fn)
+}
+
+// RangeReverse calls fn for every entry e in the BTree, s.t. lo <= e <= hi, in
+// descending order. A nil lo or hi leaves the respective end of the range
+// unbounded. The traversal stops as soon as fn returns false.
+func (bt *BTree) RangeReverse(lo, hi Entry, fn func(Entry) bool) {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	bt.root.descendRange(bt.cmp, lo, hi, true, fn)
+}
+
+// ValuesInRange returns the result of applying value to every entry e in the
+// BTree, s.t. lo <= e <= hi, in ascending order. A nil lo or hi leaves the
+// respective end of the range unbounded.
+func (bt *BTree) ValuesInRange(lo, hi Entry, value func(Entry) any) []any {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	var values []any
+	bt.root.ascendRange(bt.cmp, lo, hi, true, func(e Entry) bool {
+		values = append(values, value(e))
+		return true
+	})
+
+	return values
+}
+
+// Entries returns a sorted slice of every Entry e in the BTree, s.t.
+// from <= e < to. A nil from or to leaves the respective end of the range
+// unbounded. The slice is sized exactly from the subtree counts, so it is
+// allocated once. It returns nil if the range is empty.
+func (bt *BTree) Entries(from, to Entry) []Entry {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	n := bt.countRange(from, to)
+	if n == 0 {
+		return nil
+	}
+
+	return bt.appendRange(make([]Entry, 0, n), from, to)
+}
+
+// AppendTo appends every Entry e in the BTree, s.t. from <= e < to, to dst in
+// ascending order and returns the extended slice. A nil from or to leaves the
+// respective end of the range unbounded. If dst has sufficient spare capacity
+// no allocation takes place, so hot paths may reuse a buffer across calls by
+// passing dst[:0]. Otherwise, dst is grown exactly once.
+func (bt *BTree) AppendTo(dst []Entry, from, to Entry) []Entry {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	n := bt.countRange(from, to)
+	if n == 0 {
+		return dst
+	}
+
+	if cap(dst)-len(dst) < n {
+		grown := make([]Entry, len(dst), len(dst)+n)
+		copy(grown, dst)
+		dst = grown
+	}
+
+	return bt.appendRange(dst, from, to)
+}
+
+// appendRange appends every Entry e in the BTree, s.t. from <= e < to, to dst.
+// The caller must hold the read lock.
+func (bt *BTree) appendRange(dst []Entry, from, to Entry) []Entry {
+	bt.root.ascendRange(bt.cmp, from, to, false, func(e Entry) bool {
+		dst = append(dst, e)
+		return true
+	})
+
+	return dst
+}
+
+// AscendPrefix calls fn for every Entry in the BTree whose key starts with the
+// provided prefix, in ascending order, under the read lock. Every Entry must
+// implement PrefixEntry, whose ComparePrefix drives both the descent to the
+// first match and the end of the scan, so no upper bound needs to be
+// constructed. The traversal stops as soon as fn returns false.
+func (bt *BTree) AscendPrefix(prefix []byte, fn func(Entry) bool) {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	first := bt.root.seek(func(e Entry) int {
+		return -e.(PrefixEntry).ComparePrefix(prefix)
+	})
+
+	if first == nil {
+		return
+	}
+
+	bt.root.ascendRange(bt.cmp, first, nil, false, func(e Entry) bool {
+		return e.(PrefixEntry).ComparePrefix(prefix) == 0 && fn(e)
+	})
+}
+
+// IterateMutable visits every entry in the BTree in ascending order under the
+// write lock, deleting any entry for which fn returns true. Deleting the entry
+// currently being visited is safe, as the traversal position is re-established
+// from the last visited entry after every step. The callback must not call any
+// other method of the BTree.
+func (bt *BTree) IterateMutable(fn func(e Entry) (deleteIt bool)) {
+	bt.lock()
+	defer bt.unlock()
+
+	bt.iterateMutable(fn)
+}
+
+// DeleteAll removes every Entry satisfying pred from the BTree in a single
+// ascending pass under the write lock, rebalancing as it goes, and returns the
+// number of entries removed. The predicate must not call any other method of
+// the BTree.
+func (bt *BTree) DeleteAll(pred func(Entry) bool) int {
+	bt.lock()
+	defer bt.unlock()
+
+	return bt.iterateMutable(pred)
+}
+
+// iterateMutable implements IterateMutable and returns the number of entries
+// deleted. The caller must hold the write lock.
+func (bt *BTree) iterateMutable(fn func(e Entry) (deleteIt bool)) int {
+	var removed int
+
+	for e := bt.root.min(); e != nil; e = bt.root.ceil(bt.cmp, e, false) {
+		if fn(e) {
+			bt.delete(e)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// delete removes the entry equal to e from the BTree, returning the removed
+// entry or nil if it does not exist. The caller must hold the write lock.
+func (bt *BTree) delete(e Entry) Entry {
+	return bt.removed(bt.mutableRoot().remove(bt.cmp, e, bt.minDegree))
+}
+
+// deleteOne deletes e as the only change of the current write, which is left
+// unpublished if there is nothing to delete. The caller must hold the write
+// lock.
+func (bt *BTree) deleteOne(e Entry) Entry {
+	removed := bt.delete(e)
+	if removed == nil {
+		bt.unchanged()
+	}
+
+	return removed
+}
+
+// removed performs the bookkeeping required after an entry has been removed
+// from the root's subtree and returns the removed entry. The caller must hold
+// the write lock.
+func (bt *BTree) removed(e Entry) Entry {
+	// If the root was drained by a merge of its children, its only child becomes
+	// the new root and the tree shrinks by one level.
+	if bt.root.numEntries() == 0 && !bt.root.leaf() {
+		oldRoot := bt.root
+		bt.root = oldRoot.children[0]
+		bt.cow.freeNode(oldRoot)
+		bt.depth--
+	}
+
+	// the descent may have rebalanced nodes even if nothing was removed
+	bt.mods++
+
+	if e != nil {
+		bt.size--
+	}
+
+	return e
+}
+
+// recycle moves the nodes of the subtree rooted at n into the free list until
+// either the subtree has been exhausted or the free list is full. It returns
+// false in the latter case. Subtrees not owned by the BTree are skipped, as they
+// are still reachable from a clone. The caller must hold the write lock.
+func (bt *BTree) recycle(n *node) bool {
+	if n.cow != bt.cow {
+		return true
+	}
+
+	for _, child := range n.children {
+		if !bt.recycle(child) {
+			return false
+		}
+	}
+
+	return bt.cow.freeList.free(n)
+}
+
+// mutableRoot ensures the root is owned by the BTree and returns it. The caller
+// must hold the write lock.
+func (bt *BTree) mutableRoot() *node {
+	bt.root = bt.root.mutableFor(bt.cow)
+	return bt.root
+}
+
+// validateEntry returns the error of the BTree's validator for the Entry, if
+// any.
+func (bt *BTree) validateEntry(e Entry) error {
+	if bt.validate == nil {
+		return nil
+	}
+
+	return bt.validate(e)
+}
+
+// mustValidate panics if the Entry is rejected by the BTree's validator, prior
+// to any structural change being made.
+func (bt *BTree) mustValidate(e Entry) {
+	if err := bt.validateEntry(e); err != nil {
+		panic(err)
 	}
 }
 
+// splitRoot splits the root, which must be mutable, and grows the tree by one
+// level. The old root becomes the left child of the new root.
 func (bt *BTree) splitRoot() (*node, *node, Entry) {
-	left, right, midEntry := bt.root.split()
-	newRoot := newNode()
+	left := bt.root
+	midEntry, right := left.split()
+	newRoot := bt.cow.newNode()
 
-	newRoot.insert(midEntry)
+	newRoot.insertAt(0, midEntry)
 	newRoot.insertChildAt(0, left)
 	newRoot.insertChildAt(1, right)
-	bt.root.clear()
+	newRoot.count = left.count + right.count + 1
 
 	bt.root = newRoot
 	bt.depth++
+	bt.mods++
 
 	return left, right, midEntry
 }
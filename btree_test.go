@@ -1,14 +1,22 @@
 package btree_test
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"flag"
 	"fmt"
+	"math"
 	"math/rand"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -72,8 +80,2177 @@ func TestBTree(t *testing.T) {
 	}
 }
 
-func benchmarkInsert(b *testing.B, minDegree int) {
+// newTestBTree returns a BTree with minimum degree t containing the keys
+// [0, n) inserted in random order, along with the inserted entries in
+// ascending order.
+func newTestBTree(t *testing.T, minDegree, n int) (*btree.BTree, []testEntry) {
 	bt, err := btree.New(minDegree)
+	require.NoError(t, err)
+
+	entries := make([]testEntry, n)
+	for i := range entries {
+		entries[i] = testEntry{uint64(i), rng.Uint64()}
+	}
+
+	for _, i := range rng.Perm(n) {
+		bt.Insert(entries[i])
+	}
+
+	require.Equal(t, n, bt.Size())
+	require.NoError(t, bt.Verify())
+
+	return bt, entries
+}
+
+// newPackedBTree returns a new BTree bulk loaded with the keys in [0, n), whose
+// nodes are therefore packed close to capacity.
+func newPackedBTree(t *testing.T, minDegree, n int) *btree.BTree {
+	sorted := make([]btree.Entry, n)
+	for i := range sorted {
+		sorted[i] = testEntry{key: uint64(i)}
+	}
+
+	bt, err := btree.BulkLoad(sorted, minDegree)
+	require.NoError(t, err)
+
+	return bt
+}
+
+// requireGrows inserts 4t new entries into bt one by one and verifies it after
+// every insertion, which catches full nodes that the insertions fail to split,
+// such as a leaf root packed to capacity by a bulk load.
+func requireGrows(t *testing.T, bt *btree.BTree) {
+	t.Helper()
+
+	n, size := 4*bt.Stats().MinDegree, bt.Size()
+	for i := 0; i < n; i++ {
+		bt.Insert(testEntry{key: math.MaxUint32 + uint64(i)})
+		require.NoError(t, bt.Verify())
+	}
+
+	require.Equal(t, size+n, bt.Size())
+}
+
+func TestBTreeSet(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree)
+			require.NoError(t, err)
+
+			old, replaced := bt.Set(nil)
+			require.Nil(t, old)
+			require.False(t, replaced)
+
+			// insert every key several times with random values, which also
+			// exercises replacing entries that are split up into parents
+			const n = 1000
+			contents := make(map[uint64]testEntry, n)
+
+			for i := 0; i < 5*n; i++ {
+				e := testEntry{uint64(rng.Intn(n)), rng.Uint64()}
+				prev, ok := contents[e.key]
+
+				old, replaced := bt.Set(e)
+				require.Equal(t, ok, replaced)
+				if ok {
+					require.Equal(t, prev, old)
+				} else {
+					require.Nil(t, old)
+				}
+
+				contents[e.key] = e
+				require.Equal(t, len(contents), bt.Size())
+			}
+
+			require.NoError(t, bt.Verify())
+			for _, e := range contents {
+				require.Equal(t, e, bt.Search(e))
+			}
+		})
+	}
+}
+
+func TestBTreeModify(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 1000)
+			clone := bt.Clone()
+
+			increment := func(old btree.Entry) btree.Entry {
+				e := old.(testEntry)
+				return testEntry{e.key, e.value + 1}
+			}
+
+			require.False(t, bt.Modify(nil, increment))
+			require.False(t, bt.Modify(testEntry{key: 5000}, func(btree.Entry) btree.Entry {
+				t.Fatal("fn called for a missing entry")
+				return nil
+			}))
+
+			for _, e := range entries {
+				require.True(t, bt.Modify(testEntry{key: e.key}, increment))
+			}
+
+			require.Equal(t, len(entries), bt.Size())
+			require.NoError(t, bt.Verify())
+
+			for _, e := range entries {
+				require.Equal(t, testEntry{e.key, e.value + 1}, bt.Search(e))
+				require.Equal(t, e, clone.Search(e))
+			}
+		})
+	}
+}
+
+func TestBTreeCompareAndSwap(t *testing.T) {
+	bt, entries := newTestBTree(t, 3, 1000)
+	e := entries[500]
+
+	require.False(t, bt.CompareAndSwap(nil, e))
+	require.False(t, bt.CompareAndSwap(e, nil))
+
+	// the new entry must be equal to the old one
+	require.False(t, bt.CompareAndSwap(e, testEntry{key: 501}))
+	require.Equal(t, entries[501], bt.Search(entries[501]))
+
+	// a stale old entry does not swap
+	require.False(t, bt.CompareAndSwap(testEntry{e.key, e.value + 1}, testEntry{e.key, 0}))
+	require.Equal(t, e, bt.Search(e))
+
+	// a missing entry does not swap
+	require.False(t, bt.CompareAndSwap(testEntry{key: 5000}, testEntry{key: 5000}))
+	require.False(t, bt.Has(testEntry{key: 5000}))
+
+	require.True(t, bt.CompareAndSwap(e, testEntry{e.key, 0}))
+	require.Equal(t, testEntry{e.key, 0}, bt.Search(e))
+	require.False(t, bt.CompareAndSwap(e, testEntry{e.key, 1}))
+	require.Equal(t, len(entries), bt.Size())
+
+	// concurrent increments through a CAS loop never lose an update
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				for {
+					old := bt.Search(e).(testEntry)
+					if bt.CompareAndSwap(old, testEntry{old.key, old.value + 1}) {
+						break
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	require.Equal(t, testEntry{e.key, 800}, bt.Search(e))
+}
+
+// testKey implements a btree.Key looking up a testEntry by key alone.
+type testKey uint64
+
+func (k testKey) CompareEntry(e btree.Entry) int {
+	return testEntry{key: uint64(k)}.Compare(e)
+}
+
+func TestBTreeSearchKey(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 1000)
+			require.Nil(t, bt.SearchKey(nil))
+
+			for _, e := range entries {
+				require.Equal(t, e, bt.SearchKey(testKey(e.key)))
+			}
+
+			require.Nil(t, bt.SearchKey(testKey(len(entries))))
+		})
+	}
+}
+
+func TestBTreeSearchFunc(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 1000)
+
+			// search by a derived attribute shared by runs of ten entries
+			for bucket := uint64(0); bucket < 110; bucket++ {
+				e := bt.SearchFunc(func(e btree.Entry) int {
+					switch b := e.(testEntry).key / 10; {
+					case bucket < b:
+						return -1
+
+					case bucket > b:
+						return 1
+
+					default:
+						return 0
+					}
+				})
+
+				if bucket < 100 {
+					require.Equal(t, entries[10*bucket], e)
+				} else {
+					require.Nil(t, e)
+				}
+			}
+		})
+	}
+}
+
+func TestBTreeMultiGet(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 1000)
+			require.Empty(t, bt.MultiGet(nil))
+
+			// request present, missing, repeated and nil keys in random order
+			keys := make([]btree.Entry, 0, 1500)
+			for i := 0; i < 1500; i++ {
+				if i%100 == 0 {
+					keys = append(keys, nil)
+				} else {
+					keys = append(keys, testEntry{key: uint64(rng.Intn(1200))})
+				}
+			}
+
+			got := bt.MultiGet(keys)
+			require.Len(t, got, len(keys))
+
+			for i, k := range keys {
+				switch {
+				case k == nil:
+					require.Nil(t, got[i])
+
+				case k.(testEntry).key < uint64(len(entries)):
+					require.Equal(t, entries[k.(testEntry).key], got[i])
+
+				default:
+					require.Nil(t, got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBTreeInsertBatch(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree)
+			require.NoError(t, err)
+
+			bt.InsertBatch(nil)
+			bt.InsertBatch([]btree.Entry{nil})
+			require.Zero(t, bt.Size())
+
+			// insert batches of varying size with keys that repeat both within and
+			// across batches
+			const n = 2000
+			contents := make(map[uint64]testEntry, n)
+
+			for size := 1; size < n; size *= 3 {
+				batch := make([]btree.Entry, 0, size+1)
+				for i := 0; i < size; i++ {
+					e := testEntry{uint64(rng.Intn(n)), rng.Uint64()}
+					batch = append(batch, e)
+					contents[e.key] = e
+				}
+
+				batch = append(batch, nil)
+				unsorted := append([]btree.Entry(nil), batch...)
+
+				bt.InsertBatch(batch)
+				require.Equal(t, unsorted, batch)
+				require.Equal(t, len(contents), bt.Size())
+				require.NoError(t, bt.Verify())
+			}
+
+			for _, e := range contents {
+				require.Equal(t, e, bt.Search(e))
+			}
+		})
+	}
+}
+
+func TestBTreeInsertIfAbsent(t *testing.T) {
+	bt, err := btree.New(3)
+	require.NoError(t, err)
+	require.False(t, bt.InsertIfAbsent(nil))
+
+	const n = 1000
+	contents := make(map[uint64]testEntry, n)
+
+	for i := 0; i < 5*n; i++ {
+		e := testEntry{uint64(rng.Intn(n)), rng.Uint64()}
+		_, exists := contents[e.key]
+
+		require.Equal(t, !exists, bt.InsertIfAbsent(e))
+		if !exists {
+			contents[e.key] = e
+		}
+
+		require.Equal(t, contents[e.key], bt.Search(e))
+		require.Equal(t, len(contents), bt.Size())
+	}
+
+	require.NoError(t, bt.Verify())
+}
+
+func TestBTreeGetOrInsert(t *testing.T) {
+	bt, err := btree.New(3)
+	require.NoError(t, err)
+
+	got, inserted := bt.GetOrInsert(nil)
+	require.Nil(t, got)
+	require.False(t, inserted)
+
+	const n = 1000
+	contents := make(map[uint64]testEntry, n)
+
+	for i := 0; i < 5*n; i++ {
+		e := testEntry{uint64(rng.Intn(n)), rng.Uint64()}
+		existing, exists := contents[e.key]
+
+		got, inserted := bt.GetOrInsert(e)
+		require.Equal(t, !exists, inserted)
+
+		if exists {
+			require.Equal(t, existing, got)
+		} else {
+			require.Equal(t, e, got)
+			contents[e.key] = e
+		}
+
+		require.Equal(t, len(contents), bt.Size())
+	}
+
+	require.NoError(t, bt.Verify())
+
+	// concurrent callers racing on the same keys must all observe one winner
+	bt.Clear(false)
+
+	var (
+		wg      sync.WaitGroup
+		winners = make([][]btree.Entry, 8)
+	)
+
+	for w := range winners {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for k := 0; k < n; k++ {
+				got, _ := bt.GetOrInsert(testEntry{uint64(k), uint64(w)})
+				winners[w] = append(winners[w], got)
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	for _, got := range winners[1:] {
+		require.Equal(t, winners[0], got)
+	}
+}
+
+func TestBTreeUpsert(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree)
+			require.NoError(t, err)
+
+			sum := func(old, new btree.Entry) btree.Entry {
+				o, n := old.(testEntry), new.(testEntry)
+				return testEntry{o.key, o.value + n.value}
+			}
+
+			const n = 1000
+			counts := make(map[uint64]uint64, n)
+
+			bt.Upsert(nil, sum)
+			for i := 0; i < 10*n; i++ {
+				k := uint64(rng.Intn(n))
+				bt.Upsert(testEntry{k, 1}, sum)
+				counts[k]++
+			}
+
+			require.NoError(t, bt.Verify())
+			require.Equal(t, len(counts), bt.Size())
+
+			for k, c := range counts {
+				require.Equal(t, testEntry{k, c}, bt.Search(testEntry{key: k}))
+			}
+
+			// a nil merge replaces existing entries like Insert
+			bt.Upsert(testEntry{0, 42}, nil)
+			require.Equal(t, testEntry{0, 42}, bt.Search(testEntry{key: 0}))
+		})
+	}
+}
+
+func TestBTreeMinMax(t *testing.T) {
+	bt, err := btree.New(2)
+	require.NoError(t, err)
+	require.Nil(t, bt.Min())
+	require.Nil(t, bt.Max())
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 2000)
+
+			for lo, hi := 0, len(entries)-1; lo <= hi; lo, hi = lo+1, hi-1 {
+				require.Equal(t, entries[lo], bt.Min())
+				require.Equal(t, entries[hi], bt.Max())
+
+				bt.DeleteMin()
+				bt.DeleteMax()
+			}
+
+			require.Nil(t, bt.Min())
+			require.Nil(t, bt.Max())
+		})
+	}
+}
+
+func TestBTreeHas(t *testing.T) {
+	bt, entries := newTestBTree(t, 3, 2000)
+	require.False(t, bt.Has(nil))
+
+	for _, e := range entries {
+		require.True(t, bt.Has(e))
+		require.True(t, bt.Has(testEntry{key: e.key}))
+		require.False(t, bt.Has(testEntry{key: e.key + uint64(len(entries))}))
+	}
+
+	bt.Delete(entries[0])
+	require.False(t, bt.Has(entries[0]))
+}
+
+func TestBTreeRank(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 2000)
+			require.Zero(t, bt.Rank(nil))
+
+			for i, e := range entries {
+				require.Equal(t, i, bt.Rank(e))
+			}
+
+			require.Equal(t, len(entries), bt.Rank(testEntry{key: uint64(len(entries) + 10)}))
+
+			// absent keys rank where they would be inserted
+			for i := 0; i < len(entries); i += 2 {
+				bt.Delete(entries[i])
+			}
+
+			for i, e := range entries {
+				require.Equal(t, i/2, bt.Rank(e), i)
+			}
+		})
+	}
+}
+
+func TestBTreeAt(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 2000)
+			require.Nil(t, bt.At(-1))
+			require.Nil(t, bt.At(len(entries)))
+
+			for i, e := range entries {
+				require.Equal(t, e, bt.At(i))
+				require.Equal(t, i, bt.Rank(bt.At(i)))
+			}
+
+			for i := 0; i < len(entries); i += 2 {
+				bt.Delete(entries[i])
+			}
+
+			for i := 1; i < len(entries); i += 2 {
+				require.Equal(t, entries[i], bt.At(i/2))
+			}
+
+			require.Nil(t, bt.At(len(entries)/2))
+		})
+	}
+}
+
+func TestBTreeKthLargest(t *testing.T) {
+	bt, entries := newTestBTree(t, 3, 2000)
+	require.Nil(t, bt.KthLargest(0))
+	require.Nil(t, bt.KthLargest(len(entries)+1))
+	require.Equal(t, bt.Max(), bt.KthLargest(1))
+	require.Equal(t, bt.Min(), bt.KthLargest(len(entries)))
+
+	for k := 1; k <= len(entries); k++ {
+		require.Equal(t, entries[len(entries)-k], bt.KthLargest(k))
+	}
+}
+
+func TestBTreePercentile(t *testing.T) {
+	bt, err := btree.New(3)
+	require.NoError(t, err)
+	require.Nil(t, bt.Percentile(50))
+	require.Nil(t, bt.Median())
+
+	for i := 1; i <= 1000; i++ {
+		bt.Insert(testEntry{key: uint64(i)})
+	}
+
+	for _, p := range []float64{-1, 100.5, math.NaN()} {
+		require.Nil(t, bt.Percentile(p))
+	}
+
+	for p, want := range map[float64]uint64{0: 1, 0.1: 1, 0.15: 2, 50: 500, 95: 950, 99: 990, 99.95: 1000, 100: 1000} {
+		require.Equal(t, testEntry{key: want}, bt.Percentile(p), p)
+	}
+
+	require.Equal(t, testEntry{key: 500}, bt.Median())
+}
+
+func TestBTreeSample(t *testing.T) {
+	bt, err := btree.New(3)
+	require.NoError(t, err)
+	require.Empty(t, bt.Sample(10, rng))
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 1000)
+
+			require.Empty(t, bt.Sample(0, rng))
+			require.Empty(t, bt.Sample(-1, rng))
+
+			// oversized samples return every entry
+			all := bt.Sample(len(entries)+1, rng)
+			require.Len(t, all, len(entries))
+			for i, e := range entries {
+				require.Equal(t, e, all[i])
+			}
+
+			// samples are distinct and ascending
+			for _, n := range []int{1, 10, 100, 999} {
+				sample := bt.Sample(n, rng)
+				require.Len(t, sample, n)
+
+				for i := 1; i < n; i++ {
+					require.Less(t, sample[i-1].(testEntry).key, sample[i].(testEntry).key)
+				}
+			}
+
+			// every entry is eventually sampled
+			seen := make(map[uint64]bool)
+			for i := 0; i < 1000; i++ {
+				for _, e := range bt.Sample(100, nil) {
+					seen[e.(testEntry).key] = true
+				}
+			}
+
+			require.Equal(t, len(entries), len(seen))
+		})
+	}
+}
+
+func TestBTreeCountRange(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree)
+			require.NoError(t, err)
+			require.Zero(t, bt.CountRange(nil, nil))
+
+			// insert every other key in [0, 2n)
+			const n = 1000
+			for _, i := range rng.Perm(n) {
+				bt.Insert(testEntry{key: uint64(2 * i)})
+			}
+
+			key := func(k int) btree.Entry {
+				if k < 0 {
+					return nil
+				}
+
+				return testEntry{key: uint64(k)}
+			}
+
+			count := func(from, to int) int {
+				var c int
+				for i := 0; i < n; i++ {
+					if (from < 0 || 2*i >= from) && (to < 0 || 2*i < to) {
+						c++
+					}
+				}
+
+				return c
+			}
+
+			require.Equal(t, n, bt.CountRange(nil, nil))
+
+			for i := 0; i < 500; i++ {
+				// bounds of -1 are unbounded
+				from, to := rng.Intn(2*n+100)-1, rng.Intn(2*n+100)-1
+				require.Equal(t, count(from, to), bt.CountRange(key(from), key(to)), "[%d, %d)", from, to)
+			}
+
+			// counts remain accurate as the tree shrinks
+			for i := 0; i < n; i += 3 {
+				bt.Delete(testEntry{key: uint64(2 * i)})
+			}
+
+			require.NoError(t, bt.Verify())
+			require.Equal(t, bt.Size(), bt.CountRange(nil, nil))
+			require.Equal(t, bt.Size(), bt.CountRange(testEntry{}, testEntry{key: 2 * n}))
+		})
+	}
+}
+
+func TestBTreeFloorCeil(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree)
+			require.NoError(t, err)
+			require.Nil(t, bt.Floor(testEntry{}))
+			require.Nil(t, bt.Ceil(testEntry{}))
+
+			// insert every third key in [0, 3n)
+			const n = 1000
+			for _, i := range rng.Perm(n) {
+				bt.Insert(testEntry{uint64(3*i + 1), uint64(i)})
+			}
+
+			require.Nil(t, bt.Floor(nil))
+			require.Nil(t, bt.Ceil(nil))
+
+			for k := 0; k < 3*n+3; k++ {
+				floor, ceil := bt.Floor(testEntry{key: uint64(k)}), bt.Ceil(testEntry{key: uint64(k)})
+
+				if k < 1 {
+					require.Nil(t, floor)
+				} else {
+					i := (k - 1) / 3
+					if i >= n {
+						i = n - 1
+					}
+					require.Equal(t, testEntry{uint64(3*i + 1), uint64(i)}, floor, k)
+				}
+
+				if i := (k + 1) / 3; i >= n {
+					require.Nil(t, ceil)
+				} else {
+					require.Equal(t, testEntry{uint64(3*i + 1), uint64(i)}, ceil, k)
+				}
+			}
+		})
+	}
+}
+
+func TestBTreeAdjacent(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree)
+			require.NoError(t, err)
+
+			prev, next := bt.Adjacent(testEntry{key: 1})
+			require.Nil(t, prev)
+			require.Nil(t, next)
+
+			// insert every other key in [0, 2n)
+			const n = 1000
+			for _, i := range rng.Perm(n) {
+				bt.Insert(testEntry{key: uint64(2 * i)})
+			}
+
+			prev, next = bt.Adjacent(nil)
+			require.Nil(t, prev)
+			require.Nil(t, next)
+
+			for k := uint64(0); k <= 2*n; k++ {
+				prev, next := bt.Adjacent(testEntry{key: k})
+				require.Equal(t, bt.Prev(testEntry{key: k}), prev, k)
+				require.Equal(t, bt.Next(testEntry{key: k}), next, k)
+			}
+		})
+	}
+}
+
+func TestBTreeNearest(t *testing.T) {
+	bt, err := btree.New(3)
+	require.NoError(t, err)
+	require.Nil(t, bt.Nearest(testEntry{key: 1}, nil))
+
+	// keys are multiples of ten in [100, 10000)
+	for i := 10; i < 1000; i++ {
+		bt.Insert(testEntry{key: uint64(10 * i)})
+	}
+
+	distance := func(a, b btree.Entry) float64 {
+		return math.Abs(float64(a.(testEntry).key) - float64(b.(testEntry).key))
+	}
+
+	require.Nil(t, bt.Nearest(nil, distance))
+
+	for k := uint64(0); k < 10100; k++ {
+		var want uint64
+		switch {
+		case k < 100:
+			want = 100
+
+		case k > 9990:
+			want = 9990
+
+		case k%10 > 5:
+			want = k - k%10 + 10
+
+		default:
+			want = k - k%10
+		}
+
+		require.Equal(t, testEntry{key: want}, bt.Nearest(testEntry{key: k}, distance), k)
+
+		// without a distance, an exact match or the floor wins
+		if k >= 100 {
+			require.Equal(t, testEntry{key: min(k-k%10, 9990)}, bt.Nearest(testEntry{key: k}, nil))
+		}
+	}
+}
+
+func TestBTreePrevNext(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 2000)
+			require.Nil(t, bt.Prev(nil))
+			require.Nil(t, bt.Next(nil))
+
+			// walking via Next and Prev visits every entry in order
+			var forward, backward []testEntry
+			for e := bt.Min(); e != nil; e = bt.Next(e) {
+				forward = append(forward, e.(testEntry))
+			}
+			for e := bt.Max(); e != nil; e = bt.Prev(e) {
+				backward = append([]testEntry{e.(testEntry)}, backward...)
+			}
+
+			require.Equal(t, entries, forward)
+			require.Equal(t, entries, backward)
+
+			// absent keys resolve to their neighbours
+			for i := 1; i < len(entries)-1; i += 2 {
+				bt.Delete(entries[i])
+			}
+
+			for i := 1; i < len(entries)-1; i += 2 {
+				require.Equal(t, entries[i-1], bt.Prev(entries[i]))
+				require.Equal(t, entries[i+1], bt.Next(entries[i]))
+			}
+		})
+	}
+}
+
+func TestBTreeDelete(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			n := 5000
+			bt, entries := newTestBTree(t, minDegree, n)
+			require.Greater(t, bt.Depth(), 1)
+
+			require.Nil(t, bt.Delete(nil))
+			require.Nil(t, bt.Delete(testEntry{key: uint64(n)}))
+			require.Equal(t, n, bt.Size())
+
+			for i, j := range rng.Perm(n) {
+				e := entries[j]
+				require.Equal(t, e, bt.Delete(e))
+				require.Nil(t, bt.Search(e))
+				require.Nil(t, bt.Delete(e))
+				require.Equal(t, n-i-1, bt.Size())
+
+				if i%97 == 0 {
+					require.NoError(t, bt.Verify())
+				}
+			}
+
+			require.NoError(t, bt.Verify())
+			require.Zero(t, bt.Size())
+			require.Equal(t, 1, bt.Depth())
+
+			// the tree must remain usable once drained
+			bt.Insert(entries[0])
+			require.Equal(t, entries[0], bt.Search(entries[0]))
+			require.Equal(t, 1, bt.Size())
+		})
+	}
+}
+
+func TestBTreeDeleteIf(t *testing.T) {
+	bt, entries := newTestBTree(t, 3, 2000)
+
+	even := func(e btree.Entry) bool { return e.(testEntry).value%2 == 0 }
+	require.False(t, bt.DeleteIf(nil, even))
+	require.False(t, bt.DeleteIf(testEntry{key: uint64(len(entries))}, even))
+
+	remaining := len(entries)
+	for _, e := range entries {
+		// the predicate is evaluated against the stored entry, not the argument
+		deleted := bt.DeleteIf(testEntry{key: e.key}, even)
+		require.Equal(t, even(e), deleted)
+		require.Equal(t, !deleted, bt.Has(e))
+
+		if deleted {
+			remaining--
+		}
+	}
+
+	require.NoError(t, bt.Verify())
+	require.Equal(t, remaining, bt.Size())
+}
+
+func TestBTreeDeleteRange(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			n := 2000
+
+			key := func(k int) btree.Entry {
+				if k < 0 {
+					return nil
+				}
+
+				return testEntry{key: uint64(k)}
+			}
+
+			for i := 0; i < 50; i++ {
+				bt, entries := newTestBTree(t, minDegree, n)
+
+				// exercise cutting through nodes shared with a clone
+				var clone *btree.BTree
+				if i%2 == 0 {
+					clone = bt.Clone()
+				}
+
+				// bounds of -1 are unbounded
+				from, to := rng.Intn(n+100)-1, rng.Intn(n+100)-1
+				if i%10 == 0 {
+					from = -1
+				}
+				if i%10 == 1 {
+					to = -1
+				}
+
+				lo, hi := from, to
+				if lo < 0 {
+					lo = 0
+				}
+				if hi < 0 || hi > n {
+					hi = n
+				}
+
+				want := 0
+				if lo < hi {
+					want = hi - lo
+				}
+
+				require.Equal(t, want, bt.DeleteRange(key(from), key(to)), "[%d, %d)", from, to)
+				require.NoError(t, bt.Verify(), "[%d, %d)", from, to)
+				require.Equal(t, n-want, bt.Size())
+
+				for k, e := range entries {
+					require.Equal(t, k < lo || k >= hi, bt.Has(e), "[%d, %d) %d", from, to, k)
+				}
+
+				if clone != nil {
+					require.NoError(t, clone.Verify())
+					require.Equal(t, n, clone.Size())
+
+					for _, e := range entries {
+						require.True(t, clone.Has(e))
+					}
+				}
+
+				requireGrows(t, bt)
+			}
+
+			// what remains of a packed tree may be a leaf root at capacity,
+			// which keeps growing by splitting
+			for _, m := range []int{1, 2*minDegree - 2, 2*minDegree - 1} {
+				bt := newPackedBTree(t, minDegree, n)
+				require.Equal(t, n-m, bt.DeleteRange(key(m), nil))
+				requireGrows(t, bt)
+
+				bt = newPackedBTree(t, minDegree, n)
+				require.Equal(t, n-m, bt.DeleteRange(nil, key(n-m)))
+				requireGrows(t, bt)
+
+				bt = newPackedBTree(t, minDegree, n)
+				require.Equal(t, n-m, bt.DeleteRange(key(m/2), key(m/2+n-m)))
+				requireGrows(t, bt)
+			}
+		})
+	}
+}
+
+func TestBTreeTruncate(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 2000)
+			clone := bt.Clone()
+
+			bt.Truncate(len(entries) + 1)
+			require.Equal(t, len(entries), bt.Size())
+
+			for n := len(entries); n >= 0; n -= 1 + rng.Intn(100) {
+				bt.Truncate(n)
+				require.Equal(t, n, bt.Size())
+				require.NoError(t, bt.Verify())
+				require.Equal(t, clone.Entries(nil, testEntry{key: uint64(n)}), bt.Entries(nil, nil))
+			}
+
+			bt.Truncate(-1)
+			require.Zero(t, bt.Size())
+			require.NoError(t, bt.Verify())
+
+			// the clone is unaffected
+			require.Equal(t, len(entries), clone.Size())
+			require.NoError(t, clone.Verify())
+
+			// truncating a packed tree may leave a leaf root at capacity, which
+			// keeps growing by splitting
+			for _, n := range []int{1, 2*minDegree - 2, 2*minDegree - 1} {
+				packed := newPackedBTree(t, minDegree, len(entries))
+				packed.Truncate(n)
+				requireGrows(t, packed)
+
+				bt, _ := newTestBTree(t, minDegree, len(entries))
+				bt.Truncate(n)
+				requireGrows(t, bt)
+			}
+		})
+	}
+}
+
+func TestBTreeDeleteAll(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 3000)
+
+			odd := func(e btree.Entry) bool { return e.(testEntry).value%2 == 1 }
+
+			want := 0
+			for _, e := range entries {
+				if odd(e) {
+					want++
+				}
+			}
+
+			require.Equal(t, want, bt.DeleteAll(odd))
+			require.NoError(t, bt.Verify())
+			require.Equal(t, len(entries)-want, bt.Size())
+
+			for _, e := range entries {
+				require.Equal(t, !odd(e), bt.Has(e))
+			}
+
+			require.Zero(t, bt.DeleteAll(odd))
+			require.Equal(t, len(entries)-want, bt.DeleteAll(func(btree.Entry) bool { return true }))
+			require.Zero(t, bt.Size())
+		})
+	}
+}
+
+func TestBTreeDeleteMinMax(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			n := 5000
+			bt, entries := newTestBTree(t, minDegree, n)
+
+			for lo, hi := 0, n-1; lo <= hi; {
+				if rng.Intn(2) == 0 {
+					require.Equal(t, entries[lo], bt.DeleteMin())
+					lo++
+				} else {
+					require.Equal(t, entries[hi], bt.DeleteMax())
+					hi--
+				}
+
+				require.Equal(t, hi-lo+1, bt.Size())
+				if bt.Size()%97 == 0 {
+					require.NoError(t, bt.Verify())
+				}
+			}
+
+			require.NoError(t, bt.Verify())
+			require.Equal(t, 1, bt.Depth())
+			require.Nil(t, bt.DeleteMin())
+			require.Nil(t, bt.DeleteMax())
+			require.Zero(t, bt.Size())
+		})
+	}
+}
+
+func TestBTreeClear(t *testing.T) {
+	for _, recycle := range []bool{false, true} {
+		t.Run(fmt.Sprintf("recycle %t", recycle), func(t *testing.T) {
+			bt, entries := newTestBTree(t, 3, 5000)
+
+			bt.Clear(recycle)
+			require.NoError(t, bt.Verify())
+			require.Zero(t, bt.Size())
+			require.Equal(t, 1, bt.Depth())
+			require.Nil(t, bt.Min())
+
+			for _, e := range entries {
+				require.False(t, bt.Has(e))
+			}
+
+			if recycle {
+				require.NotZero(t, bt.FreeListLen())
+			} else {
+				require.Zero(t, bt.FreeListLen())
+			}
+
+			for _, i := range rng.Perm(len(entries)) {
+				bt.Insert(entries[i])
+			}
+
+			require.NoError(t, bt.Verify())
+			require.Equal(t, len(entries), bt.Size())
+			require.Zero(t, bt.FreeListLen())
+
+			for _, e := range entries {
+				require.Equal(t, e, bt.Search(e))
+			}
+		})
+	}
+}
+
+func TestBTreeClone(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			n := 2000
+			bt, entries := newTestBTree(t, minDegree, n)
+
+			clone := bt.Clone()
+			require.NoError(t, clone.Verify())
+			require.Equal(t, bt.Size(), clone.Size())
+			require.Equal(t, bt.Depth(), clone.Depth())
+
+			// delete the lower half from the original and the upper half from the
+			// clone, while inserting new entries into both
+			for i, e := range entries {
+				if i < n/2 {
+					bt.Delete(e)
+				} else {
+					clone.Delete(e)
+				}
+			}
+
+			bt.Insert(testEntry{key: uint64(2 * n)})
+			clone.Insert(testEntry{key: uint64(3 * n)})
+
+			require.NoError(t, bt.Verify())
+			require.NoError(t, clone.Verify())
+			require.Equal(t, n/2+1, bt.Size())
+			require.Equal(t, n/2+1, clone.Size())
+
+			for i, e := range entries {
+				require.Equal(t, i >= n/2, bt.Has(e))
+				require.Equal(t, i < n/2, clone.Has(e))
+			}
+
+			require.False(t, bt.Has(testEntry{key: uint64(3 * n)}))
+			require.False(t, clone.Has(testEntry{key: uint64(2 * n)}))
+		})
+	}
+}
+
+func TestBTreeCloneCopyOnWrite(t *testing.T) {
+	const n = 1000
+
+	bt, err := btree.New(2)
+	require.NoError(t, err)
+
+	trees := []*btree.BTree{bt}
+	contents := []map[uint64]testEntry{{}}
+
+	for round := 0; round < 20; round++ {
+		// mutate every tree randomly and check it against its reference contents
+		for i, tree := range trees {
+			for j := 0; j < 200; j++ {
+				e := testEntry{uint64(rng.Intn(n)), rng.Uint64()}
+
+				if _, ok := contents[i][e.key]; ok {
+					tree.Delete(e)
+					delete(contents[i], e.key)
+				} else {
+					tree.Insert(e)
+					contents[i][e.key] = e
+				}
+			}
+		}
+
+		for i, tree := range trees {
+			require.NoError(t, tree.Verify())
+			require.Equal(t, len(contents[i]), tree.Size())
+
+			for k := uint64(0); k < n; k++ {
+				e, ok := contents[i][k]
+				if ok {
+					require.Equal(t, e, tree.Search(e))
+				} else {
+					require.Nil(t, tree.Search(testEntry{key: k}))
+				}
+			}
+		}
+
+		// clone a random tree, copying its reference contents
+		i := rng.Intn(len(trees))
+		copied := make(map[uint64]testEntry, len(contents[i]))
+		for k, e := range contents[i] {
+			copied[k] = e
+		}
+
+		trees = append(trees, trees[i].Clone())
+		contents = append(contents, copied)
+	}
+
+	// recycling the nodes of one tree must not affect the trees it shares with
+	for i, tree := range trees {
+		if i%2 == 0 {
+			tree.Clear(true)
+			contents[i] = map[uint64]testEntry{}
+		}
+	}
+
+	for i, tree := range trees {
+		for j := 0; j < 200; j++ {
+			e := testEntry{uint64(rng.Intn(n)), rng.Uint64()}
+			if _, ok := contents[i][e.key]; !ok {
+				tree.Insert(e)
+				contents[i][e.key] = e
+			}
+		}
+
+		require.NoError(t, tree.Verify())
+		require.Equal(t, len(contents[i]), tree.Size())
+
+		for _, e := range contents[i] {
+			require.Equal(t, e, tree.Search(e))
+		}
+	}
+}
+
+func TestBTreeCloneConcurrent(t *testing.T) {
+	bt, entries := newTestBTree(t, 3, 2000)
+
+	trees := []*btree.BTree{bt, bt.Clone(), bt.Clone(), bt.Clone()}
+
+	var wg sync.WaitGroup
+	for i, tree := range trees {
+		wg.Add(1)
+
+		go func(i int, tree *btree.BTree) {
+			defer wg.Done()
+
+			for j, e := range entries {
+				if j%len(trees) == i {
+					tree.Delete(e)
+				}
+			}
+		}(i, tree)
+	}
+
+	wg.Wait()
+
+	for i, tree := range trees {
+		require.NoError(t, tree.Verify())
+
+		for j, e := range entries {
+			require.Equal(t, j%len(trees) != i, tree.Has(e))
+		}
+	}
+}
+
+func TestBTreePopMinMax(t *testing.T) {
+	bt, entries := newTestBTree(t, 3, 2000)
+
+	// concurrent consumers must never pop the same entry twice
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		popped = make(map[uint64]int)
+	)
+
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			pop := bt.PopMin
+			if w%2 == 1 {
+				pop = bt.PopMax
+			}
+
+			var prev btree.Entry
+			for {
+				e, ok := pop()
+				if !ok {
+					assert.Nil(t, e)
+					return
+				}
+
+				// every consumer observes strictly monotonic entries
+				if prev != nil {
+					c := prev.Compare(e)
+					assert.True(t, (w%2 == 0 && c < 0) || (w%2 == 1 && c > 0))
+				}
+				prev = e
+
+				mu.Lock()
+				popped[e.(testEntry).key]++
+				mu.Unlock()
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	require.Len(t, popped, len(entries))
+	for _, c := range popped {
+		require.Equal(t, 1, c)
+	}
+
+	require.Zero(t, bt.Size())
+	require.NoError(t, bt.Verify())
+}
+
+func TestBTreeEntries(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 1000)
+
+			all := bt.Entries(nil, nil)
+			require.Len(t, all, len(entries))
+			require.Equal(t, len(all), cap(all))
+			for i, e := range entries {
+				require.Equal(t, e, all[i])
+			}
+
+			for i := 0; i < 100; i++ {
+				from, to := rng.Intn(len(entries)+10), rng.Intn(len(entries)+10)
+
+				got := bt.Entries(testEntry{key: uint64(from)}, testEntry{key: uint64(to)})
+				if from >= to || from >= len(entries) {
+					require.Nil(t, got)
+					continue
+				}
+
+				if to > len(entries) {
+					to = len(entries)
+				}
+
+				require.Len(t, got, to-from)
+				require.Equal(t, len(got), cap(got))
+				for j, e := range got {
+					require.Equal(t, entries[from+j], e)
+				}
+			}
+
+			require.Len(t, bt.Entries(testEntry{key: 500}, nil), 500)
+			require.Len(t, bt.Entries(nil, testEntry{key: 500}), 500)
+		})
+	}
+}
+
+func TestBTreeAppendTo(t *testing.T) {
+	bt, entries := newTestBTree(t, 3, 1000)
+
+	// appending to a populated slice preserves its contents
+	dst := bt.AppendTo([]btree.Entry{testEntry{key: 9999}}, nil, testEntry{key: 10})
+	require.Len(t, dst, 11)
+	require.Equal(t, len(dst), cap(dst))
+	require.Equal(t, testEntry{key: 9999}, dst[0])
+	for i, e := range dst[1:] {
+		require.Equal(t, entries[i], e)
+	}
+
+	// an empty range leaves dst untouched
+	require.Equal(t, dst, bt.AppendTo(dst, testEntry{key: 10}, testEntry{key: 10}))
+
+	// reusing a sufficiently large buffer does not allocate
+	buf := make([]btree.Entry, 0, 100)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = bt.AppendTo(buf[:0], testEntry{key: 200}, testEntry{key: 300})
+	})
+
+	require.Zero(t, allocs)
+	require.Len(t, buf, 100)
+	require.Equal(t, 100, cap(buf))
+	for i, e := range buf {
+		require.Equal(t, entries[200+i], e)
+	}
+}
+
+// stringEntry implements a btree.PrefixEntry keyed by a string.
+type stringEntry string
+
+func (se stringEntry) Compare(other btree.Entry) int {
+	return strings.Compare(string(se), string(other.(stringEntry)))
+}
+
+func (se stringEntry) ComparePrefix(prefix []byte) int {
+	k := string(se)
+	if len(k) > len(prefix) {
+		k = k[:len(prefix)]
+	}
+
+	return strings.Compare(k, string(prefix))
+}
+
+func TestBTreeAscendPrefix(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree)
+			require.NoError(t, err)
+
+			var keys []string
+			for i := 0; i < 1000; i++ {
+				k := fmt.Sprintf("%x", i)
+				keys = append(keys, k)
+				bt.Insert(stringEntry(k))
+			}
+
+			for _, prefix := range []string{"", "0", "1", "a", "ab", "abc", "3e7", "3e8", "g", "ff"} {
+				var want []btree.Entry
+				for _, k := range keys {
+					if strings.HasPrefix(k, prefix) {
+						want = append(want, stringEntry(k))
+					}
+				}
+
+				sort.Slice(want, func(i, j int) bool {
+					return want[i].Compare(want[j]) < 0
+				})
+
+				var got []btree.Entry
+				bt.AscendPrefix([]byte(prefix), func(e btree.Entry) bool {
+					got = append(got, e)
+					return true
+				})
+
+				require.Equal(t, want, got, prefix)
+			}
+
+			// the traversal stops early
+			count := 0
+			bt.AscendPrefix([]byte("1"), func(btree.Entry) bool {
+				count++
+				return count < 5
+			})
+
+			require.Equal(t, 5, count)
+		})
+	}
+}
+
+func TestBTreeIterateMutable(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 5000)
+
+			var (
+				visited   []btree.Entry
+				remaining []testEntry
+			)
+
+			bt.IterateMutable(func(e btree.Entry) bool {
+				visited = append(visited, e)
+				return len(visited)%3 == 0
+			})
+
+			require.Len(t, visited, len(entries))
+			for i, e := range entries {
+				require.Equal(t, e, visited[i])
+
+				if (i+1)%3 != 0 {
+					remaining = append(remaining, e)
+				}
+			}
+
+			require.NoError(t, bt.Verify())
+			require.Equal(t, len(remaining), bt.Size())
+
+			for i, e := range entries {
+				if (i+1)%3 == 0 {
+					require.Nil(t, bt.Search(e))
+				} else {
+					require.Equal(t, e, bt.Search(e))
+				}
+			}
+
+			bt.IterateMutable(func(btree.Entry) bool { return true })
+			require.NoError(t, bt.Verify())
+			require.Zero(t, bt.Size())
+			require.Equal(t, 1, bt.Depth())
+		})
+	}
+}
+
+func TestBTreeAscend(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 2000)
+
+			var got []testEntry
+			bt.Ascend(func(e btree.Entry) bool {
+				got = append(got, e.(testEntry))
+				return true
+			})
+			require.Equal(t, entries, got)
+
+			got = got[:0]
+			bt.Ascend(func(e btree.Entry) bool {
+				got = append(got, e.(testEntry))
+				return len(got) < 10
+			})
+			require.Equal(t, entries[:10], got)
+		})
+	}
+}
+
+func TestBTreeDescend(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 2000)
+
+			var got []testEntry
+			bt.Descend(func(e btree.Entry) bool {
+				got = append(got, e.(testEntry))
+				return true
+			})
+
+			require.Len(t, got, len(entries))
+			for i, e := range got {
+				require.Equal(t, entries[len(entries)-1-i], e)
+			}
+
+			got = got[:0]
+			bt.Descend(func(e btree.Entry) bool {
+				got = append(got, e.(testEntry))
+				return len(got) < 10
+			})
+
+			require.Len(t, got, 10)
+			for i, e := range got {
+				require.Equal(t, entries[len(entries)-1-i], e)
+			}
+		})
+	}
+}
+
+func TestBTreeAscendDescendRange(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			n := 2000
+			bt, entries := newTestBTree(t, minDegree, n)
+
+			key := func(k int) btree.Entry {
+				if k < 0 {
+					return nil
+				}
+
+				return testEntry{key: uint64(k)}
+			}
+
+			for i := 0; i < 200; i++ {
+				// bounds of -1 are unbounded
+				from, to := rng.Intn(n+100)-1, rng.Intn(n+100)-1
+
+				var want []btree.Entry
+				for k, e := range entries {
+					if (from < 0 || k >= from) && (to < 0 || k < to) {
+						want = append(want, e)
+					}
+				}
+
+				var asc, desc []btree.Entry
+				bt.AscendRange(key(from), key(to), func(e btree.Entry) bool {
+					asc = append(asc, e)
+					return true
+				})
+				bt.DescendRange(key(from), key(to), func(e btree.Entry) bool {
+					desc = append([]btree.Entry{e}, desc...)
+					return true
+				})
+
+				require.Equal(t, want, asc, "[%d, %d)", from, to)
+				require.Equal(t, want, desc, "[%d, %d)", from, to)
+			}
+
+			var got []btree.Entry
+			bt.DescendRange(nil, testEntry{key: 100}, func(e btree.Entry) bool {
+				got = append(got, e)
+				return len(got) < 3
+			})
+			require.Equal(t, []btree.Entry{entries[99], entries[98], entries[97]}, got)
+		})
+	}
+}
+
+func TestBTreePivotTraversal(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			n := 2000
+			bt, entries := newTestBTree(t, minDegree, n)
+
+			for _, pivot := range []int{0, 1, n / 3, n / 2, n - 1, n, n + 10} {
+				var asc, desc []btree.Entry
+				bt.AscendGreaterOrEqual(testEntry{key: uint64(pivot)}, func(e btree.Entry) bool {
+					asc = append(asc, e)
+					return true
+				})
+				bt.DescendLessOrEqual(testEntry{key: uint64(pivot)}, func(e btree.Entry) bool {
+					desc = append(desc, e)
+					return true
+				})
+
+				var wantAsc, wantDesc []btree.Entry
+				for k := pivot; k < n; k++ {
+					wantAsc = append(wantAsc, entries[k])
+				}
+				for k := pivot; k >= 0; k-- {
+					if k < n {
+						wantDesc = append(wantDesc, entries[k])
+					}
+				}
+
+				require.Equal(t, wantAsc, asc, pivot)
+				require.Equal(t, wantDesc, desc, pivot)
+			}
+
+			// paginate in pages of 100 entries via the last key seen
+			var (
+				pages int
+				last  btree.Entry = testEntry{}
+			)
+
+			for last != nil {
+				var page []btree.Entry
+
+				pivot := last
+				last = nil
+
+				bt.AscendGreaterOrEqual(pivot, func(e btree.Entry) bool {
+					if len(page) == 100 {
+						last = e
+						return false
+					}
+
+					page = append(page, e)
+					return true
+				})
+
+				require.Equal(t, entries[pages*100].key, page[0].(testEntry).key)
+				pages++
+			}
+
+			require.Equal(t, n/100, pages)
+		})
+	}
+}
+
+func TestBTreeRangeReverse(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			n := 2000
+			bt, entries := newTestBTree(t, minDegree, n)
+
+			collect := func(lo, hi btree.Entry) []btree.Entry {
+				var got []btree.Entry
+				bt.RangeReverse(lo, hi, func(e btree.Entry) bool {
+					got = append(got, e)
+					return true
+				})
+
+				return got
+			}
+
+			expected := func(lo, hi int) []btree.Entry {
+				var want []btree.Entry
+				for i := len(entries) - 1; i >= 0; i-- {
+					if i >= lo && i <= hi {
+						want = append(want, entries[i])
+					}
+				}
+
+				return want
+			}
+
+			require.Equal(t, expected(0, n), collect(nil, nil))
+			require.Equal(t, expected(0, 500), collect(nil, testEntry{key: 500}))
+			require.Equal(t, expected(1500, n), collect(testEntry{key: 1500}, nil))
+			require.Empty(t, collect(testEntry{key: 700}, testEntry{key: 600}))
+
+			for i := 0; i < 100; i++ {
+				lo, hi := rng.Intn(n+100), rng.Intn(n+100)
+				if lo > hi {
+					lo, hi = hi, lo
+				}
+
+				got := collect(testEntry{key: uint64(lo)}, testEntry{key: uint64(hi)})
+				require.Equal(t, expected(lo, hi), got, "[%d, %d]", lo, hi)
+			}
+
+			var got []btree.Entry
+			bt.RangeReverse(nil, nil, func(e btree.Entry) bool {
+				got = append(got, e)
+				return len(got) < 10
+			})
+			require.Equal(t, expected(n-10, n), got)
+		})
+	}
+}
+
+func TestBTreeValuesInRange(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			n := 2000
+			bt, entries := newTestBTree(t, minDegree, n)
+
+			value := func(e btree.Entry) any { return e.(testEntry).value }
+
+			expected := func(lo, hi int) []any {
+				var want []any
+				for i, e := range entries {
+					if i >= lo && i <= hi {
+						want = append(want, e.value)
+					}
+				}
+
+				return want
+			}
+
+			require.Equal(t, expected(0, n), bt.ValuesInRange(nil, nil, value))
+			require.Equal(t, expected(0, 500), bt.ValuesInRange(nil, testEntry{key: 500}, value))
+			require.Equal(t, expected(1500, n), bt.ValuesInRange(testEntry{key: 1500}, nil, value))
+			require.Empty(t, bt.ValuesInRange(testEntry{key: 700}, testEntry{key: 600}, value))
+
+			for i := 0; i < 100; i++ {
+				lo, hi := rng.Intn(n+100), rng.Intn(n+100)
+				if lo > hi {
+					lo, hi = hi, lo
+				}
+
+				got := bt.ValuesInRange(testEntry{key: uint64(lo)}, testEntry{key: uint64(hi)}, value)
+				require.Equal(t, expected(lo, hi), got, "[%d, %d]", lo, hi)
+			}
+		})
+	}
+}
+
+func testEntryKey(e btree.Entry) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, e.(testEntry).key)
+	return k
+}
+
+func TestNewWithComparator(t *testing.T) {
+	_, err := btree.NewWithComparator(3, nil)
+	require.Error(t, err)
+
+	_, err = btree.NewWithComparator(1, compareValues)
+	require.Error(t, err)
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			byKey, entries := newTestBTree(t, minDegree, 1000)
+
+			// index the same entries by value instead of by key
+			byValue, err := btree.NewWithComparator(minDegree, compareValues)
+			require.NoError(t, err)
+
+			for _, e := range entries {
+				byValue.Insert(e)
+			}
+
+			require.NoError(t, byValue.Verify())
+			require.Equal(t, byKey.Size(), byValue.Size())
+
+			sort.Slice(entries, func(i, j int) bool {
+				return entries[i].value < entries[j].value
+			})
+
+			i := 0
+			byValue.Ascend(func(e btree.Entry) bool {
+				require.Equal(t, entries[i], e)
+				i++
+				return true
+			})
+
+			for _, e := range entries {
+				require.Equal(t, e, byValue.Search(testEntry{value: e.value}))
+			}
+
+			byValue.Delete(testEntry{value: entries[0].value})
+			require.Equal(t, entries[1], byValue.Min())
+		})
+	}
+}
+
+// compareValues orders testEntry objects by value rather than by key.
+func compareValues(a, b btree.Entry) int {
+	x, y := a.(testEntry).value, b.(testEntry).value
+
+	switch {
+	case x < y:
+		return -1
+
+	case x > y:
+		return 1
+
+	default:
+		return 0
+	}
+}
+
+func TestWithDescending(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree, btree.WithDescending())
+			require.NoError(t, err)
+
+			for _, i := range rng.Perm(1000) {
+				bt.Insert(testEntry{key: uint64(i), value: rng.Uint64()})
+			}
+
+			require.NoError(t, bt.Verify())
+			require.Equal(t, uint64(999), bt.Min().(testEntry).key)
+			require.Equal(t, uint64(0), bt.Max().(testEntry).key)
+
+			want := uint64(999)
+			bt.Ascend(func(e btree.Entry) bool {
+				require.Equal(t, want, e.(testEntry).key)
+				want--
+				return true
+			})
+
+			// ranges run from high to low as well
+			var got []uint64
+			bt.AscendRange(testEntry{key: 500}, testEntry{key: 495}, func(e btree.Entry) bool {
+				got = append(got, e.(testEntry).key)
+				return true
+			})
+			require.Equal(t, []uint64{500, 499, 498, 497, 496}, got)
+
+			require.Equal(t, uint64(999), bt.DeleteMin().(testEntry).key)
+			require.NotNil(t, bt.Search(testEntry{key: 42}))
+			require.NoError(t, bt.Verify())
+
+			// options compose with a custom comparator
+			byValue, err := btree.NewWithComparator(minDegree, compareValues, btree.WithDescending())
+			require.NoError(t, err)
+
+			for i := uint64(0); i < 100; i++ {
+				byValue.Insert(testEntry{key: i, value: i * 10})
+			}
+
+			require.NoError(t, byValue.Verify())
+			require.Equal(t, uint64(990), byValue.Min().(testEntry).value)
+		})
+	}
+}
+
+func TestWithNoLocking(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree, btree.WithNoLocking())
+			require.NoError(t, err)
+
+			for _, i := range rng.Perm(1000) {
+				bt.Insert(testEntry{key: uint64(i)})
+			}
+
+			require.NoError(t, bt.Verify())
+			require.Equal(t, 1000, bt.Size())
+
+			// callbacks may reenter the BTree, as no lock is held
+			bt.Ascend(func(e btree.Entry) bool {
+				require.True(t, bt.Has(e))
+				return true
+			})
+
+			clone := bt.Clone()
+			for i := uint64(0); i < 500; i++ {
+				require.NotNil(t, bt.Delete(testEntry{key: i}))
+			}
+
+			require.NoError(t, bt.Verify())
+			require.NoError(t, clone.Verify())
+			require.Equal(t, 500, bt.Size())
+			require.Equal(t, 1000, clone.Size())
+		})
+	}
+}
+
+func TestBTreeFixedKey(t *testing.T) {
+	_, err := btree.NewFixedKey(1, 8, testEntryKey)
+	require.Error(t, err)
+
+	_, err = btree.NewFixedKey(2, 0, testEntryKey)
+	require.Error(t, err)
+
+	_, err = btree.NewFixedKey(2, 8, nil)
+	require.Error(t, err)
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			fixed, err := btree.NewFixedKey(minDegree, 8, testEntryKey)
+			require.NoError(t, err)
+
+			bt, err := btree.New(minDegree)
+			require.NoError(t, err)
+
+			for i := 0; i < 5000; i++ {
+				e := testEntry{rng.Uint64(), rng.Uint64()}
+				fixed.Insert(e)
+				bt.Insert(e)
+			}
+
+			require.NoError(t, fixed.Verify())
+			require.Equal(t, bt.Size(), fixed.Size())
+
+			var want, got []btree.Entry
+			bt.RangeReverse(nil, nil, func(e btree.Entry) bool {
+				want = append(want, e)
+				return true
+			})
+			fixed.RangeReverse(nil, nil, func(e btree.Entry) bool {
+				got = append(got, e)
+				return true
+			})
+			require.Equal(t, want, got)
+
+			for _, e := range want {
+				require.Equal(t, e, fixed.Search(e))
+			}
+		})
+	}
+
+	t.Run("wrong key length", func(t *testing.T) {
+		fixed, err := btree.NewFixedKey(2, 8, func(e btree.Entry) []byte {
+			return bytes.TrimLeft(testEntryKey(e), "\x00")
+		})
+		require.NoError(t, err)
+
+		fixed.Insert(testEntry{key: 1 << 63})
+		require.PanicsWithError(t, "invalid key length: expected 8 bytes, got 1", func() {
+			fixed.Insert(testEntry{key: 1})
+		})
+		require.Equal(t, 1, fixed.Size())
+
+		// writes with an error return report the wrong length instead
+		err = fixed.InsertChecked(testEntry{key: 1})
+		require.True(t, errors.Is(err, btree.ErrKeyLength))
+		require.EqualError(t, err, "invalid key length: expected 8 bytes, got 1")
+		require.True(t, errors.Is(fixed.InsertCtx(context.Background(), testEntry{key: 2}), btree.ErrKeyLength))
+		require.NoError(t, fixed.InsertChecked(nil))
+		require.NoError(t, fixed.InsertChecked(testEntry{key: 1<<63 | 1}))
+		require.Equal(t, 2, fixed.Size())
+		require.NoError(t, fixed.Verify())
+	})
+}
+
+func TestBulkLoad(t *testing.T) {
+	_, err := btree.BulkLoad(nil, 1)
+	require.Error(t, err)
+
+	_, err = btree.BulkLoad([]btree.Entry{testEntry{key: 1}, nil}, 2)
+	require.EqualError(t, err, "entry at index 1 is nil")
+
+	_, err = btree.BulkLoad([]btree.Entry{testEntry{key: 1}, testEntry{key: 3}, testEntry{key: 3}}, 2)
+	require.True(t, errors.Is(err, btree.ErrUnsorted))
+
+	_, err = btree.BulkLoad([]btree.Entry{testEntry{key: 2}, testEntry{key: 1}}, 2)
+	require.True(t, errors.Is(err, btree.ErrUnsorted))
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			// cover every size up to a few levels, including those that exactly
+			// fill a height
+			for n := 0; n < 2000; n += 1 + rng.Intn(7) {
+				sorted := make([]btree.Entry, n)
+				for i := range sorted {
+					sorted[i] = testEntry{key: uint64(i)}
+				}
+
+				bt, err := btree.BulkLoad(sorted, minDegree)
+				require.NoError(t, err)
+				require.NoError(t, bt.Verify())
+				require.Equal(t, n, bt.Size())
+				require.Equal(t, sorted, append([]btree.Entry{}, bt.Entries(nil, nil)...))
+
+				// the loaded tree is fully usable
+				bt.Insert(testEntry{key: uint64(n)})
+				bt.Delete(testEntry{key: uint64(n / 2)})
+				require.Equal(t, n, bt.Size())
+				require.NoError(t, bt.Verify())
+			}
+
+			// a single leaf root is packed to capacity, and keeps growing by
+			// splitting rather than overflowing
+			for _, n := range []int{1, 2*minDegree - 2, 2*minDegree - 1} {
+				requireGrows(t, newPackedBTree(t, minDegree, n))
+			}
+		})
+	}
+}
+
+func TestInsertDeleteCtx(t *testing.T) {
+	bt, err := btree.New(3)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, bt.InsertCtx(ctx, testEntry{key: 1}))
+	require.NoError(t, bt.InsertCtx(ctx, nil))
+	require.Equal(t, 1, bt.Size())
+
+	removed, err := bt.DeleteCtx(ctx, testEntry{key: 1})
+	require.NoError(t, err)
+	require.Equal(t, testEntry{key: 1}, removed)
+
+	// a done context fails without touching the BTree
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	require.True(t, errors.Is(bt.InsertCtx(canceled, testEntry{key: 2}), context.Canceled))
+	_, err = bt.DeleteCtx(canceled, testEntry{key: 2})
+	require.True(t, errors.Is(err, context.Canceled))
+	require.Zero(t, bt.Size())
+
+	// while a write holds the lock, a deadline expires, while waiting without
+	// one succeeds once the lock is released
+	bt.Insert(testEntry{key: 3})
+
+	var wg sync.WaitGroup
+	bt.Modify(testEntry{key: 3}, func(old btree.Entry) btree.Entry {
+		short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		require.True(t, errors.Is(bt.InsertCtx(short, testEntry{key: 4}), context.DeadlineExceeded))
+		_, err := bt.DeleteCtx(short, testEntry{key: 3})
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, bt.InsertCtx(ctx, testEntry{key: 5}))
+		}()
+
+		return old
+	})
+
+	wg.Wait()
+
+	require.Equal(t, []btree.Entry{testEntry{key: 3}, testEntry{key: 5}}, bt.Entries(nil, nil))
+	require.NoError(t, bt.Verify())
+}
+
+func TestApply(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, _ := newTestBTree(t, minDegree, 1000)
+			bt.Apply(nil)
+
+			var wb btree.WriteBatch
+			bt.Apply(&wb)
+			require.Equal(t, 1000, bt.Size())
+
+			// writes apply in order, so a later write to the same Entry wins
+			for i := uint64(0); i < 1000; i += 2 {
+				wb.Delete(testEntry{key: i})
+			}
+
+			wb.Insert(testEntry{key: 1000, value: 1})
+			wb.Delete(testEntry{key: 1000})
+			wb.Insert(testEntry{key: 1001, value: 1})
+			wb.Insert(testEntry{key: 1001, value: 2})
+			wb.Insert(nil)
+			wb.Delete(nil)
+			require.Equal(t, 504, wb.Len())
+
+			bt.Apply(&wb)
+			require.NoError(t, bt.Verify())
+			require.Equal(t, 501, bt.Size())
+			require.Nil(t, bt.Search(testEntry{key: 1000}))
+			require.Equal(t, testEntry{key: 1001, value: 2}, bt.Search(testEntry{key: 1001}))
+			require.False(t, bt.Has(testEntry{key: 0}))
+			require.True(t, bt.Has(testEntry{key: 1}))
+
+			// the batch may be applied again
+			other, _ := newTestBTree(t, minDegree, 10)
+			other.Apply(&wb)
+			require.Equal(t, 6, other.Size())
+
+			wb.Reset()
+			require.Zero(t, wb.Len())
+		})
+	}
+
+	// a batch with an invalid Entry is rejected as a whole
+	bt, err := btree.NewFixedKey(2, 8, func(e btree.Entry) []byte {
+		if e.(testEntry).value == 1 {
+			return nil
+		}
+
+		return testEntryKey(e)
+	})
+	require.NoError(t, err)
+
+	var wb btree.WriteBatch
+	wb.Insert(testEntry{key: 1})
+	wb.Insert(testEntry{key: 2, value: 1})
+	require.Panics(t, func() { bt.Apply(&wb) })
+	require.Zero(t, bt.Size())
+}
+
+func TestBulkLoadParallel(t *testing.T) {
+	const n = 200000
+
+	sorted := make([]btree.Entry, n)
+	for i := range sorted {
+		sorted[i] = testEntry{key: uint64(i)}
+	}
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			serial, err := btree.BulkLoad(sorted, minDegree)
+			require.NoError(t, err)
+
+			for _, workers := range []int{0, 1, 3, 64} {
+				bt, err := btree.BulkLoadParallel(sorted, minDegree, workers)
+				require.NoError(t, err)
+				require.NoError(t, bt.Verify())
+				require.Equal(t, n, bt.Size())
+				require.Equal(t, serial.Entries(nil, nil), bt.Entries(nil, nil))
+
+				// the loaded tree is fully usable
+				for i := 0; i < n; i += 1000 {
+					bt.Delete(testEntry{key: uint64(i)})
+				}
+
+				bt.Insert(testEntry{key: n})
+				require.Equal(t, n-n/1000+1, bt.Size())
+				require.NoError(t, bt.Verify())
+			}
+		})
+	}
+
+	// the violation at the smallest index is reported, whichever goroutine finds
+	// it first
+	unsorted := append([]btree.Entry(nil), sorted...)
+	unsorted[150000], unsorted[150001] = unsorted[150001], unsorted[150000]
+	unsorted[60000] = nil
+
+	_, err := btree.BulkLoadParallel(unsorted, 3, 8)
+	require.EqualError(t, err, "entry at index 60000 is nil")
+
+	unsorted[60000] = sorted[60000]
+	_, err = btree.BulkLoadParallel(unsorted, 3, 8)
+	require.True(t, errors.Is(err, btree.ErrUnsorted))
+	require.Contains(t, err.Error(), "index 150001")
+}
+
+func TestNewFromSlice(t *testing.T) {
+	_, err := btree.NewFromSlice(1, nil)
+	require.Error(t, err)
+
+	bt, err := btree.NewFromSlice(3, nil)
+	require.NoError(t, err)
+	require.Zero(t, bt.Size())
+
+	// duplicate keys keep the last entry, as repeated inserts would
+	const n = 1000
+	es := make([]btree.Entry, 0, 3*n+1)
+	contents := make(map[uint64]testEntry, n)
+
+	for i := 0; i < 3*n; i++ {
+		e := testEntry{uint64(rng.Intn(n)), rng.Uint64()}
+		es = append(es, e)
+		contents[e.key] = e
+	}
+
+	es = append(es, nil)
+	unsorted := append([]btree.Entry(nil), es...)
+
+	bt, err = btree.NewFromSlice(3, es)
+	require.NoError(t, err)
+	require.Equal(t, unsorted, es)
+	require.NoError(t, bt.Verify())
+	require.Equal(t, len(contents), bt.Size())
+
+	for _, e := range contents {
+		require.Equal(t, e, bt.Search(e))
+	}
+}
+
+func TestNewFromMap(t *testing.T) {
+	m := make(map[uint64]uint64, 1000)
+	for i := 0; i < 1000; i++ {
+		m[uint64(i)] = rng.Uint64()
+	}
+
+	bt, err := btree.NewFromMap(4, m, func(k, v uint64) btree.Entry {
+		if k%10 == 0 {
+			return nil
+		}
+
+		return testEntry{k, v}
+	})
+	require.NoError(t, err)
+	require.NoError(t, bt.Verify())
+	require.Equal(t, 900, bt.Size())
+
+	for k, v := range m {
+		if k%10 == 0 {
+			require.False(t, bt.Has(testEntry{key: k}))
+		} else {
+			require.Equal(t, testEntry{k, v}, bt.Search(testEntry{key: k}))
+		}
+	}
+}
+
+func BenchmarkBulkLoad(b *testing.B) {
+	sorted := make([]btree.Entry, 1000000)
+	for i := range sorted {
+		sorted[i] = testEntry{key: uint64(i)}
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := btree.BulkLoad(sorted, 17); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBulkLoadParallel(b *testing.B) {
+	sorted := make([]btree.Entry, 1000000)
+	for i := range sorted {
+		sorted[i] = testEntry{key: uint64(i)}
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := btree.BulkLoadParallel(sorted, 17, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkInsert(b *testing.B, minDegree int, opts ...btree.Option) {
+	bt, err := btree.New(minDegree, opts...)
 	require.NoError(b, err)
 	require.NotNil(b, bt)
 
@@ -100,3 +2277,7 @@ func BenchmarkInsert17(b *testing.B) {
 	benchmarkInsert(b, 24)
 	benchmarkInsert(b, 48)
 }
+
+func BenchmarkInsertNoLocking(b *testing.B) {
+	benchmarkInsert(b, 17, btree.WithNoLocking())
+}
@@ -0,0 +1,433 @@
+package btree
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// BulkLoad returns a reference to a new B-Tree with a minimum degree t holding
+// the provided entries, which must be sorted in strictly ascending order. The
+// tree is built bottom-up in linear time, which is far cheaper than inserting
+// the entries one by one. The entries are spread evenly across the fewest
+// possible nodes, so that every node is close to its maximum of 2t-1 entries.
+// An error is returned if t is invalid or if the entries are not strictly
+// ascending or contain nil.
+func BulkLoad(sorted []Entry, t int) (*BTree, error) {
+	return BulkLoadParallel(sorted, t, 1)
+}
+
+// BulkLoadParallel returns a reference to a new B-Tree built as with BulkLoad,
+// but validating the entries and building the subtrees below the root on up to
+// workers goroutines at once, so that loading very large inputs is not bound
+// by a single core. If workers is less than one, GOMAXPROCS goroutines are
+// used. The result is identical to that of BulkLoad.
+func BulkLoadParallel(sorted []Entry, t, workers int) (*BTree, error) {
+	bt, err := New(t)
+	if err != nil {
+		return nil, err
+	}
+
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	if err := bt.checkSorted(sorted, workers); err != nil {
+		return nil, err
+	}
+
+	bt.loadParallel(sorted, workers)
+	return bt, nil
+}
+
+// checkSorted returns an error if the given entries contain nil or are not in
+// strictly ascending order, reporting the violation at the smallest index. The
+// entries are checked in contiguous chunks on up to workers goroutines.
+func (bt *BTree) checkSorted(sorted []Entry, workers int) error {
+	check := func(from, to int) error {
+		for i := from; i < to; i++ {
+			if sorted[i] == nil {
+				return fmt.Errorf("entry at index %d is nil", i)
+			}
+
+			if i > 0 && sorted[i-1] != nil && bt.cmp(sorted[i-1], sorted[i]) >= 0 {
+				return fmt.Errorf("%w: entry at index %d is not greater than its predecessor", ErrUnsorted, i)
+			}
+		}
+
+		return nil
+	}
+
+	chunk := (len(sorted) + workers - 1) / workers
+	if workers == 1 || chunk < minParallelChunk {
+		return check(0, len(sorted))
+	}
+
+	errs := make([]error, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		from, to := min(w*chunk, len(sorted)), min((w+1)*chunk, len(sorted))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[w] = check(from, to)
+		}()
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// minParallelChunk defines the fewest entries worth handing to a goroutine of
+// its own when bulk loading or scanning in parallel.
+const minParallelChunk = 1 << 14
+
+// NewFromSlice returns a reference to a new B-Tree with a minimum degree t
+// holding the provided entries in any order. The entries are sorted and bulk
+// loaded, which is considerably faster than inserting them one by one. As with
+// Insert, nil entries are skipped and of several equal entries the last one
+// wins. The provided slice is not modified.
+func NewFromSlice(t int, es []Entry) (*BTree, error) {
+	sorted := make([]Entry, 0, len(es))
+	for _, e := range es {
+		if e != nil {
+			sorted = append(sorted, e)
+		}
+	}
+
+	return bulkLoadUnsorted(t, sorted)
+}
+
+// NewFromMap returns a reference to a new B-Tree with a minimum degree t holding
+// the entries returned by entry for every key-value pair of the provided map.
+// The entries are sorted and bulk loaded as with NewFromSlice. Nil entries are
+// skipped, and if several pairs yield equal entries, it is unspecified which of
+// them is kept.
+func NewFromMap[K comparable, V any](t int, m map[K]V, entry func(K, V) Entry) (*BTree, error) {
+	sorted := make([]Entry, 0, len(m))
+	for k, v := range m {
+		if e := entry(k, v); e != nil {
+			sorted = append(sorted, e)
+		}
+	}
+
+	return bulkLoadUnsorted(t, sorted)
+}
+
+// bulkLoadUnsorted sorts the given non-nil entries in place, keeping only the
+// last of several equal entries, and bulk loads them into a new B-Tree with a
+// minimum degree t.
+func bulkLoadUnsorted(t int, es []Entry) (*BTree, error) {
+	sort.SliceStable(es, func(i, j int) bool {
+		return compareEntries(es[i], es[j]) < 0
+	})
+
+	// compact the entries in place, letting the last of every run of equal
+	// entries overwrite the preceding ones
+	n := 0
+	for i, e := range es {
+		if i > 0 && compareEntries(es[n-1], e) == 0 {
+			es[n-1] = e
+			continue
+		}
+
+		es[n] = e
+		n++
+	}
+
+	return BulkLoad(es[:n], t)
+}
+
+// newLike returns a new, empty BTree sharing the minimum degree, ordering,
+// validation, codecs and locking of the BTree.
+func (bt *BTree) newLike() *BTree {
+	cow := &copyOnWriteContext{freeList: &freeList{}}
+
+	like := &BTree{
+		mu:        bt.newLocker(),
+		root:      cow.newNode(),
+		minDegree: bt.minDegree,
+		depth:     1,
+		cmp:       bt.cmp,
+		validate:  bt.validate,
+		codec:     bt.codec,
+		jsonCodec: bt.jsonCodec,
+		cow:       cow,
+		snapshots: bt.snapshots,
+		history:   bt.history.newLike(),
+	}
+
+	if like.snapshots {
+		like.publish()
+	}
+
+	return like
+}
+
+// load builds the contents of the BTree, which must be empty and not yet shared
+// but for its snapshot, bottom-up from the given entries in strictly ascending
+// order, and publishes them.
+func (bt *BTree) load(sorted []Entry) {
+	bt.loadParallel(sorted, 1)
+}
+
+// loadParallel loads the BTree as load does, building subtrees on up to workers
+// goroutines at once.
+func (bt *BTree) loadParallel(sorted []Entry, workers int) {
+	if len(sorted) == 0 {
+		return
+	}
+
+	// find the smallest height whose full subtrees can hold every entry, where
+	// a full subtree of height h holds (2t)^h - 1 entries
+	t := bt.minDegree
+	h, capacity := 1, 2*t
+	for capacity-1 < len(sorted) {
+		h++
+		capacity *= 2 * t
+	}
+
+	bt.cow.freeNode(bt.root)
+	bt.root = bt.cow.buildParallel(t, sorted, h, workers)
+	bt.size = len(sorted)
+	bt.depth = h
+	bt.mods++
+
+	if bt.snapshots {
+		bt.publish()
+	}
+}
+
+// build returns the root of a subtree of height h holding the given sorted
+// entries, which must fit into a full subtree of that height. The entries are
+// divided between the fewest children that can hold them, and as evenly as
+// possible, so that every node below the root holds at least t-1 entries.
+func (c *copyOnWriteContext) build(t int, entries Entries, h int) *node {
+	n := c.newNode()
+	n.count = len(entries)
+
+	if h == 1 {
+		n.entries = append(n.entries, entries...)
+		return n
+	}
+
+	numChildren, perChild, extra := divide(t, len(entries), h)
+
+	for i := 0; i < numChildren; i++ {
+		size := perChild
+		if i < extra {
+			size++
+		}
+
+		n.children = append(n.children, c.build(t, entries[:size], h-1))
+		entries = entries[size:]
+
+		if i < numChildren-1 {
+			n.entries = append(n.entries, entries[0])
+			entries = entries[1:]
+		}
+	}
+
+	return n
+}
+
+// divide returns how a subtree of height h holding n entries is divided: each
+// of its numChildren children receives perChild entries, the first extra
+// children receive one more, and a separator follows every child but the last.
+func divide(t, n, h int) (numChildren, perChild, extra int) {
+	// the capacity of a full subtree of height h-1, plus one for its separator
+	childCapacity := 1
+	for i := 1; i < h; i++ {
+		childCapacity *= 2 * t
+	}
+
+	numChildren = (n + childCapacity) / childCapacity
+	perChild = (n - (numChildren - 1)) / numChildren
+	extra = (n - (numChildren - 1)) % numChildren
+
+	return numChildren, perChild, extra
+}
+
+// buildParallel returns the same subtree as build, building its children on up
+// to workers goroutines at once. If there are more workers than children, the
+// children share the workers among their own children in turn. Contexts are
+// not safe for concurrent use, so every goroutine allocates its nodes from a
+// context of its own and hands them over to c once done.
+func (c *copyOnWriteContext) buildParallel(t int, entries Entries, h, workers int) *node {
+	if workers <= 1 || h == 1 || len(entries) < minParallelChunk {
+		return c.build(t, entries, h)
+	}
+
+	n := c.newNode()
+	n.count = len(entries)
+
+	numChildren, perChild, extra := divide(t, len(entries), h)
+	n.children = append(n.children, make(nodes, numChildren)...)
+
+	parts := make([]Entries, numChildren)
+	for i := range parts {
+		size := perChild
+		if i < extra {
+			size++
+		}
+
+		parts[i] = entries[:size]
+		entries = entries[size:]
+
+		if i < numChildren-1 {
+			n.entries = append(n.entries, entries[0])
+			entries = entries[1:]
+		}
+	}
+
+	// every goroutine builds every groups-th child, and the first
+	// workers%numChildren children receive one of the spare workers
+	groups := min(workers, numChildren)
+
+	var wg sync.WaitGroup
+	for g := 0; g < groups; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			local := &copyOnWriteContext{freeList: &freeList{}}
+			for i := g; i < numChildren; i += groups {
+				childWorkers := workers / numChildren
+				if i < workers%numChildren {
+					childWorkers++
+				}
+
+				n.children[i] = local.buildParallel(t, parts[i], h-1, childWorkers)
+				n.children[i].reown(local, c)
+			}
+		}()
+	}
+
+	wg.Wait()
+	return n
+}
+
+// reown hands every node of the subtree rooted at n that is owned by from over
+// to the context to.
+func (n *node) reown(from, to *copyOnWriteContext) {
+	if n.cow != from {
+		return
+	}
+
+	n.cow = to
+	for _, child := range n.children {
+		child.reown(from, to)
+	}
+}
+
+// builder assembles a BTree bottom-up from a stream of entries in strictly
+// ascending order whose number is not known in advance. It keeps the rightmost
+// node of every level open, packing each to capacity before starting its right
+// sibling, and rebalances the rightmost spine once the stream ends.
+type builder struct {
+	bt *BTree
+
+	// levels holds the open node of every level, with the leaf first
+	levels nodes
+}
+
+// newBuilder returns a builder filling the given BTree, which must be empty and
+// not yet shared but for its snapshot, whose root is copied rather than filled.
+func newBuilder(bt *BTree) *builder {
+	return &builder{bt: bt, levels: nodes{bt.root.mutableFor(bt.cow)}}
+}
+
+// add appends the given entry, which must be greater than every entry added
+// before.
+func (b *builder) add(e Entry) {
+	b.bt.size++
+	b.push(0, e, nil)
+}
+
+// push appends the given entry, and for internal levels the child following
+// it, to the open node at the given level. If that node is full, it is closed
+// and the entry instead separates it from a new open sibling one level up.
+func (b *builder) push(level int, e Entry, child *node) {
+	n := b.levels[level]
+
+	if !b.bt.nodeFull(n) {
+		n.entries = append(n.entries, e)
+		if child != nil {
+			n.children = append(n.children, child)
+		}
+
+		return
+	}
+
+	// every child of n is closed by now, so its count is final
+	n.recount()
+
+	sibling := b.bt.cow.newNode()
+	if child != nil {
+		sibling.children = append(sibling.children, child)
+	}
+
+	if level+1 == len(b.levels) {
+		parent := b.bt.cow.newNode()
+		parent.children = append(parent.children, n)
+		b.levels = append(b.levels, parent)
+	}
+
+	b.levels[level] = sibling
+	b.push(level+1, e, sibling)
+}
+
+// finish completes the BTree and publishes it. Every closed node is full, so
+// each open node on the rightmost spine that holds fewer than t-1 entries can
+// borrow them from its left sibling through the parent, working downwards from
+// the root.
+func (b *builder) finish() {
+	// an open root without entries only holds the start of the next level down
+	for top := len(b.levels) - 1; top > 0 && b.levels[top].numEntries() == 0; top-- {
+		b.bt.cow.freeNode(b.levels[top])
+		b.levels = b.levels[:top]
+	}
+
+	t := b.bt.minDegree
+	for level := len(b.levels) - 2; level >= 0; level-- {
+		parent, right := b.levels[level+1], b.levels[level]
+		if right.numEntries() >= t-1 {
+			continue
+		}
+
+		i := parent.numEntries() - 1
+		left := parent.children[i]
+
+		for right.numEntries() < t-1 {
+			right.insertAt(0, parent.entries[i])
+			parent.entries[i] = left.removeAt(left.numEntries() - 1)
+
+			if !left.leaf() {
+				right.insertChildAt(0, left.removeChildAt(left.numChildren()-1))
+			}
+		}
+
+		left.recount()
+	}
+
+	for _, n := range b.levels {
+		n.recount()
+	}
+
+	b.bt.root = b.levels[len(b.levels)-1]
+	b.bt.depth = len(b.levels)
+
+	if b.bt.snapshots {
+		b.bt.publish()
+	}
+}
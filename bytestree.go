@@ -0,0 +1,484 @@
+package btree
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+type (
+	// BytesTree implements a thread-safe B-Tree specialized for byte-string keys
+	// ordered by bytes.Compare, each carrying an optional value blob. Every node
+	// stores its keys back to back in a single buffer, along with the offsets at
+	// which they end, so that keys take neither a slice header nor an allocation
+	// of their own, and nodes are searched by comparing slices of that buffer.
+	// Keys are copied into the buffer and values into a blob of their own when
+	// set, so callers may reuse their buffers, while the slices handed out by the
+	// tree must not be modified.
+	BytesTree struct {
+		mu   sync.RWMutex
+		t    int
+		root *bnode
+		size int
+	}
+
+	// bnode defines a node of a BytesTree. The key at index i occupies
+	// keys[ends[i-1]:ends[i]], where ends[-1] is taken to be zero, and
+	// values[i] is stored under it.
+	bnode struct {
+		keys     []byte
+		ends     []uint32
+		values   [][]byte
+		children []*bnode
+	}
+)
+
+// NewBytesTree returns a reference to a new, empty BytesTree with a minimum
+// degree t.
+func NewBytesTree(t int) (*BytesTree, error) {
+	if t < 2 {
+		return nil, fmt.Errorf("minimum degree must be at least two: %d", t)
+	}
+
+	return &BytesTree{t: t, root: &bnode{}}, nil
+}
+
+// Len returns the number of keys in the BytesTree.
+func (b *BytesTree) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.size
+}
+
+// Get returns the value stored under the given key and whether it exists.
+func (b *BytesTree) Get(key []byte) ([]byte, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	n := b.root
+	for {
+		i, found := n.search(key)
+		if found {
+			return n.values[i], true
+		}
+
+		if n.leaf() {
+			return nil, false
+		}
+
+		n = n.children[i]
+	}
+}
+
+// Has returns true if the given key exists.
+func (b *BytesTree) Has(key []byte) bool {
+	_, ok := b.Get(key)
+	return ok
+}
+
+// Set stores a copy of the value, which may be nil, under a copy of the given
+// key, returning the value it replaced, if any, and whether one was replaced.
+func (b *BytesTree) Set(key, value []byte) (old []byte, replaced bool) {
+	value = bytes.Clone(value)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.root.numKeys() == 2*b.t-1 {
+		root := &bnode{children: []*bnode{b.root}}
+		root.splitChild(0, b.t)
+		b.root = root
+	}
+
+	n := b.root
+	for {
+		i, found := n.search(key)
+		if found {
+			old, n.values[i] = n.values[i], value
+			return old, true
+		}
+
+		if n.leaf() {
+			n.insertAt(i, key, value)
+			b.size++
+			return nil, false
+		}
+
+		if n.children[i].numKeys() == 2*b.t-1 {
+			n.splitChild(i, b.t)
+
+			switch c := bytes.Compare(key, n.key(i)); {
+			case c > 0:
+				i++
+
+			case c == 0:
+				// the key is the median which was just moved into n
+				old, n.values[i] = n.values[i], value
+				return old, true
+			}
+		}
+
+		n = n.children[i]
+	}
+}
+
+// Delete removes the given key, returning the value stored under it, if any,
+// and whether it existed.
+func (b *BytesTree) Delete(key []byte) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.size == 0 {
+		return nil, false
+	}
+
+	value, ok := b.root.remove(b.t, key)
+	if b.root.numKeys() == 0 && !b.root.leaf() {
+		b.root = b.root.children[0]
+	}
+
+	if ok {
+		b.size--
+	}
+
+	return value, ok
+}
+
+// Min returns a copy of the smallest key along with its value, or false if the
+// BytesTree is empty.
+func (b *BytesTree) Min() (key, value []byte, ok bool) {
+	return b.edge(func(n *bnode) int { return 0 })
+}
+
+// Max returns a copy of the largest key along with its value, or false if the
+// BytesTree is empty.
+func (b *BytesTree) Max() (key, value []byte, ok bool) {
+	return b.edge(func(n *bnode) int { return n.numKeys() })
+}
+
+// edge descends to a leaf through the child whose index pick returns for every
+// node, and returns the key of that leaf at the same index, or its last key if
+// the index is out of range.
+func (b *BytesTree) edge(pick func(*bnode) int) (key, value []byte, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.size == 0 {
+		return nil, nil, false
+	}
+
+	n := b.root
+	for !n.leaf() {
+		n = n.children[pick(n)]
+	}
+
+	i := min(pick(n), n.numKeys()-1)
+	return bytes.Clone(n.key(i)), n.values[i], true
+}
+
+// Ascend calls fn for every key-value pair in ascending key order, under the
+// read lock. The key passed to fn is a slice of the buffer of its node, which
+// is only valid until fn returns. The traversal stops as soon as fn returns
+// false.
+func (b *BytesTree) Ascend(fn func(key, value []byte) bool) {
+	b.AscendRange(nil, nil, fn)
+}
+
+// AscendRange calls fn for every key-value pair whose key k satisfies
+// from <= k < to, in ascending key order, as Ascend does. A nil from or to
+// leaves the respective end of the range unbounded, whereas an empty but
+// non-nil one does not.
+func (b *BytesTree) AscendRange(from, to []byte, fn func(key, value []byte) bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	b.root.ascend(from, to, fn)
+}
+
+// AscendPrefix calls fn for every key-value pair whose key starts with the
+// given prefix, in ascending key order, as Ascend does.
+func (b *BytesTree) AscendPrefix(prefix []byte, fn func(key, value []byte) bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	b.root.ascend(prefix, nil, func(k, v []byte) bool {
+		return bytes.HasPrefix(k, prefix) && fn(k, v)
+	})
+}
+
+// Descend calls fn for every key-value pair in descending key order, as Ascend
+// does.
+func (b *BytesTree) Descend(fn func(key, value []byte) bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	b.root.descend(fn)
+}
+
+func (n *bnode) leaf() bool {
+	return len(n.children) == 0
+}
+
+func (n *bnode) numKeys() int {
+	return len(n.ends)
+}
+
+// start returns the offset at which the key at index i starts.
+func (n *bnode) start(i int) int {
+	if i == 0 {
+		return 0
+	}
+
+	return int(n.ends[i-1])
+}
+
+// key returns the key at index i as a slice of the buffer of n, which is only
+// valid until n is next modified.
+func (n *bnode) key(i int) []byte {
+	s, e := n.start(i), int(n.ends[i])
+	return n.keys[s:e:e]
+}
+
+// search returns the smallest index i, s.t. the key at index i is at least k,
+// and whether it equals k.
+func (n *bnode) search(k []byte) (int, bool) {
+	i := sort.Search(n.numKeys(), func(i int) bool {
+		return bytes.Compare(n.key(i), k) >= 0
+	})
+
+	return i, i < n.numKeys() && bytes.Equal(n.key(i), k)
+}
+
+// shift adds d to the ends of the keys from index i on.
+func (n *bnode) shift(i, d int) {
+	for j := i; j < len(n.ends); j++ {
+		n.ends[j] = uint32(int(n.ends[j]) + d)
+	}
+}
+
+// insertAt inserts a copy of k, which must not be a slice of the buffer of n,
+// along with v at index i.
+func (n *bnode) insertAt(i int, k, v []byte) {
+	s := n.start(i)
+
+	n.keys = slices.Insert(n.keys, s, k...)
+	n.ends = slices.Insert(n.ends, i, uint32(s))
+	n.shift(i, len(k))
+	n.values = slices.Insert(n.values, i, v)
+}
+
+// setAt replaces the key at index i by a copy of k, which must not be a slice
+// of the buffer of n, and its value by v.
+func (n *bnode) setAt(i int, k, v []byte) {
+	s, e := n.start(i), int(n.ends[i])
+
+	n.keys = slices.Replace(n.keys, s, e, k...)
+	n.shift(i, len(k)-(e-s))
+	n.values[i] = v
+}
+
+// removeAt removes the key at index i, returning its value.
+func (n *bnode) removeAt(i int) []byte {
+	s, e := n.start(i), int(n.ends[i])
+	v := n.values[i]
+
+	n.keys = slices.Delete(n.keys, s, e)
+	n.ends = slices.Delete(n.ends, i, i+1)
+	n.shift(i, s-e)
+	n.values = slices.Delete(n.values, i, i+1)
+
+	return v
+}
+
+// appendAll appends every key of other, along with its value, to n.
+func (n *bnode) appendAll(other *bnode) {
+	base := uint32(len(n.keys))
+
+	n.keys = append(n.keys, other.keys...)
+	for _, e := range other.ends {
+		n.ends = append(n.ends, base+e)
+	}
+
+	n.values = append(n.values, other.values...)
+}
+
+// splitChild splits the full child at index i around its median, which moves up
+// into n between the two halves.
+func (n *bnode) splitChild(i, t int) {
+	child := n.children[i]
+	mid, split := child.start(t-1), int(child.ends[t-1])
+
+	right := &bnode{values: slices.Clone(child.values[t:])}
+	right.keys = append(right.keys, child.keys[split:]...)
+	for _, e := range child.ends[t:] {
+		right.ends = append(right.ends, e-uint32(split))
+	}
+
+	if !child.leaf() {
+		right.children = slices.Clone(child.children[t:])
+		child.children = slices.Delete(child.children, t, len(child.children))
+	}
+
+	n.insertAt(i, child.keys[mid:split], child.values[t-1])
+	n.children = slices.Insert(n.children, i+1, right)
+
+	child.keys = child.keys[:mid]
+	child.ends = child.ends[:t-1]
+	child.values = slices.Delete(child.values, t-1, len(child.values))
+}
+
+// remove removes k from the subtree rooted at n, which must hold at least t
+// keys unless it is the root, returning its value and whether it existed.
+func (n *bnode) remove(t int, k []byte) ([]byte, bool) {
+	i, found := n.search(k)
+	if n.leaf() {
+		if !found {
+			return nil, false
+		}
+
+		return n.removeAt(i), true
+	}
+
+	if n.children[i].numKeys() <= t-1 {
+		n.growChild(i, t)
+		return n.remove(t, k)
+	}
+
+	if found {
+		// the key lives in n, so it is replaced by its predecessor, which is
+		// copied into n before it is removed from its leaf
+		v := n.values[i]
+
+		pred := n.children[i]
+		for !pred.leaf() {
+			pred = pred.children[len(pred.children)-1]
+		}
+
+		last := pred.numKeys() - 1
+		n.setAt(i, pred.key(last), pred.values[last])
+		n.children[i].removeMax(t)
+
+		return v, true
+	}
+
+	return n.children[i].remove(t, k)
+}
+
+// removeMax removes the largest key from the subtree rooted at n, which must
+// hold at least t keys.
+func (n *bnode) removeMax(t int) {
+	if n.leaf() {
+		n.removeAt(n.numKeys() - 1)
+		return
+	}
+
+	i := n.numKeys()
+	if n.children[i].numKeys() <= t-1 {
+		n.growChild(i, t)
+		n.removeMax(t)
+		return
+	}
+
+	n.children[i].removeMax(t)
+}
+
+// growChild ensures the child at index i holds at least t keys, by borrowing a
+// key from a sibling through n or, if neither sibling can spare one, by merging
+// the child with a sibling around their separator.
+func (n *bnode) growChild(i, t int) {
+	child := n.children[i]
+
+	switch {
+	case i > 0 && n.children[i-1].numKeys() > t-1:
+		left := n.children[i-1]
+		last := left.numKeys() - 1
+
+		child.insertAt(0, n.key(i-1), n.values[i-1])
+		n.setAt(i-1, left.key(last), left.values[last])
+		left.removeAt(last)
+
+		if !left.leaf() {
+			child.children = slices.Insert(child.children, 0, left.children[len(left.children)-1])
+			left.children = slices.Delete(left.children, len(left.children)-1, len(left.children))
+		}
+
+	case i < n.numKeys() && n.children[i+1].numKeys() > t-1:
+		right := n.children[i+1]
+
+		child.insertAt(child.numKeys(), n.key(i), n.values[i])
+		n.setAt(i, right.key(0), right.values[0])
+		right.removeAt(0)
+
+		if !right.leaf() {
+			child.children = append(child.children, right.children[0])
+			right.children = slices.Delete(right.children, 0, 1)
+		}
+
+	default:
+		if i >= n.numKeys() {
+			i--
+			child = n.children[i]
+		}
+
+		right := n.children[i+1]
+
+		child.insertAt(child.numKeys(), n.key(i), n.values[i])
+		child.appendAll(right)
+		child.children = append(child.children, right.children...)
+
+		n.removeAt(i)
+		n.children = slices.Delete(n.children, i+1, i+2)
+	}
+}
+
+// ascend calls fn for every key k, s.t. lo <= k < hi, in ascending order, where
+// a nil lo or hi leaves the respective end of the range unbounded. It returns
+// false if fn stopped the traversal.
+func (n *bnode) ascend(lo, hi []byte, fn func(k, v []byte) bool) bool {
+	i := 0
+	if lo != nil {
+		i, _ = n.search(lo)
+	}
+
+	for ; i < n.numKeys(); i++ {
+		if !n.leaf() && !n.children[i].ascend(lo, hi, fn) {
+			return false
+		}
+
+		if hi != nil && bytes.Compare(n.key(i), hi) >= 0 {
+			return false
+		}
+
+		if !fn(n.key(i), n.values[i]) {
+			return false
+		}
+	}
+
+	if !n.leaf() {
+		return n.children[len(n.children)-1].ascend(lo, hi, fn)
+	}
+
+	return true
+}
+
+// descend calls fn for every key in descending order. It returns false if fn
+// stopped the traversal.
+func (n *bnode) descend(fn func(k, v []byte) bool) bool {
+	for i := n.numKeys() - 1; i >= 0; i-- {
+		if !n.leaf() && !n.children[i+1].descend(fn) {
+			return false
+		}
+
+		if !fn(n.key(i), n.values[i]) {
+			return false
+		}
+	}
+
+	if !n.leaf() {
+		return n.children[0].descend(fn)
+	}
+
+	return true
+}
@@ -0,0 +1,151 @@
+package btree_test
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBytesTree(t *testing.T) {
+	_, err := btree.NewBytesTree(1)
+	require.Error(t, err)
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.NewBytesTree(minDegree)
+			require.NoError(t, err)
+
+			_, _, ok := bt.Min()
+			require.False(t, ok)
+
+			// random inserts, replacements and deletions against a reference map,
+			// reusing the same buffers for every call
+			const n = 2000
+			want := make(map[string]string, n)
+			key, value := make([]byte, 0, 16), make([]byte, 0, 16)
+
+			for i := 0; i < 5*n; i++ {
+				key = fmt.Appendf(key[:0], "%x", rng.Intn(n))
+				value = fmt.Appendf(value[:0], "v%d", rng.Int())
+				prev, existed := want[string(key)]
+
+				if rng.Intn(3) == 0 {
+					old, ok := bt.Delete(key)
+					require.Equal(t, existed, ok)
+					require.Equal(t, prev, string(old))
+					delete(want, string(key))
+				} else {
+					old, replaced := bt.Set(key, value)
+					require.Equal(t, existed, replaced)
+					require.Equal(t, prev, string(old))
+					want[string(key)] = string(value)
+				}
+			}
+
+			require.Equal(t, len(want), bt.Len())
+			require.NoError(t, bt.Verify())
+
+			keys := make([]string, 0, len(want))
+			for k, v := range want {
+				got, ok := bt.Get([]byte(k))
+				require.True(t, ok)
+				require.Equal(t, v, string(got))
+				keys = append(keys, k)
+			}
+
+			sort.Strings(keys)
+
+			k, _, ok := bt.Min()
+			require.True(t, ok)
+			require.Equal(t, keys[0], string(k))
+
+			k, _, ok = bt.Max()
+			require.True(t, ok)
+			require.Equal(t, keys[len(keys)-1], string(k))
+
+			var got []string
+			bt.Ascend(func(k, v []byte) bool {
+				require.Equal(t, want[string(k)], string(v))
+				got = append(got, string(k))
+				return true
+			})
+			require.Equal(t, keys, got)
+
+			got = got[:0]
+			bt.Descend(func(k, _ []byte) bool {
+				got = append(got, string(k))
+				return true
+			})
+			for i, k := range keys {
+				require.Equal(t, k, got[len(got)-1-i])
+			}
+
+			got = got[:0]
+			bt.AscendRange([]byte("3"), []byte("5"), func(k, _ []byte) bool {
+				got = append(got, string(k))
+				return true
+			})
+			lo, hi := sort.SearchStrings(keys, "3"), sort.SearchStrings(keys, "5")
+			require.Equal(t, keys[lo:hi], got)
+
+			got = got[:0]
+			bt.AscendPrefix([]byte("7a"), func(k, _ []byte) bool {
+				got = append(got, string(k))
+				return true
+			})
+
+			var prefixed []string
+			for _, k := range keys {
+				if bytes.HasPrefix([]byte(k), []byte("7a")) {
+					prefixed = append(prefixed, k)
+				}
+			}
+			require.Equal(t, prefixed, got)
+		})
+	}
+
+	// nil values are preserved and distinguished from empty ones
+	bt, err := btree.NewBytesTree(2)
+	require.NoError(t, err)
+
+	bt.Set([]byte("nil"), nil)
+	bt.Set([]byte("empty"), []byte{})
+
+	v, ok := bt.Get([]byte("nil"))
+	require.True(t, ok)
+	require.Nil(t, v)
+
+	v, ok = bt.Get([]byte("empty"))
+	require.True(t, ok)
+	require.NotNil(t, v)
+	require.Empty(t, v)
+
+	// keys of all lengths share the buffers of their nodes, while the keys
+	// returned by Min and Max are copies that later writes leave untouched
+	bt, err = btree.NewBytesTree(2)
+	require.NoError(t, err)
+
+	for i := 0; i < 200; i++ {
+		bt.Set(bytes.Repeat([]byte{byte('a' + i%26)}, i%7+1), []byte{byte(i)})
+	}
+
+	require.NoError(t, bt.Verify())
+
+	k, _, ok := bt.Min()
+	require.True(t, ok)
+	require.Equal(t, []byte("a"), k)
+
+	for i := 0; i < 26; i++ {
+		bt.Set([]byte{byte('a' + i)}, nil)
+		bt.Delete(bytes.Repeat([]byte{byte('a' + i)}, 2))
+	}
+
+	_, ok = bt.Delete([]byte("a"))
+	require.True(t, ok)
+	require.Equal(t, []byte("a"), k)
+	require.NoError(t, bt.Verify())
+}
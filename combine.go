@@ -0,0 +1,417 @@
+package btree
+
+import (
+	"reflect"
+	"runtime"
+	"slices"
+	"sync"
+	"unsafe"
+)
+
+// Merge returns a new BTree holding the entries of both the BTree and other,
+// leaving both untouched. If an Entry is present in both trees, only the result
+// of onConflict is kept, which is given the Entry of the BTree and that of other
+// and must return a non-nil Entry equal to both. If onConflict is nil, the Entry
+// of other is kept. When the key ranges of the two trees do not overlap and both
+// share a minimum degree, the trees are joined directly in logarithmic time,
+// sharing their nodes with the result copy-on-write. Otherwise, both are merged
+// in order and bulk loaded in linear time. The result has the minimum degree
+// and ordering of the BTree, and other must order entries the same way.
+func (bt *BTree) Merge(other *BTree, onConflict func(a, b Entry) Entry) *BTree {
+	return bt.MergeParallel(other, onConflict, 1)
+}
+
+// MergeParallel returns a new BTree merged as with Merge, but merging trees
+// whose key ranges overlap on up to workers goroutines at once. The key space
+// is partitioned by rank in the larger tree into ranges of similar size, each
+// of which is merged in order by a goroutine of its own, and the concatenated
+// results are bulk loaded in parallel. If workers is less than one, GOMAXPROCS
+// goroutines are used. The result is identical to that of Merge, although
+// onConflict may be called concurrently.
+func (bt *BTree) MergeParallel(other *BTree, onConflict func(a, b Entry) Entry, workers int) *BTree {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// Lazy clones give a consistent view of both trees without holding both locks
+	// at once, and are owned exclusively by this call.
+	a, b := bt.Clone(), other.Clone()
+
+	if a.minDegree == b.minDegree {
+		switch {
+		case a.size == 0 || b.size == 0 || a.cmp(a.root.max(), b.root.min()) < 0:
+			a.root, a.depth = join2(a.cow, a.minDegree, a.root, a.depth, b.root, b.depth)
+			a.size += b.size
+
+			if a.snapshots {
+				a.publish()
+			}
+
+			return a
+
+		case a.cmp(b.root.max(), a.root.min()) < 0:
+			a.root, a.depth = join2(a.cow, a.minDegree, b.root, b.depth, a.root, a.depth)
+			a.size += b.size
+
+			if a.snapshots {
+				a.publish()
+			}
+
+			return a
+		}
+	}
+
+	// The bounds of the ranges are the entries of the larger tree at evenly
+	// spaced ranks, where the nil bounds at either end are unbounded.
+	larger := a
+	if b.size > a.size {
+		larger = b
+	}
+
+	parts := max(min(workers, (a.size+b.size)/minParallelChunk), 1)
+
+	bounds := make([]Entry, parts+1)
+	for i := 1; i < parts; i++ {
+		bounds[i] = larger.root.at(i * larger.size / parts)
+	}
+
+	segments := make([][]Entry, parts)
+	merge := func(i int) {
+		segments[i] = make([]Entry, 0, (a.size+b.size)/parts)
+		mergeWalkRange(a, b, bounds[i], bounds[i+1], func(x, y Entry) bool {
+			switch {
+			case y == nil:
+				segments[i] = append(segments[i], x)
+
+			case x == nil:
+				segments[i] = append(segments[i], y)
+
+			case onConflict != nil:
+				segments[i] = append(segments[i], onConflict(x, y))
+
+			default:
+				segments[i] = append(segments[i], y)
+			}
+
+			return true
+		})
+	}
+
+	if parts == 1 {
+		merge(0)
+	} else {
+		var wg sync.WaitGroup
+		for i := range segments {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				merge(i)
+			}()
+		}
+
+		wg.Wait()
+	}
+
+	merged := segments[0]
+	if parts > 1 {
+		merged = slices.Concat(segments...)
+	}
+
+	result := bt.newLike()
+	result.loadParallel(merged, workers)
+
+	return result
+}
+
+// SplitAt returns two new BTrees holding the entries of the BTree smaller than
+// e and those greater than or equal to e respectively, leaving the BTree
+// untouched. Only the nodes along the path to e are rebuilt, while all others
+// are shared with the results copy-on-write, so splitting takes O(t log n)
+// time. If e is nil, the left BTree is empty and the right one holds every
+// Entry.
+func (bt *BTree) SplitAt(e Entry) (left, right *BTree) {
+	right = bt.Clone()
+	left = right.newLike()
+
+	if e == nil || right.size == 0 {
+		return left, right
+	}
+
+	l, hl, r, hr := cut(right.cow, right.minDegree, right.cmp, right.root, right.depth, e)
+
+	left.root, left.depth, left.size = l, hl, l.count
+	right.root, right.depth, right.size = r, hr, r.count
+
+	if right.snapshots {
+		left.publish()
+		right.publish()
+	}
+
+	return left, right
+}
+
+// Union returns a new BTree holding every Entry present in either the BTree or
+// other, leaving both untouched. Of two equal entries, the one of the BTree is
+// kept. It is equivalent to Merge with a conflict handler preferring the BTree.
+func (bt *BTree) Union(other *BTree) *BTree {
+	return bt.Merge(other, func(a, _ Entry) Entry {
+		return a
+	})
+}
+
+// Intersect returns a new BTree holding every Entry of the BTree for which an
+// equal Entry is present in other, leaving both untouched. Both trees are
+// walked in order once and the result is bulk loaded, taking linear time. The
+// result has the minimum degree and ordering of the BTree, and other must order
+// entries the same way.
+func (bt *BTree) Intersect(other *BTree) *BTree {
+	a, b, unlock := rlockPair(bt, other)
+
+	n := a.size
+	if b.size < n {
+		n = b.size
+	}
+
+	common := make([]Entry, 0, n)
+	mergeWalk(a, b, func(x, y Entry) bool {
+		if x != nil && y != nil {
+			common = append(common, x)
+		}
+
+		return true
+	})
+	unlock()
+
+	result := bt.newLike()
+	result.load(common)
+
+	return result
+}
+
+// Difference returns a new BTree holding every Entry of the BTree for which no
+// equal Entry is present in other, leaving both untouched. Both trees are
+// walked in order once and the result is bulk loaded, taking linear time. The
+// result has the minimum degree and ordering of the BTree, and other must order
+// entries the same way.
+func (bt *BTree) Difference(other *BTree) *BTree {
+	a, b, unlock := rlockPair(bt, other)
+
+	rest := make([]Entry, 0, a.size)
+	mergeWalk(a, b, func(x, y Entry) bool {
+		if y == nil {
+			rest = append(rest, x)
+		}
+
+		return true
+	})
+	unlock()
+
+	result := bt.newLike()
+	result.load(rest)
+
+	return result
+}
+
+// Equal returns true if the BTree and other hold the same entries, i.e. if
+// every Entry has an equal counterpart in the other tree that is also deeply
+// equal to it. Both trees are walked in order in lockstep, without copying
+// their entries, stopping at the first difference.
+func (bt *BTree) Equal(other *BTree) bool {
+	if bt == other {
+		return true
+	}
+
+	a, b, unlock := rlockPair(bt, other)
+	defer unlock()
+
+	if a.size != b.size {
+		return false
+	}
+
+	equal := true
+	mergeWalk(a, b, func(x, y Entry) bool {
+		equal = x != nil && y != nil && reflect.DeepEqual(x, y)
+		return equal
+	})
+
+	return equal
+}
+
+// Diff returns the changes turning the BTree into other, found by walking both
+// trees in order in lockstep: the entries present only in other, those present
+// only in the BTree, and the entries of other whose equal counterpart in the
+// BTree is not deeply equal to them. All three are sorted in ascending order.
+func (bt *BTree) Diff(other *BTree) (added, removed, changed []Entry) {
+	a, b, unlock := rlockPair(bt, other)
+	defer unlock()
+
+	mergeWalk(a, b, func(x, y Entry) bool {
+		switch {
+		case x == nil:
+			added = append(added, y)
+
+		case y == nil:
+			removed = append(removed, x)
+
+		case !reflect.DeepEqual(x, y):
+			changed = append(changed, y)
+		}
+
+		return true
+	})
+
+	return added, removed, changed
+}
+
+// rlockPair acquires the read locks of a and b as rlock does, returning the
+// trees to read from and a function releasing both. Unlike a clone, this takes
+// no write lock and leaves the copy-on-write contexts of both trees alone. The
+// locks are acquired in address order, as two calls locking the same pair of
+// trees in opposite orders could otherwise deadlock behind pending writers, and
+// only once if a and b are the same tree.
+func rlockPair(a, b *BTree) (ra, rb *BTree, unlock func()) {
+	if a == b {
+		ra = a.rlock()
+		return ra, ra, ra.mu.RUnlock
+	}
+
+	if uintptr(unsafe.Pointer(b)) < uintptr(unsafe.Pointer(a)) {
+		rb, ra, unlock = rlockPair(b, a)
+		return ra, rb, unlock
+	}
+
+	ra, rb = a.rlock(), b.rlock()
+
+	return ra, rb, func() {
+		rb.mu.RUnlock()
+		ra.mu.RUnlock()
+	}
+}
+
+// mergeWalk walks the entries of a and b in ascending order in lockstep,
+// ordered by a, calling fn once for every distinct Entry. An Entry present in
+// both trees is passed as x from a and y from b, while an Entry present in only
+// one of them is passed alongside nil. The walk stops as soon as fn returns
+// false. No locks are taken, so the caller must either hold the read locks of
+// both trees, as with rlockPair, or own them exclusively.
+func mergeWalk(a, b *BTree, fn func(x, y Entry) bool) {
+	mergeWalkRange(a, b, nil, nil, fn)
+}
+
+// mergeWalkRange walks the entries e of a and b, s.t. from <= e < to, as with
+// mergeWalk. A nil from or to leaves the respective end of the range unbounded.
+func mergeWalkRange(a, b *BTree, from, to Entry, fn func(x, y Entry) bool) {
+	ia, ib := a.walkFrom(from), b.walkFrom(from)
+
+	// within reports whether the Iterator is valid and still lies in the range
+	within := func(it *Iterator) bool {
+		return it.Valid() && (to == nil || a.cmp(it.entry(), to) < 0)
+	}
+
+	okA, okB := within(ia), within(ib)
+
+	for okA || okB {
+		var c int
+		switch {
+		case !okB:
+			c = -1
+
+		case !okA:
+			c = 1
+
+		default:
+			c = a.cmp(ia.entry(), ib.entry())
+		}
+
+		switch {
+		case c < 0:
+			if !fn(ia.entry(), nil) {
+				return
+			}
+
+			ia.next()
+			okA = within(ia)
+
+		case c > 0:
+			if !fn(nil, ib.entry()) {
+				return
+			}
+
+			ib.next()
+			okB = within(ib)
+
+		default:
+			if !fn(ia.entry(), ib.entry()) {
+				return
+			}
+
+			ia.next()
+			ib.next()
+			okA, okB = within(ia), within(ib)
+		}
+	}
+}
+
+// walkFrom returns an Iterator over the BTree positioned on the smallest entry
+// greater than or equal to from, or on the smallest entry if from is nil. It is
+// stepped through next and read through entry, neither of which locks the
+// BTree, so the caller must hold the read lock or own the BTree exclusively.
+func (bt *BTree) walkFrom(from Entry) *Iterator {
+	it := &Iterator{bt: bt, tree: bt}
+
+	if from == nil {
+		it.reset()
+		it.pushMin(bt.root)
+		it.ascendNext()
+	} else {
+		it.seek(from)
+	}
+
+	return it
+}
+
+// Filter returns a new BTree holding every Entry of the BTree for which pred
+// returns true, leaving the BTree untouched. The matching entries are streamed
+// from a single in-order traversal straight into a bottom-up build, without
+// being collected first. The predicate is called under the read lock and must
+// not modify the BTree.
+func (bt *BTree) Filter(pred func(Entry) bool) *BTree {
+	result := bt.newLike()
+	b := newBuilder(result)
+
+	bt.Ascend(func(e Entry) bool {
+		if pred(e) {
+			b.add(e)
+		}
+
+		return true
+	})
+
+	b.finish()
+	return result
+}
+
+// Partition returns two new BTrees holding the entries of the BTree for which
+// pred returns true and false respectively, leaving the BTree untouched. Both
+// are built bottom-up from a single in-order traversal, as with Filter. The
+// predicate is called once per Entry under the read lock and must not modify
+// the BTree.
+func (bt *BTree) Partition(pred func(Entry) bool) (match, rest *BTree) {
+	match, rest = bt.newLike(), bt.newLike()
+	bm, br := newBuilder(match), newBuilder(rest)
+
+	bt.Ascend(func(e Entry) bool {
+		if pred(e) {
+			bm.add(e)
+		} else {
+			br.add(e)
+		}
+
+		return true
+	})
+
+	bm.finish()
+	br.finish()
+
+	return match, rest
+}
@@ -0,0 +1,425 @@
+package btree_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestBTreeKeys returns a BTree with a minimum degree t holding entries for
+// the given keys, inserted in random order, with random values.
+func newTestBTreeKeys(t *testing.T, minDegree int, keys []uint64) *btree.BTree {
+	bt, err := btree.New(minDegree)
+	require.NoError(t, err)
+
+	for _, i := range rng.Perm(len(keys)) {
+		bt.Insert(testEntry{keys[i], rng.Uint64()})
+	}
+
+	return bt
+}
+
+// keyRange returns the keys in [from, to) that are multiples of step.
+func keyRange(from, to, step uint64) []uint64 {
+	var keys []uint64
+	for k := from; k < to; k += step {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+func TestBTreeMerge(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			for _, tc := range []struct {
+				name        string
+				left, right []uint64
+				rightDegree int
+			}{
+				{"both empty", nil, nil, minDegree},
+				{"left empty", nil, keyRange(0, 500, 1), minDegree},
+				{"right empty", keyRange(0, 500, 1), nil, minDegree},
+				{"disjoint", keyRange(0, 500, 1), keyRange(500, 3000, 1), minDegree},
+				{"disjoint reversed", keyRange(1000, 1100, 1), keyRange(0, 1000, 1), minDegree},
+				{"interleaved", keyRange(0, 2000, 2), keyRange(1, 2000, 2), minDegree},
+				{"overlapping", keyRange(0, 1500, 1), keyRange(1000, 2000, 1), minDegree},
+				{"different degrees", keyRange(0, 500, 1), keyRange(500, 1000, 1), minDegree + 1},
+			} {
+				t.Run(tc.name, func(t *testing.T) {
+					left := newTestBTreeKeys(t, minDegree, tc.left)
+					right := newTestBTreeKeys(t, tc.rightDegree, tc.right)
+					leftEntries, rightEntries := left.Entries(nil, nil), right.Entries(nil, nil)
+
+					// conflicting entries keep the sum of both values
+					merged := left.Merge(right, func(a, b btree.Entry) btree.Entry {
+						return testEntry{a.(testEntry).key, a.(testEntry).value + b.(testEntry).value}
+					})
+					require.NoError(t, merged.Verify())
+
+					want := make(map[uint64]uint64)
+					for _, e := range leftEntries {
+						want[e.(testEntry).key] += e.(testEntry).value
+					}
+
+					for _, e := range rightEntries {
+						want[e.(testEntry).key] += e.(testEntry).value
+					}
+
+					require.Equal(t, len(want), merged.Size())
+					for k, v := range want {
+						require.Equal(t, testEntry{k, v}, merged.Search(testEntry{key: k}))
+					}
+
+					// writes to the result are not visible in either input and vice versa
+					merged.DeleteAll(func(btree.Entry) bool { return true })
+					left.Insert(testEntry{key: 1 << 40})
+					right.Insert(testEntry{key: 1 << 41})
+
+					require.Zero(t, merged.Size())
+					require.Equal(t, leftEntries, left.Entries(nil, testEntry{key: 1 << 40}))
+					require.Equal(t, rightEntries, right.Entries(nil, testEntry{key: 1 << 41}))
+					require.NoError(t, left.Verify())
+					require.NoError(t, right.Verify())
+				})
+			}
+		})
+	}
+
+	// without a conflict handler the entry of the other tree is kept
+	left := newTestBTreeKeys(t, 3, keyRange(0, 100, 1))
+	right := newTestBTreeKeys(t, 3, keyRange(50, 150, 1))
+
+	merged := left.Merge(right, nil)
+	require.Equal(t, 150, merged.Size())
+	require.Equal(t, right.Search(testEntry{key: 75}), merged.Search(testEntry{key: 75}))
+	require.Equal(t, left.Search(testEntry{key: 25}), merged.Search(testEntry{key: 25}))
+}
+
+func TestBTreeMergeParallel(t *testing.T) {
+	load := func(minDegree int, keys []uint64) *btree.BTree {
+		sorted := make([]btree.Entry, len(keys))
+		for i, k := range keys {
+			sorted[i] = testEntry{k, k}
+		}
+
+		bt, err := btree.BulkLoad(sorted, minDegree)
+		require.NoError(t, err)
+
+		return bt
+	}
+
+	sum := func(a, b btree.Entry) btree.Entry {
+		return testEntry{a.(testEntry).key, a.(testEntry).value + b.(testEntry).value}
+	}
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			// large enough to be partitioned, with the smaller tree on either side
+			left := load(minDegree, keyRange(0, 100000, 2))
+			right := load(minDegree, keyRange(50000, 200000, 3))
+
+			for _, trees := range [][2]*btree.BTree{{left, right}, {right, left}} {
+				want := trees[0].Merge(trees[1], sum).Entries(nil, nil)
+
+				for _, workers := range []int{0, 1, 4, 64} {
+					merged := trees[0].MergeParallel(trees[1], sum, workers)
+					require.NoError(t, merged.Verify())
+					require.Equal(t, want, merged.Entries(nil, nil))
+				}
+			}
+		})
+	}
+}
+
+func TestBTreeSplitAt(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 2000)
+
+			for _, pivot := range []uint64{0, 1, 2, 17, 500, 1000, 1999, 2000, 5000} {
+				left, right := bt.SplitAt(testEntry{key: pivot})
+				require.NoError(t, left.Verify())
+				require.NoError(t, right.Verify())
+
+				i := int(pivot)
+				if i > len(entries) {
+					i = len(entries)
+				}
+
+				require.Equal(t, i, left.Size())
+				require.Equal(t, len(entries)-i, right.Size())
+				for j, e := range left.Entries(nil, nil) {
+					require.Equal(t, entries[j], e)
+				}
+
+				for j, e := range right.Entries(nil, nil) {
+					require.Equal(t, entries[i+j], e)
+				}
+
+				// the halves are independent of each other and of the original
+				left.Insert(testEntry{key: 1 << 40})
+				right.DeleteMin()
+				require.NoError(t, left.Verify())
+				require.NoError(t, right.Verify())
+			}
+
+			require.Equal(t, len(entries), bt.Size())
+			require.NoError(t, bt.Verify())
+			for i, e := range bt.Entries(nil, nil) {
+				require.Equal(t, entries[i], e)
+			}
+
+			left, right := bt.SplitAt(nil)
+			require.Zero(t, left.Size())
+			require.Equal(t, len(entries), right.Size())
+
+			// small halves of a packed tree may be leaf roots at capacity,
+			// which keep growing by splitting
+			packed := newPackedBTree(t, minDegree, len(entries))
+			for _, n := range []int{1, 2*minDegree - 2, 2*minDegree - 1} {
+				left, _ := packed.SplitAt(testEntry{key: uint64(n)})
+				requireGrows(t, left)
+
+				_, right := packed.SplitAt(testEntry{key: uint64(len(entries) - n)})
+				requireGrows(t, right)
+			}
+		})
+	}
+}
+
+func TestBTreeSetOperations(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			// multiples of two and three below 3000, giving every combination of
+			// membership
+			left := newTestBTreeKeys(t, minDegree, keyRange(0, 3000, 2))
+			right := newTestBTreeKeys(t, minDegree+1, keyRange(0, 3000, 3))
+
+			union, intersect, difference := left.Union(right), left.Intersect(right), left.Difference(right)
+			for _, result := range []*btree.BTree{union, intersect, difference} {
+				require.NoError(t, result.Verify())
+			}
+
+			var wantUnion, wantIntersect, wantDifference []btree.Entry
+			for k := uint64(0); k < 3000; k++ {
+				l, r := left.Search(testEntry{key: k}), right.Search(testEntry{key: k})
+
+				switch {
+				case l != nil && r != nil:
+					wantUnion = append(wantUnion, l)
+					wantIntersect = append(wantIntersect, l)
+
+				case l != nil:
+					wantUnion = append(wantUnion, l)
+					wantDifference = append(wantDifference, l)
+
+				case r != nil:
+					wantUnion = append(wantUnion, r)
+				}
+			}
+
+			require.Equal(t, wantUnion, union.Entries(nil, nil))
+			require.Equal(t, wantIntersect, intersect.Entries(nil, nil))
+			require.Equal(t, wantDifference, difference.Entries(nil, nil))
+
+			require.Equal(t, 1500, left.Size())
+			require.Equal(t, 1000, right.Size())
+
+			// operations with an empty tree
+			empty, err := btree.New(minDegree)
+			require.NoError(t, err)
+
+			require.Equal(t, left.Size(), left.Union(empty).Size())
+			require.Zero(t, left.Intersect(empty).Size())
+			require.Equal(t, left.Size(), left.Difference(empty).Size())
+			require.Zero(t, empty.Difference(left).Size())
+
+			// both trees are only read, so the operations take no write lock and run
+			// while read locks on them are held
+			wantLeft := left.Entries(nil, nil)
+			left.Ascend(func(btree.Entry) bool {
+				right.Ascend(func(btree.Entry) bool {
+					require.Equal(t, wantIntersect, left.Intersect(right).Entries(nil, nil))
+					require.Equal(t, wantDifference, left.Difference(right).Entries(nil, nil))
+					require.Equal(t, wantLeft, left.Intersect(left).Entries(nil, nil))
+					require.Zero(t, left.Difference(left).Size())
+					return false
+				})
+
+				return false
+			})
+		})
+	}
+}
+
+func TestBTreeEqualDiff(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 2000)
+			require.True(t, bt.Equal(bt))
+
+			// a tree of a different shape holding the same entries is equal
+			other, err := btree.NewFromSlice(minDegree+1, bt.Entries(nil, nil))
+			require.NoError(t, err)
+			require.True(t, bt.Equal(other))
+			require.True(t, other.Equal(bt))
+
+			added, removed, changed := bt.Diff(other)
+			require.Empty(t, added)
+			require.Empty(t, removed)
+			require.Empty(t, changed)
+
+			// modify the other tree in every possible way
+			var wantAdded, wantRemoved, wantChanged []btree.Entry
+			for i := 0; i < 2100; i += 7 {
+				switch e := (testEntry{key: uint64(i), value: 1}); {
+				case i >= 2000:
+					other.Insert(e)
+					wantAdded = append(wantAdded, e)
+
+				case i%2 == 0:
+					other.Delete(e)
+					wantRemoved = append(wantRemoved, btree.Entry(entries[i]))
+
+				default:
+					other.Insert(e)
+					wantChanged = append(wantChanged, e)
+				}
+			}
+
+			require.False(t, bt.Equal(other))
+			require.False(t, other.Equal(bt))
+
+			added, removed, changed = bt.Diff(other)
+			require.Equal(t, wantAdded, added)
+			require.Equal(t, wantRemoved, removed)
+			require.Equal(t, wantChanged, changed)
+
+			// trees of equal size differing only in values are not equal
+			other, err = btree.NewFromSlice(minDegree, bt.Entries(nil, nil))
+			require.NoError(t, err)
+
+			other.Insert(testEntry{key: 1000, value: 1})
+			require.False(t, bt.Equal(other))
+
+			// both trees are only read, so the comparisons take no write lock and run
+			// while read locks on them are held
+			bt.Ascend(func(btree.Entry) bool {
+				other.Ascend(func(btree.Entry) bool {
+					require.False(t, bt.Equal(other))
+
+					added, removed, changed := bt.Diff(other)
+					require.Empty(t, added)
+					require.Empty(t, removed)
+					require.Equal(t, []btree.Entry{testEntry{key: 1000, value: 1}}, changed)
+					return false
+				})
+
+				return false
+			})
+		})
+	}
+}
+
+func TestBTreeFilter(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 2000)
+
+			// keeping a prefix of every length exercises every shape of the
+			// rightmost spine
+			for n := 0; n <= len(entries); n += 1 + rng.Intn(5) {
+				filtered := bt.Filter(func(e btree.Entry) bool {
+					return e.(testEntry).key < uint64(n)
+				})
+
+				require.NoError(t, filtered.Verify())
+				require.Equal(t, n, filtered.Size())
+				require.Equal(t, bt.Entries(nil, testEntry{key: uint64(n)}), filtered.Entries(nil, nil))
+			}
+
+			// a result fitting a single leaf root keeps growing by splitting it
+			for _, n := range []int{1, 2*minDegree - 2, 2*minDegree - 1} {
+				requireGrows(t, bt.Filter(func(e btree.Entry) bool {
+					return e.(testEntry).key < uint64(n)
+				}))
+			}
+
+			for _, mod := range []uint64{2, 3, 7, 100} {
+				filtered := bt.Filter(func(e btree.Entry) bool {
+					return e.(testEntry).key%mod == 0
+				})
+				require.NoError(t, filtered.Verify())
+
+				var want []btree.Entry
+				for _, e := range entries {
+					if e.key%mod == 0 {
+						want = append(want, e)
+					}
+				}
+
+				require.Equal(t, want, filtered.Entries(nil, nil))
+
+				// the result is fully usable and independent of the original
+				filtered.Insert(testEntry{key: 1})
+				filtered.DeleteMax()
+				require.NoError(t, filtered.Verify())
+			}
+
+			require.Equal(t, len(entries), bt.Size())
+			require.NoError(t, bt.Verify())
+		})
+	}
+}
+
+func TestBTreePartition(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 2000)
+
+			calls := 0
+			match, rest := bt.Partition(func(e btree.Entry) bool {
+				calls++
+				return e.(testEntry).value%3 == 0
+			})
+
+			require.Equal(t, len(entries), calls)
+			require.NoError(t, match.Verify())
+			require.NoError(t, rest.Verify())
+
+			var wantMatch, wantRest []btree.Entry
+			for _, e := range entries {
+				if e.value%3 == 0 {
+					wantMatch = append(wantMatch, e)
+				} else {
+					wantRest = append(wantRest, e)
+				}
+			}
+
+			require.Equal(t, wantMatch, match.Entries(nil, nil))
+			require.Equal(t, wantRest, rest.Entries(nil, nil))
+
+			// all or nothing
+			match, rest = bt.Partition(func(btree.Entry) bool { return true })
+			require.Equal(t, len(entries), match.Size())
+			require.Zero(t, rest.Size())
+			require.NoError(t, rest.Verify())
+
+			// halves fitting a single leaf root keep growing by splitting it
+			for _, n := range []int{1, 2*minDegree - 2, 2*minDegree - 1} {
+				match, _ := bt.Partition(func(e btree.Entry) bool {
+					return e.(testEntry).key < uint64(n)
+				})
+				requireGrows(t, match)
+
+				_, rest := bt.Partition(func(e btree.Entry) bool {
+					return e.(testEntry).key >= uint64(n)
+				})
+				requireGrows(t, rest)
+			}
+		})
+	}
+}
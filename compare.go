@@ -0,0 +1,41 @@
+package btree
+
+import "cmp"
+
+// The helpers in this file build comparators, i.e. functions ordering two values
+// such that 0 is returned if they are equal, -1 if a < b and 1 if a > b. They
+// apply to any type, so they can be passed to NewWithComparator for entries as
+// well as to NewStringTreeWithCollation or used to implement Compare methods.
+
+// Reverse returns a comparator ordering values in the opposite order of the
+// given one.
+func Reverse[T any](compare func(a, b T) int) func(a, b T) int {
+	return func(a, b T) int {
+		return compare(b, a)
+	}
+}
+
+// Chain returns a comparator which orders values by the first of the given
+// comparators, deferring to the next one only if the previous ones consider the
+// values equal. Without comparators, every value is considered equal.
+func Chain[T any](compares ...func(a, b T) int) func(a, b T) int {
+	compares = append([]func(a, b T) int(nil), compares...)
+
+	return func(a, b T) int {
+		for _, compare := range compares {
+			if c := compare(a, b); c != 0 {
+				return c
+			}
+		}
+
+		return 0
+	}
+}
+
+// ByKeyFunc returns a comparator which orders values by the ordered key the
+// given function extracts from them.
+func ByKeyFunc[T any, K cmp.Ordered](extract func(T) K) func(a, b T) int {
+	return func(a, b T) int {
+		return cmp.Compare(extract(a), extract(b))
+	}
+}
@@ -0,0 +1,70 @@
+package btree_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	tenant string
+	age    int
+	id     uint64
+}
+
+func TestComparators(t *testing.T) {
+	byTenant := btree.ByKeyFunc(func(u user) string { return u.tenant })
+	byAge := btree.ByKeyFunc(func(u user) int { return u.age })
+	byID := btree.ByKeyFunc(func(u user) uint64 { return u.id })
+
+	a := user{tenant: "acme", age: 30, id: 2}
+	b := user{tenant: "acme", age: 30, id: 1}
+	c := user{tenant: "globex", age: 20, id: 3}
+
+	require.Equal(t, -1, byTenant(a, c))
+	require.Equal(t, 0, byTenant(a, b))
+	require.Equal(t, 1, btree.Reverse(byTenant)(a, c))
+
+	compare := btree.Chain(byTenant, btree.Reverse(byAge), byID)
+	require.Equal(t, 1, compare(a, b))
+	require.Equal(t, -1, compare(b, a))
+	require.Equal(t, 0, compare(a, a))
+	require.Equal(t, -1, compare(a, c))
+
+	require.Equal(t, 0, btree.Chain[user]()(a, c))
+}
+
+func TestComparatorsWithComparator(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			// order entries by descending value, then ascending key
+			bt, err := btree.NewWithComparator(minDegree, btree.Chain(
+				btree.Reverse(btree.ByKeyFunc(func(e btree.Entry) string { return e.(btree.StringEntry).Value.(string) })),
+				btree.ByKeyFunc(func(e btree.Entry) string { return e.(btree.StringEntry).Key }),
+			))
+			require.NoError(t, err)
+
+			for _, i := range rng.Perm(500) {
+				bt.Insert(btree.StringEntry{Key: fmt.Sprintf("%03d", i), Value: strings.Repeat("x", i%7)})
+			}
+
+			require.NoError(t, bt.Verify())
+			require.Equal(t, 500, bt.Size())
+
+			var prev btree.StringEntry
+			bt.Ascend(func(e btree.Entry) bool {
+				se := e.(btree.StringEntry)
+				if prev.Value != nil {
+					pv, v := prev.Value.(string), se.Value.(string)
+					require.True(t, len(pv) > len(v) || (pv == v && prev.Key < se.Key))
+				}
+
+				prev = se
+				return true
+			})
+		})
+	}
+}
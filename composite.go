@@ -0,0 +1,120 @@
+package btree
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CompositeEntry defines a ready-made Entry whose key consists of several
+// fields, e.g. a tenant, then a timestamp, then an id, carrying an opaque value.
+// Keys are ordered by CompareFields. Every field must be an integer, float,
+// string, []byte, bool or time.Time, and fields at the same position must share
+// a type across all entries of a BTree.
+type CompositeEntry struct {
+	Fields []any
+	Value  any
+}
+
+var _ Entry = CompositeEntry{}
+
+// Compare implements Entry. The argument must be a CompositeEntry.
+func (e CompositeEntry) Compare(other Entry) int {
+	return CompareFields(e.Fields, other.(CompositeEntry).Fields)
+}
+
+// CompareFields orders two composite keys lexicographically, comparing one pair
+// of fields at a time and deferring to the next pair only on a tie. If one key
+// is a prefix of the other, the shorter key sorts first, so that a partial key
+// sorts before every full key it is a prefix of. It panics if two fields at the
+// same position differ in type or are of an unsupported type.
+func CompareFields(a, b []any) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareField(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+
+	return cmp.Compare(len(a), len(b))
+}
+
+// AscendFields calls fn for every CompositeEntry in the BTree whose fields start
+// with the given partial key, in ascending order, under the read lock. Every
+// Entry in the BTree must be a CompositeEntry. The scan starts at the partial
+// key itself, which sorts before all of its extensions, and ends at the first
+// Entry not extending it. The traversal stops as soon as fn returns false.
+func (bt *BTree) AscendFields(prefix []any, fn func(Entry) bool) {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	hasPrefix := func(e Entry) bool {
+		fields := e.(CompositeEntry).Fields
+		return len(fields) >= len(prefix) && CompareFields(fields[:len(prefix)], prefix) == 0
+	}
+
+	bt.root.ascendRange(bt.cmp, CompositeEntry{Fields: prefix}, nil, false, func(e Entry) bool {
+		return hasPrefix(e) && fn(e)
+	})
+}
+
+// compareField orders two fields of the same supported type.
+func compareField(a, b any) int {
+	switch x := a.(type) {
+	case int:
+		return cmp.Compare(x, fieldAs[int](a, b))
+	case int8:
+		return cmp.Compare(x, fieldAs[int8](a, b))
+	case int16:
+		return cmp.Compare(x, fieldAs[int16](a, b))
+	case int32:
+		return cmp.Compare(x, fieldAs[int32](a, b))
+	case int64:
+		return cmp.Compare(x, fieldAs[int64](a, b))
+	case uint:
+		return cmp.Compare(x, fieldAs[uint](a, b))
+	case uint8:
+		return cmp.Compare(x, fieldAs[uint8](a, b))
+	case uint16:
+		return cmp.Compare(x, fieldAs[uint16](a, b))
+	case uint32:
+		return cmp.Compare(x, fieldAs[uint32](a, b))
+	case uint64:
+		return cmp.Compare(x, fieldAs[uint64](a, b))
+	case float32:
+		return cmp.Compare(x, fieldAs[float32](a, b))
+	case float64:
+		return cmp.Compare(x, fieldAs[float64](a, b))
+	case string:
+		return strings.Compare(x, fieldAs[string](a, b))
+	case []byte:
+		return bytes.Compare(x, fieldAs[[]byte](a, b))
+	case time.Time:
+		return x.Compare(fieldAs[time.Time](a, b))
+	case bool:
+		y := fieldAs[bool](a, b)
+
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		default:
+			return 1
+		}
+	default:
+		panic(fmt.Sprintf("btree: unsupported composite key field type %T", a))
+	}
+}
+
+// fieldAs returns b as a T, the type of the field a it is compared against, or
+// panics if b is of a different type.
+func fieldAs[T any](a, b any) T {
+	y, ok := b.(T)
+	if !ok {
+		panic(fmt.Sprintf("btree: cannot compare composite key fields of types %T and %T", a, b))
+	}
+
+	return y
+}
@@ -0,0 +1,91 @@
+package btree_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareFields(t *testing.T) {
+	now := time.Now()
+
+	for _, tc := range []struct {
+		a, b []any
+		want int
+	}{
+		{nil, nil, 0},
+		{[]any{"a"}, []any{"b"}, -1},
+		{[]any{"a", 2}, []any{"a", 1}, 1},
+		{[]any{"a", 1, uint64(7)}, []any{"a", 1, uint64(7)}, 0},
+		{[]any{"a"}, []any{"a", 1}, -1},
+		{[]any{"a", 1}, []any{"a"}, 1},
+		{[]any{[]byte("x"), now}, []any{[]byte("x"), now.Add(time.Second)}, -1},
+		{[]any{false}, []any{true}, -1},
+		{[]any{1.5, int8(-1)}, []any{1.5, int8(-2)}, 1},
+	} {
+		require.Equal(t, tc.want, btree.CompareFields(tc.a, tc.b), "%v %v", tc.a, tc.b)
+	}
+
+	require.Panics(t, func() { btree.CompareFields([]any{1}, []any{"1"}) })
+	require.Panics(t, func() { btree.CompareFields([]any{struct{}{}}, []any{struct{}{}}) })
+}
+
+func TestBTreeAscendFields(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree)
+			require.NoError(t, err)
+
+			// keys of tenant, then timestamp, then id
+			base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			tenants := []string{"acme", "globex", "initech"}
+
+			for _, i := range rng.Perm(900) {
+				bt.Insert(btree.CompositeEntry{
+					Fields: []any{tenants[i%3], base.Add(time.Duration(i/30) * time.Hour), uint64(i)},
+					Value:  i,
+				})
+			}
+
+			require.NoError(t, bt.Verify())
+
+			var got []btree.CompositeEntry
+			collect := func(e btree.Entry) bool {
+				got = append(got, e.(btree.CompositeEntry))
+				return true
+			}
+
+			// a single tenant
+			bt.AscendFields([]any{"globex"}, collect)
+			require.Len(t, got, 300)
+
+			for i, e := range got {
+				require.Equal(t, "globex", e.Fields[0])
+				if i > 0 {
+					require.Equal(t, -1, btree.CompareFields(got[i-1].Fields, e.Fields))
+				}
+			}
+
+			// a single tenant and hour
+			got = got[:0]
+			bt.AscendFields([]any{"acme", base.Add(5 * time.Hour)}, collect)
+			require.Len(t, got, 10)
+
+			for _, e := range got {
+				require.Equal(t, "acme", e.Fields[0])
+				require.Equal(t, base.Add(5*time.Hour), e.Fields[1])
+			}
+
+			// a missing tenant and the empty prefix
+			got = got[:0]
+			bt.AscendFields([]any{"hooli"}, collect)
+			require.Empty(t, got)
+
+			bt.AscendFields(nil, collect)
+			require.Len(t, got, 900)
+		})
+	}
+}
@@ -0,0 +1,851 @@
+package btree
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// ConcurrentTree implements a thread-safe B-Tree whose nodes are latched
+	// individually rather than guarded by one tree-wide lock, so that concurrent
+	// writers to disjoint key ranges do not serialize. Operations descend by lock
+	// coupling, also known as latch crabbing: the latch of a child is acquired
+	// before that of its parent is released. Since full nodes are split and
+	// minimal nodes grown on the way down, as BTree does, no operation ever needs
+	// to climb back up, and a writer holds at most a parent and its children at a
+	// time. Keys and values are stored inline, as with Uint64Tree.
+	//
+	// Writers still pass through the root one at a time, but only hold its latch
+	// for a single step of their descent. Scans hold the read latches of every
+	// node on their current path, so a long scan delays writers to the subtrees it
+	// is traversing.
+	//
+	// A ConcurrentTree created by NewOptimisticConcurrentTree reads by optimistic
+	// lock coupling instead: readers take no latches at all, but note the version
+	// of every node on their path and validate all of them before returning or
+	// visiting a key, restarting should a writer have modified any in the
+	// meantime. The whole path is validated, not only the latest node, since
+	// removing a key from an internal node moves its predecessor up from a leaf.
+	// Reading a node that a writer modifies would be a data race in Go, so writers
+	// never modify the contents of a node in place, but replace them by a modified
+	// copy. Reads then neither delay writers nor each other, at the price of
+	// copying every node a write modifies and of retries under contention.
+	ConcurrentTree[K, V any] struct {
+		// mu serializes the writers replacing the root, which changes when it
+		// splits or shrinks, while optimistic readers load root without it
+		mu   sync.RWMutex
+		root atomic.Pointer[cnode[K, V]]
+
+		cmp        func(a, b K) int
+		t          int
+		optimistic bool
+		size       atomic.Int64
+	}
+
+	// cnode defines a node of a ConcurrentTree. Unlike a knode, every cnode
+	// carries a latch of its own, along with a version which is odd while a
+	// writer modifies the node and grows with every modification.
+	cnode[K, V any] struct {
+		latch   sync.RWMutex
+		version atomic.Uint64
+		items   atomic.Pointer[citems[K, V]]
+	}
+
+	// citems defines the contents of a cnode, where values[i] is stored under
+	// keys[i]. Once stored in a cnode, they are never modified.
+	citems[K, V any] struct {
+		keys     []K
+		values   []V
+		children []*cnode[K, V]
+	}
+
+	// cpath holds the nodes an optimistic reader descended through, along with
+	// the versions they had when it reached them.
+	cpath[K, V any] []struct {
+		n *cnode[K, V]
+		v uint64
+	}
+
+	// optimisticScan holds the state of an ascending scan by optimistic lock
+	// coupling, which resumes after the last key visited whenever it restarts.
+	optimisticScan[K, V any] struct {
+		cmp     func(a, b K) int
+		lo, hi  *K
+		fn      func(K, V) bool
+		path    cpath[K, V]
+		last    K
+		visited bool
+	}
+)
+
+// NewConcurrentTree returns a reference to a new, empty ConcurrentTree with a
+// minimum degree t over keys ordered by cmp.
+func NewConcurrentTree[K, V any](t int, cmp func(a, b K) int) (*ConcurrentTree[K, V], error) {
+	if t < 2 {
+		return nil, fmt.Errorf("minimum degree must be at least two: %d", t)
+	}
+
+	if cmp == nil {
+		return nil, fmt.Errorf("comparator must not be nil")
+	}
+
+	c := &ConcurrentTree[K, V]{cmp: cmp, t: t}
+	c.root.Store(newCNode(&citems[K, V]{}))
+
+	return c, nil
+}
+
+// NewOptimisticConcurrentTree returns a reference to a new, empty
+// ConcurrentTree as NewConcurrentTree does, whose readers descend by
+// optimistic lock coupling rather than by latching.
+func NewOptimisticConcurrentTree[K, V any](t int, cmp func(a, b K) int) (*ConcurrentTree[K, V], error) {
+	c, err := NewConcurrentTree[K, V](t, cmp)
+	if err != nil {
+		return nil, err
+	}
+
+	c.optimistic = true
+	return c, nil
+}
+
+func newCNode[K, V any](it *citems[K, V]) *cnode[K, V] {
+	n := &cnode[K, V]{}
+	n.items.Store(it)
+
+	return n
+}
+
+// Len returns the number of keys in the ConcurrentTree.
+func (c *ConcurrentTree[K, V]) Len() int {
+	return int(c.size.Load())
+}
+
+// rlockRoot returns the root with its read latch acquired.
+func (c *ConcurrentTree[K, V]) rlockRoot() *cnode[K, V] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := c.root.Load()
+	n.latch.RLock()
+
+	return n
+}
+
+// optimisticRoot returns the root along with its version, or false if the root
+// is being modified or was replaced in the meantime.
+func (c *ConcurrentTree[K, V]) optimisticRoot() (*cnode[K, V], uint64, bool) {
+	n := c.root.Load()
+	v, ok := n.stable()
+
+	return n, v, ok && c.root.Load() == n
+}
+
+// readOptimistic descends by optimistic lock coupling from the root, calling
+// visit with the contents of every node on the way, which returns the index of
+// the child to descend to or false to stop there. The descent restarts from the
+// root whenever a node on the path changes before the last one has been
+// validated, so only the last call to visit counts.
+func (c *ConcurrentTree[K, V]) readOptimistic(visit func(it *citems[K, V]) (int, bool)) {
+	var path cpath[K, V]
+	for ; ; runtime.Gosched() {
+		n, v, ok := c.optimisticRoot()
+		for path = path[:0]; ok; {
+			path = path.push(n, v)
+			it := n.items.Load()
+
+			i, more := visit(it)
+			if !more {
+				if !path.changed() {
+					return
+				}
+
+				break
+			}
+
+			n = it.children[i]
+			v, ok = n.stable()
+			ok = ok && !path.changed()
+		}
+	}
+}
+
+// Get returns the value stored under the given key and whether it exists.
+func (c *ConcurrentTree[K, V]) Get(key K) (v V, found bool) {
+	if c.optimistic {
+		var zero V
+		c.readOptimistic(func(it *citems[K, V]) (int, bool) {
+			i, ok := it.search(c.cmp, key)
+			if v, found = zero, ok; ok {
+				v = it.values[i]
+			}
+
+			return i, !ok && !it.leaf()
+		})
+
+		return v, found
+	}
+
+	n := c.rlockRoot()
+	for {
+		it := n.items.Load()
+
+		i, found := it.search(c.cmp, key)
+		if found || it.leaf() {
+			var v V
+			if found {
+				v = it.values[i]
+			}
+
+			n.latch.RUnlock()
+			return v, found
+		}
+
+		child := it.children[i]
+		child.latch.RLock()
+		n.latch.RUnlock()
+		n = child
+	}
+}
+
+// Has returns true if a value is stored under the given key.
+func (c *ConcurrentTree[K, V]) Has(key K) bool {
+	_, ok := c.Get(key)
+	return ok
+}
+
+// Set stores the value under the given key, returning the value it replaced, if
+// any, and whether one was replaced.
+func (c *ConcurrentTree[K, V]) Set(key K, value V) (old V, replaced bool) {
+	c.mu.Lock()
+
+	n := c.root.Load()
+	n.latch.Lock()
+
+	if len(n.items.Load().keys) == 2*c.t-1 {
+		root := newCNode(&citems[K, V]{children: []*cnode[K, V]{n}})
+		root.latch.Lock()
+
+		// readers of the old root retry until the new one is in place, rather
+		// than taking its left half for the whole tree
+		n.begin()
+		root.splitChild(0, c.t)
+		c.root.Store(root)
+		n.end()
+
+		n.latch.Unlock()
+		n = root
+	}
+
+	c.mu.Unlock()
+
+	for {
+		it := n.items.Load()
+
+		i, found := it.search(c.cmp, key)
+		if found {
+			old = it.values[i]
+			n.update(func(it *citems[K, V]) { it.keys[i], it.values[i] = key, value })
+			n.latch.Unlock()
+
+			return old, true
+		}
+
+		if it.leaf() {
+			n.update(func(it *citems[K, V]) { it.insertAt(i, key, value) })
+			c.size.Add(1)
+			n.latch.Unlock()
+
+			return old, false
+		}
+
+		child := it.children[i]
+		child.latch.Lock()
+
+		if len(child.items.Load().keys) == 2*c.t-1 {
+			n.begin()
+			child.begin()
+			n.splitChild(i, c.t)
+			child.end()
+			n.end()
+
+			it = n.items.Load()
+			switch cmp := c.cmp(key, it.keys[i]); {
+			case cmp > 0:
+				// the new right half is not yet latched by anyone else
+				child.latch.Unlock()
+				child = it.children[i+1]
+				child.latch.Lock()
+
+			case cmp == 0:
+				// the key is the median which was just moved into n
+				child.latch.Unlock()
+
+				old = it.values[i]
+				n.update(func(it *citems[K, V]) { it.keys[i], it.values[i] = key, value })
+				n.latch.Unlock()
+
+				return old, true
+			}
+		}
+
+		n.latch.Unlock()
+		n = child
+	}
+}
+
+// Min returns the smallest key along with its value, or false if the
+// ConcurrentTree is empty.
+func (c *ConcurrentTree[K, V]) Min() (K, V, bool) {
+	return c.edge(func(it *citems[K, V]) int { return 0 })
+}
+
+// Max returns the largest key along with its value, or false if the
+// ConcurrentTree is empty.
+func (c *ConcurrentTree[K, V]) Max() (K, V, bool) {
+	return c.edge(func(it *citems[K, V]) int { return len(it.keys) })
+}
+
+// edge descends to a leaf through the child whose index pick returns for every
+// node, and returns the key of that leaf at the same index, or its last key if
+// the index is out of range.
+func (c *ConcurrentTree[K, V]) edge(pick func(*citems[K, V]) int) (k K, v V, ok bool) {
+	at := func(it *citems[K, V]) (K, V, bool) {
+		if len(it.keys) == 0 {
+			var (
+				k K
+				v V
+			)
+
+			return k, v, false
+		}
+
+		i := min(pick(it), len(it.keys)-1)
+		return it.keys[i], it.values[i], true
+	}
+
+	if c.optimistic {
+		c.readOptimistic(func(it *citems[K, V]) (int, bool) {
+			if !it.leaf() {
+				return pick(it), true
+			}
+
+			k, v, ok = at(it)
+			return 0, false
+		})
+
+		return k, v, ok
+	}
+
+	n := c.rlockRoot()
+	for it := n.items.Load(); !it.leaf(); it = n.items.Load() {
+		child := it.children[pick(it)]
+		child.latch.RLock()
+		n.latch.RUnlock()
+		n = child
+	}
+
+	k, v, ok = at(n.items.Load())
+	n.latch.RUnlock()
+
+	return k, v, ok
+}
+
+// Ascend calls fn for every key-value pair in ascending key order. The read
+// latches of the nodes on the path to the current key are held while fn runs,
+// so fn must not modify the ConcurrentTree, unless it was created by
+// NewOptimisticConcurrentTree, which holds none. The traversal stops as soon as
+// fn returns false.
+func (c *ConcurrentTree[K, V]) Ascend(fn func(key K, value V) bool) {
+	c.ascend(nil, nil, fn)
+}
+
+// AscendRange calls fn for every key-value pair whose key k satisfies
+// from <= k < to, in ascending key order, as Ascend does.
+func (c *ConcurrentTree[K, V]) AscendRange(from, to K, fn func(key K, value V) bool) {
+	c.ascend(&from, &to, fn)
+}
+
+func (c *ConcurrentTree[K, V]) ascend(lo, hi *K, fn func(K, V) bool) {
+	if !c.optimistic {
+		c.rlockRoot().ascendLatched(c.cmp, lo, hi, fn)
+		return
+	}
+
+	s := &optimisticScan[K, V]{cmp: c.cmp, lo: lo, hi: hi, fn: fn}
+	for ; ; runtime.Gosched() {
+		if n, v, ok := c.optimisticRoot(); ok {
+			if _, valid := s.scan(n, v); valid {
+				return
+			}
+		}
+	}
+}
+
+// Delete removes the given key, returning the value stored under it, if any,
+// and whether it existed.
+func (c *ConcurrentTree[K, V]) Delete(key K) (V, bool) {
+	_, v, ok := c.remove(key, kremoveKey)
+	return v, ok
+}
+
+// DeleteMin removes the smallest key, returning it along with its value, or
+// false if the ConcurrentTree is empty.
+func (c *ConcurrentTree[K, V]) DeleteMin() (K, V, bool) {
+	var zero K
+	return c.remove(zero, kremoveMin)
+}
+
+// DeleteMax removes the largest key, returning it along with its value, or
+// false if the ConcurrentTree is empty.
+func (c *ConcurrentTree[K, V]) DeleteMax() (K, V, bool) {
+	var zero K
+	return c.remove(zero, kremoveMax)
+}
+
+// remove removes k, or the smallest or largest key, depending on typ, and
+// returns the removed key and value and whether anything was removed. It
+// follows knode.remove iteratively, latching each child, and its siblings if
+// the child must grow, before releasing the parent. A key found in an internal
+// node is replaced by its predecessor, so that node stays latched until the
+// predecessor has been removed from the leaf below.
+func (c *ConcurrentTree[K, V]) remove(k K, typ kremoveType) (rk K, rv V, ok bool) {
+	// the root pointer stays locked while n is the root, which may shrink
+	c.mu.Lock()
+	rootLocked := true
+
+	n := c.root.Load()
+	n.latch.Lock()
+
+	// holder is the internal node whose entry at index hi is being replaced
+	var (
+		holder *cnode[K, V]
+		hi     int
+	)
+
+	for {
+		it := n.items.Load()
+
+		var (
+			i     int
+			found bool
+		)
+
+		switch typ {
+		case kremoveMin:
+			i = 0
+
+		case kremoveMax:
+			i = len(it.keys)
+
+		default:
+			i, found = it.search(c.cmp, k)
+		}
+
+		if it.leaf() {
+			j := -1
+			switch {
+			case found:
+				j = i
+
+			case typ == kremoveMin && len(it.keys) > 0:
+				j = 0
+
+			case typ == kremoveMax && len(it.keys) > 0:
+				j = len(it.keys) - 1
+			}
+
+			if j >= 0 {
+				rk, rv, ok = it.keys[j], it.values[j], true
+				c.size.Add(-1)
+
+				leaf := it.clone()
+				leaf.removeAt(j)
+
+				n.begin()
+				if holder != nil {
+					// rk and rv are the predecessor of the key to remove, which
+					// moves into the holder along with its removal from the leaf
+					holder.begin()
+
+					held := holder.items.Load().clone()
+					rk, rv, held.keys[hi], held.values[hi] = held.keys[hi], held.values[hi], rk, rv
+
+					holder.items.Store(held)
+				}
+
+				n.items.Store(leaf)
+				if holder != nil {
+					holder.end()
+				}
+
+				n.end()
+			}
+
+			if holder != nil {
+				holder.latch.Unlock()
+			}
+
+			n.latch.Unlock()
+			if rootLocked {
+				c.mu.Unlock()
+			}
+
+			return rk, rv, ok
+		}
+
+		child := it.children[i]
+		child.latch.Lock()
+
+		if len(child.items.Load().keys) <= c.t-1 {
+			c.growChild(n, i)
+
+			if it = n.items.Load(); rootLocked && len(it.keys) == 0 {
+				// the last two children of the root were merged, which leaves
+				// the old root a valid path to them for optimistic readers
+				root := it.children[0]
+				root.latch.Lock()
+				c.root.Store(root)
+				n.latch.Unlock()
+				n = root
+			}
+
+			// redo the step, as the key may have moved between the nodes
+			continue
+		}
+
+		if found {
+			holder, hi = n, i
+			typ = kremoveMax
+		} else {
+			n.latch.Unlock()
+		}
+
+		if rootLocked {
+			c.mu.Unlock()
+			rootLocked = false
+		}
+
+		n = child
+	}
+}
+
+// growChild grows the latched child at index i of the latched node n as
+// knode.growChild does, latching the siblings it borrows from or merges with
+// for the duration of the call. Keys may move between the children, so all of
+// them are unlatched afterwards and the caller must descend anew.
+func (c *ConcurrentTree[K, V]) growChild(n *cnode[K, V], i int) {
+	it := n.items.Load()
+	latched := []*cnode[K, V]{it.children[i]}
+
+	if i > 0 {
+		it.children[i-1].latch.Lock()
+		latched = append(latched, it.children[i-1])
+	}
+
+	if i < len(it.keys) {
+		it.children[i+1].latch.Lock()
+		latched = append(latched, it.children[i+1])
+	}
+
+	// a sibling merged away is marked as well, so that readers which reached
+	// it before the merge retry
+	n.begin()
+	for _, l := range latched {
+		l.begin()
+	}
+
+	n.growChild(i, c.t)
+
+	for _, l := range latched {
+		l.end()
+	}
+
+	n.end()
+
+	for _, l := range latched {
+		l.latch.Unlock()
+	}
+}
+
+// ascendLatched calls fn as knode.ascend does for the subtree rooted at n, whose
+// read latch the caller must have acquired and which it releases. Every child is
+// read latched before it is visited and released afterwards.
+func (n *cnode[K, V]) ascendLatched(cmp func(a, b K) int, lo, hi *K, fn func(K, V) bool) bool {
+	defer n.latch.RUnlock()
+
+	it := n.items.Load()
+
+	i := 0
+	if lo != nil {
+		i, _ = it.search(cmp, *lo)
+	}
+
+	visit := func(child *cnode[K, V]) bool {
+		child.latch.RLock()
+		return child.ascendLatched(cmp, lo, hi, fn)
+	}
+
+	for ; i < len(it.keys); i++ {
+		if !it.leaf() && !visit(it.children[i]) {
+			return false
+		}
+
+		if hi != nil && cmp(it.keys[i], *hi) >= 0 {
+			return false
+		}
+
+		if !fn(it.keys[i], it.values[i]) {
+			return false
+		}
+	}
+
+	if !it.leaf() {
+		return visit(it.children[len(it.children)-1])
+	}
+
+	return true
+}
+
+// scan visits the subtree rooted at n, which had version v when it was reached,
+// as ascendLatched does, skipping every key up to the last one visited. It
+// returns whether the scan continues past the subtree, or false as the second
+// result if a node on the path changed underneath it, in which case the scan
+// must restart. The path is validated before fn is called with any key.
+func (s *optimisticScan[K, V]) scan(n *cnode[K, V], v uint64) (more, valid bool) {
+	s.path = s.path.push(n, v)
+	defer func() { s.path = s.path[:len(s.path)-1] }()
+
+	it := n.items.Load()
+
+	i := 0
+	switch {
+	case s.visited:
+		i, _ = it.search(s.cmp, s.last)
+
+	case s.lo != nil:
+		i, _ = it.search(s.cmp, *s.lo)
+	}
+
+	for ; i <= len(it.keys); i++ {
+		if !it.leaf() {
+			child := it.children[i]
+
+			cv, stable := child.stable()
+			if !stable || s.path.changed() {
+				return false, false
+			}
+
+			if more, valid := s.scan(child, cv); !more || !valid {
+				return more, valid
+			}
+		}
+
+		if i == len(it.keys) {
+			break
+		}
+
+		k, val := it.keys[i], it.values[i]
+		if s.path.changed() {
+			return false, false
+		}
+
+		if s.visited && s.cmp(k, s.last) <= 0 {
+			continue
+		}
+
+		if s.hi != nil && s.cmp(k, *s.hi) >= 0 {
+			return false, true
+		}
+
+		s.last, s.visited = k, true
+		if !s.fn(k, val) {
+			return false, true
+		}
+	}
+
+	return true, true
+}
+
+func (p cpath[K, V]) push(n *cnode[K, V], v uint64) cpath[K, V] {
+	return append(p, struct {
+		n *cnode[K, V]
+		v uint64
+	}{n, v})
+}
+
+// changed returns true if any node on p was modified since it was reached.
+func (p cpath[K, V]) changed() bool {
+	for _, e := range p {
+		if e.n.changed(e.v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stable returns the version of n, or false if a writer is modifying n.
+func (n *cnode[K, V]) stable() (uint64, bool) {
+	v := n.version.Load()
+	return v, v%2 == 0
+}
+
+// changed returns true if n was modified since it had version v.
+func (n *cnode[K, V]) changed(v uint64) bool {
+	return n.version.Load() != v
+}
+
+// begin marks n, which the caller must have latched exclusively, as being
+// modified until end is called. A modification spanning several nodes marks
+// all of them before replacing the contents of any, so that no optimistic
+// reader combines old and new contents.
+func (n *cnode[K, V]) begin() {
+	n.version.Add(1)
+}
+
+func (n *cnode[K, V]) end() {
+	n.version.Add(1)
+}
+
+// update replaces the contents of n, which the caller must have latched
+// exclusively, by a copy modified by fn.
+func (n *cnode[K, V]) update(fn func(it *citems[K, V])) {
+	it := n.items.Load().clone()
+	fn(it)
+
+	n.begin()
+	n.items.Store(it)
+	n.end()
+}
+
+// splitChild splits the full child at index i around its median, which moves up
+// into n between the two halves, as knode.splitChild does. Both nodes must be
+// marked as being modified.
+func (n *cnode[K, V]) splitChild(i, t int) {
+	it := n.items.Load().clone()
+	child := it.children[i]
+	full := child.items.Load()
+
+	left := &citems[K, V]{
+		keys:   slices.Clone(full.keys[:t-1]),
+		values: slices.Clone(full.values[:t-1]),
+	}
+
+	right := &citems[K, V]{
+		keys:   slices.Clone(full.keys[t:]),
+		values: slices.Clone(full.values[t:]),
+	}
+
+	if !full.leaf() {
+		left.children = slices.Clone(full.children[:t])
+		right.children = slices.Clone(full.children[t:])
+	}
+
+	it.insertAt(i, full.keys[t-1], full.values[t-1])
+	it.children = slices.Insert(it.children, i+1, newCNode(right))
+
+	child.items.Store(left)
+	n.items.Store(it)
+}
+
+// growChild ensures the child at index i holds at least t keys, as
+// knode.growChild does. n, the child and its siblings must be marked as being
+// modified.
+func (n *cnode[K, V]) growChild(i, t int) {
+	it := n.items.Load().clone()
+
+	switch {
+	case i > 0 && len(it.children[i-1].items.Load().keys) > t-1:
+		left, child := it.children[i-1], it.children[i]
+		li, ci := left.items.Load().clone(), child.items.Load().clone()
+
+		lk, lv := li.removeAt(len(li.keys) - 1)
+		ci.insertAt(0, it.keys[i-1], it.values[i-1])
+		it.keys[i-1], it.values[i-1] = lk, lv
+
+		if !li.leaf() {
+			ci.children = slices.Insert(ci.children, 0, li.children[len(li.children)-1])
+			li.children = slices.Delete(li.children, len(li.children)-1, len(li.children))
+		}
+
+		left.items.Store(li)
+		child.items.Store(ci)
+
+	case i < len(it.keys) && len(it.children[i+1].items.Load().keys) > t-1:
+		child, right := it.children[i], it.children[i+1]
+		ci, ri := child.items.Load().clone(), right.items.Load().clone()
+
+		rk, rv := ri.removeAt(0)
+		ci.keys = append(ci.keys, it.keys[i])
+		ci.values = append(ci.values, it.values[i])
+		it.keys[i], it.values[i] = rk, rv
+
+		if !ri.leaf() {
+			ci.children = append(ci.children, ri.children[0])
+			ri.children = slices.Delete(ri.children, 0, 1)
+		}
+
+		right.items.Store(ri)
+		child.items.Store(ci)
+
+	default:
+		if i >= len(it.keys) {
+			i--
+		}
+
+		child, right := it.children[i], it.children[i+1]
+		ci, ri := child.items.Load().clone(), right.items.Load()
+
+		sk, sv := it.removeAt(i)
+		it.children = slices.Delete(it.children, i+1, i+2)
+
+		ci.keys = append(append(ci.keys, sk), ri.keys...)
+		ci.values = append(append(ci.values, sv), ri.values...)
+		ci.children = append(ci.children, ri.children...)
+
+		child.items.Store(ci)
+	}
+
+	n.items.Store(it)
+}
+
+// clone returns a copy of it which may be modified.
+func (it *citems[K, V]) clone() *citems[K, V] {
+	return &citems[K, V]{
+		keys:     slices.Clone(it.keys),
+		values:   slices.Clone(it.values),
+		children: slices.Clone(it.children),
+	}
+}
+
+func (it *citems[K, V]) leaf() bool {
+	return len(it.children) == 0
+}
+
+// search returns the smallest index i, s.t. it.keys[i] >= k, and whether
+// it.keys[i] equals k.
+func (it *citems[K, V]) search(cmp func(a, b K) int, k K) (int, bool) {
+	i := sort.Search(len(it.keys), func(i int) bool {
+		return cmp(it.keys[i], k) >= 0
+	})
+
+	return i, i < len(it.keys) && cmp(it.keys[i], k) == 0
+}
+
+func (it *citems[K, V]) insertAt(i int, k K, v V) {
+	it.keys = slices.Insert(it.keys, i, k)
+	it.values = slices.Insert(it.values, i, v)
+}
+
+func (it *citems[K, V]) removeAt(i int) (K, V) {
+	k, v := it.keys[i], it.values[i]
+
+	it.keys = slices.Delete(it.keys, i, i+1)
+	it.values = slices.Delete(it.values, i, i+1)
+
+	return k, v
+}
@@ -0,0 +1,300 @@
+package btree_test
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrentTrees holds the constructors of ConcurrentTrees with latched and
+// optimistic readers.
+var concurrentTrees = map[string]func(int, func(a, b int) int) (*btree.ConcurrentTree[int, int], error){
+	"latched":    btree.NewConcurrentTree[int, int],
+	"optimistic": btree.NewOptimisticConcurrentTree[int, int],
+}
+
+func TestConcurrentTree(t *testing.T) {
+	for name, newTree := range concurrentTrees {
+		t.Run(name, func(t *testing.T) {
+			testConcurrentTree(t, newTree)
+		})
+	}
+}
+
+func testConcurrentTree(t *testing.T, newTree func(int, func(a, b int) int) (*btree.ConcurrentTree[int, int], error)) {
+	_, err := newTree(1, cmp.Compare[int])
+	require.Error(t, err)
+
+	_, err = newTree(2, nil)
+	require.Error(t, err)
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			c, err := newTree(minDegree, cmp.Compare[int])
+			require.NoError(t, err)
+
+			_, _, ok := c.Min()
+			require.False(t, ok)
+
+			_, ok = c.Delete(1)
+			require.False(t, ok)
+
+			perm := rng.Perm(1000)
+			for _, k := range perm {
+				_, replaced := c.Set(k, -k)
+				require.False(t, replaced)
+			}
+
+			require.NoError(t, c.Verify())
+			require.Equal(t, 1000, c.Len())
+
+			old, replaced := c.Set(7, 7)
+			require.True(t, replaced)
+			require.Equal(t, -7, old)
+
+			v, ok := c.Get(7)
+			require.True(t, ok)
+			require.Equal(t, 7, v)
+			require.False(t, c.Has(1000))
+
+			k, v, ok := c.Min()
+			require.True(t, ok)
+			require.Equal(t, 0, k)
+			require.Equal(t, 0, v)
+
+			k, _, ok = c.Max()
+			require.True(t, ok)
+			require.Equal(t, 999, k)
+
+			var keys []int
+			c.AscendRange(10, 20, func(k, _ int) bool {
+				keys = append(keys, k)
+				return true
+			})
+			require.Equal(t, []int{10, 11, 12, 13, 14, 15, 16, 17, 18, 19}, keys)
+
+			k, _, ok = c.DeleteMin()
+			require.True(t, ok)
+			require.Equal(t, 0, k)
+
+			k, _, ok = c.DeleteMax()
+			require.True(t, ok)
+			require.Equal(t, 999, k)
+
+			for _, k := range perm {
+				v, ok := c.Delete(k)
+				if k == 0 || k == 999 {
+					require.False(t, ok)
+					continue
+				}
+
+				require.True(t, ok)
+				if k != 7 {
+					require.Equal(t, -k, v)
+				}
+			}
+
+			require.NoError(t, c.Verify())
+			require.Zero(t, c.Len())
+		})
+	}
+}
+
+func TestConcurrentTreeConcurrent(t *testing.T) {
+	for name, newTree := range concurrentTrees {
+		t.Run(name, func(t *testing.T) {
+			testConcurrentTreeConcurrent(t, newTree)
+		})
+	}
+}
+
+func testConcurrentTreeConcurrent(t *testing.T, newTree func(int, func(a, b int) int) (*btree.ConcurrentTree[int, int], error)) {
+	c, err := newTree(3, cmp.Compare[int])
+	require.NoError(t, err)
+
+	const (
+		writers = 8
+		n       = 2000
+	)
+
+	// every writer owns a disjoint range, sets it and then deletes half of it,
+	// while readers scan concurrently
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(2)
+
+		// the shared rng must not be used concurrently
+		perm := rng.Perm(n)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for _, i := range perm {
+				c.Set(w*n+i, i)
+			}
+
+			for i := 0; i < n; i += 2 {
+				if _, ok := c.Delete(w*n + i); !ok {
+					t.Errorf("missing key %d", w*n+i)
+				}
+			}
+		}(w)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < 10; i++ {
+				prev := -1
+				c.Ascend(func(k, _ int) bool {
+					if k <= prev {
+						t.Errorf("key %d after %d", k, prev)
+					}
+
+					prev = k
+					return true
+				})
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	require.NoError(t, c.Verify())
+	require.Equal(t, writers*n/2, c.Len())
+
+	for k := 0; k < writers*n; k++ {
+		v, ok := c.Get(k)
+		require.Equal(t, k%2 == 1, ok)
+		if ok {
+			require.Equal(t, k%n, v)
+		}
+	}
+}
+
+func TestOptimisticConcurrentTreeMixed(t *testing.T) {
+	c, err := btree.NewOptimisticConcurrentTree[int, int](2, cmp.Compare[int])
+	require.NoError(t, err)
+
+	const (
+		writers = 4
+		readers = 8
+		keys    = 512
+		ops     = 5000
+	)
+
+	// every fourth key stays in place throughout
+	for k := 0; k < keys; k += 4 {
+		c.Set(k, -k)
+	}
+
+	// writers set and delete the other keys, so that nodes split and merge
+	// underneath the readers, which check that they always find the keys in
+	// place and never observe a value under the wrong key nor keys out of order
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+
+		// the shared rng must not be used concurrently
+		r := rand.New(rand.NewSource(rng.Int63()))
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < ops; i++ {
+				k := 4*r.Intn(keys/4) + 1 + r.Intn(3)
+				if r.Intn(2) == 0 {
+					c.Delete(k)
+				} else {
+					c.Set(k, -k)
+				}
+			}
+		}()
+	}
+
+	for rd := 0; rd < readers; rd++ {
+		wg.Add(1)
+
+		r := rand.New(rand.NewSource(rng.Int63()))
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < ops/10; i++ {
+				for j := 0; j < 10; j++ {
+					k := r.Intn(keys)
+					if v, ok := c.Get(k); ok && v != -k || !ok && k%4 == 0 {
+						t.Errorf("value %d under key %d: %t", v, k, ok)
+					}
+				}
+
+				if k, _, ok := c.Min(); !ok || k != 0 {
+					t.Errorf("min key %d: %t", k, ok)
+				}
+
+				from := 4 * r.Intn(keys/4)
+				prev := from - 1
+				c.AscendRange(from, from+keys/8, func(k, v int) bool {
+					if k <= prev || k >= from+keys/8 || v != -k || k/4 > prev/4+1 {
+						t.Errorf("key %d with value %d after %d", k, v, prev)
+					}
+
+					prev = k
+					return true
+				})
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	require.NoError(t, c.Verify())
+
+	n := 0
+	c.Ascend(func(k, v int) bool {
+		require.Equal(t, -k, v)
+		n++
+
+		return true
+	})
+	require.Equal(t, c.Len(), n)
+}
+
+func TestOptimisticConcurrentTreeAscendModify(t *testing.T) {
+	c, err := btree.NewOptimisticConcurrentTree[int, int](2, cmp.Compare[int])
+	require.NoError(t, err)
+
+	for k := 0; k < 100; k++ {
+		c.Set(k, k)
+	}
+
+	// optimistic scans hold no latches, so fn may modify the tree, and every key
+	// present throughout the scan is visited exactly once
+	var keys []int
+	c.Ascend(func(k, _ int) bool {
+		keys = append(keys, k)
+		if k < 100 && k%2 == 0 {
+			c.Delete(k + 1)
+			c.Set(k+1000, k)
+		}
+
+		return true
+	})
+
+	var want []int
+	for k := 0; k < 100; k += 2 {
+		want = append(want, k)
+	}
+
+	for k := 0; k < 100; k += 2 {
+		want = append(want, k+1000)
+	}
+
+	require.Equal(t, want, keys)
+	require.NoError(t, c.Verify())
+	require.Equal(t, 100, c.Len())
+}
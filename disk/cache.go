@@ -0,0 +1,108 @@
+package disk
+
+import (
+	"container/list"
+	"slices"
+	"sync"
+)
+
+// cache holds the most recently used nodes of a Tree, decoded, up to a budget
+// in bytes, with every node accounting for a page. Leaves and internal nodes
+// are kept in separate LRU lists, and leaves are evicted before any internal
+// node, so that the nodes near the root, which every operation passes, stay
+// in the cache while cold leaves make way for one another. Nodes are handed
+// out and taken in as copies, so that a write modifying its nodes before
+// putting them never affects the cache.
+type cache struct {
+	mu       sync.Mutex
+	capacity int
+
+	// lists holds the leaves first and the internal nodes second, each with
+	// the most recently used node at the front
+	lists [2]*list.List
+	items map[uint64]*list.Element
+}
+
+// newCache returns a cache of the given budget for pages of the given size.
+func newCache(budget, pageSize int) *cache {
+	return &cache{
+		capacity: budget / pageSize,
+		lists:    [2]*list.List{list.New(), list.New()},
+		items:    make(map[uint64]*list.Element),
+	}
+}
+
+// len returns the number of cached nodes.
+func (c *cache) len() int {
+	return c.lists[0].Len() + c.lists[1].Len()
+}
+
+// list returns the LRU list holding nodes like n.
+func (c *cache) list(n *node) *list.List {
+	if n.leaf() {
+		return c.lists[0]
+	}
+
+	return c.lists[1]
+}
+
+// get returns a copy of the cached node of the given id, if any.
+func (c *cache) get(id uint64) (*node, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[id]
+	if !ok {
+		return nil, false
+	}
+
+	n := elem.Value.(*node)
+	c.list(n).MoveToFront(elem)
+
+	return n.clone(), true
+}
+
+// add caches a copy of n, replacing the node of the same id, and evicts the
+// least recently used leaves, then internal nodes, beyond the capacity.
+func (c *cache) add(n *node) {
+	if c.capacity == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a node may have turned from a leaf into an internal node or vice versa
+	if elem, ok := c.items[n.id]; ok {
+		c.list(elem.Value.(*node)).Remove(elem)
+	}
+
+	c.items[n.id] = c.list(n).PushFront(n.clone())
+
+	for c.len() > c.capacity {
+		l := c.lists[0]
+		if l.Len() == 0 {
+			l = c.lists[1]
+		}
+
+		oldest := l.Back()
+		l.Remove(oldest)
+		delete(c.items, oldest.Value.(*node).id)
+	}
+}
+
+// remove evicts the node of the given id, if cached.
+func (c *cache) remove(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[id]; ok {
+		c.list(elem.Value.(*node)).Remove(elem)
+		delete(c.items, id)
+	}
+}
+
+// clone returns a copy of n that can be modified without affecting n.
+func (n *node) clone() *node {
+	return &node{id: n.id, entries: slices.Clone(n.entries), children: slices.Clone(n.children)}
+}
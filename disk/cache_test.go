@@ -0,0 +1,78 @@
+package disk_test
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/alexanderbez/btree/disk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	for _, pages := range []int{0, 1, 3, 50} {
+		t.Run(fmt.Sprintf("%d pages", pages), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tree")
+			rng := rand.New(rand.NewSource(int64(pages)))
+
+			opts := []disk.Option{
+				disk.WithPageSize(256),
+				disk.WithMaxEntrySize(16),
+				disk.WithCacheSize(pages * 256),
+				disk.WithSyncPolicy(disk.SyncNever),
+				disk.WithCheckpointSize(64 * 256),
+			}
+
+			tr, err := disk.Open(path, testCodec{}, opts...)
+			require.NoError(t, err)
+
+			ref, err := btree.New(2)
+			require.NoError(t, err)
+
+			// the cache stays coherent with the pages across every kind of write
+			for i := 0; i < 5000; i++ {
+				k := uint64(rng.Intn(1000))
+
+				switch rng.Intn(3) {
+				case 0:
+					_, err := tr.Delete(testEntry{key: k})
+					require.NoError(t, err)
+					ref.Delete(testEntry{key: k})
+
+				default:
+					e := testEntry{key: k, value: fmt.Sprint(i)}
+					require.NoError(t, tr.Insert(e))
+					ref.Insert(e)
+				}
+
+				if i%1000 == 0 {
+					require.NoError(t, tr.Verify())
+				}
+			}
+
+			requireSame(t, ref, tr)
+
+			for i := 0; i < 1000; i++ {
+				k := uint64(rng.Intn(1000))
+				got, err := tr.Search(testEntry{key: k})
+				require.NoError(t, err)
+				require.Equal(t, ref.Search(testEntry{key: k}), got)
+			}
+
+			// leaves are evicted first, so once the budget covers the internal
+			// nodes, the root, which every search passes, stays cached
+			n, root := tr.Cached()
+			require.LessOrEqual(t, n, pages)
+			require.True(t, pages < 50 || root)
+
+			require.NoError(t, tr.Close())
+
+			tr, err = disk.Open(path, testCodec{}, opts...)
+			require.NoError(t, err)
+			requireSame(t, ref, tr)
+			require.NoError(t, tr.Close())
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package disk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/alexanderbez/btree"
+)
+
+// Verify checks the structural invariants of the Tree and the accounting of its
+// pages, returning an error describing the first violation found.
+func (tr *Tree) Verify() error {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	t, m := tr.p.meta.minDegree, tr.p.meta
+
+	// every page but the meta data must be either reachable or free, once
+	seen := make([]bool, m.numPages)
+	mark := func(id uint64) error {
+		if id == 0 || id >= m.numPages || seen[id] {
+			return fmt.Errorf("page %d referenced twice or out of range", id)
+		}
+
+		seen[id] = true
+		return nil
+	}
+
+	var (
+		prev         btree.Entry
+		count, depth int
+	)
+
+	var walk func(id uint64, level int) error
+	walk = func(id uint64, level int) error {
+		if err := mark(id); err != nil {
+			return err
+		}
+
+		page, err := tr.p.read(id)
+		if err != nil {
+			return err
+		}
+
+		n, err := tr.decodeNode(id, page)
+		if err != nil {
+			return err
+		}
+
+		if cached, ok := tr.cache.get(id); ok && (!slices.Equal(cached.entries, n.entries) || !slices.Equal(cached.children, n.children)) {
+			return fmt.Errorf("cached node %d differs from its page", id)
+		}
+
+		isRoot := id == m.root
+		switch {
+		case len(n.entries) > 2*t-1:
+			return fmt.Errorf("node %d is overfull: %d > %d", id, len(n.entries), 2*t-1)
+
+		case !isRoot && len(n.entries) < t-1:
+			return fmt.Errorf("node %d is underfull: %d < %d", id, len(n.entries), t-1)
+
+		case isRoot && !n.leaf() && len(n.entries) == 0:
+			return fmt.Errorf("internal root %d is empty", id)
+		}
+
+		if n.leaf() {
+			if depth == 0 {
+				depth = level
+			} else if depth != level {
+				return fmt.Errorf("leaf %d at level %d, expected %d", id, level, depth)
+			}
+		}
+
+		for i := 0; i <= len(n.entries); i++ {
+			if !n.leaf() {
+				if err := walk(n.children[i], level+1); err != nil {
+					return err
+				}
+			}
+
+			if i == len(n.entries) {
+				break
+			}
+
+			if prev != nil && prev.Compare(n.entries[i]) >= 0 {
+				return fmt.Errorf("entries out of order in node %d", id)
+			}
+
+			prev = n.entries[i]
+			count++
+		}
+
+		return nil
+	}
+
+	if err := walk(m.root, 1); err != nil {
+		return err
+	}
+
+	if count != m.size {
+		return fmt.Errorf("tree holds %d entries but size is %d", count, m.size)
+	}
+
+	for id := m.freeHead; id != 0; {
+		if err := mark(id); err != nil {
+			return err
+		}
+
+		page, err := tr.p.read(id)
+		if err != nil {
+			return err
+		}
+
+		id = binary.LittleEndian.Uint64(page[pageHeader:])
+	}
+
+	for id := uint64(1); id < m.numPages; id++ {
+		if !seen[id] {
+			return fmt.Errorf("page %d leaked", id)
+		}
+	}
+
+	return nil
+}
+
+// NumPages returns the number of pages of the file of the Tree.
+func (tr *Tree) NumPages() uint64 {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	return tr.p.meta.numPages
+}
+
+// LogCheckpoint logs a checkpoint without writing its pages to the file, as if
+// the process crashed right afterwards.
+func (tr *Tree) LogCheckpoint() error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if err := tr.wal.append(walCheckpoint, tr.p.image()); err != nil {
+		return err
+	}
+
+	return tr.wal.sync()
+}
+
+// Cached returns the number of cached nodes and whether the root is one of them.
+func (tr *Tree) Cached() (int, bool) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	tr.cache.mu.Lock()
+	defer tr.cache.mu.Unlock()
+
+	_, root := tr.cache.items[tr.p.meta.root]
+	return tr.cache.len(), root
+}
@@ -0,0 +1,117 @@
+package disk
+
+import (
+	"time"
+)
+
+const (
+	// DefaultPageSize defines the page size of a Tree created without
+	// WithPageSize, which matches the block size of most file systems.
+	DefaultPageSize = 4096
+
+	// DefaultMaxEntrySize defines the maximum size of an encoded entry of a Tree
+	// created without WithMaxEntrySize.
+	DefaultMaxEntrySize = 128
+
+	// DefaultSyncInterval defines the interval at which the write-ahead log of a
+	// Tree opened with SyncInterval but without WithSyncInterval is synced.
+	DefaultSyncInterval = time.Second
+
+	// DefaultCacheSize defines the budget in bytes of the page cache of a Tree
+	// opened without WithCacheSize.
+	DefaultCacheSize = 8 << 20
+
+	// DefaultCheckpointSize defines the number of bytes of pages a Tree opened
+	// without WithCheckpointSize writes between checkpoints.
+	DefaultCheckpointSize = 4 << 20
+
+	minPageSize = 128
+	maxPageSize = 1 << 16
+)
+
+// Option configures a Tree opened through Open. The page size and maximum entry
+// size only apply when the file of the Tree is created.
+type Option func(*options)
+
+// options holds the configuration assembled from a set of Options.
+type options struct {
+	pageSize       int
+	maxEntrySize   int
+	syncPolicy     SyncPolicy
+	syncInterval   time.Duration
+	checkpointSize int
+	cacheSize      int
+}
+
+// WithPageSize makes the Tree store every node in a page of n bytes, where n
+// lies within [128, 65536] and should be a multiple of the block size of the
+// underlying file system. Larger pages hold more entries per node, which
+// makes the tree shallower at the cost of reading and writing more per node.
+func WithPageSize(n int) Option {
+	return func(o *options) {
+		o.pageSize = n
+	}
+}
+
+// WithMaxEntrySize bounds the size of every entry as encoded by the Codec of
+// the Tree to n bytes. Together with the page size, it determines the minimum
+// degree of the Tree, as the largest one for which a full node still fits a
+// page.
+func WithMaxEntrySize(n int) Option {
+	return func(o *options) {
+		o.maxEntrySize = n
+	}
+}
+
+// WithSyncPolicy makes the Tree sync its write-ahead log according to p, which
+// defaults to SyncAlways.
+func WithSyncPolicy(p SyncPolicy) Option {
+	return func(o *options) {
+		o.syncPolicy = p
+	}
+}
+
+// WithSyncInterval makes the Tree sync its write-ahead log every d, as with
+// SyncInterval.
+func WithSyncInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.syncPolicy, o.syncInterval = SyncInterval, d
+	}
+}
+
+// WithCheckpointSize makes the Tree checkpoint once the pages written since the
+// last checkpoint exceed n bytes. Until then, they are held in memory, while
+// only the write-ahead log grows on disk.
+func WithCheckpointSize(n int) Option {
+	return func(o *options) {
+		o.checkpointSize = n
+	}
+}
+
+// WithCacheSize makes the Tree keep its most recently used nodes decoded in
+// memory, up to n bytes, with every node accounting for a page. The root and
+// the internal nodes near it, which every operation passes, thus tend to stay
+// cached, while the leaves least recently used are evicted. A budget of less
+// than a page disables the cache.
+func WithCacheSize(n int) Option {
+	return func(o *options) {
+		o.cacheSize = n
+	}
+}
+
+// newOptions returns the configuration assembled from the given Options.
+func newOptions(opts []Option) options {
+	o := options{
+		pageSize:       DefaultPageSize,
+		maxEntrySize:   DefaultMaxEntrySize,
+		syncInterval:   DefaultSyncInterval,
+		checkpointSize: DefaultCheckpointSize,
+		cacheSize:      DefaultCacheSize,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
@@ -0,0 +1,214 @@
+package disk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"sort"
+
+	"github.com/alexanderbez/btree"
+)
+
+// Every page of the file starts with a header holding the CRC-32C checksum of
+// the rest of the page, followed by the kind of the page and, for nodes, the
+// number of their entries. A node page continues with the page ids of the
+// children of an internal node, and then every entry as its length, as a
+// uint16, followed by its encoding. A free page holds the id of the next free
+// page instead. All integers are little-endian.
+//
+// The first page holds the meta data of the tree rather than a node: its
+// layout and configuration, the id of the root page, the number of entries and
+// pages, and the head of the list of free pages.
+
+const (
+	pageHeader  = 8
+	childSize   = 8
+	entryHeader = 2
+
+	kindLeaf  = 1
+	kindInner = 2
+	kindFree  = 3
+
+	// metaSize defines the number of bytes of the first page holding the meta
+	// data.
+	metaSize = 64
+
+	formatVersion = 1
+)
+
+// magic identifies the files of a Tree.
+var magic = [8]byte{'g', 'o', 'b', 't', 'r', 'e', 'e', 0}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type (
+	// meta holds the meta data of a Tree.
+	meta struct {
+		pageSize     int
+		maxEntrySize int
+		minDegree    int
+		root         uint64
+		size         int
+		numPages     uint64
+		freeHead     uint64
+	}
+
+	// node holds the decoded contents of a node page.
+	node struct {
+		id       uint64
+		entries  []btree.Entry
+		children []uint64
+	}
+)
+
+// minDegree returns the largest minimum degree t for which a node of 2t-1
+// entries of up to maxEntrySize bytes and 2t children fits a page.
+func minDegree(pageSize, maxEntrySize int) int {
+	return (pageSize - pageHeader + entryHeader + maxEntrySize) / (2 * (childSize + entryHeader + maxEntrySize))
+}
+
+func (m *meta) encode(b []byte) {
+	copy(b[4:], magic[:])
+	binary.LittleEndian.PutUint32(b[12:], formatVersion)
+	binary.LittleEndian.PutUint32(b[16:], uint32(m.pageSize))
+	binary.LittleEndian.PutUint32(b[20:], uint32(m.maxEntrySize))
+	binary.LittleEndian.PutUint32(b[24:], uint32(m.minDegree))
+	binary.LittleEndian.PutUint64(b[32:], m.root)
+	binary.LittleEndian.PutUint64(b[40:], uint64(m.size))
+	binary.LittleEndian.PutUint64(b[48:], m.numPages)
+	binary.LittleEndian.PutUint64(b[56:], m.freeHead)
+	binary.LittleEndian.PutUint32(b, crc32.Checksum(b[4:metaSize], crcTable))
+}
+
+func (m *meta) decode(b []byte) error {
+	if binary.LittleEndian.Uint32(b) != crc32.Checksum(b[4:metaSize], crcTable) {
+		return fmt.Errorf("%w: meta data checksum mismatch", ErrCorrupt)
+	}
+
+	if [8]byte(b[4:12]) != magic {
+		return fmt.Errorf("%w: not a tree file", ErrCorrupt)
+	}
+
+	if v := binary.LittleEndian.Uint32(b[12:]); v != formatVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrCorrupt, v)
+	}
+
+	m.pageSize = int(binary.LittleEndian.Uint32(b[16:]))
+	m.maxEntrySize = int(binary.LittleEndian.Uint32(b[20:]))
+	m.minDegree = int(binary.LittleEndian.Uint32(b[24:]))
+	m.root = binary.LittleEndian.Uint64(b[32:])
+	m.size = int(binary.LittleEndian.Uint64(b[40:]))
+	m.numPages = binary.LittleEndian.Uint64(b[48:])
+	m.freeHead = binary.LittleEndian.Uint64(b[56:])
+
+	switch {
+	case m.pageSize < minPageSize || m.pageSize > maxPageSize:
+		return fmt.Errorf("%w: page size out of range: %d", ErrCorrupt, m.pageSize)
+
+	case m.minDegree < 2 || m.minDegree != minDegree(m.pageSize, m.maxEntrySize):
+		return fmt.Errorf("%w: invalid minimum degree %d", ErrCorrupt, m.minDegree)
+
+	case m.root == 0 || m.root >= m.numPages || m.freeHead >= m.numPages:
+		return fmt.Errorf("%w: page ids out of range", ErrCorrupt)
+
+	case m.size < 0:
+		return fmt.Errorf("%w: invalid size %d", ErrCorrupt, m.size)
+	}
+
+	return nil
+}
+
+// leaf returns true if n has no children.
+func (n *node) leaf() bool {
+	return len(n.children) == 0
+}
+
+// find returns the index of the first entry of n not less than e, along with
+// whether that entry is equal to e.
+func (n *node) find(e btree.Entry) (int, bool) {
+	i := sort.Search(len(n.entries), func(i int) bool {
+		return n.entries[i].Compare(e) >= 0
+	})
+
+	return i, i < len(n.entries) && n.entries[i].Compare(e) == 0
+}
+
+// encodeNode encodes n into page, whose checksum is left to the pager.
+func (tr *Tree) encodeNode(n *node, page []byte) error {
+	clear(page)
+
+	page[4] = kindLeaf
+	if !n.leaf() {
+		page[4] = kindInner
+	}
+
+	binary.LittleEndian.PutUint16(page[5:], uint16(len(n.entries)))
+
+	off := pageHeader
+	for _, c := range n.children {
+		binary.LittleEndian.PutUint64(page[off:], c)
+		off += childSize
+	}
+
+	for _, e := range n.entries {
+		b, err := tr.encodeEntry(e)
+		if err != nil {
+			return err
+		}
+
+		binary.LittleEndian.PutUint16(page[off:], uint16(len(b)))
+		off += entryHeader + copy(page[off+entryHeader:], b)
+	}
+
+	return nil
+}
+
+// decodeNode decodes the node page of the given id.
+func (tr *Tree) decodeNode(id uint64, page []byte) (*node, error) {
+	kind, num := page[4], int(binary.LittleEndian.Uint16(page[5:]))
+	if kind != kindLeaf && kind != kindInner {
+		return nil, fmt.Errorf("%w: page %d holds no node", ErrCorrupt, id)
+	}
+
+	if num > 2*tr.p.meta.minDegree-1 {
+		return nil, fmt.Errorf("%w: page %d holds %d entries", ErrCorrupt, id, num)
+	}
+
+	n := &node{id: id, entries: make([]btree.Entry, 0, num)}
+
+	off := pageHeader
+	if kind == kindInner {
+		n.children = make([]uint64, num+1)
+		for i := range n.children {
+			n.children[i] = binary.LittleEndian.Uint64(page[off:])
+			off += childSize
+		}
+	}
+
+	for i := 0; i < num; i++ {
+		if off+entryHeader > len(page) {
+			return nil, fmt.Errorf("%w: page %d truncated", ErrCorrupt, id)
+		}
+
+		l := int(binary.LittleEndian.Uint16(page[off:]))
+		off += entryHeader
+
+		if off+l > len(page) {
+			return nil, fmt.Errorf("%w: page %d truncated", ErrCorrupt, id)
+		}
+
+		e, err := tr.codec.Decode(page[off : off+l : off+l])
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to decode entry of page %d: %w", ErrCorrupt, id, err)
+		}
+
+		if e == nil {
+			return nil, fmt.Errorf("%w: nil entry in page %d", ErrCorrupt, id)
+		}
+
+		n.entries = append(n.entries, e)
+		off += l
+	}
+
+	return n, nil
+}
@@ -0,0 +1,239 @@
+package disk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"maps"
+	"os"
+	"slices"
+)
+
+// pager reads and writes the fixed-size pages of a file, allocating pages from
+// the list of free pages before growing the file. Written pages and changes to
+// the meta data are held in memory until flushed, so that the file itself only
+// changes at checkpoints.
+type pager struct {
+	f    *os.File
+	meta meta
+
+	// dirty holds the pages written since the last flush, none of which is ever
+	// modified in place, as decoded entries may retain them
+	dirty map[uint64][]byte
+}
+
+// openPager returns a pager over f, initializing f as an empty tree configured
+// by o if it is empty. Since the meta data is written last when a file is
+// initialized, a file without any is left by a crash during initialization
+// and is initialized anew.
+func openPager(f *os.File, o options) (*pager, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pager{f: f, dirty: make(map[uint64][]byte)}
+
+	b := make([]byte, metaSize)
+	n, err := f.ReadAt(b, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if !slices.ContainsFunc(b, func(c byte) bool { return c != 0 }) {
+		return p, p.init(o)
+	}
+
+	if n < metaSize {
+		return nil, fmt.Errorf("%w: truncated meta data", ErrCorrupt)
+	}
+
+	if err := p.meta.decode(b); err != nil {
+		return nil, err
+	}
+
+	if info.Size() < int64(p.meta.numPages)*int64(p.meta.pageSize) {
+		return nil, fmt.Errorf("%w: file holds fewer than %d pages", ErrCorrupt, p.meta.numPages)
+	}
+
+	return p, nil
+}
+
+// restorePager returns a pager over f after restoring f to the checkpoint held
+// by the given image, as returned by image.
+func restorePager(f *os.File, image []byte) (*pager, error) {
+	p := &pager{f: f, dirty: make(map[uint64][]byte)}
+
+	if len(image) < metaSize {
+		return nil, fmt.Errorf("%w: truncated checkpoint", ErrCorrupt)
+	}
+
+	if err := p.meta.decode(image); err != nil {
+		return nil, err
+	}
+
+	for image = image[metaSize:]; len(image) > 0; image = image[childSize+p.meta.pageSize:] {
+		if len(image) < childSize+p.meta.pageSize {
+			return nil, fmt.Errorf("%w: truncated checkpoint", ErrCorrupt)
+		}
+
+		id := binary.LittleEndian.Uint64(image)
+		if id == 0 || id >= p.meta.numPages {
+			return nil, fmt.Errorf("%w: checkpoint page %d out of range", ErrCorrupt, id)
+		}
+
+		p.dirty[id] = image[childSize : childSize+p.meta.pageSize]
+	}
+
+	return p, p.flush()
+}
+
+// init writes the meta data and empty root of a new tree configured by o.
+func (p *pager) init(o options) error {
+	switch {
+	case o.pageSize < minPageSize || o.pageSize > maxPageSize:
+		return fmt.Errorf("page size must lie within [%d, %d]: %d", minPageSize, maxPageSize, o.pageSize)
+
+	case o.maxEntrySize < 1 || o.maxEntrySize > maxPageSize-1:
+		return fmt.Errorf("maximum entry size must lie within [1, %d]: %d", maxPageSize-1, o.maxEntrySize)
+	}
+
+	t := minDegree(o.pageSize, o.maxEntrySize)
+	if t < 2 {
+		return fmt.Errorf("page size %d too small for entries of %d bytes", o.pageSize, o.maxEntrySize)
+	}
+
+	p.meta = meta{pageSize: o.pageSize, maxEntrySize: o.maxEntrySize, minDegree: t, root: 1, numPages: 2}
+
+	root := p.page()
+	root[4] = kindLeaf
+
+	if err := p.write(1, root); err != nil {
+		return err
+	}
+
+	return p.flush()
+}
+
+// page returns a new zeroed page.
+func (p *pager) page() []byte {
+	return make([]byte, p.meta.pageSize)
+}
+
+// read returns the page of the given id after verifying its checksum.
+func (p *pager) read(id uint64) ([]byte, error) {
+	if id == 0 || id >= p.meta.numPages {
+		return nil, fmt.Errorf("%w: page %d out of range", ErrCorrupt, id)
+	}
+
+	if page, ok := p.dirty[id]; ok {
+		return page, nil
+	}
+
+	page := p.page()
+	if _, err := p.f.ReadAt(page, int64(id)*int64(p.meta.pageSize)); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: page %d truncated", ErrCorrupt, id)
+		}
+
+		return nil, err
+	}
+
+	if binary.LittleEndian.Uint32(page) != crc32.Checksum(page[4:], crcTable) {
+		return nil, fmt.Errorf("%w: page %d checksum mismatch", ErrCorrupt, id)
+	}
+
+	return page, nil
+}
+
+// write writes a copy of the page of the given id along with its checksum.
+func (p *pager) write(id uint64, page []byte) error {
+	binary.LittleEndian.PutUint32(page, crc32.Checksum(page[4:], crcTable))
+
+	p.dirty[id] = slices.Clone(page)
+	return nil
+}
+
+// alloc returns the id of a page to write a new node to, reusing a free page
+// if there is one.
+func (p *pager) alloc() (uint64, error) {
+	id := p.meta.freeHead
+	if id == 0 {
+		p.meta.numPages++
+		return p.meta.numPages - 1, nil
+	}
+
+	page, err := p.read(id)
+	if err != nil {
+		return 0, err
+	}
+
+	if page[4] != kindFree {
+		return 0, fmt.Errorf("%w: free page %d in use", ErrCorrupt, id)
+	}
+
+	p.meta.freeHead = binary.LittleEndian.Uint64(page[pageHeader:])
+	return id, nil
+}
+
+// free adds the page of the given id to the list of free pages.
+func (p *pager) free(id uint64) error {
+	page := p.page()
+	page[4] = kindFree
+	binary.LittleEndian.PutUint64(page[pageHeader:], p.meta.freeHead)
+
+	if err := p.write(id, page); err != nil {
+		return err
+	}
+
+	p.meta.freeHead = id
+	return nil
+}
+
+// dirtySize returns the number of bytes of the pages written since the last
+// flush.
+func (p *pager) dirtySize() int {
+	return len(p.dirty) * p.meta.pageSize
+}
+
+// image returns the meta data followed by every page written since the last
+// flush, in the order of their ids, each as its id followed by its contents.
+func (p *pager) image() []byte {
+	ids := slices.Sorted(maps.Keys(p.dirty))
+
+	b := make([]byte, metaSize, metaSize+len(ids)*(childSize+p.meta.pageSize))
+	p.meta.encode(b)
+
+	for _, id := range ids {
+		b = binary.LittleEndian.AppendUint64(b, id)
+		b = append(b, p.dirty[id]...)
+	}
+
+	return b
+}
+
+// flush writes the pages written since the last flush and the meta data to the
+// file and syncs it.
+func (p *pager) flush() error {
+	for id, page := range p.dirty {
+		if _, err := p.f.WriteAt(page, int64(id)*int64(p.meta.pageSize)); err != nil {
+			return err
+		}
+	}
+
+	b := make([]byte, metaSize)
+	p.meta.encode(b)
+
+	if _, err := p.f.WriteAt(b, 0); err != nil {
+		return err
+	}
+
+	if err := p.f.Sync(); err != nil {
+		return err
+	}
+
+	clear(p.dirty)
+	return nil
+}
@@ -0,0 +1,83 @@
+package disk
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alexanderbez/btree"
+)
+
+// recover opens the pager of the Tree over f and recovers the Tree from its
+// write-ahead log. The file holds the Tree as of the last checkpoint unless a
+// crash interrupted writing the pages of a checkpoint to it, in which case the
+// last record of the log holds them and f is restored from it. Every write
+// logged after that checkpoint is then replayed, up to the first torn record,
+// which is discarded along with the writes it would have covered, and the
+// result is checkpointed, leaving the log empty.
+func (tr *Tree) recover(f *os.File, o options) error {
+	recs, err := tr.wal.records()
+	if err != nil {
+		return err
+	}
+
+	last := -1
+	for i, rec := range recs {
+		if rec.kind == walCheckpoint {
+			last = i
+		}
+	}
+
+	if last >= 0 {
+		tr.p, err = restorePager(f, recs[last].payload)
+	} else {
+		tr.p, err = openPager(f, o)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	tr.cache = newCache(o.cacheSize, tr.p.meta.pageSize)
+
+	for _, rec := range recs[last+1:] {
+		if err := tr.replay(rec); err != nil {
+			return err
+		}
+	}
+
+	if err := tr.checkpoint(); err != nil {
+		return err
+	}
+
+	if tr.wal.size > 0 {
+		return tr.wal.truncate()
+	}
+
+	return nil
+}
+
+// replay applies the write logged by the given record.
+func (tr *Tree) replay(rec walRecord) error {
+	var e btree.Entry
+	if rec.kind == walInsert || rec.kind == walDelete {
+		var err error
+		if e, err = tr.codec.Decode(rec.payload); err != nil {
+			return fmt.Errorf("%w: failed to decode logged entry: %w", ErrCorrupt, err)
+		}
+
+		if e == nil {
+			return fmt.Errorf("%w: nil logged entry", ErrCorrupt)
+		}
+	}
+
+	switch rec.kind {
+	case walInsert:
+		return tr.insert(e)
+
+	case walDelete:
+		_, err := tr.delete(e)
+		return err
+	}
+
+	return fmt.Errorf("%w: unexpected log record of kind %d", ErrCorrupt, rec.kind)
+}
@@ -0,0 +1,128 @@
+package disk_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/alexanderbez/btree/disk"
+	"github.com/stretchr/testify/require"
+)
+
+// crash returns the path of a copy of the files of the Tree at path as they are
+// now, as a crash would leave them.
+func crash(t *testing.T, path string) string {
+	copied := filepath.Join(t.TempDir(), "tree")
+	for _, suffix := range []string{"", "-wal"} {
+		data, err := os.ReadFile(path + suffix)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(copied+suffix, data, 0o644))
+	}
+
+	return copied
+}
+
+// reopen opens the Tree at path, requiring it to hold the entries of ref and
+// its log to be empty.
+func reopen(t *testing.T, path string, ref *btree.BTree) {
+	t.Helper()
+
+	tr, err := disk.Open(path, testCodec{})
+	require.NoError(t, err)
+	requireSame(t, ref, tr)
+	require.Zero(t, walSize(t, path))
+	require.NoError(t, tr.Close())
+}
+
+func TestRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree")
+
+	tr, err := disk.Open(path, testCodec{}, disk.WithPageSize(256), disk.WithMaxEntrySize(16))
+	require.NoError(t, err)
+
+	ref, err := btree.New(2)
+	require.NoError(t, err)
+
+	for k := uint64(0); k < 500; k++ {
+		e := testEntry{key: k * 7 % 500, value: "v"}
+		require.NoError(t, tr.Insert(e))
+		ref.Insert(e)
+	}
+
+	// every logged write is replayed against the last checkpoint
+	reopen(t, crash(t, path), ref)
+
+	for k := uint64(0); k < 500; k += 3 {
+		_, err := tr.Delete(testEntry{key: k})
+		require.NoError(t, err)
+		ref.Delete(testEntry{key: k})
+	}
+
+	copied := crash(t, path)
+	reopen(t, copied, ref)
+
+	// the recovered Tree is checkpointed, so recovering it again is a no-op
+	reopen(t, copied, ref)
+
+	// a torn record at the end of the log is discarded
+	require.NoError(t, tr.Insert(testEntry{key: 1000}))
+
+	copied = crash(t, path)
+	info, err := os.Stat(copied + "-wal")
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(copied+"-wal", info.Size()-3))
+
+	reopen(t, copied, ref)
+	ref.Insert(testEntry{key: 1000})
+
+	copied = crash(t, path)
+	wal, err := os.OpenFile(copied+"-wal", os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = wal.Write([]byte("torn record"))
+	require.NoError(t, err)
+	require.NoError(t, wal.Close())
+
+	reopen(t, copied, ref)
+
+	// a crash while the pages of a checkpoint are written to the file is
+	// recovered from the checkpoint in the log, whatever state it left those
+	// pages in, which here are all of them
+	require.NoError(t, tr.LogCheckpoint())
+	require.NoError(t, tr.Insert(testEntry{key: 2000}))
+	ref.Insert(testEntry{key: 2000})
+
+	copied = crash(t, path)
+	data, err := os.ReadFile(copied)
+	require.NoError(t, err)
+
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	require.NoError(t, os.WriteFile(copied, data, 0o644))
+	reopen(t, copied, ref)
+
+	require.NoError(t, tr.Close())
+	reopen(t, path, ref)
+}
+
+func TestRecoveryInit(t *testing.T) {
+	// a file whose initialization was interrupted is initialized anew
+	path := filepath.Join(t.TempDir(), "tree")
+	require.NoError(t, os.WriteFile(path, make([]byte, 3*disk.DefaultPageSize), 0o644))
+
+	tr, err := disk.Open(path, testCodec{})
+	require.NoError(t, err)
+	require.Zero(t, tr.Size())
+	require.NoError(t, tr.Verify())
+
+	require.NoError(t, tr.Insert(testEntry{key: 1}))
+	require.NoError(t, tr.Close())
+
+	ref, err := btree.New(2)
+	require.NoError(t, err)
+	ref.Insert(testEntry{key: 1})
+
+	reopen(t, path, ref)
+}
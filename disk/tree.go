@@ -0,0 +1,733 @@
+// Package disk implements a B-Tree over the fixed-size pages of a single file,
+// so that datasets larger than memory can be indexed. Every node occupies a
+// page of its own and is read from the file as it is visited, unless it is
+// among the recently used nodes the Tree keeps decoded in its cache. A Tree
+// thus holds no more in memory than its cache budget, DefaultCacheSize unless
+// set WithCacheSize, plus the nodes along the path of a single operation and
+// the pages written since its last checkpoint. The minimum degree follows from
+// the page size and the maximum size of an encoded entry, as the largest for
+// which a full node fits a page, which is what ties the degree of a B-Tree to
+// the block size of the disk.
+package disk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/alexanderbez/btree"
+)
+
+var (
+	// ErrCorrupt is returned when a file does not hold a valid Tree.
+	ErrCorrupt = errors.New("corrupt tree file")
+
+	// ErrEntryTooLarge is returned when inserting an Entry whose encoding exceeds
+	// the maximum entry size of the Tree.
+	ErrEntryTooLarge = errors.New("entry too large")
+
+	// ErrClosed is returned when using a Tree that has been closed.
+	ErrClosed = errors.New("tree closed")
+)
+
+// Tree implements a thread-safe B-Tree stored in a file. Entries are ordered
+// through Entry.Compare and stored as encoded by the Codec of the Tree.
+//
+// Every write is appended to a write-ahead log next to the file, and synced
+// according to the SyncPolicy of the Tree, before it is applied. The pages it
+// writes are held in memory until the next checkpoint, which happens once they
+// exceed the checkpoint size and when the Tree is closed. A checkpoint logs
+// the pages before writing them to the file and then empties the log, so that
+// the file itself always holds the Tree as of the last checkpoint, or can be
+// restored to it from the log.
+type Tree struct {
+	mu             sync.RWMutex
+	p              *pager
+	wal            *wal
+	cache          *cache
+	codec          btree.Codec
+	checkpointSize int
+	closed         bool
+}
+
+// Open returns a Tree stored in the file at path, which is created, configured
+// by the given options, if it does not exist or is empty. An existing file
+// keeps the configuration it was created with. The write-ahead log is kept in
+// the file at path with the suffix "-wal", and if the Tree was not closed, it
+// is recovered from the log before Open returns. The entries are encoded and
+// decoded by codec, which must therefore be the same whenever the file is
+// opened.
+func Open(path string, codec btree.Codec, opts ...Option) (*Tree, error) {
+	if codec == nil {
+		return nil, btree.ErrNoCodec
+	}
+
+	o := newOptions(opts)
+	if o.syncPolicy == SyncInterval && o.syncInterval <= 0 {
+		return nil, fmt.Errorf("sync interval must be positive: %v", o.syncInterval)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := openWAL(path+"-wal", o)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	tr := &Tree{wal: w, codec: codec, checkpointSize: o.checkpointSize}
+	if err := tr.recover(f, o); err != nil {
+		w.close()
+		f.Close()
+		return nil, err
+	}
+
+	return tr, nil
+}
+
+// Close checkpoints the Tree and closes its files, after which every method of
+// the Tree returns ErrClosed.
+func (tr *Tree) Close() error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.closed {
+		return ErrClosed
+	}
+
+	tr.closed = true
+
+	err := tr.checkpoint()
+	if werr := tr.wal.close(); err == nil {
+		err = werr
+	}
+
+	if ferr := tr.p.f.Close(); err == nil {
+		err = ferr
+	}
+
+	return err
+}
+
+// Sync syncs the write-ahead log to stable storage, making every write to the
+// Tree so far durable regardless of its SyncPolicy.
+func (tr *Tree) Sync() error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.closed {
+		return ErrClosed
+	}
+
+	return tr.wal.sync()
+}
+
+// checkpoint writes the pages written since the last checkpoint to the file,
+// logging them first, so that a crash while they are written leaves them in
+// the log, and empties the log once they are safely in the file.
+func (tr *Tree) checkpoint() error {
+	if len(tr.p.dirty) == 0 {
+		return nil
+	}
+
+	if err := tr.wal.append(walCheckpoint, tr.p.image()); err != nil {
+		return err
+	}
+
+	if err := tr.wal.sync(); err != nil {
+		return err
+	}
+
+	if err := tr.p.flush(); err != nil {
+		return err
+	}
+
+	return tr.wal.truncate()
+}
+
+// applied checkpoints the Tree after a write once the pages written since the
+// last checkpoint exceed the checkpoint size.
+func (tr *Tree) applied() error {
+	if tr.p.dirtySize() <= tr.checkpointSize {
+		return nil
+	}
+
+	return tr.checkpoint()
+}
+
+// Size returns the number of entries in the Tree.
+func (tr *Tree) Size() int {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	return tr.p.meta.size
+}
+
+// MinDegree returns the minimum degree of the Tree.
+func (tr *Tree) MinDegree() int {
+	return tr.p.meta.minDegree
+}
+
+// Search returns the Entry of the Tree equal to e, or nil if there is none.
+func (tr *Tree) Search(e btree.Entry) (btree.Entry, error) {
+	if e == nil {
+		return nil, nil
+	}
+
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	if tr.closed {
+		return nil, ErrClosed
+	}
+
+	id := tr.p.meta.root
+	for {
+		n, err := tr.node(id)
+		if err != nil {
+			return nil, err
+		}
+
+		i, found := n.find(e)
+		if found {
+			return n.entries[i], nil
+		}
+
+		if n.leaf() {
+			return nil, nil
+		}
+
+		id = n.children[i]
+	}
+}
+
+// Has returns true if the Tree holds an Entry equal to e.
+func (tr *Tree) Has(e btree.Entry) (bool, error) {
+	found, err := tr.Search(e)
+	return found != nil, err
+}
+
+// Min returns the smallest Entry of the Tree, or nil if it is empty.
+func (tr *Tree) Min() (btree.Entry, error) {
+	return tr.extreme(false)
+}
+
+// Max returns the largest Entry of the Tree, or nil if it is empty.
+func (tr *Tree) Max() (btree.Entry, error) {
+	return tr.extreme(true)
+}
+
+func (tr *Tree) extreme(largest bool) (btree.Entry, error) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	if tr.closed {
+		return nil, ErrClosed
+	}
+
+	root, err := tr.node(tr.p.meta.root)
+	if err != nil || len(root.entries) == 0 {
+		return nil, err
+	}
+
+	return tr.subtreeExtreme(root, largest)
+}
+
+// subtreeExtreme returns the smallest or largest Entry of the non-empty subtree
+// rooted at n.
+func (tr *Tree) subtreeExtreme(n *node, largest bool) (btree.Entry, error) {
+	for !n.leaf() {
+		id := n.children[0]
+		if largest {
+			id = n.children[len(n.children)-1]
+		}
+
+		var err error
+		if n, err = tr.node(id); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(n.entries) == 0 {
+		return nil, fmt.Errorf("%w: empty leaf %d", ErrCorrupt, n.id)
+	}
+
+	if largest {
+		return n.entries[len(n.entries)-1], nil
+	}
+
+	return n.entries[0], nil
+}
+
+// Ascend calls fn for every Entry of the Tree in ascending order until fn
+// returns false. The Tree must not be written to from within fn.
+func (tr *Tree) Ascend(fn func(btree.Entry) bool) error {
+	return tr.AscendRange(nil, nil, fn)
+}
+
+// AscendRange calls fn for every Entry e of the Tree, s.t. from <= e < to, in
+// ascending order until fn returns false. A nil from or to leaves the
+// respective end of the range unbounded. The Tree must not be written to from
+// within fn.
+func (tr *Tree) AscendRange(from, to btree.Entry, fn func(btree.Entry) bool) error {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	if tr.closed {
+		return ErrClosed
+	}
+
+	_, err := tr.ascend(tr.p.meta.root, from, to, fn)
+	return err
+}
+
+// ascend visits the range of the subtree rooted at the given page, returning
+// false once fn returned false or the end of the range was reached.
+func (tr *Tree) ascend(id uint64, from, to btree.Entry, fn func(btree.Entry) bool) (bool, error) {
+	n, err := tr.node(id)
+	if err != nil {
+		return false, err
+	}
+
+	i := 0
+	if from != nil {
+		i, _ = n.find(from)
+	}
+
+	// only the first child visited may hold entries below from
+	for ; i <= len(n.entries); i++ {
+		if !n.leaf() {
+			if ok, err := tr.ascend(n.children[i], from, to, fn); !ok || err != nil {
+				return false, err
+			}
+
+			from = nil
+		}
+
+		if i == len(n.entries) {
+			break
+		}
+
+		e := n.entries[i]
+		if to != nil && e.Compare(to) >= 0 || !fn(e) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Insert inserts an Entry into the Tree, replacing the Entry equal to it if
+// there is one. If the provided Entry is nil, the method performs a no-op. An
+// error wrapping ErrEntryTooLarge is returned if the encoded Entry exceeds the
+// maximum entry size, in which case the Tree is left untouched.
+func (tr *Tree) Insert(e btree.Entry) error {
+	if e == nil {
+		return nil
+	}
+
+	b, err := tr.encodeEntry(e)
+	if err != nil {
+		return err
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.closed {
+		return ErrClosed
+	}
+
+	if err := tr.wal.append(walInsert, b); err != nil {
+		return err
+	}
+
+	if err := tr.insert(e); err != nil {
+		return err
+	}
+
+	return tr.applied()
+}
+
+// insert inserts e top-down, splitting every full node on the way before
+// descending into it, so that a split never propagates upwards.
+func (tr *Tree) insert(e btree.Entry) error {
+	n, err := tr.node(tr.p.meta.root)
+	if err != nil {
+		return err
+	}
+
+	if tr.full(n) {
+		root, err := tr.newNode()
+		if err != nil {
+			return err
+		}
+
+		root.children = []uint64{n.id}
+		if _, err := tr.split(root, 0, n); err != nil {
+			return err
+		}
+
+		tr.p.meta.root = root.id
+		n = root
+	}
+
+	for {
+		i, found := n.find(e)
+		if found {
+			n.entries[i] = e
+			return tr.put(n)
+		}
+
+		if n.leaf() {
+			n.entries = slices.Insert(n.entries, i, e)
+			tr.p.meta.size++
+			return tr.put(n)
+		}
+
+		child, err := tr.node(n.children[i])
+		if err != nil {
+			return err
+		}
+
+		if tr.full(child) {
+			sibling, err := tr.split(n, i, child)
+			if err != nil {
+				return err
+			}
+
+			switch c := e.Compare(n.entries[i]); {
+			case c == 0:
+				n.entries[i] = e
+				return tr.put(n)
+
+			case c > 0:
+				child = sibling
+			}
+		}
+
+		n = child
+	}
+}
+
+// split moves the upper half of the entries and children of the full i-th
+// child of parent to a new sibling, and the median up into parent, returning
+// the sibling.
+func (tr *Tree) split(parent *node, i int, child *node) (*node, error) {
+	t := tr.p.meta.minDegree
+
+	sibling, err := tr.newNode()
+	if err != nil {
+		return nil, err
+	}
+
+	median := child.entries[t-1]
+	sibling.entries = slices.Clone(child.entries[t:])
+	child.entries = child.entries[:t-1]
+
+	if !child.leaf() {
+		sibling.children = slices.Clone(child.children[t:])
+		child.children = child.children[:t]
+	}
+
+	parent.entries = slices.Insert(parent.entries, i, median)
+	parent.children = slices.Insert(parent.children, i+1, sibling.id)
+
+	return sibling, tr.put(child, sibling, parent)
+}
+
+// Delete removes the Entry equal to the provided Entry from the Tree and
+// returns the removed Entry. If no such Entry exists or the provided Entry is
+// nil, nil is returned.
+func (tr *Tree) Delete(e btree.Entry) (btree.Entry, error) {
+	if e == nil {
+		return nil, nil
+	}
+
+	// an Entry too large to insert cannot be present
+	b, err := tr.encodeEntry(e)
+	if errors.Is(err, ErrEntryTooLarge) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.closed {
+		return nil, ErrClosed
+	}
+
+	if err := tr.wal.append(walDelete, b); err != nil {
+		return nil, err
+	}
+
+	removed, err := tr.delete(e)
+	if err != nil || removed == nil {
+		return nil, err
+	}
+
+	return removed, tr.applied()
+}
+
+// delete removes e, shrinking the Tree if its root is left without entries.
+func (tr *Tree) delete(e btree.Entry) (btree.Entry, error) {
+	root, err := tr.node(tr.p.meta.root)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := tr.remove(root, e)
+	if err != nil || removed == nil {
+		return nil, err
+	}
+
+	tr.p.meta.size--
+
+	if len(root.entries) == 0 && !root.leaf() {
+		tr.p.meta.root = root.children[0]
+		if err := tr.free(root.id); err != nil {
+			return nil, err
+		}
+	}
+
+	return removed, nil
+}
+
+// remove removes e from the subtree rooted at n top-down, ensuring that every
+// node it descends into holds at least t entries, so that removing an entry
+// from it never leaves it underfull.
+func (tr *Tree) remove(n *node, e btree.Entry) (btree.Entry, error) {
+	t := tr.p.meta.minDegree
+
+	for {
+		i, found := n.find(e)
+
+		if n.leaf() {
+			if !found {
+				return nil, nil
+			}
+
+			removed := n.entries[i]
+			n.entries = slices.Delete(n.entries, i, i+1)
+			return removed, tr.put(n)
+		}
+
+		if !found {
+			child, err := tr.node(n.children[i])
+			if err != nil {
+				return nil, err
+			}
+
+			if len(child.entries) < t {
+				if child, err = tr.fill(n, i, child); err != nil {
+					return nil, err
+				}
+			}
+
+			n = child
+			continue
+		}
+
+		// e lies in an internal node, so it is replaced by its predecessor or
+		// successor if either child can spare an entry, and otherwise moved down
+		// into the merger of both children
+		removed := n.entries[i]
+
+		left, err := tr.node(n.children[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(left.entries) >= t {
+			return removed, tr.replace(n, i, left, true)
+		}
+
+		right, err := tr.node(n.children[i+1])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(right.entries) >= t {
+			return removed, tr.replace(n, i, right, false)
+		}
+
+		if err := tr.merge(n, i, left, right); err != nil {
+			return nil, err
+		}
+
+		n = left
+	}
+}
+
+// replace replaces the i-th entry of n by the largest entry of the subtree
+// rooted at child, its left child, or the smallest if it is its right child,
+// and removes that entry from the subtree.
+func (tr *Tree) replace(n *node, i int, child *node, largest bool) error {
+	e, err := tr.subtreeExtreme(child, largest)
+	if err != nil {
+		return err
+	}
+
+	n.entries[i] = e
+	if err := tr.put(n); err != nil {
+		return err
+	}
+
+	_, err = tr.remove(child, e)
+	return err
+}
+
+// fill ensures that the i-th child of n, which holds t-1 entries, holds at
+// least t, by rotating an entry over from a sibling that can spare one or
+// else merging it with a sibling. It returns the node that now holds the
+// entries of the child.
+func (tr *Tree) fill(n *node, i int, child *node) (*node, error) {
+	t := tr.p.meta.minDegree
+
+	var left *node
+	if i > 0 {
+		var err error
+		if left, err = tr.node(n.children[i-1]); err != nil {
+			return nil, err
+		}
+
+		if len(left.entries) >= t {
+			last := len(left.entries) - 1
+			child.entries = slices.Insert(child.entries, 0, n.entries[i-1])
+			n.entries[i-1] = left.entries[last]
+			left.entries = left.entries[:last]
+
+			if !left.leaf() {
+				last := len(left.children) - 1
+				child.children = slices.Insert(child.children, 0, left.children[last])
+				left.children = left.children[:last]
+			}
+
+			return child, tr.put(left, child, n)
+		}
+	}
+
+	if i < len(n.entries) {
+		right, err := tr.node(n.children[i+1])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(right.entries) >= t {
+			child.entries = append(child.entries, n.entries[i])
+			n.entries[i] = right.entries[0]
+			right.entries = slices.Delete(right.entries, 0, 1)
+
+			if !right.leaf() {
+				child.children = append(child.children, right.children[0])
+				right.children = slices.Delete(right.children, 0, 1)
+			}
+
+			return child, tr.put(right, child, n)
+		}
+
+		return child, tr.merge(n, i, child, right)
+	}
+
+	return left, tr.merge(n, i-1, left, child)
+}
+
+// merge merges the i-th entry of n and its right child into its left child,
+// freeing the page of the right child.
+func (tr *Tree) merge(n *node, i int, left, right *node) error {
+	left.entries = append(append(left.entries, n.entries[i]), right.entries...)
+	left.children = append(left.children, right.children...)
+
+	n.entries = slices.Delete(n.entries, i, i+1)
+	n.children = slices.Delete(n.children, i+1, i+2)
+
+	if err := tr.free(right.id); err != nil {
+		return err
+	}
+
+	return tr.put(left, n)
+}
+
+// free frees the page of the given id.
+func (tr *Tree) free(id uint64) error {
+	tr.cache.remove(id)
+	return tr.p.free(id)
+}
+
+// full returns true if n holds the maximum of 2t-1 entries.
+func (tr *Tree) full(n *node) bool {
+	return len(n.entries) == 2*tr.p.meta.minDegree-1
+}
+
+// node returns the node stored in the page of the given id, from the cache if
+// possible.
+func (tr *Tree) node(id uint64) (*node, error) {
+	if n, ok := tr.cache.get(id); ok {
+		return n, nil
+	}
+
+	page, err := tr.p.read(id)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := tr.decodeNode(id, page)
+	if err != nil {
+		return nil, err
+	}
+
+	tr.cache.add(n)
+	return n, nil
+}
+
+// newNode allocates a page for a new, empty node, which is written once put.
+func (tr *Tree) newNode() (*node, error) {
+	id, err := tr.p.alloc()
+	if err != nil {
+		return nil, err
+	}
+
+	return &node{id: id}, nil
+}
+
+// put writes the given nodes to their pages.
+func (tr *Tree) put(ns ...*node) error {
+	page := tr.p.page()
+	for _, n := range ns {
+		if err := tr.encodeNode(n, page); err != nil {
+			return err
+		}
+
+		if err := tr.p.write(n.id, page); err != nil {
+			return err
+		}
+
+		tr.cache.add(n)
+	}
+
+	return nil
+}
+
+// encodeEntry returns the encoding of e, which must not exceed the maximum
+// entry size.
+func (tr *Tree) encodeEntry(e btree.Entry) ([]byte, error) {
+	b, err := tr.codec.Encode(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode entry %v: %w", e, err)
+	}
+
+	if len(b) > tr.p.meta.maxEntrySize {
+		return nil, fmt.Errorf("%w: %d bytes exceed %d", ErrEntryTooLarge, len(b), tr.p.meta.maxEntrySize)
+	}
+
+	return b, nil
+}
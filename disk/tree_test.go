@@ -0,0 +1,245 @@
+package disk_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/alexanderbez/btree/disk"
+	"github.com/stretchr/testify/require"
+)
+
+// testEntry defines an Entry ordered by its key.
+type testEntry struct {
+	key   uint64
+	value string
+}
+
+func (e testEntry) Compare(other btree.Entry) int {
+	switch o := other.(testEntry); {
+	case e.key < o.key:
+		return -1
+
+	case e.key > o.key:
+		return 1
+	}
+
+	return 0
+}
+
+// testCodec encodes a testEntry as its big-endian key followed by its value.
+type testCodec struct{}
+
+func (testCodec) Encode(e btree.Entry) ([]byte, error) {
+	te := e.(testEntry)
+	return append(binary.BigEndian.AppendUint64(nil, te.key), te.value...), nil
+}
+
+func (testCodec) Decode(b []byte) (btree.Entry, error) {
+	if len(b) < 8 {
+		return nil, fmt.Errorf("invalid length %d", len(b))
+	}
+
+	return testEntry{key: binary.BigEndian.Uint64(b), value: string(b[8:])}, nil
+}
+
+// requireSame requires the Tree to hold exactly the entries of the reference.
+func requireSame(t *testing.T, ref *btree.BTree, tr *disk.Tree) {
+	t.Helper()
+
+	require.NoError(t, tr.Verify())
+	require.Equal(t, ref.Size(), tr.Size())
+
+	var got []btree.Entry
+	require.NoError(t, tr.Ascend(func(e btree.Entry) bool {
+		got = append(got, e)
+		return true
+	}))
+	require.Equal(t, ref.Entries(nil, nil), got)
+}
+
+func TestTree(t *testing.T) {
+	for _, tc := range []struct {
+		pageSize, maxEntrySize, minDegree int
+	}{
+		{128, 16, 2},
+		{256, 16, 5},
+		{4096, 32, 49},
+	} {
+		t.Run(fmt.Sprintf("page size %d", tc.pageSize), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tree")
+			rng := rand.New(rand.NewSource(int64(tc.pageSize)))
+
+			tr, err := disk.Open(path, testCodec{}, disk.WithPageSize(tc.pageSize), disk.WithMaxEntrySize(tc.maxEntrySize))
+			require.NoError(t, err)
+			require.Equal(t, tc.minDegree, tr.MinDegree())
+
+			ref, err := btree.New(2)
+			require.NoError(t, err)
+
+			const n = 3000
+			for i, k := range rng.Perm(n) {
+				e := testEntry{key: uint64(k), value: fmt.Sprint(k)}
+				require.NoError(t, tr.Insert(e))
+				ref.Insert(e)
+
+				if i%500 == 0 {
+					require.NoError(t, tr.Verify())
+				}
+			}
+
+			requireSame(t, ref, tr)
+
+			for k := uint64(0); k < n+10; k++ {
+				got, err := tr.Search(testEntry{key: k})
+				require.NoError(t, err)
+				require.Equal(t, ref.Search(testEntry{key: k}), got)
+			}
+
+			for _, r := range [][2]btree.Entry{
+				{testEntry{key: 17}, testEntry{key: 1717}},
+				{nil, testEntry{key: 5}},
+				{testEntry{key: 2990}, nil},
+				{testEntry{key: 9999}, nil},
+			} {
+				var got []btree.Entry
+				require.NoError(t, tr.AscendRange(r[0], r[1], func(e btree.Entry) bool {
+					got = append(got, e)
+					return true
+				}))
+				require.Equal(t, ref.Entries(r[0], r[1]), got)
+			}
+
+			minimum, err := tr.Min()
+			require.NoError(t, err)
+			require.Equal(t, ref.Min(), minimum)
+
+			maximum, err := tr.Max()
+			require.NoError(t, err)
+			require.Equal(t, ref.Max(), maximum)
+
+			// the contents survive reopening, even with other options
+			require.NoError(t, tr.Close())
+			require.True(t, errors.Is(tr.Close(), disk.ErrClosed))
+
+			_, err = tr.Search(testEntry{key: 1})
+			require.True(t, errors.Is(err, disk.ErrClosed))
+
+			tr, err = disk.Open(path, testCodec{}, disk.WithPageSize(512))
+			require.NoError(t, err)
+			require.Equal(t, tc.minDegree, tr.MinDegree())
+			requireSame(t, ref, tr)
+
+			for i, k := range rng.Perm(n) {
+				if i%3 == 0 {
+					continue
+				}
+
+				removed, err := tr.Delete(testEntry{key: uint64(k)})
+				require.NoError(t, err)
+				require.Equal(t, ref.Delete(testEntry{key: uint64(k)}), removed)
+
+				if i%500 == 0 {
+					require.NoError(t, tr.Verify())
+				}
+			}
+
+			removed, err := tr.Delete(testEntry{key: n + 1})
+			require.NoError(t, err)
+			require.Nil(t, removed)
+
+			// replacing entries keeps the size
+			for k := uint64(0); k < n; k += 7 {
+				if ref.Has(testEntry{key: k}) {
+					e := testEntry{key: k, value: "replaced"}
+					require.NoError(t, tr.Insert(e))
+					ref.Insert(e)
+				}
+			}
+
+			requireSame(t, ref, tr)
+			require.NoError(t, tr.Close())
+
+			tr, err = disk.Open(path, testCodec{})
+			require.NoError(t, err)
+			requireSame(t, ref, tr)
+
+			// freed pages are reused before the file grows
+			for k := uint64(0); k < n; k++ {
+				_, err := tr.Delete(testEntry{key: k})
+				require.NoError(t, err)
+			}
+
+			require.Zero(t, tr.Size())
+			require.NoError(t, tr.Verify())
+
+			pages := tr.NumPages()
+			for k := uint64(0); k < n/2; k++ {
+				require.NoError(t, tr.Insert(testEntry{key: k}))
+			}
+
+			require.Equal(t, pages, tr.NumPages())
+			require.NoError(t, tr.Verify())
+			require.NoError(t, tr.Close())
+		})
+	}
+}
+
+func TestTreeErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := disk.Open(filepath.Join(dir, "none"), nil)
+	require.True(t, errors.Is(err, btree.ErrNoCodec))
+
+	for _, opts := range [][]disk.Option{
+		{disk.WithPageSize(64)},
+		{disk.WithPageSize(1 << 20)},
+		{disk.WithMaxEntrySize(0)},
+		{disk.WithPageSize(128), disk.WithMaxEntrySize(64)},
+	} {
+		_, err := disk.Open(filepath.Join(dir, "invalid"), testCodec{}, opts...)
+		require.Error(t, err)
+	}
+
+	path := filepath.Join(dir, "tree")
+	tr, err := disk.Open(path, testCodec{}, disk.WithMaxEntrySize(16))
+	require.NoError(t, err)
+
+	require.NoError(t, tr.Insert(testEntry{key: 1, value: "12345678"}))
+	require.True(t, errors.Is(tr.Insert(testEntry{key: 2, value: "123456789"}), disk.ErrEntryTooLarge))
+	require.NoError(t, tr.Insert(nil))
+	require.Equal(t, 1, tr.Size())
+	require.NoError(t, tr.Close())
+
+	// a damaged page is detected by its checksum
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	data[disk.DefaultPageSize+100] ^= 1
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	tr, err = disk.Open(path, testCodec{})
+	require.NoError(t, err)
+
+	_, err = tr.Search(testEntry{key: 1})
+	require.True(t, errors.Is(err, disk.ErrCorrupt))
+	require.NoError(t, tr.Close())
+
+	// so is damaged meta data or a truncated file
+	for _, corrupt := range [][]byte{
+		[]byte(strings.Repeat("not a tree", 100)),
+		data[:disk.DefaultPageSize],
+		append([]byte{data[0] ^ 1}, data[1:]...),
+	} {
+		require.NoError(t, os.WriteFile(path, corrupt, 0o644))
+
+		_, err := disk.Open(path, testCodec{})
+		require.True(t, errors.Is(err, disk.ErrCorrupt))
+	}
+}
@@ -0,0 +1,193 @@
+package disk
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"sync"
+	"time"
+)
+
+// The write-ahead log of a Tree is a sequence of records, each made up of the
+// CRC-32C checksum of the rest of the record, the length of its payload as a
+// uint32, its kind and its payload. Insert and delete records hold an entry as
+// encoded by the Codec and precede the write they describe. A checkpoint
+// record holds the meta data followed by every page written since the last
+// checkpoint, each as its page id followed by its contents, and precedes
+// writing those pages to the file, after which the log is emptied.
+
+const (
+	walHeader = 9
+
+	walInsert     = 1
+	walDelete     = 2
+	walCheckpoint = 3
+)
+
+// SyncPolicy defines when the write-ahead log of a Tree is synced to stable
+// storage, which determines the writes that may be lost in a crash.
+type SyncPolicy int
+
+const (
+	// SyncAlways syncs the log before every write is applied, so that no write
+	// that returned is ever lost, at the cost of an fsync per write.
+	SyncAlways SyncPolicy = iota
+
+	// SyncInterval syncs the log periodically in the background, so that a crash
+	// loses at most the writes of the last interval.
+	SyncInterval
+
+	// SyncNever leaves syncing the log to the operating system, Sync and
+	// checkpoints, so that a crash may lose any write since the last of them.
+	SyncNever
+)
+
+type (
+	// walRecord holds a record of the write-ahead log.
+	walRecord struct {
+		kind    byte
+		payload []byte
+	}
+
+	// wal appends to the write-ahead log of a Tree.
+	wal struct {
+		f      *os.File
+		size   int64
+		policy SyncPolicy
+
+		// stop stops the goroutine syncing the log with SyncInterval
+		stop chan struct{}
+		wg   sync.WaitGroup
+	}
+)
+
+// openWAL opens the write-ahead log at path, creating it if it does not exist.
+func openWAL(path string, o options) (*wal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	w := &wal{f: f, size: info.Size(), policy: o.syncPolicy}
+	if w.policy == SyncInterval {
+		w.stop = make(chan struct{})
+		w.wg.Add(1)
+		go w.syncEvery(o.syncInterval)
+	}
+
+	return w, nil
+}
+
+// syncEvery syncs the log at the given interval until stopped. Syncs may race
+// with appends, which is safe, as the checksum of a record synced only in part
+// reveals it as torn.
+func (w *wal) syncEvery(interval time.Duration) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+
+		case <-ticker.C:
+			_ = w.f.Sync()
+		}
+	}
+}
+
+// records returns the records of the log up to the first torn one, that is
+// one cut short or failing its checksum, as left by a crash while it was
+// appended. The log is truncated before the torn record, so that it is
+// discarded along with everything following it.
+func (w *wal) records() ([]walRecord, error) {
+	data := make([]byte, w.size)
+	if _, err := w.f.ReadAt(data, 0); err != nil {
+		return nil, err
+	}
+
+	var (
+		recs []walRecord
+		off  int
+	)
+
+	for len(data)-off >= walHeader {
+		l := int(binary.LittleEndian.Uint32(data[off+4:]))
+		if l > len(data)-off-walHeader {
+			break
+		}
+
+		rec := data[off : off+walHeader+l]
+		if binary.LittleEndian.Uint32(rec) != crc32.Checksum(rec[4:], crcTable) {
+			break
+		}
+
+		recs = append(recs, walRecord{kind: rec[8], payload: rec[walHeader:]})
+		off += len(rec)
+	}
+
+	if int64(off) < w.size {
+		if err := w.f.Truncate(int64(off)); err != nil {
+			return nil, err
+		}
+
+		w.size = int64(off)
+	}
+
+	return recs, nil
+}
+
+// append appends a record of the given kind and payload, syncing the log
+// afterwards with SyncAlways.
+func (w *wal) append(kind byte, payload []byte) error {
+	rec := make([]byte, walHeader+len(payload))
+	binary.LittleEndian.PutUint32(rec[4:], uint32(len(payload)))
+	rec[8] = kind
+	copy(rec[walHeader:], payload)
+	binary.LittleEndian.PutUint32(rec, crc32.Checksum(rec[4:], crcTable))
+
+	if _, err := w.f.WriteAt(rec, w.size); err != nil {
+		return err
+	}
+
+	w.size += int64(len(rec))
+
+	if w.policy == SyncAlways {
+		return w.f.Sync()
+	}
+
+	return nil
+}
+
+// sync syncs the log to stable storage.
+func (w *wal) sync() error {
+	return w.f.Sync()
+}
+
+// truncate empties the log once its records have been checkpointed.
+func (w *wal) truncate() error {
+	if err := w.f.Truncate(0); err != nil {
+		return err
+	}
+
+	w.size = 0
+	return w.f.Sync()
+}
+
+// close stops syncing the log in the background and closes it.
+func (w *wal) close() error {
+	if w.stop != nil {
+		close(w.stop)
+		w.wg.Wait()
+	}
+
+	return w.f.Close()
+}
@@ -0,0 +1,90 @@
+package disk_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexanderbez/btree/disk"
+	"github.com/stretchr/testify/require"
+)
+
+// walSize returns the size of the write-ahead log of the Tree at path.
+func walSize(t *testing.T, path string) int64 {
+	info, err := os.Stat(path + "-wal")
+	require.NoError(t, err)
+
+	return info.Size()
+}
+
+func TestWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree")
+
+	tr, err := disk.Open(path, testCodec{}, disk.WithPageSize(256), disk.WithMaxEntrySize(16))
+	require.NoError(t, err)
+
+	base, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// writes are logged, while the file only changes at checkpoints
+	for k := uint64(0); k < 100; k++ {
+		require.NoError(t, tr.Insert(testEntry{key: k, value: "v"}))
+	}
+
+	removed, err := tr.Delete(testEntry{key: 5})
+	require.NoError(t, err)
+	require.NotNil(t, removed)
+
+	require.Equal(t, int64(101*(9+8+1)-1), walSize(t, path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, base, data)
+
+	require.NoError(t, tr.Sync())
+	require.NoError(t, tr.Close())
+	require.Zero(t, walSize(t, path))
+
+	tr, err = disk.Open(path, testCodec{}, disk.WithCheckpointSize(0))
+	require.NoError(t, err)
+	require.Equal(t, 99, tr.Size())
+
+	// without a checkpoint size, every write is checkpointed right away
+	require.NoError(t, tr.Insert(testEntry{key: 5}))
+	require.Zero(t, walSize(t, path))
+	require.NoError(t, tr.Verify())
+	require.NoError(t, tr.Close())
+
+	for _, opts := range [][]disk.Option{
+		{disk.WithSyncPolicy(disk.SyncNever)},
+		{disk.WithSyncInterval(time.Millisecond)},
+	} {
+		tr, err := disk.Open(path, testCodec{}, opts...)
+		require.NoError(t, err)
+
+		for k := uint64(100); k < 200; k++ {
+			require.NoError(t, tr.Insert(testEntry{key: k}))
+			if k%50 == 0 {
+				time.Sleep(5 * time.Millisecond)
+			}
+		}
+
+		for k := uint64(100); k < 200; k++ {
+			_, err := tr.Delete(testEntry{key: k})
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, tr.Close())
+	}
+
+	_, err = disk.Open(path, testCodec{}, disk.WithSyncInterval(0))
+	require.Error(t, err)
+
+	tr, err = disk.Open(path, testCodec{})
+	require.NoError(t, err)
+	require.Equal(t, 100, tr.Size())
+	require.NoError(t, tr.Verify())
+
+	require.NoError(t, tr.Close())
+}
@@ -0,0 +1,437 @@
+package btree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The binary encoding of a BTree starts with the format version as a uvarint
+// and the name of the registered codec that encoded the entries, as its length
+// as a uvarint followed by its bytes, which is empty if the Codec of the BTree
+// is not registered. The first version of the encoding lacks the name. Then
+// follow the minimum degree, size and depth of the BTree as uvarints. The nodes
+// follow in preorder, each as the number of its entries and then every entry
+// as the length of its encoding followed by the encoding produced by the Codec.
+// Whether a node has children follows from its level and the depth, so the
+// encoding holds nothing but the entries and the shape of the tree, which is
+// restored exactly rather than rebuilt.
+
+const (
+	// encodingVersion defines the version of the binary encoding of a BTree.
+	encodingVersion = 2
+
+	// maxEncodedDepth bounds the depth of an encoded BTree. Even at the smallest
+	// minimum degree, a BTree that deep would hold more than 2^63 entries.
+	maxEncodedDepth = 64
+)
+
+var (
+	// ErrNoCodec is returned when serializing a BTree that was not created
+	// WithCodec.
+	ErrNoCodec = errors.New("no codec configured")
+
+	// ErrInvalidEncoding is returned when deserializing data that is not a
+	// valid encoding of a BTree.
+	ErrInvalidEncoding = errors.New("invalid encoding")
+)
+
+// Codec defines how the entries of a BTree are encoded to and decoded from
+// bytes when the BTree is serialized. Decode must return an Entry equal to the
+// one that was encoded, and it may retain the provided bytes.
+type Codec interface {
+	Encode(Entry) ([]byte, error)
+	Decode([]byte) (Entry, error)
+}
+
+type (
+	// encoder writes the binary encoding of a BTree, retaining the first write
+	// error, after which all further writes are skipped.
+	encoder struct {
+		w       io.Writer
+		codec   Codec
+		scratch [binary.MaxVarintLen64]byte
+		err     error
+	}
+
+	// byteReader defines the reader the binary encoding is decoded from.
+	byteReader interface {
+		io.Reader
+		io.ByteReader
+	}
+
+	// treeSource defines the source a decoder reads an encoded BTree from: the
+	// minimum degree, size and depth, and then the nodes in preorder, each as
+	// the number of its entries followed by the entries.
+	treeSource interface {
+		// int reads the next number, which must lie within [lo, hi].
+		int(what string, lo, hi int) (int, error)
+
+		// entry reads the next entry.
+		entry() (Entry, error)
+	}
+
+	// binarySource defines the treeSource of the binary encoding.
+	binarySource struct {
+		r     byteReader
+		codec Codec
+	}
+
+	// decoder reads a BTree from a treeSource into nodes owned by cow, verifying
+	// every invariant of the tree along the way.
+	decoder struct {
+		src       treeSource
+		cmp       compareFunc
+		validate  func(Entry) error
+		cow       *copyOnWriteContext
+		minDegree int
+		depth     int
+	}
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler, encoding the BTree along
+// with its minimum degree and exact structure, as of a single point in time,
+// under the read lock. The entries are encoded by the Codec of the BTree, and
+// ErrNoCodec is returned if it has none.
+func (bt *BTree) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := bt.encode(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, replacing the contents
+// of the BTree, including its minimum degree, by those encoded in data by
+// MarshalBinary. The nodes are restored as encoded rather than rebuilt by
+// insertion, after verifying that they form a valid BTree in the order of the
+// BTree, which must therefore be created first, through New or the like, unless
+// it is ordered by Entry.Compare, in which case a zero BTree becomes one as
+// returned by New. The entries are decoded by the registered codec whose name
+// data records, or else by the Codec of the BTree, and ErrNoCodec is returned
+// if there is neither. If data is not a valid encoding, an error wrapping
+// ErrInvalidEncoding is returned and the BTree is left untouched.
+func (bt *BTree) UnmarshalBinary(data []byte) error {
+	if bt.cmp == nil {
+		bt.init()
+	}
+
+	r := bytes.NewReader(data)
+
+	d, root, size, err := bt.decode(r)
+	if err != nil {
+		return err
+	}
+
+	if r.Len() > 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidEncoding, r.Len())
+	}
+
+	bt.adopt(root, size, d.depth, d.minDegree, d.cow)
+	return nil
+}
+
+// encode writes the binary encoding of the BTree to w.
+func (bt *BTree) encode(w io.Writer) error {
+	// snapshots carry no codec, so it is taken from the BTree itself
+	codec := bt.codec
+	if codec == nil {
+		return ErrNoCodec
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	enc := &encoder{w: w, codec: codec}
+	enc.header()
+	enc.uvarint(uint64(bt.minDegree))
+	enc.uvarint(uint64(bt.size))
+	enc.uvarint(uint64(bt.depth))
+	enc.node(bt.root)
+
+	return enc.err
+}
+
+// header writes the version of the encoding and the name of the codec.
+func (enc *encoder) header() {
+	name := codecName(enc.codec)
+
+	enc.uvarint(encodingVersion)
+	enc.uvarint(uint64(len(name)))
+	enc.write([]byte(name))
+}
+
+func (enc *encoder) write(b []byte) {
+	if enc.err == nil {
+		_, enc.err = enc.w.Write(b)
+	}
+}
+
+func (enc *encoder) uvarint(v uint64) {
+	enc.write(enc.scratch[:binary.PutUvarint(enc.scratch[:], v)])
+}
+
+// node writes the subtree rooted at n in preorder.
+func (enc *encoder) node(n *node) {
+	enc.uvarint(uint64(n.numEntries()))
+
+	for _, e := range n.entries {
+		enc.entry(e)
+	}
+
+	for _, child := range n.children {
+		enc.node(child)
+	}
+}
+
+// entry writes a single entry as its length and encoding.
+func (enc *encoder) entry(e Entry) {
+	if enc.err != nil {
+		return
+	}
+
+	b, err := enc.codec.Encode(e)
+	if err != nil {
+		enc.err = fmt.Errorf("failed to encode entry %v: %w", e, err)
+		return
+	}
+
+	enc.uvarint(uint64(len(b)))
+	enc.write(b)
+}
+
+// decode reads the binary encoding of a BTree from r, returning the decoder
+// holding its minimum degree, depth and context along with its root and size.
+// The BTree itself is not touched.
+func (bt *BTree) decode(r byteReader) (*decoder, *node, int, error) {
+	src := &binarySource{r: r, codec: bt.codec}
+	if err := src.header(); err != nil {
+		return nil, nil, 0, err
+	}
+
+	return bt.decodeFrom(src)
+}
+
+// decodeFrom reads an encoded BTree from src as decode does.
+func (bt *BTree) decodeFrom(src treeSource) (*decoder, *node, int, error) {
+	d := &decoder{
+		src:      src,
+		cmp:      bt.cmp,
+		validate: bt.validate,
+		cow:      &copyOnWriteContext{freeList: &freeList{}},
+	}
+
+	t, err := src.int("minimum degree", 2, math.MaxInt32)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	size, err := src.int("size", 0, math.MaxInt)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	depth, err := src.int("depth", 1, maxEncodedDepth)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	d.minDegree, d.depth = t, depth
+
+	root, err := d.node(1, nil, nil)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	if root.count != size {
+		return nil, nil, 0, fmt.Errorf("%w: tree holds %d entries but size is %d", ErrInvalidEncoding, root.count, size)
+	}
+
+	return d, root, size, nil
+}
+
+// adopt replaces the contents of the BTree by the tree rooted at root, whose
+// nodes are owned by cow and referenced by nothing else. The BTree takes over
+// cow along with the nodes.
+func (bt *BTree) adopt(root *node, size, depth, minDegree int, cow *copyOnWriteContext) {
+	bt.lock()
+	defer bt.unlock()
+
+	cow.freeList = bt.cow.freeList
+
+	bt.root, bt.size, bt.depth, bt.minDegree = root, size, depth, minDegree
+	bt.cow = cow
+	bt.mods++
+}
+
+// node reads the subtree at the given level, whose entries must lie strictly
+// between lo and hi, where nil bounds are unbounded.
+func (d *decoder) node(level int, lo, hi Entry) (*node, error) {
+	minEntries := d.minDegree - 1
+	switch {
+	case level == 1 && d.depth == 1:
+		minEntries = 0
+
+	case level == 1:
+		minEntries = 1
+	}
+
+	num, err := d.src.int("number of entries", minEntries, 2*d.minDegree-1)
+	if err != nil {
+		return nil, fmt.Errorf("%w at level %d", err, level)
+	}
+
+	n := d.cow.newNode()
+
+	prev := lo
+	for i := 0; i < num; i++ {
+		e, err := d.entry()
+		if err != nil {
+			return nil, err
+		}
+
+		if prev != nil && d.cmp(prev, e) >= 0 {
+			return nil, fmt.Errorf("%w: entries out of order at level %d", ErrInvalidEncoding, level)
+		}
+
+		n.entries = append(n.entries, e)
+		prev = e
+	}
+
+	if num > 0 && hi != nil && d.cmp(prev, hi) >= 0 {
+		return nil, fmt.Errorf("%w: entries out of order at level %d", ErrInvalidEncoding, level)
+	}
+
+	n.count = num
+	if level == d.depth {
+		return n, nil
+	}
+
+	for i := 0; i <= num; i++ {
+		clo, chi := lo, hi
+		if i > 0 {
+			clo = n.entries[i-1]
+		}
+
+		if i < num {
+			chi = n.entries[i]
+		}
+
+		child, err := d.node(level+1, clo, chi)
+		if err != nil {
+			return nil, err
+		}
+
+		n.children = append(n.children, child)
+		n.count += child.count
+	}
+
+	return n, nil
+}
+
+// entry reads the next entry from the source, which must be valid.
+func (d *decoder) entry() (Entry, error) {
+	e, err := d.src.entry()
+	if err != nil {
+		return nil, err
+	}
+
+	if e == nil {
+		return nil, fmt.Errorf("%w: nil entry", ErrInvalidEncoding)
+	}
+
+	if d.validate != nil {
+		if err := d.validate(e); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidEncoding, err)
+		}
+	}
+
+	return e, nil
+}
+
+// header reads the version of the encoding and, as of the second version, the
+// name of the codec, which then decodes the entries in place of the Codec of
+// the BTree if it is not empty.
+func (src *binarySource) header() error {
+	version, err := src.uvarint("version")
+	if err != nil {
+		return err
+	}
+
+	switch version {
+	case 1:
+
+	case encodingVersion:
+		l, err := src.int("codec name length", 0, maxCodecName)
+		if err != nil {
+			return err
+		}
+
+		name := make([]byte, l)
+		if _, err := io.ReadFull(src.r, name); err != nil {
+			return fmt.Errorf("%w: truncated codec name", ErrInvalidEncoding)
+		}
+
+		if l > 0 {
+			if src.codec, err = EntryCodec(string(name)); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidEncoding, version)
+	}
+
+	if src.codec == nil {
+		return ErrNoCodec
+	}
+
+	return nil
+}
+
+func (src *binarySource) uvarint(what string) (uint64, error) {
+	v, err := binary.ReadUvarint(src.r)
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to read %s: %v", ErrInvalidEncoding, what, err)
+	}
+
+	return v, nil
+}
+
+func (src *binarySource) int(what string, lo, hi int) (int, error) {
+	v, err := src.uvarint(what)
+	if err != nil {
+		return 0, err
+	}
+
+	if v < uint64(lo) || v > uint64(hi) {
+		return 0, fmt.Errorf("%w: %s out of range: %d", ErrInvalidEncoding, what, v)
+	}
+
+	return int(v), nil
+}
+
+func (src *binarySource) entry() (Entry, error) {
+	l, err := src.int("entry length", 0, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+
+	// the buffer grows as data arrives, so a corrupt length cannot make it
+	// allocate more than is actually there
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(io.LimitReader(src.r, int64(l))); err != nil || buf.Len() != l {
+		return nil, fmt.Errorf("%w: truncated entry", ErrInvalidEncoding)
+	}
+
+	e, err := src.codec.Decode(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode entry: %w", ErrInvalidEncoding, err)
+	}
+
+	return e, nil
+}
@@ -0,0 +1,279 @@
+package btree_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+// testCodec encodes a testEntry as its key and value in big-endian order.
+type testCodec struct{}
+
+func (testCodec) Encode(e btree.Entry) ([]byte, error) {
+	te := e.(testEntry)
+
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, te.key)
+	binary.BigEndian.PutUint64(b[8:], te.value)
+
+	return b, nil
+}
+
+func (testCodec) Decode(b []byte) (btree.Entry, error) {
+	if len(b) != 16 {
+		return nil, fmt.Errorf("invalid length %d", len(b))
+	}
+
+	return testEntry{binary.BigEndian.Uint64(b), binary.BigEndian.Uint64(b[8:])}, nil
+}
+
+// reversedCodec decodes keys in the opposite order they were encoded in.
+type reversedCodec struct {
+	testCodec
+}
+
+func (c reversedCodec) Decode(b []byte) (btree.Entry, error) {
+	e, err := c.testCodec.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return testEntry{key: math.MaxUint64 - e.(testEntry).key}, nil
+}
+
+// appendUvarints returns b with every value appended as a uvarint.
+func appendUvarints(b []byte, values ...uint64) []byte {
+	for _, v := range values {
+		b = binary.AppendUvarint(b, v)
+	}
+
+	return b
+}
+
+func TestMarshalBinary(t *testing.T) {
+	bt, err := btree.New(3)
+	require.NoError(t, err)
+
+	_, err = bt.MarshalBinary()
+	require.True(t, errors.Is(err, btree.ErrNoCodec))
+	require.True(t, errors.Is(bt.UnmarshalBinary(appendUvarints(nil, 2, 0)), btree.ErrNoCodec))
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree, btree.WithCodec(testCodec{}))
+			require.NoError(t, err)
+
+			for _, i := range rng.Perm(2000) {
+				bt.Insert(testEntry{uint64(i), rng.Uint64()})
+			}
+
+			for _, i := range rng.Perm(2000)[:700] {
+				bt.Delete(testEntry{key: uint64(i)})
+			}
+
+			data, err := bt.MarshalBinary()
+			require.NoError(t, err)
+
+			// the structure is restored exactly, along with the minimum degree
+			restored, err := btree.New(2, btree.WithCodec(testCodec{}))
+			require.NoError(t, err)
+			restored.Insert(testEntry{key: 5000})
+
+			require.NoError(t, restored.UnmarshalBinary(data))
+			require.NoError(t, restored.Verify())
+			require.Equal(t, bt.Stats(), restored.Stats())
+			require.Equal(t, bt.Entries(nil, nil), restored.Entries(nil, nil))
+
+			again, err := restored.MarshalBinary()
+			require.NoError(t, err)
+			require.Equal(t, data, again)
+
+			// the restored BTree is fully usable
+			for i := uint64(0); i < 2000; i += 3 {
+				restored.Insert(testEntry{key: i})
+				restored.Delete(testEntry{key: i + 1})
+			}
+
+			require.NoError(t, restored.Verify())
+
+			// a corrupt encoding leaves the BTree untouched
+			want := restored.Entries(nil, nil)
+			for _, corrupt := range [][]byte{
+				nil,
+				data[:len(data)/2],
+				data[:len(data)-1],
+				append(append([]byte(nil), data...), 0),
+				append([]byte{3}, data[1:]...),
+			} {
+				require.True(t, errors.Is(restored.UnmarshalBinary(corrupt), btree.ErrInvalidEncoding))
+			}
+
+			reversed, err := btree.New(minDegree, btree.WithCodec(reversedCodec{}))
+			require.NoError(t, err)
+			require.True(t, errors.Is(reversed.UnmarshalBinary(data), btree.ErrInvalidEncoding))
+
+			require.Equal(t, want, restored.Entries(nil, nil))
+			require.NoError(t, restored.Verify())
+		})
+	}
+
+	// an empty BTree round-trips as well
+	empty, err := btree.New(4, btree.WithCodec(testCodec{}))
+	require.NoError(t, err)
+
+	data, err := empty.MarshalBinary()
+	require.NoError(t, err)
+
+	restored, err := btree.New(2, btree.WithCodec(testCodec{}))
+	require.NoError(t, err)
+	restored.Insert(testEntry{key: 1})
+
+	require.NoError(t, restored.UnmarshalBinary(data))
+	require.Zero(t, restored.Size())
+	require.Equal(t, 4, restored.Stats().MinDegree)
+
+	// nodes must hold at least t-1 entries
+	entry := func(k uint64) []byte {
+		b, _ := testCodec{}.Encode(testEntry{key: k})
+		return append(appendUvarints(nil, uint64(len(b))), b...)
+	}
+
+	encode := func(minDegree uint64) []byte {
+		data := appendUvarints(nil, 1, minDegree, 3, 2, 1)
+		data = append(data, entry(5)...)
+		data = append(appendUvarints(data, 1), entry(1)...)
+		return append(appendUvarints(data, 1), entry(9)...)
+	}
+
+	require.True(t, errors.Is(restored.UnmarshalBinary(encode(3)), btree.ErrInvalidEncoding))
+	require.NoError(t, restored.UnmarshalBinary(encode(2)))
+	require.Equal(t, []btree.Entry{testEntry{key: 1}, testEntry{key: 5}, testEntry{key: 9}}, restored.Entries(nil, nil))
+}
+
+func TestRestoredMinDegree(t *testing.T) {
+	const n = 300
+
+	// packed returns a BTree configured WithCodec holding the even keys below
+	// 2*size, built bottom-up by ReadFrom so that its nodes are full
+	packed := func(t *testing.T, minDegree, size int) *btree.BTree {
+		bt, err := btree.New(minDegree, btree.WithCodec(testCodec{}))
+		require.NoError(t, err)
+
+		for i := 0; i < size; i++ {
+			bt.Insert(testEntry{key: uint64(2 * i)})
+		}
+
+		var buf bytes.Buffer
+		_, err = bt.WriteTo(&buf)
+		require.NoError(t, err)
+
+		loaded, err := btree.New(minDegree, btree.WithCodec(testCodec{}))
+		require.NoError(t, err)
+
+		_, err = loaded.ReadFrom(&buf)
+		require.NoError(t, err)
+
+		return loaded
+	}
+
+	keyed := func(i int) btree.Entry { return testEntry{key: uint64(i)} }
+	named := func(i int) btree.Entry { return namedEntry{fmt.Sprintf("%05d", i)} }
+
+	evens := func(entry func(int) btree.Entry, size int) []btree.Entry {
+		sorted := make([]btree.Entry, size)
+		for i := range sorted {
+			sorted[i] = entry(2 * i)
+		}
+
+		return sorted
+	}
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		// up to 2t-1 entries are restored as a single leaf root at capacity
+		for _, size := range []int{1, 2*minDegree - 2, 2*minDegree - 1, n} {
+			for _, tc := range []struct {
+				name    string
+				entry   func(int) btree.Entry
+				restore func(t *testing.T) *btree.BTree
+			}{
+				{"BulkLoad", keyed, func(t *testing.T) *btree.BTree {
+					bt, err := btree.BulkLoad(evens(keyed, size), minDegree)
+					require.NoError(t, err)
+
+					return bt
+				}},
+				{"UnmarshalBinary", keyed, func(t *testing.T) *btree.BTree {
+					data, err := packed(t, minDegree, size).MarshalBinary()
+					require.NoError(t, err)
+
+					bt, err := btree.New(minDegree+1, btree.WithCodec(testCodec{}))
+					require.NoError(t, err)
+					require.NoError(t, bt.UnmarshalBinary(data))
+
+					return bt
+				}},
+				{"ReadFrom", keyed, func(t *testing.T) *btree.BTree {
+					return packed(t, minDegree, size)
+				}},
+				{"GobDecode", named, func(t *testing.T) *btree.BTree {
+					src, err := btree.BulkLoad(evens(named, size), minDegree)
+					require.NoError(t, err)
+
+					data, err := src.GobEncode()
+					require.NoError(t, err)
+
+					var bt btree.BTree
+					require.NoError(t, bt.GobDecode(data))
+
+					return &bt
+				}},
+			} {
+				t.Run(fmt.Sprintf("%s minimum degree %d size %d", tc.name, minDegree, size), func(t *testing.T) {
+					bt := tc.restore(t)
+					require.NoError(t, bt.Verify())
+					require.Equal(t, minDegree, bt.Stats().MinDegree)
+					require.Equal(t, size, bt.Size())
+
+					// an odd key lands between every pair of restored ones,
+					// so every node is filled past 2t-1 entries unless it
+					// splits
+					for i := 0; i < size; i++ {
+						bt.Insert(tc.entry(2*i + 1))
+						require.NoError(t, bt.Verify())
+					}
+
+					require.Equal(t, 2*size, bt.Size())
+				})
+			}
+		}
+	}
+
+	// node fills incompatible with the encoded minimum degree are rejected,
+	// whether the full nodes overflow a smaller degree or underflow a larger
+	for _, minDegree := range []int{3, 4, 11} {
+		data, err := packed(t, minDegree, n).MarshalBinary()
+		require.NoError(t, err)
+
+		// the version and the empty codec name precede the minimum degree
+		require.Equal(t, []byte{2, 0, byte(minDegree)}, data[:3])
+
+		for _, degree := range []int{minDegree - 1, 2*minDegree + 1} {
+			corrupt := append([]byte(nil), data...)
+			corrupt[2] = byte(degree)
+
+			bt, err := btree.New(minDegree, btree.WithCodec(testCodec{}))
+			require.NoError(t, err)
+
+			err = bt.UnmarshalBinary(corrupt)
+			require.True(t, errors.Is(err, btree.ErrInvalidEncoding))
+			require.Contains(t, err.Error(), "number of entries out of range")
+			require.Zero(t, bt.Size())
+		}
+	}
+}
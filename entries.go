@@ -0,0 +1,86 @@
+package btree
+
+import (
+	"bytes"
+	"cmp"
+	"strings"
+)
+
+type (
+	// IntEntry defines a ready-made Entry ordered by an int key, carrying an
+	// opaque value.
+	IntEntry struct {
+		Key   int
+		Value any
+	}
+
+	// Uint64Entry defines a ready-made Entry ordered by a uint64 key, carrying an
+	// opaque value.
+	Uint64Entry struct {
+		Key   uint64
+		Value any
+	}
+
+	// StringEntry defines a ready-made Entry ordered by a string key, carrying an
+	// opaque value. It implements PrefixEntry, so its BTree may be scanned via
+	// AscendPrefix.
+	StringEntry struct {
+		Key   string
+		Value any
+	}
+
+	// BytesEntry defines a ready-made Entry ordered by a byte-string key, carrying
+	// an opaque value. It implements PrefixEntry, so its BTree may be scanned via
+	// AscendPrefix. The key must not be modified once the Entry is inserted.
+	BytesEntry struct {
+		Key   []byte
+		Value any
+	}
+)
+
+var (
+	_ Entry       = IntEntry{}
+	_ Entry       = Uint64Entry{}
+	_ PrefixEntry = StringEntry{}
+	_ PrefixEntry = BytesEntry{}
+)
+
+// Compare implements Entry. The argument must be an IntEntry.
+func (e IntEntry) Compare(other Entry) int {
+	return cmp.Compare(e.Key, other.(IntEntry).Key)
+}
+
+// Compare implements Entry. The argument must be a Uint64Entry.
+func (e Uint64Entry) Compare(other Entry) int {
+	return cmp.Compare(e.Key, other.(Uint64Entry).Key)
+}
+
+// Compare implements Entry. The argument must be a StringEntry.
+func (e StringEntry) Compare(other Entry) int {
+	return strings.Compare(e.Key, other.(StringEntry).Key)
+}
+
+// ComparePrefix implements PrefixEntry.
+func (e StringEntry) ComparePrefix(prefix []byte) int {
+	k := e.Key
+	if len(k) > len(prefix) {
+		k = k[:len(prefix)]
+	}
+
+	return strings.Compare(k, string(prefix))
+}
+
+// Compare implements Entry. The argument must be a BytesEntry.
+func (e BytesEntry) Compare(other Entry) int {
+	return bytes.Compare(e.Key, other.(BytesEntry).Key)
+}
+
+// ComparePrefix implements PrefixEntry.
+func (e BytesEntry) ComparePrefix(prefix []byte) int {
+	k := e.Key
+	if len(k) > len(prefix) {
+		k = k[:len(prefix)]
+	}
+
+	return bytes.Compare(k, prefix)
+}
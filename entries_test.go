@@ -0,0 +1,74 @@
+package btree_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinEntries(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		entry func(i int) btree.Entry
+	}{
+		{"int", func(i int) btree.Entry { return btree.IntEntry{Key: i - 500, Value: i} }},
+		{"uint64", func(i int) btree.Entry { return btree.Uint64Entry{Key: uint64(i) << 40, Value: i} }},
+		{"string", func(i int) btree.Entry { return btree.StringEntry{Key: fmt.Sprintf("%04d", i), Value: i} }},
+		{"bytes", func(i int) btree.Entry { return btree.BytesEntry{Key: []byte{byte(i >> 8), byte(i)}, Value: i} }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			bt, err := btree.New(3)
+			require.NoError(t, err)
+
+			for _, i := range rng.Perm(1000) {
+				bt.Insert(tc.entry(i))
+			}
+
+			require.NoError(t, bt.Verify())
+
+			i := 0
+			bt.Ascend(func(e btree.Entry) bool {
+				require.Equal(t, tc.entry(i), e)
+				i++
+				return true
+			})
+
+			require.Equal(t, 1000, i)
+			require.Equal(t, tc.entry(42), bt.Search(tc.entry(42)))
+			require.Nil(t, bt.Search(tc.entry(1000)))
+		})
+	}
+
+	// string and byte keys support prefix scans
+	bt, err := btree.New(3)
+	require.NoError(t, err)
+
+	for _, k := range []string{"a", "ab", "abc", "abd", "b", "ba"} {
+		bt.Insert(btree.StringEntry{Key: k})
+	}
+
+	var got []string
+	bt.AscendPrefix([]byte("ab"), func(e btree.Entry) bool {
+		got = append(got, e.(btree.StringEntry).Key)
+		return true
+	})
+
+	require.Equal(t, []string{"ab", "abc", "abd"}, got)
+
+	bt, err = btree.New(3)
+	require.NoError(t, err)
+
+	for _, k := range []string{"a", "ab", "abc", "abd", "b", "ba"} {
+		bt.Insert(btree.BytesEntry{Key: []byte(k)})
+	}
+
+	got = got[:0]
+	bt.AscendPrefix([]byte("b"), func(e btree.Entry) bool {
+		got = append(got, string(e.(btree.BytesEntry).Key))
+		return true
+	})
+
+	require.Equal(t, []string{"b", "ba"}, got)
+}
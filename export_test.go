@@ -0,0 +1,332 @@
+package btree
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Verify checks the structural invariants of the BTree, returning an error
+// describing the first violation found.
+func (bt *BTree) Verify() error {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	var (
+		prev  Entry
+		count int
+	)
+
+	maxEntries := 2*bt.minDegree - 1
+
+	var walk func(n *node, level int) error
+	walk = func(n *node, level int) error {
+		isRoot := n == bt.root
+
+		switch {
+		case n.numEntries() > maxEntries:
+			return fmt.Errorf("node at level %d is overfull: %d > %d", level, n.numEntries(), maxEntries)
+
+		case !isRoot && n.numEntries() < bt.minDegree-1:
+			return fmt.Errorf("node at level %d is underfull: %d < %d", level, n.numEntries(), bt.minDegree-1)
+
+		case isRoot && !n.leaf() && n.numEntries() == 0:
+			return fmt.Errorf("internal root node has no entries")
+
+		case !n.leaf() && n.numChildren() != n.numEntries()+1:
+			return fmt.Errorf("node at level %d has %d entries but %d children", level, n.numEntries(), n.numChildren())
+
+		case n.leaf() && level != bt.depth:
+			return fmt.Errorf("leaf at level %d but tree depth is %d", level, bt.depth)
+		}
+
+		subtreeCount := n.numEntries()
+		for _, child := range n.children {
+			subtreeCount += child.count
+		}
+
+		if n.count != subtreeCount {
+			return fmt.Errorf("node at level %d has count %d but holds %d entries", level, n.count, subtreeCount)
+		}
+
+		for i, e := range n.entries {
+			if !n.leaf() {
+				if err := walk(n.children[i], level+1); err != nil {
+					return err
+				}
+			}
+
+			if prev != nil && bt.cmp(prev, e) >= 0 {
+				return fmt.Errorf("entries out of order at level %d: %v >= %v", level, prev, e)
+			}
+
+			prev = e
+			count++
+		}
+
+		if !n.leaf() {
+			return walk(n.children[n.numChildren()-1], level+1)
+		}
+
+		return nil
+	}
+
+	if err := walk(bt.root, 1); err != nil {
+		return err
+	}
+
+	if count != bt.size {
+		return fmt.Errorf("tree contains %d entries but size is %d", count, bt.size)
+	}
+
+	return nil
+}
+
+// FreeListLen returns the number of nodes retained in the BTree's free list.
+func (bt *BTree) FreeListLen() int {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+	return len(bt.cow.freeList.nodes)
+}
+
+// Verify checks the structural invariants of the Uint64Tree, returning an error
+// describing the first violation found.
+func (u *Uint64Tree) Verify() error {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.tree.verify()
+}
+
+// Verify checks the structural invariants of the BytesTree, returning an error
+// describing the first violation found.
+func (b *BytesTree) Verify() error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	root, err := b.root.knode()
+	if err != nil {
+		return err
+	}
+
+	tr := &ktree[[]byte, []byte]{cmp: bytes.Compare, t: b.t, root: root, size: b.size}
+	return tr.verify()
+}
+
+// knode returns a copy of the subtree rooted at n made of knodes, so that it can
+// be verified as a ktree, or an error if the buffer of a node does not match the
+// ends of its keys.
+func (n *bnode) knode() (*knode[[]byte, []byte], error) {
+	kn := &knode[[]byte, []byte]{values: n.values}
+
+	prev := uint32(0)
+	for i, e := range n.ends {
+		if e < prev {
+			return nil, fmt.Errorf("key %d ends at %d before it starts at %d", i, e, prev)
+		}
+
+		kn.keys = append(kn.keys, n.key(i))
+		prev = e
+	}
+
+	if int(prev) != len(n.keys) {
+		return nil, fmt.Errorf("keys end at %d but the buffer holds %d bytes", prev, len(n.keys))
+	}
+
+	for _, child := range n.children {
+		kc, err := child.knode()
+		if err != nil {
+			return nil, err
+		}
+
+		kn.children = append(kn.children, kc)
+	}
+
+	return kn, nil
+}
+
+// Verify checks the structural invariants of the StringTree, returning an error
+// describing the first violation found.
+func (s *StringTree[V]) Verify() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.verify()
+}
+
+// Verify checks the structural invariants of the ConcurrentTree, returning an
+// error describing the first violation found. It must not be called
+// concurrently with writes.
+func (c *ConcurrentTree[K, V]) Verify() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	root, err := c.root.Load().knode()
+	if err != nil {
+		return err
+	}
+
+	tr := &ktree[K, V]{cmp: c.cmp, t: c.t, root: root, size: c.Len()}
+	return tr.verify()
+}
+
+// knode returns a copy of the subtree rooted at n made of knodes, so that it can
+// be verified as a ktree, or an error if a node is still marked as being
+// modified.
+func (n *cnode[K, V]) knode() (*knode[K, V], error) {
+	if _, ok := n.stable(); !ok {
+		return nil, fmt.Errorf("node still being modified")
+	}
+
+	it := n.items.Load()
+	kn := &knode[K, V]{keys: it.keys, values: it.values}
+	for _, child := range it.children {
+		kc, err := child.knode()
+		if err != nil {
+			return nil, err
+		}
+
+		kn.children = append(kn.children, kc)
+	}
+
+	return kn, nil
+}
+
+// Verify checks the structural invariants of the BLinkTree, returning an error
+// describing the first violation found. It must not be called concurrently
+// with writes.
+func (b *BLinkTree[K, V]) Verify() error {
+	count := 0
+
+	// walk checks the subtree rooted at n, whose keys must lie in [lo, hi),
+	// where nil bounds are unbounded
+	var walk func(n *blnode[K, V], lo, hi *K) error
+	walk = func(n *blnode[K, V], lo, hi *K) error {
+		if len(n.keys) > 2*b.t-1 {
+			return fmt.Errorf("node at level %d holds %d keys", n.level, len(n.keys))
+		}
+
+		if n.bounded != (hi != nil) || hi != nil && b.cmp(n.high, *hi) != 0 {
+			return fmt.Errorf("high key mismatch at level %d", n.level)
+		}
+
+		for i, k := range n.keys {
+			if i > 0 && b.cmp(n.keys[i-1], k) >= 0 || lo != nil && b.cmp(k, *lo) < 0 || hi != nil && b.cmp(k, *hi) >= 0 {
+				return fmt.Errorf("key %v out of order at level %d", k, n.level)
+			}
+		}
+
+		if n.level == 0 {
+			if len(n.children) != 0 || len(n.values) != len(n.keys) {
+				return fmt.Errorf("malformed leaf")
+			}
+
+			count += len(n.keys)
+			return nil
+		}
+
+		if len(n.children) != len(n.keys)+1 {
+			return fmt.Errorf("node at level %d has %d keys but %d children", n.level, len(n.keys), len(n.children))
+		}
+
+		for i, child := range n.children {
+			if child.level != n.level-1 {
+				return fmt.Errorf("child at level %d below level %d", child.level, n.level)
+			}
+
+			if i < len(n.keys) && child.right != n.children[i+1] {
+				return fmt.Errorf("broken right link at level %d", child.level)
+			}
+
+			clo, chi := lo, hi
+			if i > 0 {
+				clo = &n.keys[i-1]
+			}
+
+			if i < len(n.keys) {
+				chi = &n.keys[i]
+			}
+
+			if err := walk(child, clo, chi); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(b.root.Load(), nil, nil); err != nil {
+		return err
+	}
+
+	if count != b.Len() {
+		return fmt.Errorf("size mismatch: counted %d, expected %d", count, b.Len())
+	}
+
+	return nil
+}
+
+// verify checks the structural invariants of the ktree, returning an error
+// describing the first violation found.
+func (tr *ktree[K, V]) verify() error {
+	var (
+		prev    *K
+		count   int
+		leafLvl = -1
+	)
+
+	var walk func(n *knode[K, V], level int) error
+	walk = func(n *knode[K, V], level int) error {
+		isRoot := n == tr.root
+
+		switch {
+		case len(n.keys) > 2*tr.t-1:
+			return fmt.Errorf("node at level %d is overfull: %d > %d", level, len(n.keys), 2*tr.t-1)
+
+		case !isRoot && len(n.keys) < tr.t-1:
+			return fmt.Errorf("node at level %d is underfull: %d < %d", level, len(n.keys), tr.t-1)
+
+		case len(n.values) != len(n.keys):
+			return fmt.Errorf("node at level %d has %d keys but %d values", level, len(n.keys), len(n.values))
+
+		case !n.leaf() && len(n.children) != len(n.keys)+1:
+			return fmt.Errorf("node at level %d has %d keys but %d children", level, len(n.keys), len(n.children))
+		}
+
+		if n.leaf() {
+			if leafLvl == -1 {
+				leafLvl = level
+			} else if leafLvl != level {
+				return fmt.Errorf("leaves at levels %d and %d", leafLvl, level)
+			}
+		}
+
+		for i := range n.keys {
+			if !n.leaf() {
+				if err := walk(n.children[i], level+1); err != nil {
+					return err
+				}
+			}
+
+			if prev != nil && tr.cmp(*prev, n.keys[i]) >= 0 {
+				return fmt.Errorf("keys out of order at level %d: %v >= %v", level, *prev, n.keys[i])
+			}
+
+			prev = &n.keys[i]
+			count++
+		}
+
+		if !n.leaf() {
+			return walk(n.children[len(n.children)-1], level+1)
+		}
+
+		return nil
+	}
+
+	if err := walk(tr.root, 1); err != nil {
+		return err
+	}
+
+	if count != tr.size {
+		return fmt.Errorf("tree contains %d keys but size is %d", count, tr.size)
+	}
+
+	return nil
+}
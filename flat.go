@@ -0,0 +1,561 @@
+package btree
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// The functions in this file write trees in the FlatBuffers format, following
+// the Tree table of tree.fbs, and search them in place. The buffer is built
+// front to back, with every table preceding the vectors and tables it refers
+// to, since all offsets of FlatBuffers point forward. The few tables involved
+// share four vtables written up front, and are laid out directly rather than
+// through a FlatBuffers runtime.
+
+const (
+	flatEntryData = 0
+
+	flatNodeEntries  = 0
+	flatNodeChildren = 1
+
+	flatTreeMinDegree = 0
+	flatTreeSize      = 1
+	flatTreeDepth     = 2
+	flatTreeRoot      = 3
+)
+
+type (
+	// FlatTree defines a read-only BTree searched in place within the buffer
+	// written by MarshalFlatBuffer, which is never decoded as a whole. It never
+	// changes, so it is safe for concurrent use without locking.
+	FlatTree struct {
+		data      flatBuffer
+		cmp       compareFunc
+		codec     Codec
+		minDegree int
+		size      int
+		depth     int
+		root      int
+
+		// unmap unmaps the data of a FlatTree returned by OpenMmap
+		unmap  func() error
+		closed bool
+	}
+
+	// flatBuffer defines a FlatBuffers buffer, every read from which is bounds
+	// checked.
+	flatBuffer []byte
+
+	// flatNode locates the entries and children vectors of a node of a
+	// FlatTree.
+	flatNode struct {
+		entries, numEntries   int
+		children, numChildren int
+	}
+
+	// flatBuilder writes a FlatBuffers buffer front to back.
+	flatBuilder struct {
+		buf                      []byte
+		codec                    Codec
+		vtEntry, vtLeaf, vtInner int
+	}
+)
+
+// MarshalFlatBuffer encodes the BTree as a Tree table of tree.fbs, holding its
+// nodes as they are, as of a single point in time, under the read lock. The
+// result can be opened and queried in place by OpenFlatBuffer, or read by any
+// FlatBuffers implementation. The entries are encoded by the Codec of the
+// BTree, and ErrNoCodec is returned if it has none.
+func (bt *BTree) MarshalFlatBuffer() ([]byte, error) {
+	// snapshots carry no codec, so it is taken from the BTree itself
+	codec := bt.codec
+	if codec == nil {
+		return nil, ErrNoCodec
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	b := &flatBuilder{codec: codec}
+	root := b.reserve(4)
+
+	b.vtEntry = b.vtable(8, 4)
+	b.vtLeaf = b.vtable(24, 4, 0, 16)
+	b.vtInner = b.vtable(24, 4, 8, 16)
+	vtTree := b.vtable(24, 4, 16, 12, 8)
+
+	tree := b.table(8, 24, vtTree)
+	b.offset(root, tree)
+	b.put32(tree+4, uint32(bt.minDegree))
+	b.put32(tree+12, uint32(bt.depth))
+	binary.LittleEndian.PutUint64(b.buf[tree+16:], uint64(bt.size))
+
+	n, err := b.node(bt.root)
+	if err != nil {
+		return nil, err
+	}
+
+	b.offset(tree+8, n)
+	b.align(8)
+
+	if len(b.buf) > math.MaxUint32 {
+		return nil, fmt.Errorf("tree too large for FlatBuffers: %d bytes", len(b.buf))
+	}
+
+	return b.buf, nil
+}
+
+// OpenFlatBuffer returns a FlatTree over data written by MarshalFlatBuffer,
+// ordering and decoding the entries like the BTree, which must therefore be
+// created first, through New or the like, WithCodec. ErrNoCodec is returned if
+// the BTree has no Codec. Opening reads nothing but the header, so it takes
+// constant time regardless of the size of the tree: nodes are located through
+// their offsets as they are visited, and entries are decoded by the Codec one
+// at a time as they are compared or visited. Decoded entries may retain data,
+// which must therefore not be modified while the FlatTree is in use.
+//
+// Every read from data is bounds checked, so corrupt data results in errors
+// wrapping ErrInvalidEncoding rather than panics. The order of the entries is
+// trusted, however, and a FlatTree over misordered entries may miss some.
+func (bt *BTree) OpenFlatBuffer(data []byte) (*FlatTree, error) {
+	if bt.codec == nil {
+		return nil, ErrNoCodec
+	}
+
+	ft := &FlatTree{data: data, cmp: bt.cmp, codec: bt.codec}
+
+	tree, err := ft.data.deref(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if ft.minDegree, err = ft.data.int(tree, flatTreeMinDegree, 4, 2, math.MaxInt32); err != nil {
+		return nil, err
+	}
+
+	if ft.size, err = ft.data.int(tree, flatTreeSize, 8, 0, math.MaxInt); err != nil {
+		return nil, err
+	}
+
+	if ft.depth, err = ft.data.int(tree, flatTreeDepth, 4, 1, maxEncodedDepth); err != nil {
+		return nil, err
+	}
+
+	f, err := ft.data.field(tree, flatTreeRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	if f < 0 {
+		return nil, fmt.Errorf("%w: missing root", ErrInvalidEncoding)
+	}
+
+	if ft.root, err = ft.data.deref(f); err != nil {
+		return nil, err
+	}
+
+	if _, err := ft.node(ft.root, 1); err != nil {
+		return nil, err
+	}
+
+	return ft, nil
+}
+
+// Size returns the number of entries in the FlatTree.
+func (ft *FlatTree) Size() int {
+	return ft.size
+}
+
+// Search returns the Entry of the FlatTree equal to e, or nil if there is
+// none, decoding only the entries compared on the way down.
+func (ft *FlatTree) Search(e Entry) (Entry, error) {
+	if ft.closed {
+		return nil, ErrClosed
+	}
+
+	pos := ft.root
+	for level := 1; ; level++ {
+		n, err := ft.node(pos, level)
+		if err != nil {
+			return nil, err
+		}
+
+		i, found, err := ft.find(n, e)
+		if err != nil || found != nil || n.numChildren == 0 {
+			return found, err
+		}
+
+		if pos, err = ft.data.deref(n.children + 4*i); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Has returns true if the FlatTree holds an Entry equal to e.
+func (ft *FlatTree) Has(e Entry) (bool, error) {
+	found, err := ft.Search(e)
+	return found != nil, err
+}
+
+// Ascend calls fn for every Entry of the FlatTree in ascending order until fn
+// returns false.
+func (ft *FlatTree) Ascend(fn func(Entry) bool) error {
+	return ft.AscendRange(nil, nil, fn)
+}
+
+// AscendRange calls fn for every Entry e of the FlatTree, s.t. from <= e < to,
+// in ascending order until fn returns false. A nil from or to leaves the
+// respective end of the range unbounded.
+func (ft *FlatTree) AscendRange(from, to Entry, fn func(Entry) bool) error {
+	if ft.closed {
+		return ErrClosed
+	}
+
+	_, err := ft.ascend(ft.root, 1, from, to, fn)
+	return err
+}
+
+// ascend visits the range of the subtree at pos on the given level, returning
+// false once fn returned false or the end of the range was reached.
+func (ft *FlatTree) ascend(pos, level int, from, to Entry, fn func(Entry) bool) (bool, error) {
+	n, err := ft.node(pos, level)
+	if err != nil {
+		return false, err
+	}
+
+	i := 0
+	if from != nil {
+		if i, _, err = ft.find(n, from); err != nil {
+			return false, err
+		}
+	}
+
+	// only the first child visited may hold entries below from
+	for ; i <= n.numEntries; i++ {
+		if n.numChildren > 0 {
+			child, err := ft.data.deref(n.children + 4*i)
+			if err != nil {
+				return false, err
+			}
+
+			if ok, err := ft.ascend(child, level+1, from, to, fn); !ok || err != nil {
+				return false, err
+			}
+
+			from = nil
+		}
+
+		if i == n.numEntries {
+			break
+		}
+
+		e, err := ft.entry(n, i)
+		if err != nil {
+			return false, err
+		}
+
+		if to != nil && ft.cmp(e, to) >= 0 || !fn(e) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// node locates the node at pos on the given level, which has children unless
+// it lies on the deepest level.
+func (ft *FlatTree) node(pos, level int) (flatNode, error) {
+	var (
+		n   flatNode
+		err error
+	)
+
+	if n.entries, n.numEntries, err = ft.data.vector(pos, flatNodeEntries, 4); err != nil {
+		return n, err
+	}
+
+	if n.children, n.numChildren, err = ft.data.vector(pos, flatNodeChildren, 4); err != nil {
+		return n, err
+	}
+
+	want := 0
+	if level < ft.depth {
+		want = n.numEntries + 1
+	}
+
+	if n.numChildren != want {
+		return n, fmt.Errorf("%w: node at level %d has %d entries but %d children", ErrInvalidEncoding, level, n.numEntries, n.numChildren)
+	}
+
+	return n, nil
+}
+
+// find returns the index of the first entry of n not less than e, along with
+// that entry if it is equal to e.
+func (ft *FlatTree) find(n flatNode, e Entry) (int, Entry, error) {
+	lo, hi := 0, n.numEntries
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+
+		m, err := ft.entry(n, mid)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch c := ft.cmp(m, e); {
+		case c == 0:
+			return mid, m, nil
+
+		case c < 0:
+			lo = mid + 1
+
+		default:
+			hi = mid
+		}
+	}
+
+	return lo, nil, nil
+}
+
+// entry decodes the i-th entry of n.
+func (ft *FlatTree) entry(n flatNode, i int) (Entry, error) {
+	table, err := ft.data.deref(n.entries + 4*i)
+	if err != nil {
+		return nil, err
+	}
+
+	start, l, err := ft.data.vector(table, flatEntryData, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := ft.codec.Decode(ft.data[start : start+l : start+l])
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode entry: %w", ErrInvalidEncoding, err)
+	}
+
+	if e == nil {
+		return nil, fmt.Errorf("%w: nil entry", ErrInvalidEncoding)
+	}
+
+	return e, nil
+}
+
+// check returns an error unless the n bytes at pos lie within the buffer.
+func (fb flatBuffer) check(pos, n int) error {
+	if pos < 0 || pos > len(fb)-n {
+		return fmt.Errorf("%w: offset %d out of bounds", ErrInvalidEncoding, pos)
+	}
+
+	return nil
+}
+
+func (fb flatBuffer) u16(pos int) (int, error) {
+	if err := fb.check(pos, 2); err != nil {
+		return 0, err
+	}
+
+	return int(binary.LittleEndian.Uint16(fb[pos:])), nil
+}
+
+func (fb flatBuffer) u32(pos int) (uint32, error) {
+	if err := fb.check(pos, 4); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint32(fb[pos:]), nil
+}
+
+// deref returns the position the offset at pos refers to.
+func (fb flatBuffer) deref(pos int) (int, error) {
+	off, err := fb.u32(pos)
+	if err != nil {
+		return 0, err
+	}
+
+	return pos + int(off), nil
+}
+
+// field returns the position of the field with the given id of the table at
+// pos, or -1 if the field is absent.
+func (fb flatBuffer) field(table, id int) (int, error) {
+	soff, err := fb.u32(table)
+	if err != nil {
+		return 0, err
+	}
+
+	vtable := table - int(int32(soff))
+
+	size, err := fb.u16(vtable)
+	if err != nil {
+		return 0, err
+	}
+
+	if 4+2*id+2 > size {
+		return -1, nil
+	}
+
+	off, err := fb.u16(vtable + 4 + 2*id)
+	if err != nil || off == 0 {
+		return -1, err
+	}
+
+	return table + off, nil
+}
+
+// int reads the unsigned scalar field with the given id and width in bytes of
+// the table at pos, which must lie within [lo, hi], and is zero if absent.
+func (fb flatBuffer) int(table, id, width, lo, hi int) (int, error) {
+	f, err := fb.field(table, id)
+	if err != nil {
+		return 0, err
+	}
+
+	var v uint64
+	if f >= 0 {
+		if err := fb.check(f, width); err != nil {
+			return 0, err
+		}
+
+		if width == 4 {
+			v = uint64(binary.LittleEndian.Uint32(fb[f:]))
+		} else {
+			v = binary.LittleEndian.Uint64(fb[f:])
+		}
+	}
+
+	if v < uint64(lo) || v > uint64(hi) {
+		return 0, fmt.Errorf("%w: field %d out of range: %d", ErrInvalidEncoding, id, v)
+	}
+
+	return int(v), nil
+}
+
+// vector returns the start and length of the vector of elements of the given
+// size referred to by the field with the given id of the table at pos, which
+// is empty if the field is absent.
+func (fb flatBuffer) vector(table, id, size int) (int, int, error) {
+	f, err := fb.field(table, id)
+	if err != nil || f < 0 {
+		return 0, 0, err
+	}
+
+	vec, err := fb.deref(f)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	n, err := fb.u32(vec)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if uint64(n) > uint64(len(fb)-vec-4)/uint64(size) {
+		return 0, 0, fmt.Errorf("%w: vector at %d out of bounds", ErrInvalidEncoding, vec)
+	}
+
+	return vec + 4, int(n), nil
+}
+
+func (b *flatBuilder) align(n int) {
+	for len(b.buf)%n != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// reserve appends n zero bytes, returning their position.
+func (b *flatBuilder) reserve(n int) int {
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, n)...)
+	return pos
+}
+
+func (b *flatBuilder) put32(pos int, v uint32) {
+	binary.LittleEndian.PutUint32(b.buf[pos:], v)
+}
+
+// offset stores the offset from at to the position to at at.
+func (b *flatBuilder) offset(at, to int) {
+	b.put32(at, uint32(to-at))
+}
+
+// vtable appends a vtable for tables of the given size with the given field
+// offsets, returning its position.
+func (b *flatBuilder) vtable(size int, fields ...int) int {
+	b.align(2)
+	pos := len(b.buf)
+
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(4+2*len(fields)))
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(size))
+	for _, f := range fields {
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(f))
+	}
+
+	return pos
+}
+
+// table appends a zeroed table of the given alignment and size described by
+// the vtable at the given position, returning its position.
+func (b *flatBuilder) table(align, size, vtable int) int {
+	b.align(align)
+	pos := b.reserve(size)
+	b.put32(pos, uint32(int32(pos-vtable)))
+	return pos
+}
+
+// vector appends a zeroed vector of n elements of the given size, returning its
+// position.
+func (b *flatBuilder) vector(n, size int) int {
+	b.align(4)
+	pos := b.reserve(4 + n*size)
+	b.put32(pos, uint32(n))
+	return pos
+}
+
+// node appends the Node table of n followed by its entries and children,
+// returning its position.
+func (b *flatBuilder) node(n *node) (int, error) {
+	vtable := b.vtInner
+	if n.leaf() {
+		vtable = b.vtLeaf
+	}
+
+	pos := b.table(8, 24, vtable)
+	binary.LittleEndian.PutUint64(b.buf[pos+16:], uint64(n.count))
+
+	entries := b.vector(n.numEntries(), 4)
+	b.offset(pos+4, entries)
+
+	for i, e := range n.entries {
+		data, err := b.codec.Encode(e)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode entry %v: %w", e, err)
+		}
+
+		table := b.table(4, 8, b.vtEntry)
+		b.offset(entries+4+4*i, table)
+
+		vec := b.vector(len(data), 1)
+		copy(b.buf[vec+4:], data)
+		b.offset(table+4, vec)
+	}
+
+	if n.leaf() {
+		return pos, nil
+	}
+
+	children := b.vector(n.numChildren(), 4)
+	b.offset(pos+8, children)
+
+	for i, child := range n.children {
+		c, err := b.node(child)
+		if err != nil {
+			return 0, err
+		}
+
+		b.offset(children+4+4*i, c)
+	}
+
+	return pos, nil
+}
@@ -0,0 +1,146 @@
+package btree_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlatBuffer(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree, btree.WithCodec(testCodec{}))
+			require.NoError(t, err)
+
+			for i := uint64(0); i < 1000; i++ {
+				bt.Insert(testEntry{key: 2 * i, value: i})
+			}
+
+			data, err := bt.MarshalFlatBuffer()
+			require.NoError(t, err)
+
+			// any BTree with the same ordering and codec can open the buffer
+			template, err := btree.New(2, btree.WithCodec(testCodec{}))
+			require.NoError(t, err)
+
+			ft, err := template.OpenFlatBuffer(data)
+			require.NoError(t, err)
+			require.Equal(t, 1000, ft.Size())
+
+			for k := uint64(0); k < 2002; k++ {
+				got, err := ft.Search(testEntry{key: k})
+				require.NoError(t, err)
+				require.Equal(t, bt.Search(testEntry{key: k}), got)
+
+				ok, err := ft.Has(testEntry{key: k})
+				require.NoError(t, err)
+				require.Equal(t, k%2 == 0 && k < 2000, ok)
+			}
+
+			ranges := [][2]btree.Entry{
+				{nil, nil},
+				{testEntry{key: 501}, nil},
+				{nil, testEntry{key: 77}},
+				{testEntry{key: 100}, testEntry{key: 1400}},
+				{testEntry{key: 3}, testEntry{key: 4}},
+				{testEntry{key: 5000}, nil},
+			}
+
+			for _, r := range ranges {
+				var got []btree.Entry
+				require.NoError(t, ft.AscendRange(r[0], r[1], func(e btree.Entry) bool {
+					got = append(got, e)
+					return true
+				}))
+				require.Equal(t, bt.Entries(r[0], r[1]), got)
+			}
+
+			n := 0
+			require.NoError(t, ft.Ascend(func(btree.Entry) bool {
+				n++
+				return n < 10
+			}))
+			require.Equal(t, 10, n)
+		})
+	}
+}
+
+func TestFlatBufferOrdering(t *testing.T) {
+	bt, err := btree.New(3, btree.WithCodec(testCodec{}), btree.WithDescending())
+	require.NoError(t, err)
+
+	empty, err := bt.MarshalFlatBuffer()
+	require.NoError(t, err)
+
+	ft, err := bt.OpenFlatBuffer(empty)
+	require.NoError(t, err)
+	require.Equal(t, 0, ft.Size())
+
+	found, err := ft.Search(testEntry{key: 1})
+	require.NoError(t, err)
+	require.Nil(t, found)
+
+	for i := uint64(0); i < 100; i++ {
+		bt.Insert(testEntry{key: i})
+	}
+
+	data, err := bt.MarshalFlatBuffer()
+	require.NoError(t, err)
+
+	ft, err = bt.OpenFlatBuffer(data)
+	require.NoError(t, err)
+
+	var got []btree.Entry
+	require.NoError(t, ft.Ascend(func(e btree.Entry) bool {
+		got = append(got, e)
+		return true
+	}))
+	require.Equal(t, bt.Entries(nil, nil), got)
+	require.Equal(t, testEntry{key: 99}, got[0])
+
+	noCodec, err := btree.New(3)
+	require.NoError(t, err)
+
+	_, err = noCodec.MarshalFlatBuffer()
+	require.True(t, errors.Is(err, btree.ErrNoCodec))
+
+	_, err = noCodec.OpenFlatBuffer(data)
+	require.True(t, errors.Is(err, btree.ErrNoCodec))
+}
+
+func TestFlatBufferCorrupt(t *testing.T) {
+	bt, err := btree.New(2, btree.WithCodec(testCodec{}))
+	require.NoError(t, err)
+
+	for i := uint64(0); i < 50; i++ {
+		bt.Insert(testEntry{key: i})
+	}
+
+	data, err := bt.MarshalFlatBuffer()
+	require.NoError(t, err)
+
+	// every entry is reachable, so a truncated buffer fails to open or scan
+	for l := 0; l < len(data)-7; l++ {
+		ft, err := bt.OpenFlatBuffer(data[:l])
+		if err == nil {
+			err = ft.Ascend(func(btree.Entry) bool { return true })
+		}
+
+		require.True(t, errors.Is(err, btree.ErrInvalidEncoding), "length %d: %v", l, err)
+	}
+
+	// offsets pointing anywhere are rejected rather than followed blindly
+	for i := 0; i < len(data); i += 4 {
+		corrupt := append([]byte(nil), data...)
+		corrupt[i], corrupt[i+1], corrupt[i+2], corrupt[i+3] = 0xff, 0xff, 0xff, 0x7f
+
+		ft, err := bt.OpenFlatBuffer(corrupt)
+		if err == nil {
+			_, _ = ft.Search(testEntry{key: 25})
+			_ = ft.Ascend(func(btree.Entry) bool { return true })
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package btree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// SnapshotFormat defines a self-describing format such as MessagePack or CBOR
+// in which EncodeSnapshot writes a BTree. A snapshot is a map of three keys:
+// "minDegree" and "size", holding the minimum degree and size of the BTree as
+// unsigned integers, and "entries", holding an array of the entries in ascending
+// order as byte strings, each as encoded by the Codec of the BTree.
+type SnapshotFormat interface {
+	// WriteHeader writes everything ahead of the first entry of a snapshot of
+	// size entries.
+	WriteHeader(w io.Writer, minDegree, size int) error
+
+	// WriteEntry writes the encoding of a single entry.
+	WriteEntry(w io.Writer, entry []byte) error
+}
+
+var (
+	// MessagePack writes snapshots in MessagePack.
+	MessagePack SnapshotFormat = msgpackFormat{}
+
+	// CBOR writes snapshots in CBOR as specified by RFC 8949.
+	CBOR SnapshotFormat = cborFormat{}
+)
+
+type (
+	msgpackFormat struct{}
+	cborFormat    struct{}
+)
+
+// EncodeSnapshot writes the BTree to w as a snapshot in the given format, as of
+// a single point in time. As with WriteTo, the entries are written from a lazy
+// Clone, one at a time, and encoded by the Codec of the BTree, and ErrNoCodec
+// is returned if it has none.
+func (bt *BTree) EncodeSnapshot(w io.Writer, f SnapshotFormat) error {
+	if bt.codec == nil {
+		return ErrNoCodec
+	}
+
+	// the clone is owned by this call, so it is read without locking
+	clone := bt.Clone()
+
+	bw := bufio.NewWriter(w)
+	if err := f.WriteHeader(bw, clone.minDegree, clone.size); err != nil {
+		return err
+	}
+
+	var err error
+	clone.root.ascendRange(clone.cmp, nil, nil, true, func(e Entry) bool {
+		var b []byte
+		if b, err = bt.codec.Encode(e); err != nil {
+			err = fmt.Errorf("failed to encode entry %v: %w", e, err)
+			return false
+		}
+
+		err = f.WriteEntry(bw, b)
+		return err == nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func (msgpackFormat) WriteHeader(w io.Writer, minDegree, size int) error {
+	if uint64(size) > math.MaxUint32 {
+		return fmt.Errorf("too many entries for MessagePack: %d", size)
+	}
+
+	b := []byte{0x83}
+	b = msgpackString(b, "minDegree")
+	b = msgpackUint(b, uint64(minDegree))
+	b = msgpackString(b, "size")
+	b = msgpackUint(b, uint64(size))
+	b = msgpackString(b, "entries")
+
+	switch {
+	case size < 16:
+		b = append(b, 0x90|byte(size))
+
+	case size <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(size))
+
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(size))
+	}
+
+	_, err := w.Write(b)
+	return err
+}
+
+func (msgpackFormat) WriteEntry(w io.Writer, entry []byte) error {
+	var b []byte
+	switch l := len(entry); {
+	case l <= math.MaxUint8:
+		b = []byte{0xc4, byte(l)}
+
+	case l <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16([]byte{0xc5}, uint16(l))
+
+	case uint64(l) <= math.MaxUint32:
+		b = binary.BigEndian.AppendUint32([]byte{0xc6}, uint32(l))
+
+	default:
+		return fmt.Errorf("entry too large for MessagePack: %d bytes", l)
+	}
+
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+
+	_, err := w.Write(entry)
+	return err
+}
+
+// msgpackString appends the MessagePack encoding of s, which must be shorter
+// than 32 bytes.
+func msgpackString(b []byte, s string) []byte {
+	return append(append(b, 0xa0|byte(len(s))), s...)
+}
+
+// msgpackUint appends the shortest MessagePack encoding of v.
+func msgpackUint(b []byte, v uint64) []byte {
+	switch {
+	case v < 0x80:
+		return append(b, byte(v))
+
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(v))
+
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(v))
+	}
+
+	return binary.BigEndian.AppendUint64(append(b, 0xcf), v)
+}
+
+func (cborFormat) WriteHeader(w io.Writer, minDegree, size int) error {
+	b := cborHead(nil, 5, 3)
+	b = cborString(b, "minDegree")
+	b = cborHead(b, 0, uint64(minDegree))
+	b = cborString(b, "size")
+	b = cborHead(b, 0, uint64(size))
+	b = cborString(b, "entries")
+	b = cborHead(b, 4, uint64(size))
+
+	_, err := w.Write(b)
+	return err
+}
+
+func (cborFormat) WriteEntry(w io.Writer, entry []byte) error {
+	if _, err := w.Write(cborHead(nil, 2, uint64(len(entry)))); err != nil {
+		return err
+	}
+
+	_, err := w.Write(entry)
+	return err
+}
+
+// cborString appends the CBOR encoding of s as a text string.
+func cborString(b []byte, s string) []byte {
+	return append(cborHead(b, 3, uint64(len(s))), s...)
+}
+
+// cborHead appends the shortest CBOR head of the given major type and argument.
+func cborHead(b []byte, major byte, v uint64) []byte {
+	major <<= 5
+
+	switch {
+	case v < 24:
+		return append(b, major|byte(v))
+
+	case v <= math.MaxUint8:
+		return append(b, major|24, byte(v))
+
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(v))
+
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(v))
+	}
+
+	return binary.BigEndian.AppendUint64(append(b, major|27), v)
+}
@@ -0,0 +1,88 @@
+package btree_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeSnapshot(t *testing.T) {
+	bt, err := btree.New(3, btree.WithCodec(testCodec{}))
+	require.NoError(t, err)
+
+	bt.Insert(testEntry{key: 7, value: 9})
+
+	entry := binary.BigEndian.AppendUint64(binary.BigEndian.AppendUint64(nil, 7), 9)
+
+	var buf bytes.Buffer
+	require.NoError(t, bt.EncodeSnapshot(&buf, btree.MessagePack))
+
+	want := []byte{0x83, 0xa9}
+	want = append(want, "minDegree"...)
+	want = append(want, 0x03, 0xa4)
+	want = append(want, "size"...)
+	want = append(want, 0x01, 0xa7)
+	want = append(want, "entries"...)
+	want = append(want, 0x91, 0xc4, 0x10)
+	require.Equal(t, append(want, entry...), buf.Bytes())
+
+	buf.Reset()
+	require.NoError(t, bt.EncodeSnapshot(&buf, btree.CBOR))
+
+	want = []byte{0xa3, 0x69}
+	want = append(want, "minDegree"...)
+	want = append(want, 0x03, 0x64)
+	want = append(want, "size"...)
+	want = append(want, 0x01, 0x67)
+	want = append(want, "entries"...)
+	want = append(want, 0x81, 0x50)
+	require.Equal(t, append(want, entry...), buf.Bytes())
+
+	// longer arrays take wider headers, and the entries follow in order
+	for i := uint64(0); i < 300; i++ {
+		bt.Insert(testEntry{key: i})
+	}
+
+	tests := []struct {
+		format btree.SnapshotFormat
+		size   []byte
+		array  []byte
+		entry  []byte
+	}{
+		{btree.MessagePack, []byte{0xcd, 0x01, 0x2c}, []byte{0xdc, 0x01, 0x2c}, []byte{0xc4, 0x10}},
+		{btree.CBOR, []byte{0x19, 0x01, 0x2c}, []byte{0x99, 0x01, 0x2c}, []byte{0x50}},
+	}
+
+	for _, tc := range tests {
+		buf.Reset()
+		require.NoError(t, bt.EncodeSnapshot(&buf, tc.format))
+
+		b := buf.Bytes()
+		i := bytes.Index(b, []byte("size")) + len("size")
+		require.Equal(t, tc.size, b[i:i+len(tc.size)])
+
+		i = bytes.Index(b, []byte("entries")) + len("entries")
+		require.Equal(t, tc.array, b[i:i+len(tc.array)])
+
+		b = b[i+len(tc.array):]
+		for k := uint64(0); k < 300; k++ {
+			encoded, err := testCodec{}.Encode(bt.Search(testEntry{key: k}))
+			require.NoError(t, err)
+
+			require.Equal(t, append(tc.entry, encoded...), b[:len(tc.entry)+len(encoded)])
+			b = b[len(tc.entry)+len(encoded):]
+		}
+
+		require.Empty(t, b)
+	}
+
+	require.True(t, errors.Is(bt.EncodeSnapshot(failingWriter{}, btree.CBOR), errWrite))
+
+	noCodec, err := btree.New(3)
+	require.NoError(t, err)
+	require.True(t, errors.Is(noCodec.EncodeSnapshot(&buf, btree.MessagePack), btree.ErrNoCodec))
+}
@@ -0,0 +1,83 @@
+package btree
+
+// ReadOnlyTree defines the read operations of a BTree, which every BTree
+// implements. Freeze returns one that never changes.
+type ReadOnlyTree interface {
+	Size() int
+	Search(e Entry) Entry
+	Has(e Entry) bool
+	Min() Entry
+	Max() Entry
+	CountRange(from, to Entry) int
+	Ascend(fn func(Entry) bool)
+	AscendRange(from, to Entry, fn func(Entry) bool)
+	Descend(fn func(Entry) bool)
+	DescendRange(from, to Entry, fn func(Entry) bool)
+	Iterator() *Iterator
+}
+
+var _ ReadOnlyTree = (*BTree)(nil)
+
+// frozenTree implements a ReadOnlyTree over a BTree that is never written to,
+// without exposing its write operations.
+type frozenTree struct {
+	bt *BTree
+}
+
+func (f frozenTree) Size() int {
+	return f.bt.Size()
+}
+
+func (f frozenTree) Search(e Entry) Entry {
+	return f.bt.Search(e)
+}
+
+func (f frozenTree) Has(e Entry) bool {
+	return f.bt.Has(e)
+}
+
+func (f frozenTree) Min() Entry {
+	return f.bt.Min()
+}
+
+func (f frozenTree) Max() Entry {
+	return f.bt.Max()
+}
+
+func (f frozenTree) CountRange(from, to Entry) int {
+	return f.bt.CountRange(from, to)
+}
+
+func (f frozenTree) Ascend(fn func(Entry) bool) {
+	f.bt.Ascend(fn)
+}
+
+func (f frozenTree) AscendRange(from, to Entry, fn func(Entry) bool) {
+	f.bt.AscendRange(from, to, fn)
+}
+
+func (f frozenTree) Descend(fn func(Entry) bool) {
+	f.bt.Descend(fn)
+}
+
+func (f frozenTree) DescendRange(from, to Entry, fn func(Entry) bool) {
+	f.bt.DescendRange(from, to, fn)
+}
+
+func (f frozenTree) Iterator() *Iterator {
+	return f.bt.Iterator()
+}
+
+// Freeze returns an immutable view of the current contents of the BTree. The
+// view shares every node with the BTree, which copies the nodes along the path
+// of each subsequent write instead of modifying them, so freezing is constant
+// time. As the view never changes, reading it requires no locking at all: a
+// long scan of the view sees a consistent snapshot without blocking writers to
+// the BTree, and fn may even write to the BTree during the scan.
+func (bt *BTree) Freeze() ReadOnlyTree {
+	frozen := bt.Clone()
+	frozen.mu = nopLocker{}
+	frozen.snapshots, frozen.history = false, nil
+
+	return frozenTree{bt: frozen}
+}
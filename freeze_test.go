@@ -0,0 +1,54 @@
+package btree_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreeze(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 1000)
+
+			frozen := bt.Freeze()
+			_, writable := frozen.(interface{ Insert(btree.Entry) })
+			require.False(t, writable)
+
+			// the scan may write to the live tree without affecting the view
+			i := 0
+			frozen.Ascend(func(e btree.Entry) bool {
+				require.Equal(t, entries[i], e)
+				bt.Delete(e)
+				bt.Insert(testEntry{key: uint64(1000 + i)})
+				i++
+				return true
+			})
+
+			require.Equal(t, 1000, i)
+			require.NoError(t, bt.Verify())
+			require.Equal(t, 1000, bt.Size())
+			require.Nil(t, bt.Search(testEntry{key: 0}))
+
+			require.Equal(t, 1000, frozen.Size())
+			require.Equal(t, entries[0], frozen.Min())
+			require.Equal(t, entries[999], frozen.Max())
+			require.Equal(t, entries[42], frozen.Search(testEntry{key: 42}))
+			require.False(t, frozen.Has(testEntry{key: 1000}))
+			require.Equal(t, 10, frozen.CountRange(testEntry{key: 10}, testEntry{key: 20}))
+
+			it := frozen.Iterator()
+			require.True(t, it.Last())
+			require.Equal(t, entries[999], it.Entry())
+
+			var got []btree.Entry
+			frozen.DescendRange(testEntry{key: 5}, testEntry{key: 8}, func(e btree.Entry) bool {
+				got = append(got, e)
+				return true
+			})
+			require.Len(t, got, 3)
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package btree
+
+import (
+	"fmt"
+)
+
+// BTreeG implements a thread-safe ordered set of items of any type, ordered by a
+// user-supplied less function rather than by an Entry implementation. Its API
+// mirrors the generic variant of google/btree. It is backed by a BTree, sharing
+// all of its node machinery, and wraps every item in an internal Entry.
+type BTreeG[T any] struct {
+	bt *BTree
+}
+
+// gEntry defines the Entry a BTreeG stores for every item.
+type gEntry[T any] struct {
+	item T
+}
+
+// Compare is never called, as a BTreeG orders its entries through the less
+// function given to NewG instead.
+func (gEntry[T]) Compare(Entry) int {
+	panic("btree: gEntry is ordered by its BTreeG")
+}
+
+// NewG returns a reference to a new, empty BTreeG with a minimum degree t, whose
+// items are ordered by less. It panics if t is less than two, as the generic
+// variant of google/btree does for invalid degrees.
+func NewG[T any](t int, less func(a, b T) bool) *BTreeG[T] {
+	bt, err := New(t)
+	if err != nil {
+		panic(fmt.Sprintf("btree: %s", err))
+	}
+
+	bt.cmp = func(a, b Entry) int {
+		x, y := a.(gEntry[T]).item, b.(gEntry[T]).item
+
+		switch {
+		case less(x, y):
+			return -1
+
+		case less(y, x):
+			return 1
+
+		default:
+			return 0
+		}
+	}
+
+	return &BTreeG[T]{bt: bt}
+}
+
+// Len returns the number of items in the BTreeG.
+func (g *BTreeG[T]) Len() int {
+	return g.bt.Size()
+}
+
+// ReplaceOrInsert adds the given item to the BTreeG. If an equal item already
+// exists, it is replaced and returned along with true.
+func (g *BTreeG[T]) ReplaceOrInsert(item T) (T, bool) {
+	return unwrapG[T](g.bt.Set(gEntry[T]{item}))
+}
+
+// Get returns the item equal to the given key and whether it exists.
+func (g *BTreeG[T]) Get(key T) (T, bool) {
+	e := g.bt.Search(gEntry[T]{key})
+	return unwrapG[T](e, e != nil)
+}
+
+// Has returns true if an item equal to the given key exists.
+func (g *BTreeG[T]) Has(key T) bool {
+	return g.bt.Has(gEntry[T]{key})
+}
+
+// Delete removes the item equal to the given key, returning it and whether it
+// existed.
+func (g *BTreeG[T]) Delete(key T) (T, bool) {
+	e := g.bt.Delete(gEntry[T]{key})
+	return unwrapG[T](e, e != nil)
+}
+
+// DeleteMin removes the smallest item, returning it and whether it existed.
+func (g *BTreeG[T]) DeleteMin() (T, bool) {
+	return unwrapG[T](g.bt.PopMin())
+}
+
+// DeleteMax removes the largest item, returning it and whether it existed.
+func (g *BTreeG[T]) DeleteMax() (T, bool) {
+	return unwrapG[T](g.bt.PopMax())
+}
+
+// Min returns the smallest item and whether the BTreeG is not empty.
+func (g *BTreeG[T]) Min() (T, bool) {
+	e := g.bt.Min()
+	return unwrapG[T](e, e != nil)
+}
+
+// Max returns the largest item and whether the BTreeG is not empty.
+func (g *BTreeG[T]) Max() (T, bool) {
+	e := g.bt.Max()
+	return unwrapG[T](e, e != nil)
+}
+
+// Ascend calls fn for every item in ascending order, under the read lock. The
+// traversal stops as soon as fn returns false.
+func (g *BTreeG[T]) Ascend(fn func(item T) bool) {
+	g.bt.Ascend(visitG(fn))
+}
+
+// AscendRange calls fn for every item i, s.t. greaterOrEqual <= i < lessThan,
+// in ascending order, under the read lock. The traversal stops as soon as fn
+// returns false.
+func (g *BTreeG[T]) AscendRange(greaterOrEqual, lessThan T, fn func(item T) bool) {
+	g.bt.AscendRange(gEntry[T]{greaterOrEqual}, gEntry[T]{lessThan}, visitG(fn))
+}
+
+// AscendLessThan calls fn for every item i, s.t. i < pivot, in ascending order,
+// under the read lock. The traversal stops as soon as fn returns false.
+func (g *BTreeG[T]) AscendLessThan(pivot T, fn func(item T) bool) {
+	g.bt.AscendRange(nil, gEntry[T]{pivot}, visitG(fn))
+}
+
+// AscendGreaterOrEqual calls fn for every item i, s.t. i >= pivot, in ascending
+// order, under the read lock. The traversal stops as soon as fn returns false.
+func (g *BTreeG[T]) AscendGreaterOrEqual(pivot T, fn func(item T) bool) {
+	g.bt.AscendGreaterOrEqual(gEntry[T]{pivot}, visitG(fn))
+}
+
+// Descend calls fn for every item in descending order, under the read lock. The
+// traversal stops as soon as fn returns false.
+func (g *BTreeG[T]) Descend(fn func(item T) bool) {
+	g.bt.Descend(visitG(fn))
+}
+
+// DescendLessOrEqual calls fn for every item i, s.t. i <= pivot, in descending
+// order, under the read lock. The traversal stops as soon as fn returns false.
+func (g *BTreeG[T]) DescendLessOrEqual(pivot T, fn func(item T) bool) {
+	g.bt.DescendLessOrEqual(gEntry[T]{pivot}, visitG(fn))
+}
+
+// Clone returns a lazy, independent copy of the BTreeG, as BTree.Clone does.
+func (g *BTreeG[T]) Clone() *BTreeG[T] {
+	return &BTreeG[T]{bt: g.bt.Clone()}
+}
+
+// Clear removes every item from the BTreeG, retaining its nodes for reuse if
+// recycle is true, as BTree.Clear does.
+func (g *BTreeG[T]) Clear(recycle bool) {
+	g.bt.Clear(recycle)
+}
+
+// visitG adapts an item callback to the entries of a BTreeG.
+func visitG[T any](fn func(T) bool) func(Entry) bool {
+	return func(e Entry) bool {
+		return fn(e.(gEntry[T]).item)
+	}
+}
+
+// unwrapG returns the item of the given BTreeG entry if ok is true, or the zero
+// item otherwise.
+func unwrapG[T any](e Entry, ok bool) (T, bool) {
+	if !ok {
+		var zero T
+		return zero, false
+	}
+
+	return e.(gEntry[T]).item, true
+}
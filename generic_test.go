@@ -0,0 +1,140 @@
+package btree_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+// point is an item which is not naturally ordered, and is ordered by distance
+// from the origin and then by coordinates in the tests below.
+type point struct {
+	x, y int
+}
+
+func lessPoint(a, b point) bool {
+	da, db := a.x*a.x+a.y*a.y, b.x*b.x+b.y*b.y
+	if da != db {
+		return da < db
+	}
+
+	return a.x < b.x || (a.x == b.x && a.y < b.y)
+}
+
+func TestBTreeG(t *testing.T) {
+	require.Panics(t, func() {
+		btree.NewG(1, lessPoint)
+	})
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			g := btree.NewG(minDegree, func(a, b int) bool { return a < b })
+
+			_, ok := g.Min()
+			require.False(t, ok)
+
+			for _, i := range rng.Perm(1000) {
+				_, replaced := g.ReplaceOrInsert(i)
+				require.False(t, replaced)
+			}
+
+			old, replaced := g.ReplaceOrInsert(500)
+			require.True(t, replaced)
+			require.Equal(t, 500, old)
+			require.Equal(t, 1000, g.Len())
+
+			v, ok := g.Get(42)
+			require.True(t, ok)
+			require.Equal(t, 42, v)
+			require.False(t, g.Has(1000))
+
+			v, _ = g.Min()
+			require.Equal(t, 0, v)
+			v, _ = g.Max()
+			require.Equal(t, 999, v)
+
+			var got []int
+			g.AscendRange(100, 110, func(i int) bool {
+				got = append(got, i)
+				return true
+			})
+			require.Equal(t, []int{100, 101, 102, 103, 104, 105, 106, 107, 108, 109}, got)
+
+			got = got[:0]
+			g.AscendLessThan(3, func(i int) bool {
+				got = append(got, i)
+				return true
+			})
+			require.Equal(t, []int{0, 1, 2}, got)
+
+			got = got[:0]
+			g.AscendGreaterOrEqual(997, func(i int) bool {
+				got = append(got, i)
+				return true
+			})
+			require.Equal(t, []int{997, 998, 999}, got)
+
+			got = got[:0]
+			g.DescendLessOrEqual(2, func(i int) bool {
+				got = append(got, i)
+				return true
+			})
+			require.Equal(t, []int{2, 1, 0}, got)
+
+			prev := 1000
+			g.Descend(func(i int) bool {
+				require.Equal(t, prev-1, i)
+				prev = i
+				return true
+			})
+			require.Zero(t, prev)
+
+			clone := g.Clone()
+
+			v, ok = g.DeleteMin()
+			require.True(t, ok)
+			require.Equal(t, 0, v)
+
+			v, ok = g.DeleteMax()
+			require.True(t, ok)
+			require.Equal(t, 999, v)
+
+			v, ok = g.Delete(500)
+			require.True(t, ok)
+			require.Equal(t, 500, v)
+
+			_, ok = g.Delete(500)
+			require.False(t, ok)
+			require.Equal(t, 997, g.Len())
+			require.Equal(t, 1000, clone.Len())
+
+			g.Clear(true)
+			require.Zero(t, g.Len())
+		})
+	}
+
+	// items ordered through a custom less function
+	g := btree.NewG(3, lessPoint)
+	for x := -10; x <= 10; x++ {
+		for y := -10; y <= 10; y++ {
+			g.ReplaceOrInsert(point{x, y})
+		}
+	}
+
+	var prev *point
+	g.Ascend(func(p point) bool {
+		if prev != nil {
+			require.True(t, lessPoint(*prev, p))
+		}
+
+		prev = &p
+		return true
+	})
+
+	p, _ := g.Min()
+	require.Equal(t, point{0, 0}, p)
+	require.True(t, g.Has(point{3, -4}))
+	require.Equal(t, 441, g.Len())
+}
@@ -0,0 +1,140 @@
+package btree
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sync"
+)
+
+// gobVersion defines the version of the gob encoding of a BTree.
+const gobVersion = 1
+
+type (
+	// gobTree defines the gob encoding of a BTree. Shape holds the minimum
+	// degree, size and depth of the BTree followed by the number of entries of
+	// every node in preorder, and Entries holds the entries in the same order.
+	gobTree struct {
+		Version int
+		Shape   []uint64
+		Entries []Entry
+	}
+
+	// gobSource defines the treeSource of a gobTree.
+	gobSource struct {
+		tree *gobTree
+	}
+)
+
+// GobEncode implements gob.GobEncoder, encoding the BTree along with its minimum
+// degree and exact structure, as of a single point in time, under the read
+// lock. Unlike MarshalBinary, no Codec is needed: the entries are encoded by
+// encoding/gob itself as interface values, so their concrete types must be
+// registered through gob.Register.
+func (bt *BTree) GobEncode() ([]byte, error) {
+	gt := bt.gobTree()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(gt); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder, replacing the contents of the BTree,
+// including its minimum degree, by those encoded by GobEncode. The nodes are
+// restored as encoded after verifying that they form a valid BTree. A BTree
+// that has been configured, e.g. through NewWithComparator, keeps its
+// configuration, while a zero BTree, such as one allocated by encoding/gob
+// itself, becomes one as returned by New. If data is not a valid encoding, an
+// error wrapping ErrInvalidEncoding is returned and the BTree is left
+// untouched.
+func (bt *BTree) GobDecode(data []byte) error {
+	var gt gobTree
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&gt); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEncoding, err)
+	}
+
+	if gt.Version != gobVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidEncoding, gt.Version)
+	}
+
+	if bt.cmp == nil {
+		bt.init()
+	}
+
+	d, root, size, err := bt.decodeFrom(&gobSource{tree: &gt})
+	if err != nil {
+		return err
+	}
+
+	if len(gt.Shape) > 0 || len(gt.Entries) > 0 {
+		return fmt.Errorf("%w: trailing data", ErrInvalidEncoding)
+	}
+
+	bt.adopt(root, size, d.depth, d.minDegree, d.cow)
+	return nil
+}
+
+// gobTree returns the gobTree of the BTree under the read lock.
+func (bt *BTree) gobTree() *gobTree {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	gt := &gobTree{
+		Version: gobVersion,
+		Shape:   []uint64{uint64(bt.minDegree), uint64(bt.size), uint64(bt.depth)},
+		Entries: make([]Entry, 0, bt.size),
+	}
+
+	var walk func(n *node)
+	walk = func(n *node) {
+		gt.Shape = append(gt.Shape, uint64(n.numEntries()))
+		gt.Entries = append(gt.Entries, n.entries...)
+
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+
+	walk(bt.root)
+	return gt
+}
+
+// init turns a zero BTree into an empty one as returned by New, which is left
+// to be replaced by the decoded contents.
+func (bt *BTree) init() {
+	cow := &copyOnWriteContext{freeList: &freeList{}}
+
+	bt.mu = new(sync.RWMutex)
+	bt.root, bt.minDegree, bt.depth = cow.newNode(), 2, 1
+	bt.cmp = compareEntries
+	bt.cow = cow
+}
+
+func (src *gobSource) int(what string, lo, hi int) (int, error) {
+	if len(src.tree.Shape) == 0 {
+		return 0, fmt.Errorf("%w: missing %s", ErrInvalidEncoding, what)
+	}
+
+	v := src.tree.Shape[0]
+	src.tree.Shape = src.tree.Shape[1:]
+
+	if v < uint64(lo) || v > uint64(hi) {
+		return 0, fmt.Errorf("%w: %s out of range: %d", ErrInvalidEncoding, what, v)
+	}
+
+	return int(v), nil
+}
+
+func (src *gobSource) entry() (Entry, error) {
+	if len(src.tree.Entries) == 0 {
+		return nil, fmt.Errorf("%w: missing entry", ErrInvalidEncoding)
+	}
+
+	e := src.tree.Entries[0]
+	src.tree.Entries = src.tree.Entries[1:]
+
+	return e, nil
+}
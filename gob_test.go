@@ -0,0 +1,83 @@
+package btree_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gob.Register(namedEntry{})
+}
+
+func TestGob(t *testing.T) {
+	type snapshot struct {
+		Name string
+		Tree *btree.BTree
+	}
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree)
+			require.NoError(t, err)
+
+			for _, i := range rng.Perm(2000) {
+				bt.Insert(namedEntry{fmt.Sprintf("%05d", i)})
+			}
+
+			for _, i := range rng.Perm(2000)[:700] {
+				bt.Delete(namedEntry{fmt.Sprintf("%05d", i)})
+			}
+
+			var buf bytes.Buffer
+			require.NoError(t, gob.NewEncoder(&buf).Encode(snapshot{"test", bt}))
+
+			// the BTree is allocated by encoding/gob and restored exactly
+			var got snapshot
+			require.NoError(t, gob.NewDecoder(&buf).Decode(&got))
+			require.Equal(t, "test", got.Name)
+			require.NoError(t, got.Tree.Verify())
+			require.Equal(t, bt.Stats(), got.Tree.Stats())
+			require.Equal(t, bt.Entries(nil, nil), got.Tree.Entries(nil, nil))
+
+			// and fully usable
+			got.Tree.Insert(namedEntry{"zzz"})
+			got.Tree.Delete(bt.Min())
+			require.Equal(t, bt.Size(), got.Tree.Size())
+			require.NoError(t, got.Tree.Verify())
+		})
+	}
+
+	// a configured BTree keeps its ordering
+	desc, err := btree.New(3, btree.WithDescending())
+	require.NoError(t, err)
+
+	for _, name := range []string{"a", "b", "c"} {
+		desc.Insert(namedEntry{name})
+	}
+
+	data, err := desc.GobEncode()
+	require.NoError(t, err)
+
+	restored, err := btree.New(2, btree.WithDescending())
+	require.NoError(t, err)
+	require.NoError(t, restored.GobDecode(data))
+	require.Equal(t, desc.Entries(nil, nil), restored.Entries(nil, nil))
+	require.NoError(t, restored.Verify())
+
+	// which the encoded order must agree with
+	asc, err := btree.New(2)
+	require.NoError(t, err)
+	require.True(t, errors.Is(asc.GobDecode(data), btree.ErrInvalidEncoding))
+	require.True(t, errors.Is(asc.GobDecode(data[:len(data)/2]), btree.ErrInvalidEncoding))
+	require.Zero(t, asc.Size())
+
+	// entries of unregistered types cannot be encoded
+	_, err = newTestBTreeKeys(t, 2, keyRange(0, 10, 1)).GobEncode()
+	require.Error(t, err)
+}
@@ -0,0 +1,347 @@
+package btree
+
+import "errors"
+
+// ErrConcurrentModification is reported by an Iterator whose BTree was
+// modified, other than by Modify or CompareAndSwap, since the Iterator was
+// positioned.
+var ErrConcurrentModification = errors.New("tree modified during iteration")
+
+type (
+	// Iterator implements a stateful, pull-driven cursor over the entries of a
+	// BTree. It maintains the path from the root to the current entry, so that
+	// stepping to an adjacent entry takes amortized constant time. An Iterator is
+	// not safe for concurrent use and the BTree must not be modified while it is
+	// in use, other than by Modify or CompareAndSwap, as the path would no
+	// longer reflect the tree. Rather than silently skipping or repeating
+	// entries, the Iterator fails fast: the next step after such a modification
+	// invalidates it and Err reports ErrConcurrentModification. For a BTree
+	// created WithSnapshotReads, the Iterator instead traverses the snapshot
+	// taken when it was last positioned by First, Last or Seek, so the BTree may
+	// be modified freely in the meantime. An Iterator returned by WeakIterator
+	// tolerates modifications as well.
+	Iterator struct {
+		bt         *BTree
+		tree       *BTree // the BTree itself or the snapshot the path lies in
+		stack      []iterFrame
+		positioned bool
+
+		// mods is the modification count of tree when the Iterator was last
+		// positioned, and err is set once a step found it changed
+		mods uint64
+		err  error
+
+		// weak is set if every step re-seeks from the current entry, which is
+		// remembered in current, instead of following the path
+		weak    bool
+		current Entry
+	}
+
+	// iterFrame defines a single step on an Iterator's path. For the last frame,
+	// i is the index of the current entry in n. For every other frame, i is the
+	// index of the child of n the path descends into.
+	iterFrame struct {
+		n *node
+		i int
+	}
+)
+
+// Iterator returns a new, unpositioned Iterator over the BTree. Calling Next or
+// Prev on an unpositioned Iterator positions it on the smallest or largest
+// entry respectively.
+func (bt *BTree) Iterator() *Iterator {
+	return &Iterator{bt: bt}
+}
+
+// WeakIterator returns a new, unpositioned Iterator over the BTree which, unlike
+// one returned by Iterator, remains usable while the BTree is modified. Rather
+// than following a path that modifications may invalidate, every step re-seeks
+// the neighbour of the current entry from the root in logarithmic time, taking
+// the read lock only for the duration of the step. The Iterator is weakly
+// consistent: it visits every entry present throughout the iteration exactly
+// once, in order, and may or may not visit entries inserted or deleted in the
+// meantime.
+func (bt *BTree) WeakIterator() *Iterator {
+	return &Iterator{bt: bt, weak: true}
+}
+
+// Valid returns true if the Iterator is positioned on an entry.
+func (it *Iterator) Valid() bool {
+	return len(it.stack) > 0
+}
+
+// Err returns ErrConcurrentModification if the Iterator was invalidated by a
+// modification of the BTree, or nil otherwise. It is reset whenever the
+// Iterator is repositioned.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Entry returns the entry the Iterator is positioned on or nil if the Iterator
+// is not valid.
+func (it *Iterator) Entry() Entry {
+	if !it.Valid() {
+		return nil
+	}
+
+	if it.weak {
+		// the nodes on the path may have been modified since the last step
+		return it.current
+	}
+
+	return it.entry()
+}
+
+// entry returns the entry at the end of the path of the valid Iterator.
+func (it *Iterator) entry() Entry {
+	top := it.stack[len(it.stack)-1]
+	return top.n.entries[top.i]
+}
+
+// remember records the entry the Iterator is positioned on for a weak Iterator,
+// whose path must not be read outside of a step.
+func (it *Iterator) remember() {
+	it.current = nil
+	if it.weak && it.Valid() {
+		it.current = it.entry()
+	}
+}
+
+// First positions the Iterator on the smallest entry and returns whether the
+// Iterator is valid, i.e. whether the BTree is not empty.
+func (it *Iterator) First() bool {
+	it.tree = it.bt.rlock()
+	defer it.tree.mu.RUnlock()
+
+	it.reset()
+	it.pushMin(it.tree.root)
+	it.ascendNext()
+	it.remember()
+
+	return it.Valid()
+}
+
+// Last positions the Iterator on the largest entry and returns whether the
+// Iterator is valid, i.e. whether the BTree is not empty.
+func (it *Iterator) Last() bool {
+	it.tree = it.bt.rlock()
+	defer it.tree.mu.RUnlock()
+
+	it.reset()
+	it.pushMax(it.tree.root)
+	it.ascendPrev()
+	it.remember()
+
+	return it.Valid()
+}
+
+// Seek positions the Iterator on the smallest entry greater than or equal to
+// the provided Entry and returns whether such an entry exists.
+func (it *Iterator) Seek(e Entry) bool {
+	it.tree = it.bt.rlock()
+	defer it.tree.mu.RUnlock()
+
+	it.seek(e)
+	it.remember()
+
+	return it.Valid()
+}
+
+// seek repositions the Iterator on the smallest entry greater than or equal to
+// the provided Entry. The caller must hold the read lock of it.tree.
+func (it *Iterator) seek(e Entry) {
+	it.reset()
+
+	n := it.tree.root
+	for {
+		found, i := n.get(it.tree.cmp, e)
+		it.stack = append(it.stack, iterFrame{n, i})
+
+		if found != nil || n.leaf() {
+			break
+		}
+
+		n = n.children[i]
+	}
+
+	it.ascendNext()
+}
+
+// Next advances the Iterator to the next larger entry and returns whether the
+// Iterator is still valid. Once the Iterator has been exhausted, it remains
+// invalid until it is repositioned.
+func (it *Iterator) Next() bool {
+	if !it.positioned {
+		return it.First()
+	}
+
+	if !it.Valid() {
+		return false
+	}
+
+	if it.weak {
+		it.tree = it.bt.rlock()
+		defer it.tree.mu.RUnlock()
+
+		// the current entry may be gone, in which case its successor is found
+		it.seek(it.current)
+		if !it.Valid() || it.tree.cmp(it.entry(), it.current) > 0 {
+			it.remember()
+			return it.Valid()
+		}
+	} else {
+		it.tree.mu.RLock()
+		defer it.tree.mu.RUnlock()
+
+		if it.modified() {
+			return false
+		}
+	}
+
+	it.next()
+	it.remember()
+
+	return it.Valid()
+}
+
+// next advances the valid Iterator along its path to the next larger entry.
+// The caller must hold the read lock of it.tree.
+func (it *Iterator) next() {
+	top := &it.stack[len(it.stack)-1]
+	top.i++
+
+	if !top.n.leaf() {
+		// the successor is the smallest entry of the right child
+		it.pushMin(top.n.children[top.i])
+		return
+	}
+
+	it.ascendNext()
+}
+
+// Prev moves the Iterator to the next smaller entry and returns whether the
+// Iterator is still valid. Once the Iterator has been exhausted, it remains
+// invalid until it is repositioned.
+func (it *Iterator) Prev() bool {
+	if !it.positioned {
+		return it.Last()
+	}
+
+	if !it.Valid() {
+		return false
+	}
+
+	if it.weak {
+		it.tree = it.bt.rlock()
+		defer it.tree.mu.RUnlock()
+
+		// the predecessor precedes the smallest entry greater than or equal to
+		// the current one, or is the largest entry if there is none
+		it.seek(it.current)
+		if !it.Valid() {
+			it.pushMax(it.tree.root)
+			it.ascendPrev()
+			it.remember()
+
+			return it.Valid()
+		}
+	} else {
+		it.tree.mu.RLock()
+		defer it.tree.mu.RUnlock()
+
+		if it.modified() {
+			return false
+		}
+	}
+
+	it.prev()
+	it.remember()
+
+	return it.Valid()
+}
+
+// prev moves the valid Iterator along its path to the next smaller entry. The
+// caller must hold the read lock of it.tree.
+func (it *Iterator) prev() {
+	top := &it.stack[len(it.stack)-1]
+
+	if !top.n.leaf() {
+		// the predecessor is the largest entry of the left child
+		it.pushMax(top.n.children[top.i])
+		return
+	}
+
+	top.i--
+	it.ascendPrev()
+}
+
+func (it *Iterator) reset() {
+	it.stack = it.stack[:0]
+	it.positioned = true
+	it.mods = it.tree.mods
+	it.err = nil
+}
+
+// modified invalidates the Iterator and returns true if it.tree was modified,
+// other than by Modify or CompareAndSwap, since the Iterator was positioned.
+// The caller must hold the read lock of it.tree.
+func (it *Iterator) modified() bool {
+	if it.tree.mods == it.mods {
+		return false
+	}
+
+	it.stack = it.stack[:0]
+	it.err = ErrConcurrentModification
+
+	return true
+}
+
+// pushMin extends the path along the leftmost spine of the subtree rooted at n.
+func (it *Iterator) pushMin(n *node) {
+	for {
+		it.stack = append(it.stack, iterFrame{n, 0})
+		if n.leaf() {
+			return
+		}
+
+		n = n.children[0]
+	}
+}
+
+// pushMax extends the path along the rightmost spine of the subtree rooted at n.
+func (it *Iterator) pushMax(n *node) {
+	for !n.leaf() {
+		it.stack = append(it.stack, iterFrame{n, n.numChildren() - 1})
+		n = n.children[n.numChildren()-1]
+	}
+
+	it.stack = append(it.stack, iterFrame{n, n.numEntries() - 1})
+}
+
+// ascendNext pops frames that have run past their last entry. Once a frame is
+// popped, the parent's child index i doubles as the index of its next entry.
+func (it *Iterator) ascendNext() {
+	for len(it.stack) > 0 {
+		top := it.stack[len(it.stack)-1]
+		if top.i < top.n.numEntries() {
+			return
+		}
+
+		it.stack = it.stack[:len(it.stack)-1]
+	}
+}
+
+// ascendPrev pops frames that have run past their first entry. Once a frame is
+// popped, the parent's previous entry precedes the child index i.
+func (it *Iterator) ascendPrev() {
+	for len(it.stack) > 0 {
+		if it.stack[len(it.stack)-1].i >= 0 {
+			return
+		}
+
+		it.stack = it.stack[:len(it.stack)-1]
+		if len(it.stack) > 0 {
+			it.stack[len(it.stack)-1].i--
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package btree_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIterator(t *testing.T) {
+	bt, err := btree.New(2)
+	require.NoError(t, err)
+
+	it := bt.Iterator()
+	require.False(t, it.Valid())
+	require.Nil(t, it.Entry())
+	require.False(t, it.Next())
+	require.False(t, it.Prev())
+	require.False(t, it.First())
+	require.False(t, it.Last())
+	require.False(t, it.Seek(testEntry{}))
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 2000)
+
+			// a fresh iterator walks forwards from the smallest entry
+			var got []btree.Entry
+			for it := bt.Iterator(); it.Next(); {
+				got = append(got, it.Entry())
+			}
+
+			require.Len(t, got, len(entries))
+			for i, e := range entries {
+				require.Equal(t, e, got[i])
+			}
+
+			// a fresh iterator walks backwards from the largest entry
+			got = got[:0]
+			for it := bt.Iterator(); it.Prev(); {
+				got = append(got, it.Entry())
+			}
+
+			require.Len(t, got, len(entries))
+			for i, e := range entries {
+				require.Equal(t, e, got[len(got)-1-i])
+			}
+
+			// an exhausted iterator remains invalid
+			it := bt.Iterator()
+			require.True(t, it.Last())
+			require.False(t, it.Next())
+			require.False(t, it.Valid())
+			require.False(t, it.Prev())
+			require.Nil(t, it.Entry())
+		})
+	}
+}
+
+func TestIteratorSeek(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree)
+			require.NoError(t, err)
+
+			// insert every other key in [0, 2n)
+			const n = 1000
+			for _, i := range rng.Perm(n) {
+				bt.Insert(testEntry{key: uint64(2 * i)})
+			}
+
+			it := bt.Iterator()
+			for k := 0; k < 2*n-1; k++ {
+				want := uint64(k + k%2)
+
+				require.True(t, it.Seek(testEntry{key: uint64(k)}))
+				require.Equal(t, testEntry{key: want}, it.Entry())
+
+				// zig-zag around the sought position
+				steps := rng.Intn(20)
+				pos := int(want / 2)
+
+				for s := 0; s < steps; s++ {
+					if rng.Intn(2) == 0 {
+						pos++
+						require.Equal(t, pos < n, it.Next())
+					} else {
+						pos--
+						require.Equal(t, pos >= 0, it.Prev())
+					}
+
+					if pos < 0 || pos >= n {
+						require.False(t, it.Valid())
+						break
+					}
+
+					require.Equal(t, testEntry{key: uint64(2 * pos)}, it.Entry())
+				}
+			}
+
+			require.False(t, it.Seek(testEntry{key: 2*n - 1}))
+			require.False(t, it.Valid())
+		})
+	}
+}
+
+func TestWeakIterator(t *testing.T) {
+	bt, err := btree.New(2)
+	require.NoError(t, err)
+
+	it := bt.WeakIterator()
+	require.False(t, it.Next())
+	require.False(t, it.Prev())
+	require.Nil(t, it.Entry())
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, _ := newTestBTree(t, minDegree, 2000)
+
+			// deleting the current entry and inserting entries both behind and
+			// ahead of it leaves the iteration intact
+			var got []uint64
+			for it := bt.WeakIterator(); it.Next(); {
+				k := it.Entry().(testEntry).key
+				got = append(got, k)
+
+				require.NotNil(t, bt.Delete(it.Entry()))
+				if k%2 == 0 {
+					bt.Insert(testEntry{key: k + 2001})
+				}
+
+				if k > 0 {
+					bt.Insert(testEntry{key: k - 1})
+				}
+			}
+
+			// the entries inserted ahead are visited as well, those behind not
+			want := 2000 + 1000
+			require.Len(t, got, want)
+			for i := 1; i < len(got); i++ {
+				require.Less(t, got[i-1], got[i])
+			}
+
+			// walking backwards past deleted entries
+			it := bt.WeakIterator()
+			require.True(t, it.Last())
+			last := it.Entry().(testEntry).key
+
+			for it.Prev() {
+				k := it.Entry().(testEntry).key
+				require.Less(t, k, last)
+				bt.Delete(testEntry{key: last})
+				last = k
+			}
+
+			require.Equal(t, 1, bt.Size())
+			require.NoError(t, bt.Verify())
+
+			// seeking to a deleted entry proceeds from its successor
+			bt.Insert(testEntry{key: 5000})
+			bt.Insert(testEntry{key: 6000})
+
+			it = bt.WeakIterator()
+			require.True(t, it.Seek(testEntry{key: 5000}))
+			bt.Delete(testEntry{key: 5000})
+			require.True(t, it.Next())
+			require.Equal(t, uint64(6000), it.Entry().(testEntry).key)
+			require.False(t, it.Next())
+		})
+	}
+}
+
+func TestIteratorConcurrentModification(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, _ := newTestBTree(t, minDegree, 500)
+
+			// replacing an entry in place does not invalidate the Iterator
+			it := bt.Iterator()
+			require.True(t, it.Seek(testEntry{key: 100}))
+			require.True(t, bt.CompareAndSwap(bt.Search(testEntry{key: 101}), testEntry{key: 101, value: 1}))
+			require.True(t, it.Next())
+			require.Equal(t, testEntry{key: 101, value: 1}, it.Entry())
+			require.NoError(t, it.Err())
+
+			modifications := []func(){
+				func() { bt.Insert(testEntry{key: 1000}) },
+				func() { bt.Delete(testEntry{key: 300}) },
+				func() { bt.DeleteMin() },
+				func() { bt.DeleteRange(testEntry{key: 400}, testEntry{key: 410}) },
+				func() { bt.Truncate(450) },
+			}
+
+			for _, modify := range modifications {
+				require.True(t, it.Seek(testEntry{key: 200}))
+				modify()
+
+				require.False(t, it.Next())
+				require.False(t, it.Valid())
+				require.True(t, errors.Is(it.Err(), btree.ErrConcurrentModification))
+				require.False(t, it.Prev())
+
+				// repositioning clears the error
+				require.True(t, it.Last())
+				require.NoError(t, it.Err())
+				require.True(t, it.Prev())
+			}
+
+			bt.Clear(false)
+			require.False(t, it.Prev())
+			require.True(t, errors.Is(it.Err(), btree.ErrConcurrentModification))
+		})
+	}
+
+	// an Iterator over a snapshot is unaffected
+	bt, err := btree.New(2, btree.WithSnapshotReads())
+	require.NoError(t, err)
+
+	bt.Insert(testEntry{key: 1})
+	bt.Insert(testEntry{key: 2})
+
+	it := bt.Iterator()
+	require.True(t, it.First())
+	bt.Delete(testEntry{key: 2})
+	require.True(t, it.Next())
+	require.Equal(t, uint64(2), it.Entry().(testEntry).key)
+	require.NoError(t, it.Err())
+}
+
+func TestBTreeSeq(t *testing.T) {
+	bt, entries := newTestBTree(t, 3, 2000)
+
+	var got []btree.Entry
+	for e := range bt.All() {
+		got = append(got, e)
+	}
+
+	require.Len(t, got, len(entries))
+	for i, e := range entries {
+		require.Equal(t, e, got[i])
+	}
+
+	got = got[:0]
+	for e := range bt.Backward() {
+		got = append(got, e)
+	}
+
+	require.Len(t, got, len(entries))
+	for i, e := range entries {
+		require.Equal(t, e, got[len(got)-1-i])
+	}
+
+	got = got[:0]
+	for e := range bt.Range(testEntry{key: 100}, testEntry{key: 200}) {
+		got = append(got, e)
+	}
+
+	require.Len(t, got, 100)
+	for i, e := range got {
+		require.Equal(t, entries[100+i], e)
+	}
+
+	// breaking out of the loop stops the traversal and releases the lock
+	got = got[:0]
+	for e := range bt.All() {
+		if len(got) == 10 {
+			break
+		}
+
+		got = append(got, e)
+	}
+
+	require.Len(t, got, 10)
+	bt.Insert(testEntry{key: uint64(len(entries))})
+	require.Equal(t, len(entries)+1, bt.Size())
+}
+
+func TestBTreeStream(t *testing.T) {
+	bt, entries := newTestBTree(t, 3, 2000)
+
+	var got []btree.Entry
+	for e := range bt.Stream(context.Background(), testEntry{key: 100}, nil) {
+		got = append(got, e)
+	}
+
+	require.Len(t, got, len(entries)-100)
+	for i, e := range got {
+		require.Equal(t, entries[100+i], e)
+	}
+
+	// a paused consumer holds the read lock, so writers wait until the stream is
+	// cancelled, which closes the channel promptly
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := bt.Stream(ctx, nil, nil)
+	require.Equal(t, entries[0], <-ch)
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		bt.Insert(testEntry{key: uint64(len(entries))})
+	}()
+
+	select {
+	case <-written:
+		t.Fatal("writer was not blocked by a paused stream")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+
+	select {
+	case <-closed(ch):
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not closed after cancellation")
+	}
+
+	<-written
+	require.Equal(t, len(entries)+1, bt.Size())
+
+	// with snapshot reads, writers are not blocked by a paused consumer and are
+	// not observed by it
+	snap, err := btree.New(3, btree.WithSnapshotReads())
+	require.NoError(t, err)
+
+	for _, e := range entries {
+		snap.Insert(e)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	ch = snap.Stream(ctx, nil, nil)
+	require.Equal(t, entries[0], <-ch)
+
+	snap.Delete(entries[1])
+	snap.Insert(testEntry{key: uint64(len(entries))})
+	require.Equal(t, entries[1], <-ch)
+
+	cancel()
+
+	select {
+	case <-closed(ch):
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not closed after cancellation")
+	}
+}
+
+// closed returns a channel which is closed once ch is closed, discarding any
+// remaining entries.
+func closed(ch <-chan btree.Entry) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for range ch {
+		}
+	}()
+
+	return done
+}
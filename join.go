@@ -0,0 +1,220 @@
+package btree
+
+import (
+	"sort"
+)
+
+// The functions in this file operate on whole subtrees, identified by their root
+// node and height, where a leaf has a height of one. A subtree root may hold any
+// number of entries, including zero if it is a leaf, while every node below it
+// must uphold the usual B-Tree invariants. All nodes that are modified or
+// created are owned by the given copy-on-write context.
+
+// join concatenates the subtrees l and r, of heights hl and hr respectively,
+// around the separator k, s.t. every entry in l is smaller than k and every
+// entry in r is greater than k. It returns the root of the joined subtree and
+// its height.
+func join(cow *copyOnWriteContext, t int, l *node, hl int, k Entry, r *node, hr int) (*node, int) {
+	left, midEntry, right := joinAt(cow, t, l, hl, k, r, hr)
+
+	h := hl
+	if hr > h {
+		h = hr
+	}
+
+	if right == nil {
+		return left, h
+	}
+
+	root := cow.newNode()
+	root.entries = append(root.entries, midEntry)
+	root.children = append(root.children, left, right)
+	root.count = left.count + right.count + 1
+
+	return root, h + 1
+}
+
+// joinAt joins the subtrees l and r around k as join does, but returns either a
+// single node (with a nil right node) or a pair of nodes and their separating
+// entry, all at the height of the taller subtree. The shorter subtree is hung
+// off the inner spine of the taller one, splitting any node that overflows on
+// the way back up.
+func joinAt(cow *copyOnWriteContext, t int, l *node, hl int, k Entry, r *node, hr int) (*node, Entry, *node) {
+	switch {
+	case hl == hr:
+		return concat(cow, t, l, k, r)
+
+	case hl > hr:
+		l = l.mutableFor(cow)
+		i := l.numChildren() - 1
+
+		left, midEntry, right := joinAt(cow, t, l.children[i], hl-1, k, r, hr)
+		l.children[i] = left
+
+		if right != nil {
+			l.entries = append(l.entries, midEntry)
+			l.children = append(l.children, right)
+		}
+
+		l.recount()
+		return splitOverfull(t, l)
+
+	default:
+		r = r.mutableFor(cow)
+
+		left, midEntry, right := joinAt(cow, t, l, hl, k, r.children[0], hr-1)
+		r.children[0] = left
+
+		if right != nil {
+			r.insertAt(0, midEntry)
+			r.insertChildAt(1, right)
+		}
+
+		r.recount()
+		return splitOverfull(t, r)
+	}
+}
+
+// concat joins two nodes of equal height around the separator k. If the entries
+// fit into a single node, the nodes are merged. Otherwise, the entries are
+// redistributed evenly between two nodes, so that both hold at least t-1
+// entries, and returned along with their new separator.
+func concat(cow *copyOnWriteContext, t int, l *node, k Entry, r *node) (*node, Entry, *node) {
+	if l.numEntries()+r.numEntries()+1 <= 2*t-1 {
+		n := l.mutableFor(cow)
+		n.entries = append(n.entries, k)
+		n.entries = append(n.entries, r.entries...)
+		n.children = append(n.children, r.children...)
+		n.count += r.count + 1
+		cow.freeNode(r)
+
+		return n, nil, nil
+	}
+
+	entries := make(Entries, 0, l.numEntries()+r.numEntries()+1)
+	entries = append(entries, l.entries...)
+	entries = append(entries, k)
+	entries = append(entries, r.entries...)
+
+	children := make(nodes, 0, l.numChildren()+r.numChildren())
+	children = append(children, l.children...)
+	children = append(children, r.children...)
+
+	cow.freeNode(l)
+	cow.freeNode(r)
+
+	midEntryIdx := len(entries) / 2
+
+	left := cow.newNode()
+	left.entries = append(left.entries, entries[:midEntryIdx]...)
+
+	right := cow.newNode()
+	right.entries = append(right.entries, entries[midEntryIdx+1:]...)
+
+	if len(children) > 0 {
+		left.children = append(left.children, children[:midEntryIdx+1]...)
+		right.children = append(right.children, children[midEntryIdx+1:]...)
+	}
+
+	left.recount()
+	right.recount()
+
+	return left, entries[midEntryIdx], right
+}
+
+// splitOverfull splits n around its median entry if it holds more than 2t-1
+// entries and returns the resulting pair. Otherwise, n is returned alone.
+func splitOverfull(t int, n *node) (*node, Entry, *node) {
+	if n.numEntries() <= 2*t-1 {
+		return n, nil, nil
+	}
+
+	midEntry, right := n.split()
+	return n, midEntry, right
+}
+
+// join2 concatenates the subtrees l and r, of heights hl and hr respectively,
+// s.t. every entry in l is smaller than every entry in r. The smallest entry of
+// r is used as the separator. It returns the root of the joined subtree and its
+// height.
+func join2(cow *copyOnWriteContext, t int, l *node, hl int, r *node, hr int) (*node, int) {
+	if r.numEntries() == 0 {
+		return l, hl
+	}
+
+	if l.numEntries() == 0 {
+		return r, hr
+	}
+
+	r = r.mutableFor(cow)
+	k := r.removeMin(t)
+
+	if r.numEntries() == 0 && !r.leaf() {
+		oldRoot := r
+		r = r.children[0]
+		cow.freeNode(oldRoot)
+		hr--
+	}
+
+	return join(cow, t, l, hl, k, r, hr)
+}
+
+// cut splits the subtree n of height h into two subtrees holding the entries
+// smaller than k and the entries greater than or equal to k respectively. It
+// returns the roots of both subtrees and their heights. The nodes along the
+// path to k are discarded, while all other nodes are shared with the results.
+func cut(
+	cow *copyOnWriteContext, t int, cmp compareFunc, n *node, h int, k Entry,
+) (l *node, hl int, r *node, hr int) {
+
+	// binary search for the smallest index i, s.t. n.entries[i] >= k
+	i := sort.Search(n.numEntries(), func(i int) bool {
+		return cmp(n.entries[i], k) >= 0
+	})
+
+	if n.leaf() {
+		l = cow.newNode()
+		l.entries = append(l.entries, n.entries[:i]...)
+		l.count = l.numEntries()
+
+		r = cow.newNode()
+		r.entries = append(r.entries, n.entries[i:]...)
+		r.count = r.numEntries()
+
+		cow.freeNode(n)
+		return l, 1, r, 1
+	}
+
+	l, hl, r, hr = cut(cow, t, cmp, n.children[i], h-1, k)
+
+	// Everything left of the i-th child, along with the separator preceding it,
+	// is joined onto the left result. Likewise for the right.
+	if i > 0 {
+		lp, hlp := subtree(cow, n.entries[:i-1], n.children[:i], h)
+		l, hl = join(cow, t, lp, hlp, n.entries[i-1], l, hl)
+	}
+
+	if i < n.numEntries() {
+		rp, hrp := subtree(cow, n.entries[i+1:], n.children[i+1:], h)
+		r, hr = join(cow, t, r, hr, n.entries[i], rp, hrp)
+	}
+
+	cow.freeNode(n)
+	return l, hl, r, hr
+}
+
+// subtree returns a subtree of height h made up of a copy of the given entries
+// and children. If there are no entries, the only child, of height h-1, is
+// returned instead.
+func subtree(cow *copyOnWriteContext, entries Entries, children nodes, h int) (*node, int) {
+	if len(entries) == 0 {
+		return children[0], h - 1
+	}
+
+	n := cow.newNode()
+	n.entries = append(n.entries, entries...)
+	n.children = append(n.children, children...)
+	n.recount()
+
+	return n, h
+}
@@ -0,0 +1,98 @@
+package btree
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// MarshalJSON implements json.Marshaler, encoding the entries of the BTree as a
+// JSON array in ascending order, as of a single point in time, under the read
+// lock. The entries are encoded by the JSON Codec of the BTree or, if it has
+// none, by encoding/json itself. The minimum degree and structure of the BTree
+// are not encoded.
+func (bt *BTree) MarshalJSON() ([]byte, error) {
+	codec := bt.jsonCodec
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	var (
+		buf bytes.Buffer
+		err error
+	)
+
+	buf.WriteByte('[')
+	bt.root.ascendRange(bt.cmp, nil, nil, true, func(e Entry) bool {
+		var b []byte
+		if codec != nil {
+			b, err = codec.Encode(e)
+		} else {
+			b, err = json.Marshal(e)
+		}
+
+		if err != nil {
+			err = fmt.Errorf("failed to encode entry %v: %w", e, err)
+			return false
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+
+		buf.Write(b)
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, replacing the contents of the
+// BTree by the entries of the JSON array in data, which are decoded by the JSON
+// Codec of the BTree and must be in strictly ascending order, as written by
+// MarshalJSON. The entries are bulk loaded at the minimum degree of the BTree,
+// which must therefore be created first, through New or the like,
+// WithJSONCodec. ErrNoCodec is returned if the BTree has no JSON Codec. If an
+// error is returned, the BTree is left untouched.
+func (bt *BTree) UnmarshalJSON(data []byte) error {
+	codec := bt.jsonCodec
+	if codec == nil {
+		return ErrNoCodec
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	sorted := make([]Entry, len(raw))
+	for i, b := range raw {
+		e, err := codec.Decode(b)
+		if err != nil {
+			return fmt.Errorf("failed to decode entry at index %d: %w", i, err)
+		}
+
+		if e != nil && bt.validate != nil {
+			if err := bt.validate(e); err != nil {
+				return fmt.Errorf("invalid entry at index %d: %w", i, err)
+			}
+		}
+
+		sorted[i] = e
+	}
+
+	if err := bt.checkSorted(sorted, 1); err != nil {
+		return err
+	}
+
+	loaded := bt.newLike()
+	loaded.load(sorted)
+
+	bt.adopt(loaded.root, loaded.size, loaded.depth, loaded.minDegree, loaded.cow)
+	return nil
+}
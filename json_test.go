@@ -0,0 +1,111 @@
+package btree_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+// testJSONCodec encodes a testEntry as a JSON object.
+type testJSONCodec struct{}
+
+type testJSONEntry struct {
+	Key   uint64 `json:"key"`
+	Value uint64 `json:"value"`
+}
+
+func (testJSONCodec) Encode(e btree.Entry) ([]byte, error) {
+	te := e.(testEntry)
+	return json.Marshal(testJSONEntry{te.key, te.value})
+}
+
+func (testJSONCodec) Decode(b []byte) (btree.Entry, error) {
+	var je testJSONEntry
+	if err := json.Unmarshal(b, &je); err != nil {
+		return nil, err
+	}
+
+	return testEntry{je.Key, je.Value}, nil
+}
+
+// namedEntry defines an Entry encoding/json handles without a Codec.
+type namedEntry struct {
+	Name string
+}
+
+func (e namedEntry) Compare(other btree.Entry) int {
+	return strings.Compare(e.Name, other.(namedEntry).Name)
+}
+
+func TestMarshalJSON(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree, btree.WithJSONCodec(testJSONCodec{}))
+			require.NoError(t, err)
+
+			for _, i := range rng.Perm(1000) {
+				bt.Insert(testEntry{uint64(i), uint64(i) * 7})
+			}
+
+			data, err := json.Marshal(bt)
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(string(data), `[{"key":0,"value":0},{"key":1,"value":7},`))
+
+			// decoding rebuilds the entries at the degree of the receiver
+			restored, err := btree.New(3, btree.WithJSONCodec(testJSONCodec{}))
+			require.NoError(t, err)
+			restored.Insert(testEntry{key: 5000})
+
+			require.NoError(t, json.Unmarshal(data, restored))
+			require.NoError(t, restored.Verify())
+			require.Equal(t, 3, restored.Stats().MinDegree)
+			require.Equal(t, bt.Entries(nil, nil), restored.Entries(nil, nil))
+
+			// as a field of an enclosing document
+			var doc struct {
+				Tree *btree.BTree `json:"tree"`
+			}
+
+			doc.Tree = restored
+			require.NoError(t, json.Unmarshal([]byte(`{"tree": [{"key": 2}, {"key": 3, "value": 1}]}`), &doc))
+			require.Equal(t, []btree.Entry{testEntry{2, 0}, testEntry{3, 1}}, restored.Entries(nil, nil))
+		})
+	}
+
+	bt, err := btree.New(2, btree.WithJSONCodec(testJSONCodec{}))
+	require.NoError(t, err)
+	bt.Insert(testEntry{key: 1})
+
+	data, err := json.Marshal(bt)
+	require.NoError(t, err)
+	require.Equal(t, `[{"key":1,"value":0}]`, string(data))
+
+	// invalid input leaves the BTree untouched
+	require.Error(t, json.Unmarshal([]byte(`{"key": 1}`), bt))
+	require.Error(t, json.Unmarshal([]byte(`[{"key": "a"}]`), bt))
+	require.True(t, errors.Is(json.Unmarshal([]byte(`[{"key": 3}, {"key": 2}]`), bt), btree.ErrUnsorted))
+	require.Equal(t, []btree.Entry{testEntry{key: 1}}, bt.Entries(nil, nil))
+
+	require.NoError(t, json.Unmarshal([]byte(`[]`), bt))
+	require.Zero(t, bt.Size())
+
+	data, err = json.Marshal(bt)
+	require.NoError(t, err)
+	require.Equal(t, `[]`, string(data))
+
+	// without a Codec, entries encode themselves but cannot be decoded
+	named, err := btree.New(2)
+	require.NoError(t, err)
+	named.Insert(namedEntry{"b"})
+	named.Insert(namedEntry{"a"})
+
+	data, err = json.Marshal(named)
+	require.NoError(t, err)
+	require.Equal(t, `[{"Name":"a"},{"Name":"b"}]`, string(data))
+	require.True(t, errors.Is(named.UnmarshalJSON(data), btree.ErrNoCodec))
+}
@@ -0,0 +1,419 @@
+package btree
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The types in this file implement the B-Tree algorithms over concrete key and
+// value types, stored inline in per-node slices rather than behind the Entry
+// interface. They back the specialized trees, such as Uint64Tree, which add
+// the locking and public API on top. Insertion splits full nodes proactively on
+// the way down and deletion grows minimal nodes on the way down, as BTree does.
+
+type (
+	// knode defines a node of a ktree, where values[i] is stored under keys[i].
+	knode[K, V any] struct {
+		keys     []K
+		values   []V
+		children []*knode[K, V]
+	}
+
+	// ktree defines a B-Tree of minimum degree t over keys ordered by cmp.
+	ktree[K, V any] struct {
+		cmp  func(a, b K) int
+		t    int
+		root *knode[K, V]
+		size int
+	}
+
+	// kremoveType defines what a knode removal targets.
+	kremoveType int
+)
+
+const (
+	kremoveKey kremoveType = iota
+	kremoveMin
+	kremoveMax
+)
+
+// newKTree returns a new, empty ktree with a minimum degree t over keys ordered
+// by cmp.
+func newKTree[K, V any](t int, cmp func(a, b K) int) (*ktree[K, V], error) {
+	if t < 2 {
+		return nil, fmt.Errorf("minimum degree must be at least two: %d", t)
+	}
+
+	return &ktree[K, V]{cmp: cmp, t: t, root: &knode[K, V]{}}, nil
+}
+
+func (n *knode[K, V]) leaf() bool {
+	return len(n.children) == 0
+}
+
+// search returns the smallest index i, s.t. n.keys[i] >= k, and whether
+// n.keys[i] equals k.
+func (n *knode[K, V]) search(cmp func(a, b K) int, k K) (int, bool) {
+	i := sort.Search(len(n.keys), func(i int) bool {
+		return cmp(n.keys[i], k) >= 0
+	})
+
+	return i, i < len(n.keys) && cmp(n.keys[i], k) == 0
+}
+
+func (n *knode[K, V]) insertAt(i int, k K, v V) {
+	var (
+		zk K
+		zv V
+	)
+
+	n.keys = append(n.keys, zk)
+	copy(n.keys[i+1:], n.keys[i:])
+	n.keys[i] = k
+
+	n.values = append(n.values, zv)
+	copy(n.values[i+1:], n.values[i:])
+	n.values[i] = v
+}
+
+func (n *knode[K, V]) removeAt(i int) (K, V) {
+	var (
+		zk K
+		zv V
+	)
+
+	k, v := n.keys[i], n.values[i]
+
+	copy(n.keys[i:], n.keys[i+1:])
+	n.keys[len(n.keys)-1] = zk
+	n.keys = n.keys[:len(n.keys)-1]
+
+	copy(n.values[i:], n.values[i+1:])
+	n.values[len(n.values)-1] = zv
+	n.values = n.values[:len(n.values)-1]
+
+	return k, v
+}
+
+func (n *knode[K, V]) insertChildAt(i int, child *knode[K, V]) {
+	n.children = append(n.children, nil)
+	copy(n.children[i+1:], n.children[i:])
+	n.children[i] = child
+}
+
+func (n *knode[K, V]) removeChildAt(i int) *knode[K, V] {
+	child := n.children[i]
+
+	copy(n.children[i:], n.children[i+1:])
+	n.children[len(n.children)-1] = nil
+	n.children = n.children[:len(n.children)-1]
+
+	return child
+}
+
+// splitChild splits the full child at index i around its median, which moves up
+// into n between the two halves.
+func (n *knode[K, V]) splitChild(i, t int) {
+	child := n.children[i]
+	right := &knode[K, V]{
+		keys:   append([]K(nil), child.keys[t:]...),
+		values: append([]V(nil), child.values[t:]...),
+	}
+
+	if !child.leaf() {
+		right.children = append(right.children, child.children[t:]...)
+		clear(child.children[t:])
+		child.children = child.children[:t]
+	}
+
+	n.insertAt(i, child.keys[t-1], child.values[t-1])
+	n.insertChildAt(i+1, right)
+
+	clear(child.keys[t-1:])
+	clear(child.values[t-1:])
+	child.keys, child.values = child.keys[:t-1], child.values[:t-1]
+}
+
+// get returns the value stored under k and whether it exists.
+func (tr *ktree[K, V]) get(k K) (V, bool) {
+	n := tr.root
+	for {
+		i, found := n.search(tr.cmp, k)
+		if found {
+			return n.values[i], true
+		}
+
+		if n.leaf() {
+			var zero V
+			return zero, false
+		}
+
+		n = n.children[i]
+	}
+}
+
+// set stores v under k, returning the value it replaced, if any, and whether one
+// was replaced.
+func (tr *ktree[K, V]) set(k K, v V) (old V, replaced bool) {
+	if len(tr.root.keys) == 2*tr.t-1 {
+		root := &knode[K, V]{children: []*knode[K, V]{tr.root}}
+		root.splitChild(0, tr.t)
+		tr.root = root
+	}
+
+	n := tr.root
+	for {
+		i, found := n.search(tr.cmp, k)
+		if found {
+			old = n.values[i]
+			n.keys[i], n.values[i] = k, v
+			return old, true
+		}
+
+		if n.leaf() {
+			n.insertAt(i, k, v)
+			tr.size++
+			return old, false
+		}
+
+		if len(n.children[i].keys) == 2*tr.t-1 {
+			n.splitChild(i, tr.t)
+
+			switch c := tr.cmp(k, n.keys[i]); {
+			case c > 0:
+				i++
+
+			case c == 0:
+				// the key is the median which was just moved into n
+				old = n.values[i]
+				n.keys[i], n.values[i] = k, v
+				return old, true
+			}
+		}
+
+		n = n.children[i]
+	}
+}
+
+// remove removes k, or the smallest or largest key, depending on typ, and
+// returns the removed key and value and whether anything was removed.
+func (tr *ktree[K, V]) remove(k K, typ kremoveType) (K, V, bool) {
+	if tr.size == 0 {
+		var (
+			zk K
+			zv V
+		)
+
+		return zk, zv, false
+	}
+
+	rk, rv, ok := tr.root.remove(tr.cmp, tr.t, k, typ)
+	if len(tr.root.keys) == 0 && !tr.root.leaf() {
+		tr.root = tr.root.children[0]
+	}
+
+	if ok {
+		tr.size--
+	}
+
+	return rk, rv, ok
+}
+
+// remove removes a key from the subtree rooted at n, which must hold at least t
+// keys unless it is the root, so that a key can be removed from it without
+// rebalancing on the way back up.
+func (n *knode[K, V]) remove(cmp func(a, b K) int, t int, k K, typ kremoveType) (K, V, bool) {
+	var (
+		i     int
+		found bool
+	)
+
+	switch typ {
+	case kremoveMin:
+		if n.leaf() {
+			rk, rv := n.removeAt(0)
+			return rk, rv, true
+		}
+
+	case kremoveMax:
+		if n.leaf() {
+			rk, rv := n.removeAt(len(n.keys) - 1)
+			return rk, rv, true
+		}
+
+		i = len(n.keys)
+
+	default:
+		i, found = n.search(cmp, k)
+		if n.leaf() {
+			if !found {
+				var (
+					zk K
+					zv V
+				)
+
+				return zk, zv, false
+			}
+
+			rk, rv := n.removeAt(i)
+			return rk, rv, true
+		}
+	}
+
+	if len(n.children[i].keys) <= t-1 {
+		n.growChild(i, t)
+		return n.remove(cmp, t, k, typ)
+	}
+
+	if found {
+		// the key lives in n, so it is replaced by its predecessor
+		rk, rv := n.keys[i], n.values[i]
+		n.keys[i], n.values[i], _ = n.children[i].remove(cmp, t, k, kremoveMax)
+		return rk, rv, true
+	}
+
+	return n.children[i].remove(cmp, t, k, typ)
+}
+
+// growChild ensures the child at index i holds at least t keys, by borrowing a
+// key from a sibling through n or, if neither sibling can spare one, by merging
+// the child with a sibling around their separator.
+func (n *knode[K, V]) growChild(i, t int) {
+	child := n.children[i]
+
+	switch {
+	case i > 0 && len(n.children[i-1].keys) > t-1:
+		left := n.children[i-1]
+		lk, lv := left.removeAt(len(left.keys) - 1)
+
+		child.insertAt(0, n.keys[i-1], n.values[i-1])
+		n.keys[i-1], n.values[i-1] = lk, lv
+
+		if !left.leaf() {
+			child.insertChildAt(0, left.removeChildAt(len(left.children)-1))
+		}
+
+	case i < len(n.keys) && len(n.children[i+1].keys) > t-1:
+		right := n.children[i+1]
+		rk, rv := right.removeAt(0)
+
+		child.keys = append(child.keys, n.keys[i])
+		child.values = append(child.values, n.values[i])
+		n.keys[i], n.values[i] = rk, rv
+
+		if !right.leaf() {
+			child.children = append(child.children, right.removeChildAt(0))
+		}
+
+	default:
+		if i >= len(n.keys) {
+			i--
+			child = n.children[i]
+		}
+
+		sk, sv := n.removeAt(i)
+		right := n.removeChildAt(i + 1)
+
+		child.keys = append(append(child.keys, sk), right.keys...)
+		child.values = append(append(child.values, sv), right.values...)
+		child.children = append(child.children, right.children...)
+	}
+}
+
+// min returns the smallest key and its value, or false if the ktree is empty.
+func (tr *ktree[K, V]) min() (K, V, bool) {
+	if tr.size == 0 {
+		var (
+			zk K
+			zv V
+		)
+
+		return zk, zv, false
+	}
+
+	n := tr.root
+	for !n.leaf() {
+		n = n.children[0]
+	}
+
+	return n.keys[0], n.values[0], true
+}
+
+// max returns the largest key and its value, or false if the ktree is empty.
+func (tr *ktree[K, V]) max() (K, V, bool) {
+	if tr.size == 0 {
+		var (
+			zk K
+			zv V
+		)
+
+		return zk, zv, false
+	}
+
+	n := tr.root
+	for !n.leaf() {
+		n = n.children[len(n.children)-1]
+	}
+
+	return n.keys[len(n.keys)-1], n.values[len(n.values)-1], true
+}
+
+// ascend calls fn for every key k, s.t. lo <= k < hi, in ascending order, where
+// a nil lo or hi leaves the respective end of the range unbounded. It returns
+// false if fn stopped the traversal.
+func (n *knode[K, V]) ascend(cmp func(a, b K) int, lo, hi *K, fn func(K, V) bool) bool {
+	i := 0
+	if lo != nil {
+		i, _ = n.search(cmp, *lo)
+	}
+
+	for ; i < len(n.keys); i++ {
+		if !n.leaf() && !n.children[i].ascend(cmp, lo, hi, fn) {
+			return false
+		}
+
+		if hi != nil && cmp(n.keys[i], *hi) >= 0 {
+			return false
+		}
+
+		if !fn(n.keys[i], n.values[i]) {
+			return false
+		}
+	}
+
+	if !n.leaf() {
+		return n.children[len(n.children)-1].ascend(cmp, lo, hi, fn)
+	}
+
+	return true
+}
+
+// descend calls fn for every key k, s.t. lo <= k < hi, in descending order,
+// where a nil lo or hi leaves the respective end of the range unbounded. It
+// returns false if fn stopped the traversal.
+func (n *knode[K, V]) descend(cmp func(a, b K) int, lo, hi *K, fn func(K, V) bool) bool {
+	j := len(n.keys)
+	if hi != nil {
+		j, _ = n.search(cmp, *hi)
+	}
+
+	if !n.leaf() && !n.children[j].descend(cmp, lo, hi, fn) {
+		return false
+	}
+
+	for i := j - 1; i >= 0; i-- {
+		if lo != nil && cmp(n.keys[i], *lo) < 0 {
+			return false
+		}
+
+		if !fn(n.keys[i], n.values[i]) {
+			return false
+		}
+
+		if !n.leaf() && !n.children[i].descend(cmp, lo, hi, fn) {
+			return false
+		}
+	}
+
+	return true
+}
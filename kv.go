@@ -0,0 +1,140 @@
+package btree
+
+import (
+	"iter"
+)
+
+// Comparable defines the interface contract of a key type that orders itself,
+// such that Compare returns 0 if the receiver and other are equal, -1 if the
+// receiver is less than other and 1 otherwise.
+type Comparable[K any] interface {
+	Compare(other K) int
+}
+
+// KVTree implements a thread-safe ordered key-value store. Unlike a BTree of
+// entries, only the keys take part in ordering and equality, while values are
+// stored alongside them: Put replaces the value stored under an equal key and
+// Get looks up a value by key alone. It is backed by a BTree, sharing all of its
+// node machinery.
+type KVTree[K Comparable[K], V any] struct {
+	bt *BTree
+}
+
+// kvEntry defines the Entry a KVTree stores for every key-value pair.
+type kvEntry[K Comparable[K], V any] struct {
+	key   K
+	value V
+}
+
+func (e kvEntry[K, V]) Compare(other Entry) int {
+	return e.key.Compare(other.(kvEntry[K, V]).key)
+}
+
+// NewKVTree returns a reference to a new, empty KVTree backed by a BTree with a
+// minimum degree t.
+func NewKVTree[K Comparable[K], V any](t int) (*KVTree[K, V], error) {
+	bt, err := New(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KVTree[K, V]{bt: bt}, nil
+}
+
+// Len returns the number of key-value pairs in the KVTree.
+func (kv *KVTree[K, V]) Len() int {
+	return kv.bt.Size()
+}
+
+// Put stores the value under the given key, returning the value it replaced, if
+// any, and whether one was replaced. The stored key is replaced as well.
+func (kv *KVTree[K, V]) Put(key K, value V) (old V, replaced bool) {
+	e, replaced := kv.bt.Set(kvEntry[K, V]{key, value})
+	if replaced {
+		old = e.(kvEntry[K, V]).value
+	}
+
+	return old, replaced
+}
+
+// Get returns the value stored under the given key and whether it exists.
+func (kv *KVTree[K, V]) Get(key K) (V, bool) {
+	_, value, ok := unwrapKV[K, V](kv.bt.Search(kvEntry[K, V]{key: key}))
+	return value, ok
+}
+
+// Has returns true if a value is stored under the given key.
+func (kv *KVTree[K, V]) Has(key K) bool {
+	return kv.bt.Has(kvEntry[K, V]{key: key})
+}
+
+// Delete removes the given key from the KVTree, returning the value stored under
+// it, if any, and whether it existed.
+func (kv *KVTree[K, V]) Delete(key K) (V, bool) {
+	_, value, ok := unwrapKV[K, V](kv.bt.Delete(kvEntry[K, V]{key: key}))
+	return value, ok
+}
+
+// Min returns the smallest key in the KVTree along with its value, or false if
+// the KVTree is empty.
+func (kv *KVTree[K, V]) Min() (K, V, bool) {
+	return unwrapKV[K, V](kv.bt.Min())
+}
+
+// Max returns the largest key in the KVTree along with its value, or false if
+// the KVTree is empty.
+func (kv *KVTree[K, V]) Max() (K, V, bool) {
+	return unwrapKV[K, V](kv.bt.Max())
+}
+
+// Ascend calls fn for every key-value pair in ascending key order, under the
+// read lock. The traversal stops as soon as fn returns false.
+func (kv *KVTree[K, V]) Ascend(fn func(key K, value V) bool) {
+	kv.bt.Ascend(visitKV(fn))
+}
+
+// Descend calls fn for every key-value pair in descending key order, under the
+// read lock. The traversal stops as soon as fn returns false.
+func (kv *KVTree[K, V]) Descend(fn func(key K, value V) bool) {
+	kv.bt.Descend(visitKV(fn))
+}
+
+// AscendRange calls fn for every key-value pair whose key k satisfies
+// from <= k < to, in ascending key order, under the read lock. The traversal
+// stops as soon as fn returns false.
+func (kv *KVTree[K, V]) AscendRange(from, to K, fn func(key K, value V) bool) {
+	kv.bt.AscendRange(kvEntry[K, V]{key: from}, kvEntry[K, V]{key: to}, visitKV(fn))
+}
+
+// All returns an iterator over every key-value pair in ascending key order,
+// suitable for use with range. The read lock is held until the loop completes
+// or breaks, so the loop body must not modify the KVTree.
+func (kv *KVTree[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		kv.Ascend(yield)
+	}
+}
+
+// visitKV adapts a key-value callback to the entries of a KVTree.
+func visitKV[K Comparable[K], V any](fn func(K, V) bool) func(Entry) bool {
+	return func(e Entry) bool {
+		kve := e.(kvEntry[K, V])
+		return fn(kve.key, kve.value)
+	}
+}
+
+// unwrapKV returns the key and value of the given KVTree entry, or false if it
+// is nil.
+func unwrapKV[K Comparable[K], V any](e Entry) (K, V, bool) {
+	if e == nil {
+		var (
+			key   K
+			value V
+		)
+
+		return key, value, false
+	}
+
+	kve := e.(kvEntry[K, V])
+	return kve.key, kve.value, true
+}
@@ -0,0 +1,95 @@
+package btree_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+// caseInsensitive implements a btree.Comparable key which ignores case.
+type caseInsensitive string
+
+func (k caseInsensitive) Compare(other caseInsensitive) int {
+	return strings.Compare(strings.ToLower(string(k)), strings.ToLower(string(other)))
+}
+
+func TestKVTree(t *testing.T) {
+	_, err := btree.NewKVTree[caseInsensitive, int](1)
+	require.Error(t, err)
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			kv, err := btree.NewKVTree[caseInsensitive, []int](minDegree)
+			require.NoError(t, err)
+
+			_, _, ok := kv.Min()
+			require.False(t, ok)
+
+			// values need not be comparable and take no part in equality
+			for i := 0; i < 1000; i++ {
+				_, replaced := kv.Put(caseInsensitive(fmt.Sprintf("key-%04d", i)), []int{i})
+				require.False(t, replaced)
+			}
+
+			old, replaced := kv.Put("KEY-0042", []int{-42})
+			require.True(t, replaced)
+			require.Equal(t, []int{42}, old)
+			require.Equal(t, 1000, kv.Len())
+
+			v, ok := kv.Get("key-0042")
+			require.True(t, ok)
+			require.Equal(t, []int{-42}, v)
+			require.True(t, kv.Has("Key-0999"))
+			require.False(t, kv.Has("key-1000"))
+
+			k, v, ok := kv.Min()
+			require.True(t, ok)
+			require.Equal(t, caseInsensitive("key-0000"), k)
+			require.Equal(t, []int{0}, v)
+
+			k, _, ok = kv.Max()
+			require.True(t, ok)
+			require.Equal(t, caseInsensitive("key-0999"), k)
+
+			i := 0
+			for k, v := range kv.All() {
+				require.Zero(t, k.Compare(caseInsensitive(fmt.Sprintf("key-%04d", i))))
+				if i != 42 {
+					require.Equal(t, []int{i}, v)
+				}
+
+				i++
+			}
+
+			require.Equal(t, 1000, i)
+
+			var keys []caseInsensitive
+			kv.AscendRange("key-0100", "key-0103", func(k caseInsensitive, _ []int) bool {
+				keys = append(keys, k)
+				return true
+			})
+			require.Equal(t, []caseInsensitive{"key-0100", "key-0101", "key-0102"}, keys)
+
+			i = 999
+			kv.Descend(func(_ caseInsensitive, v []int) bool {
+				if i != 42 {
+					require.Equal(t, []int{i}, v)
+				}
+
+				i--
+				return true
+			})
+
+			v, ok = kv.Delete("KEY-0042")
+			require.True(t, ok)
+			require.Equal(t, []int{-42}, v)
+
+			_, ok = kv.Delete("KEY-0042")
+			require.False(t, ok)
+			require.Equal(t, 999, kv.Len())
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package btree
+
+import (
+	"cmp"
+	"iter"
+)
+
+// Map implements a thread-safe ordered map from keys of any ordered type to
+// values of any type. It is backed by a BTree, sharing all of its node
+// machinery, and wraps every key-value pair in an internal Entry, so that
+// callers never box keys or assert types themselves.
+type Map[K cmp.Ordered, V any] struct {
+	bt *BTree
+}
+
+// mapEntry defines the Entry a Map stores for every key-value pair.
+type mapEntry[K cmp.Ordered, V any] struct {
+	key   K
+	value V
+}
+
+func (me mapEntry[K, V]) Compare(other Entry) int {
+	return cmp.Compare(me.key, other.(mapEntry[K, V]).key)
+}
+
+// NewMap returns a reference to a new, empty Map backed by a BTree with a
+// minimum degree t.
+func NewMap[K cmp.Ordered, V any](t int) (*Map[K, V], error) {
+	bt, err := New(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Map[K, V]{bt: bt}, nil
+}
+
+// Len returns the number of key-value pairs in the Map.
+func (m *Map[K, V]) Len() int {
+	return m.bt.Size()
+}
+
+// Get returns the value stored under the given key and whether it exists.
+func (m *Map[K, V]) Get(key K) (V, bool) {
+	e := m.bt.Search(mapEntry[K, V]{key: key})
+	if e == nil {
+		var zero V
+		return zero, false
+	}
+
+	return e.(mapEntry[K, V]).value, true
+}
+
+// Has returns true if a value is stored under the given key.
+func (m *Map[K, V]) Has(key K) bool {
+	return m.bt.Has(mapEntry[K, V]{key: key})
+}
+
+// Set stores the value under the given key, returning the value it replaced, if
+// any, and whether one was replaced.
+func (m *Map[K, V]) Set(key K, value V) (old V, replaced bool) {
+	e, replaced := m.bt.Set(mapEntry[K, V]{key, value})
+	if replaced {
+		old = e.(mapEntry[K, V]).value
+	}
+
+	return old, replaced
+}
+
+// Delete removes the given key from the Map, returning the value stored under
+// it, if any, and whether it existed.
+func (m *Map[K, V]) Delete(key K) (V, bool) {
+	e := m.bt.Delete(mapEntry[K, V]{key: key})
+	if e == nil {
+		var zero V
+		return zero, false
+	}
+
+	return e.(mapEntry[K, V]).value, true
+}
+
+// Min returns the smallest key in the Map along with its value, or false if the
+// Map is empty.
+func (m *Map[K, V]) Min() (K, V, bool) {
+	return unwrap[K, V](m.bt.Min())
+}
+
+// Max returns the largest key in the Map along with its value, or false if the
+// Map is empty.
+func (m *Map[K, V]) Max() (K, V, bool) {
+	return unwrap[K, V](m.bt.Max())
+}
+
+// Ascend calls fn for every key-value pair in the Map in ascending key order,
+// under the read lock. The traversal stops as soon as fn returns false.
+func (m *Map[K, V]) Ascend(fn func(key K, value V) bool) {
+	m.bt.Ascend(visit(fn))
+}
+
+// Descend calls fn for every key-value pair in the Map in descending key order,
+// under the read lock. The traversal stops as soon as fn returns false.
+func (m *Map[K, V]) Descend(fn func(key K, value V) bool) {
+	m.bt.Descend(visit(fn))
+}
+
+// AscendRange calls fn for every key-value pair in the Map whose key k satisfies
+// from <= k < to, in ascending key order, under the read lock. The traversal
+// stops as soon as fn returns false.
+func (m *Map[K, V]) AscendRange(from, to K, fn func(key K, value V) bool) {
+	m.bt.AscendRange(mapEntry[K, V]{key: from}, mapEntry[K, V]{key: to}, visit(fn))
+}
+
+// All returns an iterator over every key-value pair in the Map in ascending key
+// order, suitable for use with range. The read lock is held until the loop
+// completes or breaks, so the loop body must not modify the Map.
+func (m *Map[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.Ascend(yield)
+	}
+}
+
+// Backward returns an iterator over every key-value pair in the Map in
+// descending key order, suitable for use with range. The read lock is held
+// until the loop completes or breaks, so the loop body must not modify the Map.
+func (m *Map[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.Descend(yield)
+	}
+}
+
+// visit adapts a key-value callback to the entries of a Map.
+func visit[K cmp.Ordered, V any](fn func(K, V) bool) func(Entry) bool {
+	return func(e Entry) bool {
+		me := e.(mapEntry[K, V])
+		return fn(me.key, me.value)
+	}
+}
+
+// unwrap returns the key and value of the given Map entry, or false if it is
+// nil.
+func unwrap[K cmp.Ordered, V any](e Entry) (K, V, bool) {
+	if e == nil {
+		var (
+			key   K
+			value V
+		)
+
+		return key, value, false
+	}
+
+	me := e.(mapEntry[K, V])
+	return me.key, me.value, true
+}
@@ -0,0 +1,103 @@
+package btree_test
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMap(t *testing.T) {
+	_, err := btree.NewMap[int, string](1)
+	require.Error(t, err)
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			m, err := btree.NewMap[string, int](minDegree)
+			require.NoError(t, err)
+
+			_, _, ok := m.Min()
+			require.False(t, ok)
+
+			want := make(map[string]int)
+			for i := 0; i < 3000; i++ {
+				k, v := fmt.Sprint(rng.Intn(1000)), rng.Int()
+				prev, existed := want[k]
+
+				old, replaced := m.Set(k, v)
+				require.Equal(t, existed, replaced)
+				require.Equal(t, prev, old)
+
+				want[k] = v
+			}
+
+			require.Equal(t, len(want), m.Len())
+
+			for k, v := range want {
+				got, ok := m.Get(k)
+				require.True(t, ok)
+				require.Equal(t, v, got)
+				require.True(t, m.Has(k))
+			}
+
+			_, ok = m.Get("missing")
+			require.False(t, ok)
+
+			keys := make([]string, 0, len(want))
+			for k := range want {
+				keys = append(keys, k)
+			}
+
+			sort.Strings(keys)
+
+			k, v, ok := m.Min()
+			require.True(t, ok)
+			require.Equal(t, keys[0], k)
+			require.Equal(t, want[k], v)
+
+			k, v, ok = m.Max()
+			require.True(t, ok)
+			require.Equal(t, keys[len(keys)-1], k)
+			require.Equal(t, want[k], v)
+
+			var got []string
+			for k, v := range m.All() {
+				require.Equal(t, want[k], v)
+				got = append(got, k)
+			}
+
+			require.Equal(t, keys, got)
+
+			got = got[:0]
+			for k := range m.Backward() {
+				got = append(got, k)
+			}
+
+			for i, k := range keys {
+				require.Equal(t, k, got[len(got)-1-i])
+			}
+
+			got = got[:0]
+			m.AscendRange("3", "5", func(k string, _ int) bool {
+				got = append(got, k)
+				return true
+			})
+
+			lo, hi := sort.SearchStrings(keys, "3"), sort.SearchStrings(keys, "5")
+			require.Equal(t, keys[lo:hi], got)
+
+			for _, k := range keys[:len(keys)/2] {
+				v, ok := m.Delete(k)
+				require.True(t, ok)
+				require.Equal(t, want[k], v)
+				require.False(t, m.Has(k))
+			}
+
+			_, ok = m.Delete(keys[0])
+			require.False(t, ok)
+			require.Equal(t, len(keys)-len(keys)/2, m.Len())
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package btree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// ErrClosed is returned when using a FlatTree that has been closed.
+var ErrClosed = errors.New("tree closed")
+
+// OpenMmap returns a FlatTree over the file at path, holding the result of
+// MarshalFlatBuffer, as OpenFlatBuffer does. The file is mapped into memory
+// read-only rather than read, so the operating system loads its pages only as
+// Search or Ascend touch them, which makes opening a large read-only index
+// nearly instant without ever loading its nodes into heap objects. On
+// platforms without memory mapping, the file is read as a whole instead. The
+// file must not be modified while it is mapped, and the FlatTree must be
+// closed once no longer used to unmap it.
+func (bt *BTree) OpenMmap(path string) (*FlatTree, error) {
+	if bt.codec == nil {
+		return nil, ErrNoCodec
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// the mapping outlives the file descriptor
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size() > math.MaxUint32 {
+		return nil, fmt.Errorf("%w: file too large: %d bytes", ErrInvalidEncoding, info.Size())
+	}
+
+	data, unmap, err := mmap(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+
+	ft, err := bt.OpenFlatBuffer(data)
+	if err != nil {
+		_ = unmap()
+		return nil, err
+	}
+
+	ft.unmap = unmap
+	return ft, nil
+}
+
+// Close releases the FlatTree, unmapping the file of one returned by OpenMmap,
+// after which its methods return ErrClosed. Close must not be called while
+// other methods are in progress, and if the Codec retains the bytes it decodes
+// entries from, the entries of a mapped FlatTree must not be used after Close.
+func (ft *FlatTree) Close() error {
+	if ft.closed {
+		return ErrClosed
+	}
+
+	ft.closed, ft.data = true, nil
+	if ft.unmap != nil {
+		return ft.unmap()
+	}
+
+	return nil
+}
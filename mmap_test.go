@@ -0,0 +1,66 @@
+package btree_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenMmap(t *testing.T) {
+	bt, err := btree.New(11, btree.WithCodec(testCodec{}))
+	require.NoError(t, err)
+
+	for i := uint64(0); i < 5000; i++ {
+		bt.Insert(testEntry{key: 3 * i, value: i})
+	}
+
+	data, err := bt.MarshalFlatBuffer()
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "tree")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	ft, err := bt.OpenMmap(path)
+	require.NoError(t, err)
+	require.Equal(t, 5000, ft.Size())
+
+	for k := uint64(0); k < 15010; k += 5 {
+		got, err := ft.Search(testEntry{key: k})
+		require.NoError(t, err)
+		require.Equal(t, bt.Search(testEntry{key: k}), got)
+	}
+
+	var got []btree.Entry
+	require.NoError(t, ft.AscendRange(testEntry{key: 100}, testEntry{key: 9000}, func(e btree.Entry) bool {
+		got = append(got, e)
+		return true
+	}))
+	require.Equal(t, bt.Entries(testEntry{key: 100}, testEntry{key: 9000}), got)
+
+	require.NoError(t, ft.Close())
+	require.True(t, errors.Is(ft.Close(), btree.ErrClosed))
+
+	_, err = ft.Search(testEntry{key: 3})
+	require.True(t, errors.Is(err, btree.ErrClosed))
+	require.True(t, errors.Is(ft.Ascend(func(btree.Entry) bool { return true }), btree.ErrClosed))
+
+	// files that do not hold a FlatBuffer are rejected
+	empty := filepath.Join(t.TempDir(), "empty")
+	require.NoError(t, os.WriteFile(empty, nil, 0o644))
+
+	_, err = bt.OpenMmap(empty)
+	require.True(t, errors.Is(err, btree.ErrInvalidEncoding))
+
+	_, err = bt.OpenMmap(filepath.Join(t.TempDir(), "missing"))
+	require.True(t, errors.Is(err, os.ErrNotExist))
+
+	noCodec, err := btree.New(3)
+	require.NoError(t, err)
+
+	_, err = noCodec.OpenMmap(path)
+	require.True(t, errors.Is(err, btree.ErrNoCodec))
+}
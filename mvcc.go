@@ -0,0 +1,112 @@
+package btree
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrVersionUnavailable is returned when reading a version of a BTree that it
+// does not retain, either because it has not been written yet or because it
+// has fallen out of the history.
+var ErrVersionUnavailable = errors.New("version unavailable")
+
+// history retains the snapshots of the most recent versions of a BTree in a
+// ring, where the snapshot of version v lies at index v modulo its length.
+type history struct {
+	mu        sync.RWMutex
+	snapshots []*BTree
+}
+
+// newHistory returns a history retaining n versions, or nil if n is not
+// positive.
+func newHistory(n int) *history {
+	if n <= 0 {
+		return nil
+	}
+
+	return &history{snapshots: make([]*BTree, n)}
+}
+
+// newLike returns an empty history retaining as many versions as h.
+func (h *history) newLike() *history {
+	if h == nil {
+		return nil
+	}
+
+	return newHistory(len(h.snapshots))
+}
+
+// add retains the given snapshot, evicting the oldest one retained if the
+// history is full.
+func (h *history) add(s *BTree) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.snapshots[s.version%uint64(len(h.snapshots))] = s
+}
+
+// get returns the snapshot of the given version or nil if it is not retained.
+func (h *history) get(version uint64) *BTree {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if s := h.snapshots[version%uint64(len(h.snapshots))]; s != nil && s.version == version {
+		return s
+	}
+
+	return nil
+}
+
+// Version returns the version of the latest write to the BTree, counting from
+// 0 for the empty BTree. For a BTree created without WithSnapshotReads or
+// WithVersionHistory, it is always 0.
+func (bt *BTree) Version() uint64 {
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+	return bt.version
+}
+
+// GetAt returns the Entry equal to the provided Entry as of the given version
+// of the BTree, or nil if no such Entry existed then or the Entry is nil. Reads
+// of past versions do not block, nor are they blocked by, writes. An error
+// wrapping ErrVersionUnavailable is returned if the version is not retained,
+// see WithVersionHistory.
+func (bt *BTree) GetAt(version uint64, e Entry) (Entry, error) {
+	s, err := bt.at(version)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Search(e), nil
+}
+
+// AscendAt calls fn for every Entry of the given version of the BTree in
+// ascending order, as GetAt reads it. The traversal stops as soon as fn
+// returns false.
+func (bt *BTree) AscendAt(version uint64, fn func(Entry) bool) error {
+	s, err := bt.at(version)
+	if err != nil {
+		return err
+	}
+
+	s.Ascend(fn)
+	return nil
+}
+
+// at returns the retained snapshot of the given version.
+func (bt *BTree) at(version uint64) (*BTree, error) {
+	if s := bt.history.get(version); s != nil {
+		return s, nil
+	}
+
+	return nil, fmt.Errorf("%w: %d", ErrVersionUnavailable, version)
+}
@@ -0,0 +1,119 @@
+package btree_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionHistory(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree, btree.WithVersionHistory(10))
+			require.NoError(t, err)
+			require.Zero(t, bt.Version())
+
+			// version i holds the keys below i
+			for i := uint64(0); i < 100; i++ {
+				bt.Insert(testEntry{key: i})
+				require.Equal(t, i+1, bt.Version())
+			}
+
+			for v := uint64(91); v <= 100; v++ {
+				e, err := bt.GetAt(v, testEntry{key: v - 1})
+				require.NoError(t, err)
+				require.NotNil(t, e)
+
+				e, err = bt.GetAt(v, testEntry{key: v})
+				require.NoError(t, err)
+				require.Nil(t, e)
+
+				n := uint64(0)
+				require.NoError(t, bt.AscendAt(v, func(e btree.Entry) bool {
+					require.Equal(t, n, e.(testEntry).key)
+					n++
+					return true
+				}))
+				require.Equal(t, v, n)
+			}
+
+			// versions fall out of the history and future ones do not exist yet
+			for _, v := range []uint64{0, 90, 101} {
+				_, err := bt.GetAt(v, testEntry{})
+				require.True(t, errors.Is(err, btree.ErrVersionUnavailable))
+				require.True(t, errors.Is(bt.AscendAt(v, func(btree.Entry) bool { return true }), btree.ErrVersionUnavailable))
+			}
+
+			// a batch produces a single version, and a write changing nothing
+			// none at all
+			var wb btree.WriteBatch
+			for i := uint64(0); i < 50; i++ {
+				wb.Delete(testEntry{key: i})
+			}
+
+			bt.Apply(&wb)
+			require.Equal(t, uint64(101), bt.Version())
+			bt.Has(testEntry{key: 0})
+			require.Equal(t, uint64(101), bt.Version())
+
+			e, err := bt.GetAt(100, testEntry{key: 0})
+			require.NoError(t, err)
+			require.NotNil(t, e)
+
+			e, err = bt.GetAt(101, testEntry{key: 0})
+			require.NoError(t, err)
+			require.Nil(t, e)
+		})
+	}
+
+	bt, err := btree.New(2)
+	require.NoError(t, err)
+	bt.Insert(testEntry{})
+	require.Zero(t, bt.Version())
+
+	_, err = bt.GetAt(0, testEntry{})
+	require.True(t, errors.Is(err, btree.ErrVersionUnavailable))
+}
+
+func TestVersionHistoryConcurrent(t *testing.T) {
+	bt, err := btree.New(3, btree.WithVersionHistory(1000))
+	require.NoError(t, err)
+
+	const n = 2000
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		for i := uint64(0); i < n; i++ {
+			bt.Insert(testEntry{key: i})
+		}
+	}()
+
+	// a lagging reader observes every retained version consistently
+	go func() {
+		defer wg.Done()
+
+		for bt.Version() < n {
+			v := bt.Version()
+
+			count := uint64(0)
+			err := bt.AscendAt(v, func(btree.Entry) bool {
+				count++
+				return true
+			})
+
+			if err == nil && count != v {
+				t.Errorf("version %d holds %d entries", v, count)
+			}
+		}
+	}()
+
+	wg.Wait()
+}
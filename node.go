@@ -17,14 +17,51 @@ type (
 		Compare(Entry) int
 	}
 
+	// Key defines the interface contract of a lightweight lookup key, which can
+	// be compared against the entries of a BTree without being an Entry itself,
+	// e.g. to avoid constructing an expensive value just to perform a search.
+	Key interface {
+		// CompareEntry compares a receiver Key with an Entry argument, such that 0
+		// is returned if they're equal, -1 if the receiver Key is less than the
+		// Entry argument and 1 otherwise. The order must agree with that of the
+		// BTree being searched.
+		CompareEntry(Entry) int
+	}
+
+	// PrefixEntry defines the interface contract of entries which are ordered by
+	// a byte-string key, allowing their BTree to be scanned by key prefix.
+	PrefixEntry interface {
+		Entry
+
+		// ComparePrefix compares the first len(prefix) bytes of the receiver's key
+		// with prefix, such that 0 is returned if the key starts with prefix, -1
+		// if the key is less than prefix and 1 otherwise.
+		ComparePrefix(prefix []byte) int
+	}
+
+	// compareFunc defines a function which orders two entries, such that 0 is
+	// returned if they're equal, -1 if a is less than b and 1 otherwise.
+	compareFunc func(a, b Entry) int
+
 	nodes []*node
 
 	node struct {
 		entries  Entries
 		children nodes
+		cow      *copyOnWriteContext
+
+		// count holds the total number of entries in the subtree rooted at the
+		// node, including the node's own entries.
+		count int
 	}
 )
 
+// compareEntries is the default compareFunc which delegates to the Compare
+// method of the entries themselves.
+func compareEntries(a, b Entry) int {
+	return a.Compare(b)
+}
+
 func newNode() *node {
 	return &node{
 		entries:  make(Entries, 0),
@@ -32,9 +69,107 @@ func newNode() *node {
 	}
 }
 
-func (n *node) clear() {
-	n.entries = nil
-	n.children = nil
+// maxFreeListSize defines the maximum number of nodes a freeList retains.
+const maxFreeListSize = 1024
+
+// freeList retains discarded nodes, so their allocations can be reused by
+// subsequently created nodes instead of being left for the garbage collector.
+type freeList struct {
+	nodes nodes
+}
+
+// newNode returns a recycled node if one is available or a newly allocated
+// node otherwise.
+func (fl *freeList) newNode() *node {
+	if len(fl.nodes) == 0 {
+		return newNode()
+	}
+
+	i := len(fl.nodes) - 1
+	n := fl.nodes[i]
+	fl.nodes[i] = nil
+	fl.nodes = fl.nodes[:i]
+
+	return n
+}
+
+// free resets the node and retains it for reuse. It returns false if the
+// freeList is full, in which case the node is left for the garbage collector.
+func (fl *freeList) free(n *node) bool {
+	if len(fl.nodes) >= maxFreeListSize {
+		return false
+	}
+
+	for i := range n.entries {
+		n.entries[i] = nil
+	}
+
+	for i := range n.children {
+		n.children[i] = nil
+	}
+
+	n.entries = n.entries[:0]
+	n.children = n.children[:0]
+	n.cow = nil
+	n.count = 0
+	fl.nodes = append(fl.nodes, n)
+
+	return true
+}
+
+// copyOnWriteContext identifies the nodes a BTree owns and may therefore mutate
+// in place. Nodes owned by any other context may be shared with a clone and must
+// be copied into the BTree's context before being modified.
+type copyOnWriteContext struct {
+	freeList *freeList
+}
+
+// newNode returns an empty node owned by the context.
+func (c *copyOnWriteContext) newNode() *node {
+	n := c.freeList.newNode()
+	n.cow = c
+	return n
+}
+
+// freeNode releases a discarded node into the free list if, and only if, it is
+// owned by the context, as a node owned by another context may still be
+// reachable from a clone.
+func (c *copyOnWriteContext) freeNode(n *node) {
+	if n.cow == c {
+		c.freeList.free(n)
+	}
+}
+
+// mutableFor returns n if it is owned by the given context or a shallow copy of
+// n owned by the context otherwise.
+func (n *node) mutableFor(cow *copyOnWriteContext) *node {
+	if n.cow == cow {
+		return n
+	}
+
+	out := cow.newNode()
+	out.entries = append(out.entries, n.entries...)
+	out.children = append(out.children, n.children...)
+	out.count = n.count
+
+	return out
+}
+
+// mutableChild ensures the child at index i is owned by the same context as n,
+// which must itself be mutable, and returns it.
+func (n *node) mutableChild(i int) *node {
+	child := n.children[i].mutableFor(n.cow)
+	n.children[i] = child
+
+	return child
+}
+
+// recount recomputes the count of n from its entries and its children's counts.
+func (n *node) recount() {
+	n.count = n.numEntries()
+	for _, child := range n.children {
+		n.count += child.count
+	}
 }
 
 func (n *node) leaf() bool {
@@ -49,14 +184,14 @@ func (n *node) numChildren() int {
 	return len(n.children)
 }
 
-func (n *node) get(e Entry) (Entry, int) {
+func (n *node) get(cmp compareFunc, e Entry) (Entry, int) {
 	// binary search for the smallest index i, s.t. n.entries[i] >= e
 	i := sort.Search(n.numEntries(), func(i int) bool {
-		return n.entries[i].Compare(e) >= 0 // n.entries[i] >= e
+		return cmp(n.entries[i], e) >= 0 // n.entries[i] >= e
 	})
 
 	// if the index i is in bounds and equals the provided entry, return that entry
-	if i < n.numEntries() && n.entries[i].Compare(e) == 0 {
+	if i < n.numEntries() && cmp(n.entries[i], e) == 0 {
 		return n.entries[i], i
 	}
 
@@ -64,21 +199,62 @@ func (n *node) get(e Entry) (Entry, int) {
 	return nil, i
 }
 
-func (n *node) insert(e Entry) {
-	found, i := n.get(e)
-	if found != nil && i >= 0 {
-		// The entry already exists in the node, so we simply overwrite it.
-		n.entries[i] = e
-		return
+// find returns the entry in the subtree rooted at n for which cmp returns 0,
+// where cmp reports how the sought position compares to a given entry and must
+// be monotone in the order of the entries.
+func (n *node) find(cmp func(Entry) int) Entry {
+	for {
+		// binary search for the smallest index i, s.t. n.entries[i] >= the target
+		i := sort.Search(n.numEntries(), func(i int) bool {
+			return cmp(n.entries[i]) <= 0
+		})
+
+		if i < n.numEntries() && cmp(n.entries[i]) == 0 {
+			return n.entries[i]
+		}
+
+		if n.leaf() {
+			return nil
+		}
+
+		n = n.children[i]
 	}
+}
+
+// seek returns the smallest entry in the subtree rooted at n for which cmp
+// returns 0 or less, where cmp is as for find, or nil if there is none.
+func (n *node) seek(cmp func(Entry) int) Entry {
+	var candidate Entry
+	for {
+		i := sort.Search(n.numEntries(), func(i int) bool {
+			return cmp(n.entries[i]) <= 0
+		})
+
+		if i < n.numEntries() {
+			candidate = n.entries[i]
+		}
+
+		if n.leaf() {
+			return candidate
+		}
+
+		n = n.children[i]
+	}
+}
 
+func (n *node) insertAt(i int, e Entry) {
 	n.entries = append(n.entries, nil)
 	copy(n.entries[i+1:], n.entries[i:])
 	n.entries[i] = e
 }
 
-func (n *node) replaceChildAt(i int, child *node) {
-	n.children[i] = child
+func (n *node) removeAt(i int) Entry {
+	e := n.entries[i]
+	copy(n.entries[i:], n.entries[i+1:])
+	n.entries[len(n.entries)-1] = nil
+	n.entries = n.entries[:len(n.entries)-1]
+
+	return e
 }
 
 func (n *node) insertChildAt(i int, child *node) {
@@ -87,31 +263,490 @@ func (n *node) insertChildAt(i int, child *node) {
 	n.children[i] = child
 }
 
-func (n *node) split() (left *node, right *node, mid Entry) {
+func (n *node) removeChildAt(i int) *node {
+	child := n.children[i]
+	copy(n.children[i:], n.children[i+1:])
+	n.children[len(n.children)-1] = nil
+	n.children = n.children[:len(n.children)-1]
+
+	return child
+}
+
+// split splits n around its median entry, keeping the smaller entries (and
+// their children) in n and moving the larger ones into a new node. It returns
+// the median entry and the new node. The node n must be mutable.
+func (n *node) split() (Entry, *node) {
 	midEntryIdx := n.numEntries() / 2
+	midEntry := n.entries[midEntryIdx]
+
+	right := n.cow.newNode()
+	right.entries = append(right.entries, n.entries[midEntryIdx+1:]...)
+	n.truncate(midEntryIdx)
+
+	if !n.leaf() {
+		right.children = append(right.children, n.children[midEntryIdx+1:]...)
+		n.truncateChildren(midEntryIdx + 1)
+	}
+
+	right.recount()
+	n.count -= right.count + 1
+
+	return midEntry, right
+}
+
+// truncate removes all entries at index i and beyond.
+func (n *node) truncate(i int) {
+	for j := i; j < n.numEntries(); j++ {
+		n.entries[j] = nil
+	}
+
+	n.entries = n.entries[:i]
+}
+
+// truncateChildren removes all children at index i and beyond.
+func (n *node) truncateChildren(i int) {
+	for j := i; j < n.numChildren(); j++ {
+		n.children[j] = nil
+	}
+
+	n.children = n.children[:i]
+}
+
+// min returns the smallest entry in the subtree rooted at n or nil if the
+// subtree is empty.
+func (n *node) min() Entry {
+	curr := n
+	for !curr.leaf() {
+		curr = curr.children[0]
+	}
+
+	if curr.numEntries() == 0 {
+		return nil
+	}
+
+	return curr.entries[0]
+}
+
+// max returns the largest entry in the subtree rooted at n or nil if the
+// subtree is empty.
+func (n *node) max() Entry {
+	curr := n
+	for !curr.leaf() {
+		curr = curr.children[curr.numChildren()-1]
+	}
+
+	if curr.numEntries() == 0 {
+		return nil
+	}
+
+	return curr.entries[curr.numEntries()-1]
+}
+
+// ceil returns the smallest entry in the subtree rooted at n that is greater
+// than or equal to e, or strictly greater than e if inclusive is false. It
+// returns nil if no such entry exists. The entry e itself need not exist in the
+// subtree.
+func (n *node) ceil(cmp compareFunc, e Entry, inclusive bool) Entry {
+	var found Entry
+
+	curr := n
+	for curr != nil {
+		// binary search for the smallest index i, s.t. curr.entries[i] >= e, or
+		// curr.entries[i] > e if not inclusive
+		i := sort.Search(curr.numEntries(), func(i int) bool {
+			c := cmp(curr.entries[i], e)
+			return c > 0 || (inclusive && c == 0)
+		})
+
+		if i < curr.numEntries() {
+			found = curr.entries[i]
+
+			if inclusive && cmp(found, e) == 0 {
+				break
+			}
+		}
+
+		if curr.leaf() {
+			break
+		}
+
+		curr = curr.children[i]
+	}
 
-	leftEntries := make(Entries, len(n.entries[:midEntryIdx]))
-	copy(leftEntries[:], n.entries[:midEntryIdx])
+	return found
+}
+
+// floor returns the largest entry in the subtree rooted at n that is less than
+// or equal to e, or strictly less than e if inclusive is false. It returns nil if
+// no such entry exists. The entry e itself need not exist in the subtree.
+func (n *node) floor(cmp compareFunc, e Entry, inclusive bool) Entry {
+	var found Entry
+
+	curr := n
+	for curr != nil {
+		// binary search for the smallest index i, s.t. curr.entries[i] > e, or
+		// curr.entries[i] >= e if not inclusive, so that i-1 is the candidate
+		i := sort.Search(curr.numEntries(), func(i int) bool {
+			c := cmp(curr.entries[i], e)
+			return c > 0 || (!inclusive && c == 0)
+		})
+
+		if i > 0 {
+			found = curr.entries[i-1]
+
+			if inclusive && cmp(found, e) == 0 {
+				break
+			}
+		}
+
+		if curr.leaf() {
+			break
+		}
+
+		curr = curr.children[i]
+	}
+
+	return found
+}
+
+// getAll looks up many entries in a single descent of the subtree rooted at n.
+// The indices in order refer to keys and must sort them in ascending order. For
+// every index k, out[k] is set to the entry equal to keys[k], if one exists. The
+// keys are distributed among the children in a single pass over the entries of
+// n, so that every node is visited at most once.
+func (n *node) getAll(cmp compareFunc, keys Entries, order []int, out Entries) {
+	j := 0
+	for i := 0; i <= n.numEntries() && j < len(order); i++ {
+		// the run of keys smaller than the i-th entry belongs to the i-th child
+		start := j
+		for j < len(order) && (i == n.numEntries() || cmp(keys[order[j]], n.entries[i]) < 0) {
+			j++
+		}
+
+		if j > start && !n.leaf() {
+			n.children[i].getAll(cmp, keys, order[start:j], out)
+		}
+
+		for j < len(order) && i < n.numEntries() && cmp(keys[order[j]], n.entries[i]) == 0 {
+			out[order[j]] = n.entries[i]
+			j++
+		}
+	}
+}
+
+// adjacent returns the largest entry strictly less than e and the smallest
+// entry strictly greater than e in the subtree rooted at n, in a single descent.
+// Either is nil if no such entry exists.
+func (n *node) adjacent(cmp compareFunc, e Entry) (prev, next Entry) {
+	for {
+		// binary search for the smallest index i, s.t. n.entries[i] >= e
+		i := sort.Search(n.numEntries(), func(i int) bool {
+			return cmp(n.entries[i], e) >= 0
+		})
+
+		found := i < n.numEntries() && cmp(n.entries[i], e) == 0
+
+		if found && !n.leaf() {
+			// the neighbours are the extremes of the subtrees around e
+			return n.children[i].max(), n.children[i+1].min()
+		}
+
+		// any neighbour found further down is closer than these candidates
+		if i > 0 {
+			prev = n.entries[i-1]
+		}
+
+		j := i
+		if found {
+			j++
+		}
+
+		if j < n.numEntries() {
+			next = n.entries[j]
+		}
+
+		if n.leaf() {
+			return prev, next
+		}
+
+		n = n.children[i]
+	}
+}
+
+// rank returns the number of entries in the subtree rooted at n that are
+// strictly less than e, using the subtree counts of the children to the left of
+// the path to e. The entry e itself need not exist in the subtree.
+func (n *node) rank(cmp compareFunc, e Entry) int {
+	var r int
+
+	curr := n
+	for {
+		found, i := curr.get(cmp, e)
+		r += i
+
+		if curr.leaf() {
+			return r
+		}
+
+		for _, child := range curr.children[:i] {
+			r += child.count
+		}
+
+		if found != nil {
+			return r + curr.children[i].count
+		}
+
+		curr = curr.children[i]
+	}
+}
+
+// at returns the entry at index i, in ascending order, of the subtree rooted at
+// n, using the subtree counts to skip over children. The index must be within
+// bounds.
+func (n *node) at(i int) Entry {
+	curr := n
+
+descend:
+	for !curr.leaf() {
+		for j, child := range curr.children {
+			if i < child.count {
+				curr = child
+				continue descend
+			}
+
+			i -= child.count
+			if i == 0 {
+				return curr.entries[j]
+			}
+
+			i--
+		}
+	}
+
+	return curr.entries[i]
+}
+
+// remove removes the entry equal to e from the subtree rooted at n and returns
+// it or nil if no such entry exists. Prior to descending into a child, the child
+// is guaranteed to contain at least t entries, so removing an entry from it can
+// never cause an underflow. The caller is responsible for collapsing the root
+// if it becomes empty.
+func (n *node) remove(cmp compareFunc, e Entry, t int) Entry {
+	found, i := n.get(cmp, e)
+
+	if n.leaf() {
+		if found == nil {
+			return nil
+		}
+
+		n.count--
+		return n.removeAt(i)
+	}
+
+	if n.children[i].numEntries() < t {
+		// The child we must descend into is at minimum capacity. Grow it and retry
+		// as growing may move entries (including e) between n and its children.
+		n.growChild(i, t)
+		return n.remove(cmp, e, t)
+	}
 
-	rightEntries := make(Entries, len(n.entries[midEntryIdx+1:]))
-	copy(rightEntries[:], n.entries[midEntryIdx+1:])
+	child := n.mutableChild(i)
 
-	leftNode := newNode()
-	leftNode.entries = leftEntries
+	if found != nil {
+		// The entry exists in an internal node, so replace it with its predecessor,
+		// i.e. the maximum entry in the left child, which we know has at least t
+		// entries.
+		n.entries[i] = child.removeMax(t)
+		n.count--
+		return found
+	}
+
+	removed := child.remove(cmp, e, t)
+	if removed != nil {
+		n.count--
+	}
+
+	return removed
+}
+
+// removeMin removes and returns the smallest entry in the subtree rooted at n
+// or nil if the subtree is empty. It upholds the same guarantees as remove.
+func (n *node) removeMin(t int) Entry {
+	if n.leaf() {
+		if n.numEntries() == 0 {
+			return nil
+		}
+
+		n.count--
+		return n.removeAt(0)
+	}
+
+	if n.children[0].numEntries() < t {
+		n.growChild(0, t)
+		return n.removeMin(t)
+	}
+
+	n.count--
+	return n.mutableChild(0).removeMin(t)
+}
+
+// removeMax removes and returns the largest entry in the subtree rooted at n
+// or nil if the subtree is empty. It upholds the same guarantees as remove.
+func (n *node) removeMax(t int) Entry {
+	if n.leaf() {
+		if n.numEntries() == 0 {
+			return nil
+		}
+
+		n.count--
+		return n.removeAt(n.numEntries() - 1)
+	}
+
+	i := n.numChildren() - 1
+	if n.children[i].numEntries() < t {
+		n.growChild(i, t)
+		return n.removeMax(t)
+	}
+
+	n.count--
+	return n.mutableChild(i).removeMax(t)
+}
+
+// growChild ensures the child at index i contains at least t entries by either
+// borrowing an entry from an adjacent sibling through n or by merging the child
+// with an adjacent sibling and the separating entry in n. The node n must be
+// mutable.
+func (n *node) growChild(i, t int) {
+	child := n.mutableChild(i)
+
+	switch {
+	case i > 0 && n.children[i-1].numEntries() >= t:
+		// Borrow from the left sibling: the separator moves down into the child
+		// and the left sibling's largest entry moves up to replace it.
+		left := n.mutableChild(i - 1)
+
+		child.insertAt(0, n.entries[i-1])
+		n.entries[i-1] = left.removeAt(left.numEntries() - 1)
+		moved := 1
+
+		if !left.leaf() {
+			grandchild := left.removeChildAt(left.numChildren() - 1)
+			child.insertChildAt(0, grandchild)
+			moved += grandchild.count
+		}
+
+		child.count += moved
+		left.count -= moved
+
+	case i < n.numEntries() && n.children[i+1].numEntries() >= t:
+		// Borrow from the right sibling: the separator moves down into the child
+		// and the right sibling's smallest entry moves up to replace it.
+		right := n.mutableChild(i + 1)
+
+		child.entries = append(child.entries, n.entries[i])
+		n.entries[i] = right.removeAt(0)
+		moved := 1
+
+		if !right.leaf() {
+			grandchild := right.removeChildAt(0)
+			child.children = append(child.children, grandchild)
+			moved += grandchild.count
+		}
+
+		child.count += moved
+		right.count -= moved
+
+	default:
+		// Both siblings are at minimum capacity, so merge the child with one of
+		// them, pulling the separating entry down from n.
+		if i >= n.numEntries() {
+			i--
+		}
+
+		left, right := n.mutableChild(i), n.children[i+1]
+
+		left.entries = append(left.entries, n.removeAt(i))
+		left.entries = append(left.entries, right.entries...)
+		left.children = append(left.children, right.children...)
+		left.count += right.count + 1
+
+		n.removeChildAt(i + 1)
+		n.cow.freeNode(right)
+	}
+}
+
+// ascendRange calls fn for every entry e in the subtree rooted at n, s.t.
+// lo <= e <= hi, or lo <= e < hi if inclusive is false, in ascending order. A
+// nil bound is treated as unbounded. It returns false if the traversal was
+// stopped, either by fn or by reaching an entry beyond hi, in which case no
+// further entries should be visited.
+func (n *node) ascendRange(cmp compareFunc, lo, hi Entry, inclusive bool, fn func(Entry) bool) bool {
+	// binary search for the smallest index i, s.t. n.entries[i] >= lo
+	i := 0
+	if lo != nil {
+		i = sort.Search(n.numEntries(), func(i int) bool {
+			return cmp(n.entries[i], lo) >= 0
+		})
+	}
+
+	for ; i <= n.numEntries(); i++ {
+		if !n.leaf() && !n.children[i].ascendRange(cmp, lo, hi, inclusive, fn) {
+			return false
+		}
+
+		if i == n.numEntries() {
+			break
+		}
+
+		e := n.entries[i]
+		if hi != nil {
+			if c := cmp(e, hi); c > 0 || (!inclusive && c == 0) {
+				return false
+			}
+		}
+
+		if !fn(e) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// descendRange calls fn for every entry e in the subtree rooted at n, s.t.
+// lo <= e <= hi, or lo <= e < hi if inclusive is false, in descending order. A
+// nil bound is treated as unbounded. It returns false if the traversal was
+// stopped, either by fn or by reaching an entry below lo, in which case no
+// further entries should be visited.
+func (n *node) descendRange(cmp compareFunc, lo, hi Entry, inclusive bool, fn func(Entry) bool) bool {
+	// binary search for the smallest index j, s.t. n.entries[j] > hi, or
+	// n.entries[j] >= hi if not inclusive
+	j := n.numEntries()
+	if hi != nil {
+		j = sort.Search(n.numEntries(), func(i int) bool {
+			c := cmp(n.entries[i], hi)
+			return c > 0 || (!inclusive && c == 0)
+		})
+	}
 
-	rightNode := newNode()
-	rightNode.entries = rightEntries
+	for i := j; i >= 0; i-- {
+		if !n.leaf() && !n.children[i].descendRange(cmp, lo, hi, inclusive, fn) {
+			return false
+		}
 
-	if n.numChildren() > 0 {
-		leftChildren := make(nodes, len(n.children[:midEntryIdx+1]))
-		copy(leftChildren[:], n.children[:midEntryIdx+1])
+		if i == 0 {
+			break
+		}
 
-		rightChildren := make(nodes, len(n.children[midEntryIdx+1:]))
-		copy(rightChildren[:], n.children[midEntryIdx+1:])
+		e := n.entries[i-1]
+		if lo != nil && cmp(e, lo) < 0 {
+			return false
+		}
 
-		leftNode.children = leftChildren
-		rightNode.children = rightChildren
+		if !fn(e) {
+			return false
+		}
 	}
 
-	return leftNode, rightNode, n.entries[midEntryIdx]
+	return true
 }
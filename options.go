@@ -0,0 +1,109 @@
+package btree
+
+// Option configures a BTree at construction time, e.g. through New.
+type Option func(*options)
+
+// options holds the configuration assembled from a set of Options.
+type options struct {
+	descending bool
+	noLocking  bool
+	lockStats  bool
+	snapshots  bool
+	versions   int
+	codec      Codec
+	jsonCodec  Codec
+}
+
+// WithDescending makes the BTree maintain its entries in descending order, i.e.
+// the reverse of the order given by Entry.Compare or the comparator of the
+// BTree. Every method then follows that order, so Min returns the largest Entry
+// and Ascend iterates from the largest Entry to the smallest. SearchKey,
+// AscendPrefix and AscendFields, which rely on the ascending order of the
+// entries themselves, are not supported on such a BTree.
+func WithDescending() Option {
+	return func(o *options) {
+		o.descending = true
+	}
+}
+
+// WithNoLocking makes the BTree skip all locking, which removes the cost of its
+// mutex from every operation. Such a BTree is not safe for concurrent use: as
+// with a plain map, the caller must ensure that writes do not happen
+// concurrently with any other operation. Clones and trees derived from the
+// BTree, such as the results of Merge, do not lock either.
+func WithNoLocking() Option {
+	return func(o *options) {
+		o.noLocking = true
+	}
+}
+
+// WithLockStats makes the BTree record how often its lock is acquired and how
+// long acquisitions wait if the lock is held, as reported by Stats. Recording
+// costs a few atomic operations per acquisition. Clones and trees derived from
+// the BTree record their own LockStats. It has no effect WithNoLocking.
+func WithLockStats() Option {
+	return func(o *options) {
+		o.lockStats = true
+	}
+}
+
+// WithSnapshotReads makes readers of the BTree never block writers, nor each
+// other. Every write copies the nodes along its path instead of modifying them
+// in place and then atomically publishes the new root, so reads such as Search
+// or Ascend run without any lock against an immutable snapshot of the BTree as
+// of the last completed write. A long scan thus neither stalls writers nor
+// observes their changes. The price is a copy of O(log n) nodes per write.
+// This is read-copy-update: since replaced nodes are reclaimed by the garbage
+// collector once the last reader drops them, no reader ever acquires the
+// mutex, which makes the option suited to read-heavy workloads contending on
+// it.
+func WithSnapshotReads() Option {
+	return func(o *options) {
+		o.snapshots = true
+	}
+}
+
+// WithVersionHistory makes the BTree retain its n most recent versions for
+// reading through GetAt and AscendAt. Every write, or group of writes applied
+// at once such as by Apply, produces a new version, numbered consecutively
+// from 0 for the empty BTree. Clones and trees derived from the BTree retain as
+// many versions, numbered anew from their first write. This implies
+// WithSnapshotReads, as versions are retained as the immutable snapshots it
+// publishes. A non-positive n retains no history.
+func WithVersionHistory(n int) Option {
+	return func(o *options) {
+		o.versions = n
+	}
+}
+
+// WithCodec makes the BTree encode and decode its entries through c when it is
+// serialized, e.g. by MarshalBinary and UnmarshalBinary. If c is returned by
+// EntryCodec, the encodings record its name, so that they can be decoded
+// without configuring c. Clones and trees derived from the BTree share the
+// Codec.
+func WithCodec(c Codec) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
+
+// WithJSONCodec makes the BTree encode and decode its entries through c when it
+// is serialized by MarshalJSON and UnmarshalJSON, where c must produce and
+// accept JSON values. Without it, entries are encoded by encoding/json
+// itself, but cannot be decoded. Clones and trees derived from the BTree share
+// the Codec.
+func WithJSONCodec(c Codec) Option {
+	return func(o *options) {
+		o.jsonCodec = c
+	}
+}
+
+// newOptions returns the configuration assembled from the given Options.
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
@@ -0,0 +1,69 @@
+package btree
+
+import (
+	"runtime"
+	"sync"
+)
+
+// AscendParallel calls fn for every Entry e in the BTree, s.t. from <= e < to,
+// spreading the scan across up to workers goroutines, under the read lock. A
+// nil from or to leaves the respective end of the range unbounded. If workers
+// is less than one, GOMAXPROCS goroutines are used.
+//
+// The range is partitioned by rank, using the subtree counts, into contiguous
+// chunks of equal size, each of which is located in logarithmic time and then
+// scanned in ascending order by a goroutine of its own. Hence fn is called
+// concurrently and entries of different chunks are visited in no particular
+// order. AscendParallel returns once every Entry in the range has been visited.
+func (bt *BTree) AscendParallel(from, to Entry, workers int, fn func(Entry)) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	lo, hi := 0, bt.size
+	if from != nil {
+		lo = bt.root.rank(bt.cmp, from)
+	}
+
+	if to != nil {
+		hi = bt.root.rank(bt.cmp, to)
+	}
+
+	if hi <= lo {
+		return
+	}
+
+	visit := func(e Entry) bool {
+		fn(e)
+		return true
+	}
+
+	chunk := max((hi-lo+workers-1)/workers, minParallelChunk)
+	if chunk >= hi-lo {
+		bt.root.ascendRange(bt.cmp, from, to, false, visit)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for start := lo; start < hi; start += chunk {
+		end := min(start+chunk, hi)
+
+		// The chunk holds the entries at indices start through end-1, bounded
+		// by the entry at index end, which lies beyond the range if end is hi.
+		first, bound := bt.root.at(start), to
+		if end < hi {
+			bound = bt.root.at(end)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bt.root.ascendRange(bt.cmp, first, bound, false, visit)
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,64 @@
+package btree_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAscendParallel(t *testing.T) {
+	const n = 100000
+
+	sorted := make([]btree.Entry, n)
+	for i := range sorted {
+		sorted[i] = testEntry{key: uint64(2 * i)}
+	}
+
+	ranges := []struct {
+		from, to btree.Entry
+	}{
+		{nil, nil},
+		{testEntry{key: 1001}, testEntry{key: 150000}},
+		{testEntry{key: 150000}, nil},
+		{nil, testEntry{key: 20}},
+		{testEntry{key: 500}, testEntry{key: 400}},
+		{testEntry{key: 3 * n}, nil},
+	}
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.BulkLoad(sorted, minDegree)
+			require.NoError(t, err)
+
+			for _, r := range ranges {
+				want := bt.Entries(r.from, r.to)
+
+				for _, workers := range []int{0, 1, 4, 64} {
+					// every Entry in the range is visited exactly once
+					visits := make([]atomic.Int32, n)
+					bt.AscendParallel(r.from, r.to, workers, func(e btree.Entry) {
+						visits[e.(testEntry).key/2].Add(1)
+					})
+
+					var got []btree.Entry
+					for i := range visits {
+						switch visits[i].Load() {
+						case 0:
+
+						case 1:
+							got = append(got, sorted[i])
+
+						default:
+							t.Fatalf("entry %d visited %d times", 2*i, visits[i].Load())
+						}
+					}
+
+					require.Equal(t, want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package btree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The functions in this file write and read tree snapshots in the protobuf wire
+// format, following the Snapshot message of snapshot.proto, so that they can be
+// consumed by any protobuf implementation. The few wire types involved are
+// encoded directly rather than through a protobuf runtime.
+
+const (
+	protoMinDegree = 1
+	protoSize      = 2
+	protoEntries   = 3
+
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// Export writes the BTree to w as a Snapshot message of snapshot.proto, as of a
+// single point in time. As with WriteTo, the entries are written from a lazy
+// Clone, one at a time, and encoded by the Codec of the BTree, and ErrNoCodec
+// is returned if it has none.
+func (bt *BTree) Export(w io.Writer) error {
+	if bt.codec == nil {
+		return ErrNoCodec
+	}
+
+	// the clone is owned by this call, so it is read without locking
+	clone := bt.Clone()
+
+	bw := bufio.NewWriter(w)
+	enc := &encoder{w: bw, codec: bt.codec}
+
+	enc.uvarint(protoMinDegree<<3 | protoVarint)
+	enc.uvarint(uint64(clone.minDegree))
+	enc.uvarint(protoSize<<3 | protoVarint)
+	enc.uvarint(uint64(clone.size))
+
+	clone.root.ascendRange(clone.cmp, nil, nil, true, func(e Entry) bool {
+		enc.uvarint(protoEntries<<3 | protoBytes)
+		enc.entry(e)
+		return enc.err == nil
+	})
+
+	if enc.err != nil {
+		return enc.err
+	}
+
+	return bw.Flush()
+}
+
+// Import replaces the contents of the BTree by the Snapshot message of
+// snapshot.proto read from r until EOF, such as one written by Export. The
+// entries are decoded by the Codec of the BTree, and ErrNoCodec is returned if
+// it has none. They must be in strictly ascending order and are bulk loaded at
+// the minimum degree of the snapshot, or that of the BTree if the snapshot
+// leaves it unset. Unknown fields are skipped. If r does not hold a valid
+// snapshot, an error wrapping ErrInvalidEncoding is returned and the BTree is
+// left untouched.
+func (bt *BTree) Import(r io.Reader) error {
+	if bt.codec == nil {
+		return ErrNoCodec
+	}
+
+	src := &binarySource{r: bufio.NewReader(r), codec: bt.codec}
+	d := &decoder{src: src, validate: bt.validate}
+
+	var (
+		minDegree, size uint64
+		hasSize         bool
+		sorted          []Entry
+	)
+
+	for {
+		tag, err := binary.ReadUvarint(src.r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: failed to read tag: %v", ErrInvalidEncoding, err)
+		}
+
+		switch field, wireType := tag>>3, tag&7; {
+		case field == protoMinDegree && wireType == protoVarint:
+			if minDegree, err = src.uvarint("minimum degree"); err != nil {
+				return err
+			}
+
+		case field == protoSize && wireType == protoVarint:
+			if size, err = src.uvarint("size"); err != nil {
+				return err
+			}
+
+			hasSize = true
+
+		case field == protoEntries && wireType == protoBytes:
+			e, err := d.entry()
+			if err != nil {
+				return err
+			}
+
+			sorted = append(sorted, e)
+
+		default:
+			if err := src.skip(wireType); err != nil {
+				return err
+			}
+		}
+	}
+
+	if hasSize && size != uint64(len(sorted)) {
+		return fmt.Errorf("%w: snapshot holds %d entries but size is %d", ErrInvalidEncoding, len(sorted), size)
+	}
+
+	loaded := bt.newLike()
+	if minDegree != 0 {
+		if minDegree < 2 || minDegree > math.MaxInt32 {
+			return fmt.Errorf("%w: minimum degree out of range: %d", ErrInvalidEncoding, minDegree)
+		}
+
+		loaded.minDegree = int(minDegree)
+	}
+
+	if err := loaded.checkSorted(sorted, 1); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEncoding, err)
+	}
+
+	loaded.load(sorted)
+
+	bt.adopt(loaded.root, loaded.size, loaded.depth, loaded.minDegree, loaded.cow)
+	return nil
+}
+
+// skip skips the value of a field of an unknown number of the given wire type.
+func (src *binarySource) skip(wireType uint64) error {
+	var n int
+	switch wireType {
+	case protoVarint:
+		_, err := src.uvarint("unknown field")
+		return err
+
+	case protoFixed64:
+		n = 8
+
+	case protoFixed32:
+		n = 4
+
+	case protoBytes:
+		l, err := src.int("unknown field length", 0, math.MaxInt32)
+		if err != nil {
+			return err
+		}
+
+		n = l
+
+	default:
+		return fmt.Errorf("%w: unsupported wire type %d", ErrInvalidEncoding, wireType)
+	}
+
+	if _, err := io.CopyN(io.Discard, src.r, int64(n)); err != nil {
+		return fmt.Errorf("%w: truncated unknown field", ErrInvalidEncoding)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package btree_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	bt, err := btree.New(3)
+	require.NoError(t, err)
+	require.True(t, errors.Is(bt.Export(io.Discard), btree.ErrNoCodec))
+	require.True(t, errors.Is(bt.Import(bytes.NewReader(nil)), btree.ErrNoCodec))
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree, btree.WithCodec(testCodec{}))
+			require.NoError(t, err)
+
+			for _, i := range rng.Perm(3000) {
+				bt.Insert(testEntry{uint64(i), rng.Uint64()})
+			}
+
+			var buf bytes.Buffer
+			require.NoError(t, bt.Export(&buf))
+
+			restored, err := btree.New(2, btree.WithCodec(testCodec{}))
+			require.NoError(t, err)
+			restored.Insert(testEntry{key: 1 << 40})
+
+			require.NoError(t, restored.Import(&buf))
+			require.NoError(t, restored.Verify())
+			require.Equal(t, minDegree, restored.Stats().MinDegree)
+			require.Equal(t, bt.Entries(nil, nil), restored.Entries(nil, nil))
+		})
+	}
+
+	// the output is a plain Snapshot message
+	bt, err = btree.New(2, btree.WithCodec(testCodec{}))
+	require.NoError(t, err)
+	bt.Insert(testEntry{1, 2})
+
+	var buf bytes.Buffer
+	require.NoError(t, bt.Export(&buf))
+
+	entry, _ := testCodec{}.Encode(testEntry{1, 2})
+	want := append([]byte{0x08, 0x02, 0x10, 0x01, 0x1a, 0x10}, entry...)
+	require.Equal(t, want, buf.Bytes())
+
+	// fields may come in any order, be missing or be unknown
+	other, _ := testCodec{}.Encode(testEntry{3, 4})
+
+	var msg []byte
+	msg = append(append(msg, 0x1a, 0x10), entry...)
+	msg = append(msg, 0x20, 0x05, 0x2a, 0x01, 0xff, 0x35, 1, 2, 3, 4)
+	msg = append(append(msg, 0x1a, 0x10), other...)
+
+	restored, err := btree.New(5, btree.WithCodec(testCodec{}))
+	require.NoError(t, err)
+	require.NoError(t, restored.Import(bytes.NewReader(msg)))
+	require.Equal(t, 5, restored.Stats().MinDegree)
+	require.Equal(t, []btree.Entry{testEntry{1, 2}, testEntry{3, 4}}, restored.Entries(nil, nil))
+
+	// invalid snapshots leave the BTree untouched
+	var unsorted []byte
+	unsorted = append(append(unsorted, 0x1a, 0x10), other...)
+	unsorted = append(append(unsorted, 0x1a, 0x10), entry...)
+
+	for _, invalid := range [][]byte{
+		unsorted,
+		want[:len(want)-1],
+		{0x10, 0x02, 0x1a, 0x10},
+		append(append([]byte(nil), want...), 0x10, 0x02),
+		append([]byte{0x08, 0x01}, want[2:]...),
+		{0x0b},
+	} {
+		require.True(t, errors.Is(restored.Import(bytes.NewReader(invalid)), btree.ErrInvalidEncoding))
+		require.Equal(t, 2, restored.Size())
+	}
+}
@@ -0,0 +1,86 @@
+package btree
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// maxCodecName bounds the length of the name of a registered codec.
+const maxCodecName = 255
+
+// ErrUnknownCodec is returned when looking up a codec that was not registered
+// through RegisterEntryCodec.
+var ErrUnknownCodec = errors.New("unknown codec")
+
+// namedCodec implements a Codec registered under a name.
+type namedCodec struct {
+	name string
+	enc  func(Entry) ([]byte, error)
+	dec  func([]byte) (Entry, error)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]*namedCodec{}
+)
+
+// RegisterEntryCodec registers a codec of the given name, which encodes entries
+// through enc and decodes them through dec, as with Codec. A BTree configured
+// WithCodec to use the codec returned by EntryCodec records the name in its
+// binary and stream encodings, so that every BTree can decode them through
+// UnmarshalBinary and ReadFrom, without a Codec of its own and regardless of
+// the Codec it has, as long as the codec is registered in the decoding process
+// as well. As with gob.Register, codecs are meant to be registered during
+// initialization, and RegisterEntryCodec panics if the name is empty, longer
+// than 255 bytes or already registered, or if enc or dec is nil.
+func RegisterEntryCodec(name string, enc func(Entry) ([]byte, error), dec func([]byte) (Entry, error)) {
+	if name == "" || len(name) > maxCodecName {
+		panic(fmt.Sprintf("btree: invalid codec name %q", name))
+	}
+
+	if enc == nil || dec == nil {
+		panic(fmt.Sprintf("btree: codec %q must encode and decode", name))
+	}
+
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	if _, ok := codecs[name]; ok {
+		panic(fmt.Sprintf("btree: codec %q registered twice", name))
+	}
+
+	codecs[name] = &namedCodec{name: name, enc: enc, dec: dec}
+}
+
+// EntryCodec returns the codec registered under the given name, or an error
+// wrapping ErrUnknownCodec if there is none.
+func EntryCodec(name string) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+	}
+
+	return c, nil
+}
+
+// codecName returns the name c is registered under, or an empty string if c
+// was not returned by EntryCodec.
+func codecName(c Codec) string {
+	if nc, ok := c.(*namedCodec); ok {
+		return nc.name
+	}
+
+	return ""
+}
+
+func (c *namedCodec) Encode(e Entry) ([]byte, error) {
+	return c.enc(e)
+}
+
+func (c *namedCodec) Decode(b []byte) (Entry, error) {
+	return c.dec(b)
+}
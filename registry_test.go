@@ -0,0 +1,85 @@
+package btree_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	btree.RegisterEntryCodec("testEntry", testCodec{}.Encode, testCodec{}.Decode)
+}
+
+func TestRegisterEntryCodec(t *testing.T) {
+	codec, err := btree.EntryCodec("testEntry")
+	require.NoError(t, err)
+
+	_, err = btree.EntryCodec("missing")
+	require.True(t, errors.Is(err, btree.ErrUnknownCodec))
+
+	bt, err := btree.New(3, btree.WithCodec(codec))
+	require.NoError(t, err)
+
+	for i := uint64(0); i < 500; i++ {
+		bt.Insert(testEntry{key: i, value: i * i})
+	}
+
+	// a zero BTree decodes the entries through the recorded codec, which takes
+	// precedence over the Codec of the BTree
+	data, err := bt.MarshalBinary()
+	require.NoError(t, err)
+
+	var restored btree.BTree
+	require.NoError(t, restored.UnmarshalBinary(data))
+	require.Equal(t, bt.Entries(nil, nil), restored.Entries(nil, nil))
+	require.NoError(t, restored.Verify())
+
+	reversed, err := btree.New(3, btree.WithCodec(reversedCodec{}))
+	require.NoError(t, err)
+	require.NoError(t, reversed.UnmarshalBinary(data))
+	require.Equal(t, bt.Entries(nil, nil), reversed.Entries(nil, nil))
+
+	var buf bytes.Buffer
+	_, err = bt.WriteTo(&buf)
+	require.NoError(t, err)
+
+	var streamed btree.BTree
+	_, err = streamed.ReadFrom(&buf)
+	require.NoError(t, err)
+	require.Equal(t, bt.Entries(nil, nil), streamed.Entries(nil, nil))
+	require.NoError(t, streamed.Verify())
+
+	// the first version, lacking the name, still decodes through the Codec of
+	// the BTree
+	entry, err := testCodec{}.Encode(testEntry{key: 7})
+	require.NoError(t, err)
+
+	v1 := append(appendUvarints(nil, 1, 2, 1, 1, 1, uint64(len(entry))), entry...)
+	require.NoError(t, reversed.UnmarshalBinary(v1))
+	require.Equal(t, []btree.Entry{testEntry{key: 1<<64 - 1 - 7}}, reversed.Entries(nil, nil))
+
+	var plain btree.BTree
+	require.True(t, errors.Is(plain.UnmarshalBinary(v1), btree.ErrNoCodec))
+
+	// names must be registered in the decoding process as well
+	unknown := append(appendUvarints(nil, 2, 7), "missing"...)
+	unknown = append(appendUvarints(unknown, 2, 1, 1, 1, uint64(len(entry))), entry...)
+	require.True(t, errors.Is(reversed.UnmarshalBinary(unknown), btree.ErrUnknownCodec))
+
+	_, err = reversed.ReadFrom(bytes.NewReader(binary.AppendUvarint(append(appendUvarints(nil, 2, 7), "missing"...), 0)))
+	require.True(t, errors.Is(err, btree.ErrUnknownCodec))
+
+	require.Panics(t, func() {
+		btree.RegisterEntryCodec("testEntry", testCodec{}.Encode, testCodec{}.Decode)
+	})
+	require.Panics(t, func() {
+		btree.RegisterEntryCodec("", testCodec{}.Encode, testCodec{}.Decode)
+	})
+	require.Panics(t, func() {
+		btree.RegisterEntryCodec("nil", nil, testCodec{}.Decode)
+	})
+}
@@ -0,0 +1,67 @@
+package btree
+
+import (
+	"context"
+	"iter"
+)
+
+// All returns an iterator over every Entry in the BTree in ascending order,
+// suitable for use with range. The read lock is held until the loop completes or
+// breaks, so the loop body must not modify the BTree.
+func (bt *BTree) All() iter.Seq[Entry] {
+	return func(yield func(Entry) bool) {
+		bt.Ascend(yield)
+	}
+}
+
+// Backward returns an iterator over every Entry in the BTree in descending
+// order, suitable for use with range. The read lock is held until the loop
+// completes or breaks, so the loop body must not modify the BTree.
+func (bt *BTree) Backward() iter.Seq[Entry] {
+	return func(yield func(Entry) bool) {
+		bt.Descend(yield)
+	}
+}
+
+// Range returns an iterator over every Entry e in the BTree, s.t.
+// from <= e < to, in ascending order, suitable for use with range. A nil from or
+// to leaves the respective end of the range unbounded. The read lock is held
+// until the loop completes or breaks, so the loop body must not modify the
+// BTree.
+func (bt *BTree) Range(from, to Entry) iter.Seq[Entry] {
+	return func(yield func(Entry) bool) {
+		bt.AscendRange(from, to, yield)
+	}
+}
+
+// Stream feeds every Entry e in the BTree, s.t. from <= e < to, in ascending
+// order to the returned channel from a separate goroutine. A nil from or to
+// leaves the respective end of the range unbounded. If the BTree was created
+// WithSnapshotReads, the entries are read from the snapshot published when
+// Stream is called, so slow consumers never block writers and do not observe
+// subsequent writes. Otherwise, the read lock is acquired when Stream is called
+// and held until the channel is closed, so writers wait for the consumer, which
+// must not modify the BTree. The channel is closed once the range has been
+// exhausted or the context is cancelled, whichever happens first. The caller
+// must drain the channel or cancel the context to release the goroutine.
+func (bt *BTree) Stream(ctx context.Context, from, to Entry) <-chan Entry {
+	tree := bt.rlock()
+	ch := make(chan Entry)
+
+	go func() {
+		defer close(ch)
+		defer tree.mu.RUnlock()
+
+		tree.root.ascendRange(tree.cmp, from, to, false, func(e Entry) bool {
+			select {
+			case ch <- e:
+				return true
+
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+
+	return ch
+}
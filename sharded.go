@@ -0,0 +1,235 @@
+package btree
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+// ShardedBTree implements a thread-safe ordered set of entries partitioned
+// across several BTrees, each with its own lock, so that writers to different
+// shards do not contend. A user-provided hook assigns every Entry to a shard,
+// e.g. by hashing it or by looking up the key range it falls into. Point
+// operations only lock the shard of their Entry, while ordered traversals merge
+// the entries of all shards.
+type ShardedBTree struct {
+	shards []*BTree
+	shard  func(Entry) int
+}
+
+// NewShardedBTree returns a reference to a new ShardedBTree of n shards, each a
+// BTree with a minimum degree t configured by the given options. The function
+// shard returns the index in [0, n) of the shard an Entry belongs to, and must
+// assign equal entries to the same shard.
+func NewShardedBTree(t, n int, shard func(Entry) int, opts ...Option) (*ShardedBTree, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("number of shards must be positive: %d", n)
+	}
+
+	if shard == nil {
+		return nil, fmt.Errorf("shard function must not be nil")
+	}
+
+	s := &ShardedBTree{shards: make([]*BTree, n), shard: shard}
+	for i := range s.shards {
+		bt, err := New(t, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		s.shards[i] = bt
+	}
+
+	return s, nil
+}
+
+// shardOf returns the shard the given Entry belongs to.
+func (s *ShardedBTree) shardOf(e Entry) *BTree {
+	i := s.shard(e)
+	if i < 0 || i >= len(s.shards) {
+		panic(fmt.Sprintf("btree: shard index %d out of range [0, %d)", i, len(s.shards)))
+	}
+
+	return s.shards[i]
+}
+
+// Size returns the total number of entries in all shards. As the shards are
+// counted one after the other, the result need not reflect a single point in
+// time while writes are in progress.
+func (s *ShardedBTree) Size() int {
+	size := 0
+	for _, bt := range s.shards {
+		size += bt.Size()
+	}
+
+	return size
+}
+
+// Insert inserts the given Entry into its shard, replacing an equal Entry if
+// one exists. A nil Entry is ignored.
+func (s *ShardedBTree) Insert(e Entry) {
+	if e == nil {
+		return
+	}
+
+	s.shardOf(e).Insert(e)
+}
+
+// Delete removes the Entry equal to the provided Entry from its shard and
+// returns it, or nil if no such Entry exists or the Entry is nil.
+func (s *ShardedBTree) Delete(e Entry) Entry {
+	if e == nil {
+		return nil
+	}
+
+	return s.shardOf(e).Delete(e)
+}
+
+// Search returns the Entry equal to the provided Entry or nil if no such Entry
+// exists or the Entry is nil.
+func (s *ShardedBTree) Search(e Entry) Entry {
+	if e == nil {
+		return nil
+	}
+
+	return s.shardOf(e).Search(e)
+}
+
+// Has returns true if an Entry equal to the provided Entry exists.
+func (s *ShardedBTree) Has(e Entry) bool {
+	return s.Search(e) != nil
+}
+
+// Min returns the smallest Entry across all shards or nil if they are empty.
+func (s *ShardedBTree) Min() Entry {
+	return s.extreme((*BTree).Min, -1)
+}
+
+// Max returns the largest Entry across all shards or nil if they are empty.
+func (s *ShardedBTree) Max() Entry {
+	return s.extreme((*BTree).Max, 1)
+}
+
+// extreme returns the one of the entries get returns for every shard that
+// compares to all others with the sign of sign.
+func (s *ShardedBTree) extreme(get func(*BTree) Entry, sign int) Entry {
+	var best Entry
+	for _, bt := range s.shards {
+		if e := get(bt); e != nil && (best == nil || bt.cmp(e, best)*sign > 0) {
+			best = e
+		}
+	}
+
+	return best
+}
+
+// Ascend calls fn for every Entry across all shards in ascending order, as
+// AscendRange does.
+func (s *ShardedBTree) Ascend(fn func(Entry) bool) {
+	s.AscendRange(nil, nil, fn)
+}
+
+// AscendRange calls fn for every Entry e across all shards, s.t.
+// from <= e < to, in ascending order, where a nil from or to leaves the
+// respective end of the range unbounded. Every shard is traversed through a
+// lazy clone, so no lock is held while fn runs, and fn may modify the
+// ShardedBTree without affecting the traversal. The traversal stops as soon as
+// fn returns false.
+func (s *ShardedBTree) AscendRange(from, to Entry, fn func(Entry) bool) {
+	m := s.merge(false, func(it *Iterator) bool {
+		if from == nil {
+			return it.First()
+		}
+
+		return it.Seek(from)
+	})
+
+	for e := m.next(); e != nil; e = m.next() {
+		if to != nil && m.cmp(e, to) >= 0 || !fn(e) {
+			return
+		}
+	}
+}
+
+// Descend calls fn for every Entry across all shards in descending order, with
+// the same guarantees as AscendRange. The traversal stops as soon as fn
+// returns false.
+func (s *ShardedBTree) Descend(fn func(Entry) bool) {
+	m := s.merge(true, (*Iterator).Last)
+
+	for e := m.next(); e != nil; e = m.next() {
+		if !fn(e) {
+			return
+		}
+	}
+}
+
+// merge returns a mergeIterator over lazy clones of every shard, each
+// positioned by start.
+func (s *ShardedBTree) merge(descending bool, start func(*Iterator) bool) *mergeIterator {
+	m := &mergeIterator{cmp: s.shards[0].cmp, descending: descending}
+	for _, bt := range s.shards {
+		it := bt.Clone().Iterator()
+		if start(it) {
+			m.its = append(m.its, it)
+		}
+	}
+
+	heap.Init(m)
+	return m
+}
+
+// mergeIterator merges several positioned Iterators over disjoint trees into a
+// single ordered stream. It implements heap.Interface, keeping the Iterator
+// whose entry comes first on top.
+type mergeIterator struct {
+	its        []*Iterator
+	cmp        compareFunc
+	descending bool
+}
+
+func (m *mergeIterator) Len() int { return len(m.its) }
+
+func (m *mergeIterator) Less(i, j int) bool {
+	c := m.cmp(m.its[i].Entry(), m.its[j].Entry())
+	if m.descending {
+		return c > 0
+	}
+
+	return c < 0
+}
+
+func (m *mergeIterator) Swap(i, j int) { m.its[i], m.its[j] = m.its[j], m.its[i] }
+
+func (m *mergeIterator) Push(x any) { m.its = append(m.its, x.(*Iterator)) }
+
+func (m *mergeIterator) Pop() any {
+	it := m.its[len(m.its)-1]
+	m.its[len(m.its)-1] = nil
+	m.its = m.its[:len(m.its)-1]
+
+	return it
+}
+
+// next returns the next Entry of the stream, advancing the Iterator it came
+// from, or nil once every Iterator is exhausted.
+func (m *mergeIterator) next() Entry {
+	if len(m.its) == 0 {
+		return nil
+	}
+
+	top := m.its[0]
+	e := top.Entry()
+
+	advanced := top.Next
+	if m.descending {
+		advanced = top.Prev
+	}
+
+	if advanced() {
+		heap.Fix(m, 0)
+	} else {
+		heap.Pop(m)
+	}
+
+	return e
+}
@@ -0,0 +1,120 @@
+package btree_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedBTree(t *testing.T) {
+	_, err := btree.NewShardedBTree(2, 0, func(btree.Entry) int { return 0 })
+	require.Error(t, err)
+
+	_, err = btree.NewShardedBTree(2, 4, nil)
+	require.Error(t, err)
+
+	_, err = btree.NewShardedBTree(1, 4, func(btree.Entry) int { return 0 })
+	require.Error(t, err)
+
+	shardings := map[string]func(btree.Entry) int{
+		"hash":  func(e btree.Entry) int { return int(e.(testEntry).key % 4) },
+		"range": func(e btree.Entry) int { return int(min(e.(testEntry).key/250, 3)) },
+	}
+
+	for name, shard := range shardings {
+		for _, minDegree := range []int{2, 3, 4, 11, 17} {
+			t.Run(fmt.Sprintf("%s minimum degree %d", name, minDegree), func(t *testing.T) {
+				s, err := btree.NewShardedBTree(minDegree, 4, shard)
+				require.NoError(t, err)
+				require.Nil(t, s.Min())
+
+				for _, i := range rng.Perm(1000) {
+					s.Insert(testEntry{key: uint64(i), value: uint64(i)})
+				}
+
+				s.Insert(nil)
+				require.Equal(t, 1000, s.Size())
+				require.Equal(t, uint64(0), s.Min().(testEntry).key)
+				require.Equal(t, uint64(999), s.Max().(testEntry).key)
+				require.True(t, s.Has(testEntry{key: 42}))
+				require.Nil(t, s.Search(testEntry{key: 1000}))
+
+				want := uint64(0)
+				s.Ascend(func(e btree.Entry) bool {
+					require.Equal(t, want, e.(testEntry).key)
+					want++
+					return true
+				})
+				require.Equal(t, uint64(1000), want)
+
+				var got []uint64
+				s.AscendRange(testEntry{key: 248}, testEntry{key: 253}, func(e btree.Entry) bool {
+					got = append(got, e.(testEntry).key)
+					return true
+				})
+				require.Equal(t, []uint64{248, 249, 250, 251, 252}, got)
+
+				// deleting during the traversal does not affect it
+				want = 999
+				s.Descend(func(e btree.Entry) bool {
+					require.Equal(t, want, e.(testEntry).key)
+					require.NotNil(t, s.Delete(e))
+					want--
+					return want >= 500
+				})
+
+				require.Equal(t, 500, s.Size())
+				require.Equal(t, uint64(499), s.Max().(testEntry).key)
+			})
+		}
+	}
+
+	s, err := btree.NewShardedBTree(2, 2, func(btree.Entry) int { return 2 })
+	require.NoError(t, err)
+	require.Panics(t, func() { s.Insert(testEntry{}) })
+}
+
+func TestShardedBTreeConcurrent(t *testing.T) {
+	const (
+		shards = 8
+		n      = 1000
+	)
+
+	s, err := btree.NewShardedBTree(3, shards, func(e btree.Entry) int {
+		return int(e.(testEntry).key % shards)
+	})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for w := 0; w < shards; w++ {
+		wg.Add(2)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < n; i++ {
+				s.Insert(testEntry{key: uint64(i*shards + w)})
+			}
+		}(w)
+
+		go func() {
+			defer wg.Done()
+
+			var prev btree.Entry
+			s.Ascend(func(e btree.Entry) bool {
+				if prev != nil && prev.Compare(e) >= 0 {
+					t.Errorf("%v after %v", e, prev)
+				}
+
+				prev = e
+				return true
+			})
+		}()
+	}
+
+	wg.Wait()
+	require.Equal(t, shards*n, s.Size())
+}
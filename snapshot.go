@@ -0,0 +1,148 @@
+package btree
+
+import (
+	"context"
+	"reflect"
+)
+
+// The methods in this file guard every operation of a BTree. Unless the BTree
+// was created WithSnapshotReads, they simply take its lock. Otherwise, writers
+// still serialize on the write lock, but once a write completes, the nodes it
+// produced are handed over to a new, immutable snapshot of the BTree: the BTree
+// moves to a fresh copy-on-write context, so that the next write copies every
+// node it touches rather than modifying the snapshot. Readers then load the
+// latest snapshot atomically and read it without any locking.
+
+// rlock returns the BTree to read from. That is either the latest snapshot of
+// the BTree, which requires no locking, or the BTree itself, whose read lock
+// is acquired. In both cases, the read lock of the result must be released.
+// Every BTree maintaining snapshots publishes one as soon as it is created, so
+// reads never touch the write lock, which a write callback already holds.
+func (bt *BTree) rlock() *BTree {
+	if bt.snapshots {
+		return bt.snapshot.Load()
+	}
+
+	bt.mu.RLock()
+	return bt
+}
+
+// lock acquires the write lock of the BTree.
+func (bt *BTree) lock() {
+	bt.mu.Lock()
+}
+
+// lockCtx acquires the write lock of the BTree as lock does, unless ctx is done
+// before it is granted, in which case the lock is not held and the error of
+// ctx is returned.
+func (bt *BTree) lockCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if bt.mu.TryLock() {
+		return nil
+	}
+
+	// A sync.RWMutex cannot stop waiting, so a goroutine waits instead and hands
+	// the lock over, or releases it right away if no one is waiting any longer.
+	granted := make(chan struct{})
+	go func() {
+		bt.mu.Lock()
+
+		select {
+		case granted <- struct{}{}:
+		case <-ctx.Done():
+			bt.mu.Unlock()
+		}
+	}()
+
+	select {
+	case <-granted:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// unlock publishes a new snapshot of the BTree, if it maintains them, and
+// releases its write lock.
+func (bt *BTree) unlock() {
+	if bt.snapshots {
+		bt.publish()
+	}
+
+	bt.mu.Unlock()
+}
+
+// publish makes the current contents of the BTree available to readers as an
+// immutable snapshot, unless they already are. Every write replaces the root,
+// as it copies the nodes along its path, unless it reverted to the latest
+// snapshot through unchanged, so an unchanged root means the BTree is
+// unchanged. The caller must hold the write lock or own the BTree.
+func (bt *BTree) publish() {
+	s := bt.snapshot.Load()
+	if s != nil && s.root == bt.root {
+		return
+	}
+
+	if s != nil {
+		bt.version++
+	}
+
+	// Move the BTree to a new context, so that it no longer owns, and hence
+	// mutates, the nodes it now shares with the snapshot.
+	bt.cow = &copyOnWriteContext{freeList: bt.cow.freeList}
+
+	s = &BTree{
+		mu:        nopLocker{},
+		root:      bt.root,
+		minDegree: bt.minDegree,
+		size:      bt.size,
+		depth:     bt.depth,
+		cmp:       bt.cmp,
+		validate:  bt.validate,
+		codec:     bt.codec,
+		jsonCodec: bt.jsonCodec,
+		cow:       &copyOnWriteContext{freeList: &freeList{}},
+		version:   bt.version,
+	}
+
+	bt.history.add(s)
+	bt.snapshot.Store(s)
+}
+
+// unchanged reverts the BTree to its latest snapshot, if it maintains them,
+// after a write that turned out to leave every entry as it was, such as a
+// Delete of a missing Entry or a Modify returning the Entry it was given. The
+// write may still have copied or rebalanced the nodes along its path, but
+// since the snapshot holds the same entries, nothing new is published and the
+// version stays the same. The caller must hold the write lock.
+func (bt *BTree) unchanged() {
+	if !bt.snapshots {
+		return
+	}
+
+	if s := bt.snapshot.Load(); s != nil {
+		bt.root, bt.size, bt.depth = s.root, s.size, s.depth
+	}
+}
+
+// sameEntry returns true if a and b are identical, which is only decided for
+// comparable entries, so that comparing never panics.
+func sameEntry(a, b Entry) bool {
+	return reflect.ValueOf(a).Comparable() && a == b
+}
+
+// SnapshotGet looks up many entries at once, like MultiGet, and guarantees that
+// all of them are read from the same point-in-time view of the BTree, even
+// while writers run concurrently. For a BTree created WithSnapshotReads, the
+// lookups are performed on the latest published snapshot, so they never delay
+// writers, and otherwise under a single acquisition of the read lock, which
+// keeps writers out until all of them are done. The result holds, for every
+// provided key, the Entry equal to it at the same index, or nil if no such
+// Entry exists or the key is nil.
+func (bt *BTree) SnapshotGet(keys []Entry) []Entry {
+	return bt.MultiGet(keys)
+}
@@ -0,0 +1,244 @@
+package btree_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSnapshotReads(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree, btree.WithSnapshotReads())
+			require.NoError(t, err)
+
+			for i := 0; i < 1000; i++ {
+				bt.Insert(testEntry{key: uint64(2 * i)})
+			}
+
+			require.NoError(t, bt.Verify())
+
+			// a scan sees the snapshot it started on, while writes continue,
+			// even from within the scan itself
+			n := 0
+			bt.Ascend(func(e btree.Entry) bool {
+				require.Equal(t, uint64(2*n), e.(testEntry).key)
+				bt.Insert(testEntry{key: e.(testEntry).key + 1})
+				n++
+				return true
+			})
+
+			require.Equal(t, 1000, n)
+			require.Equal(t, 2000, bt.Size())
+			require.NoError(t, bt.Verify())
+
+			// so does an iterator, until it is repositioned
+			it := bt.Iterator()
+			require.True(t, it.First())
+
+			for i := uint64(0); i < 2000; i += 2 {
+				require.NotNil(t, bt.Delete(testEntry{key: i}))
+			}
+
+			for i := uint64(0); i < 2000; i++ {
+				require.Equal(t, i, it.Entry().(testEntry).key)
+				it.Next()
+			}
+
+			require.False(t, it.Valid())
+			require.True(t, it.First())
+			require.Equal(t, uint64(1), it.Entry().(testEntry).key)
+
+			// trees derived from the BTree maintain snapshots as well
+			clone := bt.Clone()
+			clone.Insert(testEntry{key: 0})
+			require.Equal(t, 1001, clone.Size())
+			require.Equal(t, 1000, bt.Size())
+			require.True(t, clone.Has(testEntry{key: 0}))
+			require.False(t, bt.Has(testEntry{key: 0}))
+			require.NoError(t, clone.Verify())
+			require.NoError(t, bt.Verify())
+
+			// and read without the lock before their first write already, so
+			// that a read within a write does not deadlock
+			clone = bt.Clone()
+			require.Equal(t, 1000, clone.Size())
+			require.True(t, clone.Modify(testEntry{key: 1}, func(old btree.Entry) btree.Entry {
+				require.Equal(t, old, clone.Search(old))
+				return testEntry{key: 1, value: 1}
+			}))
+			require.Equal(t, testEntry{key: 1, value: 1}, clone.Search(testEntry{key: 1}))
+		})
+	}
+}
+
+func TestWithSnapshotReadsUnchanged(t *testing.T) {
+	bt, err := btree.New(2, btree.WithSnapshotReads())
+	require.NoError(t, err)
+
+	require.Nil(t, bt.DeleteMin())
+	require.Nil(t, bt.DeleteMax())
+	require.Zero(t, bt.Version())
+
+	for i := uint64(0); i < 100; i++ {
+		bt.Insert(testEntry{key: 2 * i})
+	}
+
+	// writes leaving every entry as it was publish no new version, even if
+	// their descent rebalanced or split nodes on the way
+	v := bt.Version()
+	require.Equal(t, uint64(100), v)
+
+	require.Nil(t, bt.Delete(testEntry{key: 1}))
+	require.False(t, bt.Modify(testEntry{key: 1}, func(old btree.Entry) btree.Entry { return old }))
+	require.True(t, bt.Modify(testEntry{key: 2}, func(old btree.Entry) btree.Entry { return old }))
+	require.True(t, bt.CompareAndSwap(testEntry{key: 4}, testEntry{key: 4}))
+	bt.Insert(testEntry{key: 6})
+	bt.Upsert(testEntry{key: 8, value: 1}, func(old, _ btree.Entry) btree.Entry { return old })
+
+	require.Equal(t, v, bt.Version())
+	require.Equal(t, 100, bt.Size())
+	require.NoError(t, bt.Verify())
+
+	// while any actual change does
+	require.NotNil(t, bt.Delete(testEntry{key: 0}))
+	require.True(t, bt.Modify(testEntry{key: 2}, func(btree.Entry) btree.Entry { return testEntry{key: 2, value: 1} }))
+	bt.Insert(testEntry{key: 4, value: 1})
+
+	require.Equal(t, v+3, bt.Version())
+	require.Equal(t, testEntry{key: 2, value: 1}, bt.Search(testEntry{key: 2}))
+	require.NoError(t, bt.Verify())
+}
+
+func TestWithSnapshotReadsDerived(t *testing.T) {
+	bt, err := btree.New(3, btree.WithSnapshotReads())
+	require.NoError(t, err)
+
+	other, err := btree.New(3, btree.WithSnapshotReads())
+	require.NoError(t, err)
+
+	for i := uint64(0); i < 100; i++ {
+		bt.Insert(testEntry{key: i, value: i})
+		other.Insert(testEntry{key: i + 100, value: i + 100})
+	}
+
+	left, right := bt.SplitAt(testEntry{key: 50})
+	match, _ := bt.Partition(func(e btree.Entry) bool { return e.(testEntry).key%2 == 0 })
+
+	derived := map[string]*btree.BTree{
+		"Clone":     bt.Clone(),
+		"Filter":    bt.Filter(func(btree.Entry) bool { return true }),
+		"Partition": match,
+		"Intersect": bt.Intersect(bt),
+		"Merge":     bt.Merge(other, nil),
+		"SplitAt":   left,
+	}
+
+	for name, tr := range derived {
+		t.Run(name, func(t *testing.T) {
+			key := testEntry{key: 10}
+
+			// a derived tree has a snapshot from the start, so a read within a
+			// write callback never waits for the write lock held by the write
+			require.True(t, tr.Modify(key, func(old btree.Entry) btree.Entry {
+				require.Equal(t, testEntry{key: 10, value: 10}, tr.Search(old))
+				return testEntry{key: 10, value: 1000}
+			}))
+
+			require.Equal(t, testEntry{key: 10, value: 1000}, tr.Search(key))
+			require.NoError(t, tr.Verify())
+		})
+	}
+
+	require.True(t, right.Modify(testEntry{key: 60}, func(old btree.Entry) btree.Entry {
+		require.Equal(t, old, right.Search(old))
+		return old
+	}))
+
+	require.Equal(t, 50, right.Size())
+	require.Equal(t, testEntry{key: 10, value: 10}, bt.Search(testEntry{key: 10}))
+	require.NoError(t, bt.Verify())
+}
+
+func TestWithSnapshotReadsConcurrent(t *testing.T) {
+	bt, err := btree.New(3, btree.WithSnapshotReads())
+	require.NoError(t, err)
+
+	const n = 2000
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < n; i++ {
+			bt.Insert(testEntry{key: uint64(i)})
+		}
+	}()
+
+	// every reader must observe some prefix of the inserted keys
+	for r := 0; r < 3; r++ {
+		go func() {
+			defer wg.Done()
+
+			for bt.Size() < n {
+				var count uint64
+				bt.Ascend(func(e btree.Entry) bool {
+					if e.(testEntry).key != count {
+						t.Errorf("unexpected entry %d at position %d", e.(testEntry).key, count)
+						return false
+					}
+
+					count++
+					return true
+				})
+			}
+		}()
+	}
+
+	wg.Wait()
+	require.NoError(t, bt.Verify())
+}
+
+func TestSnapshotGet(t *testing.T) {
+	for _, opts := range [][]btree.Option{nil, {btree.WithSnapshotReads()}} {
+		t.Run(fmt.Sprintf("%d options", len(opts)), func(t *testing.T) {
+			bt, err := btree.New(3, opts...)
+			require.NoError(t, err)
+
+			for i := uint64(0); i < 1000; i++ {
+				bt.Insert(testEntry{key: i})
+			}
+
+			got := bt.SnapshotGet([]btree.Entry{testEntry{key: 999}, nil, testEntry{key: 5000}, testEntry{key: 3}})
+			require.Equal(t, []btree.Entry{testEntry{key: 999}, nil, nil, testEntry{key: 3}}, got)
+
+			// the writer bumps the value of key 0 before that of key 1, so a
+			// consistent view never sees key 1 ahead or key 0 more than one ahead
+			var wg sync.WaitGroup
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				for v := uint64(1); v <= 2000; v++ {
+					bt.Insert(testEntry{key: 0, value: v})
+					bt.Insert(testEntry{key: 1, value: v})
+				}
+			}()
+
+			for i := 0; i < 2000; i++ {
+				got := bt.SnapshotGet([]btree.Entry{testEntry{key: 1}, testEntry{key: 0}})
+				v1, v0 := got[0].(testEntry).value, got[1].(testEntry).value
+				require.True(t, v0 == v1 || v0 == v1+1, "inconsistent view: %d, %d", v0, v1)
+			}
+
+			wg.Wait()
+			require.NoError(t, bt.Verify())
+		})
+	}
+}
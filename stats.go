@@ -0,0 +1,101 @@
+package btree
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Stats describes the shape of a BTree and, if it was created
+	// WithLockStats, the contention on its lock, so that slow operations can be
+	// attributed to either the depth of the tree or waiting for the lock.
+	Stats struct {
+		Size      int
+		Depth     int
+		MinDegree int
+		Lock      LockStats
+	}
+
+	// LockStats counts the acquisitions of the lock of a BTree since it was
+	// created. An acquisition is contended if it could not be granted right away,
+	// in which case the time spent waiting for it is added to the respective
+	// wait. Reads served from a snapshot of a BTree created WithSnapshotReads do
+	// not acquire the lock and are not counted.
+	LockStats struct {
+		Reads           uint64
+		ContendedReads  uint64
+		ReadWait        time.Duration
+		Writes          uint64
+		ContendedWrites uint64
+		WriteWait       time.Duration
+	}
+
+	// statsLocker defines a read-write mutex that records LockStats.
+	statsLocker struct {
+		sync.RWMutex
+
+		reads, contendedReads, readWait    atomic.Uint64
+		writes, contendedWrites, writeWait atomic.Uint64
+	}
+)
+
+// Stats returns the current Stats of the BTree. The LockStats are sampled
+// before Stats acquires the read lock itself, which later calls count as well.
+func (bt *BTree) Stats() Stats {
+	var lock LockStats
+	if l, ok := bt.mu.(*statsLocker); ok {
+		lock = l.stats()
+	}
+
+	bt = bt.rlock()
+	defer bt.mu.RUnlock()
+
+	return Stats{Size: bt.size, Depth: bt.depth, MinDegree: bt.minDegree, Lock: lock}
+}
+
+func (l *statsLocker) Lock() {
+	l.writes.Add(1)
+	if l.RWMutex.TryLock() {
+		return
+	}
+
+	start := time.Now()
+	l.RWMutex.Lock()
+
+	l.contendedWrites.Add(1)
+	l.writeWait.Add(uint64(time.Since(start)))
+}
+
+func (l *statsLocker) TryLock() bool {
+	if !l.RWMutex.TryLock() {
+		return false
+	}
+
+	l.writes.Add(1)
+	return true
+}
+
+func (l *statsLocker) RLock() {
+	l.reads.Add(1)
+	if l.TryRLock() {
+		return
+	}
+
+	start := time.Now()
+	l.RWMutex.RLock()
+
+	l.contendedReads.Add(1)
+	l.readWait.Add(uint64(time.Since(start)))
+}
+
+func (l *statsLocker) stats() LockStats {
+	return LockStats{
+		Reads:           l.reads.Load(),
+		ContendedReads:  l.contendedReads.Load(),
+		ReadWait:        time.Duration(l.readWait.Load()),
+		Writes:          l.writes.Load(),
+		ContendedWrites: l.contendedWrites.Load(),
+		WriteWait:       time.Duration(l.writeWait.Load()),
+	}
+}
@@ -0,0 +1,70 @@
+package btree_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, _ := newTestBTree(t, minDegree, 1000)
+
+			stats := bt.Stats()
+			require.Equal(t, 1000, stats.Size)
+			require.Equal(t, bt.Depth(), stats.Depth)
+			require.Equal(t, minDegree, stats.MinDegree)
+			require.Zero(t, stats.Lock)
+		})
+	}
+}
+
+func TestWithLockStats(t *testing.T) {
+	bt, err := btree.New(3, btree.WithLockStats())
+	require.NoError(t, err)
+
+	for i := uint64(0); i < 100; i++ {
+		bt.Insert(testEntry{key: i})
+	}
+
+	for i := uint64(0); i < 50; i++ {
+		require.True(t, bt.Has(testEntry{key: i}))
+	}
+
+	stats := bt.Stats()
+	require.Equal(t, 100, stats.Size)
+	require.Equal(t, btree.LockStats{Reads: 50, Writes: 100}, stats.Lock)
+
+	// a read waiting for a write in progress is contended
+	var wg sync.WaitGroup
+	bt.Modify(testEntry{key: 1}, func(old btree.Entry) btree.Entry {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bt.Search(testEntry{key: 1})
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		return old
+	})
+
+	wg.Wait()
+
+	stats = bt.Stats()
+	// the previous call to Stats counts as a read as well
+	require.Equal(t, uint64(52), stats.Lock.Reads)
+	require.Equal(t, uint64(1), stats.Lock.ContendedReads)
+	require.Greater(t, int64(stats.Lock.ReadWait), int64(0))
+	require.Equal(t, uint64(101), stats.Lock.Writes)
+	require.Zero(t, stats.Lock.ContendedWrites)
+
+	// clones record their own
+	clone := bt.Clone()
+	require.Zero(t, clone.Stats().Lock)
+	require.Equal(t, uint64(102), bt.Stats().Lock.Writes)
+}
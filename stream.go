@@ -0,0 +1,144 @@
+package btree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The stream encoding of a BTree starts with the format version and the name of
+// the codec, as in the binary encoding, and the number of entries as a uvarint,
+// followed by every entry in ascending order as the
+// length of its encoding and the encoding produced by the Codec. Unlike the
+// binary encoding, it holds no structure, so it can be written and read one
+// entry at a time.
+
+type (
+	// countingWriter counts the bytes written to w.
+	countingWriter struct {
+		w io.Writer
+		n int64
+	}
+
+	// countingReader counts the bytes read from r.
+	countingReader struct {
+		r byteReader
+		n int64
+	}
+)
+
+// WriteTo implements io.WriterTo, streaming the entries of the BTree to w in
+// ascending order, each framed by its length, as of a single point in time.
+// The entries are written from a lazy Clone, so writers are not blocked while
+// a large BTree is written to a slow w, and the encoding is never held in
+// memory as a whole. The entries are encoded by the Codec of the BTree, and
+// ErrNoCodec is returned if it has none. It returns the number of bytes
+// written.
+func (bt *BTree) WriteTo(w io.Writer) (int64, error) {
+	if bt.codec == nil {
+		return 0, ErrNoCodec
+	}
+
+	// the clone is owned by this call, so it is read without locking
+	clone := bt.Clone()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	enc := &encoder{w: bw, codec: bt.codec}
+	enc.header()
+	enc.uvarint(uint64(clone.size))
+
+	clone.root.ascendRange(clone.cmp, nil, nil, true, func(e Entry) bool {
+		enc.entry(e)
+		return enc.err == nil
+	})
+
+	if enc.err == nil {
+		enc.err = bw.Flush()
+	}
+
+	return cw.n, enc.err
+}
+
+// ReadFrom implements io.ReaderFrom, replacing the contents of the BTree by the
+// entries streamed from r by WriteTo. The entries are decoded one at a time by
+// the registered codec whose name the encoding records, or else by the Codec of
+// the BTree, and ErrNoCodec is returned if there is neither. They are built
+// bottom-up at the minimum degree of the BTree, which must therefore be created
+// first, through New or the like, unless the default suits, in which case a
+// zero BTree becomes one as returned by New. Reading stops at
+// the end of the encoding, although r is buffered unless it implements
+// io.ByteReader, so more of it may be consumed. It returns the number of bytes
+// of the encoding read. If r does not hold a valid encoding, an error wrapping
+// ErrInvalidEncoding is returned and the BTree is left untouched.
+func (bt *BTree) ReadFrom(r io.Reader) (int64, error) {
+	if bt.cmp == nil {
+		bt.init()
+	}
+
+	br, ok := r.(byteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	cr := &countingReader{r: br}
+	src := &binarySource{r: cr, codec: bt.codec}
+
+	if err := src.header(); err != nil {
+		return cr.n, err
+	}
+
+	size, err := src.int("size", 0, math.MaxInt)
+	if err != nil {
+		return cr.n, err
+	}
+
+	loaded := bt.newLike()
+	b := newBuilder(loaded)
+	d := &decoder{src: src, validate: bt.validate}
+
+	var prev Entry
+	for i := 0; i < size; i++ {
+		e, err := d.entry()
+		if err != nil {
+			return cr.n, err
+		}
+
+		if prev != nil && bt.cmp(prev, e) >= 0 {
+			return cr.n, fmt.Errorf("%w: entry %d out of order", ErrInvalidEncoding, i)
+		}
+
+		b.add(e)
+		prev = e
+	}
+
+	b.finish()
+
+	bt.adopt(loaded.root, loaded.size, loaded.depth, loaded.minDegree, loaded.cow)
+	return cr.n, nil
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+
+	return n, err
+}
+
+func (cr *countingReader) Read(b []byte) (int, error) {
+	n, err := cr.r.Read(b)
+	cr.n += int64(n)
+
+	return n, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	c, err := cr.r.ReadByte()
+	if err == nil {
+		cr.n++
+	}
+
+	return c, err
+}
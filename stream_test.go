@@ -0,0 +1,101 @@
+package btree_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteToReadFrom(t *testing.T) {
+	bt, err := btree.New(3)
+	require.NoError(t, err)
+
+	_, err = bt.WriteTo(io.Discard)
+	require.True(t, errors.Is(err, btree.ErrNoCodec))
+
+	_, err = bt.ReadFrom(bytes.NewReader(appendUvarints(nil, 2, 0)))
+	require.True(t, errors.Is(err, btree.ErrNoCodec))
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, err := btree.New(minDegree, btree.WithCodec(testCodec{}))
+			require.NoError(t, err)
+
+			for _, i := range rng.Perm(5000) {
+				bt.Insert(testEntry{uint64(i), rng.Uint64()})
+			}
+
+			var buf bytes.Buffer
+			n, err := bt.WriteTo(&buf)
+			require.NoError(t, err)
+			require.Equal(t, int64(buf.Len()), n)
+
+			// followed by unrelated data, which a byte reader leaves in place
+			data := buf.Bytes()
+			buf.WriteString("trailer")
+
+			restored, err := btree.New(4, btree.WithCodec(testCodec{}))
+			require.NoError(t, err)
+			restored.Insert(testEntry{key: 1 << 40})
+
+			n, err = restored.ReadFrom(&buf)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(data)), n)
+			require.Equal(t, "trailer", buf.String())
+
+			require.NoError(t, restored.Verify())
+			require.Equal(t, 4, restored.Stats().MinDegree)
+			require.Equal(t, bt.Entries(nil, nil), restored.Entries(nil, nil))
+
+			// any reader will do, however it splits the data
+			restored, err = btree.New(minDegree, btree.WithCodec(testCodec{}))
+			require.NoError(t, err)
+
+			_, err = restored.ReadFrom(iotest.OneByteReader(bytes.NewReader(data)))
+			require.NoError(t, err)
+			require.Equal(t, bt.Entries(nil, nil), restored.Entries(nil, nil))
+
+			// a truncated stream leaves the BTree untouched
+			_, err = restored.ReadFrom(bytes.NewReader(data[:len(data)-1]))
+			require.True(t, errors.Is(err, btree.ErrInvalidEncoding))
+			require.Equal(t, bt.Size(), restored.Size())
+			require.NoError(t, restored.Verify())
+		})
+	}
+
+	// entries must be in order
+	reversed, err := btree.New(2, btree.WithCodec(reversedCodec{}))
+	require.NoError(t, err)
+
+	src, err := btree.New(2, btree.WithCodec(testCodec{}))
+	require.NoError(t, err)
+	src.Insert(testEntry{key: 1})
+	src.Insert(testEntry{key: 2})
+
+	var buf bytes.Buffer
+	_, err = src.WriteTo(&buf)
+	require.NoError(t, err)
+
+	_, err = reversed.ReadFrom(&buf)
+	require.True(t, errors.Is(err, btree.ErrInvalidEncoding))
+	require.Zero(t, reversed.Size())
+
+	// a failing writer is reported
+	_, err = src.WriteTo(failingWriter{})
+	require.True(t, errors.Is(err, errWrite))
+}
+
+var errWrite = errors.New("write failed")
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errWrite
+}
@@ -0,0 +1,150 @@
+package btree
+
+import (
+	"errors"
+	"iter"
+	"strings"
+	"sync"
+)
+
+// StringTree implements a thread-safe B-Tree specialized for string keys, each
+// carrying a value of type V. Keys and values are stored inline in the nodes
+// rather than boxed in entries, so inserting does not allocate per entry and
+// comparisons do not dispatch through an interface. Its surface mirrors that of
+// Map.
+type StringTree[V any] struct {
+	mu   sync.RWMutex
+	tree *ktree[string, V]
+}
+
+// NewStringTree returns a reference to a new, empty StringTree with a minimum
+// degree t, whose keys are ordered bytewise.
+func NewStringTree[V any](t int) (*StringTree[V], error) {
+	tree, err := newKTree[string, V](t, strings.Compare)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StringTree[V]{tree: tree}, nil
+}
+
+// NewStringTreeWithCollation returns a reference to a new, empty StringTree with
+// a minimum degree t, whose keys are ordered by the given collation instead of
+// bytewise, so that iteration follows user-visible alphabetical order. The
+// collation returns 0 if a and b are equal, a negative value if a sorts before
+// b and a positive value otherwise; the CompareString method of a
+// golang.org/x/text/collate Collator fits directly. Keys which the collation
+// deems equal, such as differently accented forms at a low strength, are stored
+// under a single entry.
+func NewStringTreeWithCollation[V any](t int, collation func(a, b string) int) (*StringTree[V], error) {
+	if collation == nil {
+		return nil, errors.New("collation must not be nil")
+	}
+
+	tree, err := newKTree[string, V](t, collation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StringTree[V]{tree: tree}, nil
+}
+
+// Len returns the number of keys in the StringTree.
+func (s *StringTree[V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.size
+}
+
+// Get returns the value stored under the given key and whether it exists.
+func (s *StringTree[V]) Get(key string) (V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.get(key)
+}
+
+// Has returns true if a value is stored under the given key.
+func (s *StringTree[V]) Has(key string) bool {
+	_, ok := s.Get(key)
+	return ok
+}
+
+// Set stores the value under the given key, returning the value it replaced, if
+// any, and whether one was replaced.
+func (s *StringTree[V]) Set(key string, value V) (old V, replaced bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.set(key, value)
+}
+
+// Delete removes the given key, returning the value stored under it, if any,
+// and whether it existed.
+func (s *StringTree[V]) Delete(key string) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, value, ok := s.tree.remove(key, kremoveKey)
+	return value, ok
+}
+
+// Min returns the smallest key along with its value, or false if the
+// StringTree is empty.
+func (s *StringTree[V]) Min() (string, V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.min()
+}
+
+// Max returns the largest key along with its value, or false if the StringTree
+// is empty.
+func (s *StringTree[V]) Max() (string, V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.max()
+}
+
+// Ascend calls fn for every key-value pair in ascending key order, under the
+// read lock. The traversal stops as soon as fn returns false.
+func (s *StringTree[V]) Ascend(fn func(key string, value V) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.tree.root.ascend(s.tree.cmp, nil, nil, fn)
+}
+
+// AscendRange calls fn for every key-value pair whose key k satisfies
+// from <= k < to, in ascending key order, under the read lock. The traversal
+// stops as soon as fn returns false.
+func (s *StringTree[V]) AscendRange(from, to string, fn func(key string, value V) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.tree.root.ascend(s.tree.cmp, &from, &to, fn)
+}
+
+// Descend calls fn for every key-value pair in descending key order, under the
+// read lock. The traversal stops as soon as fn returns false.
+func (s *StringTree[V]) Descend(fn func(key string, value V) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.tree.root.descend(s.tree.cmp, nil, nil, fn)
+}
+
+// All returns an iterator over every key-value pair in ascending key order,
+// suitable for use with range. The read lock is held until the loop completes
+// or breaks, so the loop body must not modify the StringTree.
+func (s *StringTree[V]) All() iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		s.Ascend(yield)
+	}
+}
+
+// Backward returns an iterator over every key-value pair in descending key
+// order, suitable for use with range. The read lock is held until the loop
+// completes or breaks, so the loop body must not modify the StringTree.
+func (s *StringTree[V]) Backward() iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		s.Descend(yield)
+	}
+}
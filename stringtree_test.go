@@ -0,0 +1,123 @@
+package btree_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringTree(t *testing.T) {
+	_, err := btree.NewStringTree[int](1)
+	require.Error(t, err)
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			s, err := btree.NewStringTree[int](minDegree)
+			require.NoError(t, err)
+
+			_, _, ok := s.Max()
+			require.False(t, ok)
+
+			// random inserts, replacements and deletions against a reference map
+			const n = 2000
+			want := make(map[string]int, n)
+
+			for i := 0; i < 5*n; i++ {
+				k, v := fmt.Sprint(rng.Intn(n)), rng.Int()
+				prev, existed := want[k]
+
+				if rng.Intn(3) == 0 {
+					old, ok := s.Delete(k)
+					require.Equal(t, existed, ok)
+					require.Equal(t, prev, old)
+					delete(want, k)
+				} else {
+					old, replaced := s.Set(k, v)
+					require.Equal(t, existed, replaced)
+					require.Equal(t, prev, old)
+					want[k] = v
+				}
+			}
+
+			require.Equal(t, len(want), s.Len())
+			require.NoError(t, s.Verify())
+
+			keys := make([]string, 0, len(want))
+			for k, v := range want {
+				got, ok := s.Get(k)
+				require.True(t, ok)
+				require.Equal(t, v, got)
+				require.True(t, s.Has(k))
+				keys = append(keys, k)
+			}
+
+			sort.Strings(keys)
+
+			k, v, ok := s.Min()
+			require.True(t, ok)
+			require.Equal(t, keys[0], k)
+			require.Equal(t, want[k], v)
+
+			k, _, ok = s.Max()
+			require.True(t, ok)
+			require.Equal(t, keys[len(keys)-1], k)
+
+			var got []string
+			for k, v := range s.All() {
+				require.Equal(t, want[k], v)
+				got = append(got, k)
+			}
+			require.Equal(t, keys, got)
+
+			got = got[:0]
+			for k := range s.Backward() {
+				got = append(got, k)
+			}
+			for i, k := range keys {
+				require.Equal(t, k, got[len(got)-1-i])
+			}
+
+			got = got[:0]
+			s.AscendRange("3", "5", func(k string, _ int) bool {
+				got = append(got, k)
+				return true
+			})
+			lo, hi := sort.SearchStrings(keys, "3"), sort.SearchStrings(keys, "5")
+			require.Equal(t, keys[lo:hi], got)
+		})
+	}
+}
+
+func TestStringTreeWithCollation(t *testing.T) {
+	_, err := btree.NewStringTreeWithCollation[int](3, nil)
+	require.Error(t, err)
+
+	// a case-insensitive collation which breaks no ties
+	s, err := btree.NewStringTreeWithCollation[int](2, func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+	require.NoError(t, err)
+
+	for i, k := range []string{"banana", "Apple", "cherry", "apricot", "Blueberry", "APPLE"} {
+		s.Set(k, i)
+	}
+
+	require.NoError(t, s.Verify())
+
+	var keys []string
+	s.Ascend(func(k string, _ int) bool {
+		keys = append(keys, k)
+		return true
+	})
+
+	// bytewise order would place every capitalized key first
+	require.Equal(t, []string{"APPLE", "apricot", "banana", "Blueberry", "cherry"}, keys)
+
+	v, ok := s.Get("apple")
+	require.True(t, ok)
+	require.Equal(t, 5, v)
+}
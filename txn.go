@@ -0,0 +1,98 @@
+package btree
+
+import "errors"
+
+var (
+	// ErrConflict is returned when committing a Txn whose BTree has been written
+	// to since the Txn began.
+	ErrConflict = errors.New("transaction conflicts with a concurrent write")
+
+	// ErrTxnDone is returned when committing a Txn that has already been
+	// committed or rolled back.
+	ErrTxnDone = errors.New("transaction already committed or rolled back")
+)
+
+// Txn implements a transaction on a BTree: a group of writes which are staged
+// on a private, lazy copy of the BTree and then either committed all at once or
+// discarded. Only the nodes a write touches are copied, so beginning a Txn is
+// constant time and the BTree itself is never modified before Commit. Reads
+// within the Txn observe its own writes, while other readers of the BTree only
+// observe them once committed. A Txn is not safe for concurrent use.
+type Txn struct {
+	bt   *BTree
+	tree *BTree
+	base *node
+	done bool
+}
+
+// Begin starts a new Txn on the BTree.
+func (bt *BTree) Begin() *Txn {
+	tree := bt.Clone()
+	return &Txn{bt: bt, tree: tree, base: tree.root}
+}
+
+// Get returns the Entry equal to the provided Entry as seen by the Txn, or nil
+// if no such Entry exists or the Entry is nil.
+func (tx *Txn) Get(e Entry) Entry {
+	tx.mustBeActive()
+	return tx.tree.Search(e)
+}
+
+// Insert inserts the given Entry within the Txn, replacing an equal Entry if
+// one exists. A nil Entry is ignored.
+func (tx *Txn) Insert(e Entry) {
+	tx.mustBeActive()
+	tx.tree.Insert(e)
+}
+
+// Delete removes the Entry equal to the provided Entry within the Txn and
+// returns it, or nil if no such Entry exists or the Entry is nil.
+func (tx *Txn) Delete(e Entry) Entry {
+	tx.mustBeActive()
+	return tx.tree.Delete(e)
+}
+
+// Commit makes every write of the Txn visible in the BTree at once and ends the
+// Txn. If the BTree has been written to since the Txn began, the Txn is
+// discarded instead and ErrConflict is returned. Conflicts are detected
+// conservatively, so a concurrent write that changed nothing, such as deleting
+// a missing Entry, may cause one as well.
+func (tx *Txn) Commit() error {
+	if tx.done {
+		return ErrTxnDone
+	}
+
+	tx.done = true
+
+	tx.bt.lock()
+	defer tx.bt.unlock()
+
+	// The first write to the BTree after cloning replaces its root, so an
+	// unchanged root means no write has happened since.
+	if tx.bt.root != tx.base {
+		return ErrConflict
+	}
+
+	// The BTree takes over the context of the copy along with its nodes, as
+	// nothing else references them any longer.
+	tx.bt.root, tx.bt.size, tx.bt.depth = tx.tree.root, tx.tree.size, tx.tree.depth
+	tx.bt.cow = tx.tree.cow
+	tx.bt.mods++
+	tx.tree = nil
+
+	return nil
+}
+
+// Rollback discards every write of the Txn and ends it, leaving the BTree
+// untouched. Rolling back a Txn that has already ended is a no-op.
+func (tx *Txn) Rollback() {
+	tx.done = true
+	tx.tree = nil
+}
+
+// mustBeActive panics if the Txn has already ended.
+func (tx *Txn) mustBeActive() {
+	if tx.done {
+		panic("btree: transaction already committed or rolled back")
+	}
+}
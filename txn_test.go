@@ -0,0 +1,71 @@
+package btree_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxn(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 1000)
+
+			// writes are visible within the transaction only
+			tx := bt.Begin()
+			for i := uint64(0); i < 1000; i += 2 {
+				require.NotNil(t, tx.Delete(testEntry{key: i}))
+			}
+
+			tx.Insert(testEntry{key: 1000, value: 1})
+			require.Nil(t, tx.Get(testEntry{key: 0}))
+			require.Equal(t, testEntry{key: 1000, value: 1}, tx.Get(testEntry{key: 1000}))
+			require.Equal(t, 1000, bt.Size())
+			require.Equal(t, entries[0], bt.Search(testEntry{key: 0}))
+
+			require.NoError(t, tx.Commit())
+			require.NoError(t, bt.Verify())
+			require.Equal(t, 501, bt.Size())
+			require.Nil(t, bt.Search(testEntry{key: 0}))
+			require.True(t, bt.Has(testEntry{key: 1000}))
+
+			require.True(t, errors.Is(tx.Commit(), btree.ErrTxnDone))
+			require.Panics(t, func() { tx.Insert(testEntry{}) })
+
+			// the tree remains usable after taking over the transaction's nodes
+			bt.Insert(testEntry{key: 0})
+			bt.Delete(testEntry{key: 1})
+			require.Equal(t, 501, bt.Size())
+			require.NoError(t, bt.Verify())
+
+			// a rolled back transaction leaves the tree untouched
+			tx = bt.Begin()
+			tx.Delete(testEntry{key: 0})
+			tx.Insert(testEntry{key: 2000})
+			tx.Rollback()
+			tx.Rollback()
+
+			require.Equal(t, 501, bt.Size())
+			require.True(t, bt.Has(testEntry{key: 0}))
+			require.False(t, bt.Has(testEntry{key: 2000}))
+			require.True(t, errors.Is(tx.Commit(), btree.ErrTxnDone))
+
+			// a transaction conflicting with a concurrent write is discarded
+			tx = bt.Begin()
+			other := bt.Begin()
+			tx.Insert(testEntry{key: 3000})
+			other.Insert(testEntry{key: 4000})
+
+			bt.Insert(testEntry{key: 5000})
+			require.True(t, errors.Is(tx.Commit(), btree.ErrConflict))
+			require.False(t, bt.Has(testEntry{key: 3000}))
+
+			require.True(t, errors.Is(other.Commit(), btree.ErrConflict))
+			require.Equal(t, 502, bt.Size())
+			require.NoError(t, bt.Verify())
+		})
+	}
+}
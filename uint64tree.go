@@ -0,0 +1,117 @@
+package btree
+
+import (
+	"cmp"
+	"sync"
+)
+
+// Uint64Tree implements a thread-safe B-Tree specialized for uint64 keys and
+// values. Keys and values are stored inline in the nodes rather than boxed in
+// entries, so inserting does not allocate per entry and comparisons do not
+// dispatch through an interface.
+type Uint64Tree struct {
+	mu   sync.RWMutex
+	tree *ktree[uint64, uint64]
+}
+
+// NewUint64Tree returns a reference to a new, empty Uint64Tree with a minimum
+// degree t.
+func NewUint64Tree(t int) (*Uint64Tree, error) {
+	tree, err := newKTree[uint64, uint64](t, cmp.Compare[uint64])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Uint64Tree{tree: tree}, nil
+}
+
+// Len returns the number of keys in the Uint64Tree.
+func (u *Uint64Tree) Len() int {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.tree.size
+}
+
+// Get returns the value stored under the given key and whether it exists.
+func (u *Uint64Tree) Get(key uint64) (uint64, bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.tree.get(key)
+}
+
+// Has returns true if a value is stored under the given key.
+func (u *Uint64Tree) Has(key uint64) bool {
+	_, ok := u.Get(key)
+	return ok
+}
+
+// Set stores the value under the given key, returning the value it replaced, if
+// any, and whether one was replaced.
+func (u *Uint64Tree) Set(key, value uint64) (old uint64, replaced bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.tree.set(key, value)
+}
+
+// Delete removes the given key, returning the value stored under it, if any,
+// and whether it existed.
+func (u *Uint64Tree) Delete(key uint64) (uint64, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	_, value, ok := u.tree.remove(key, kremoveKey)
+	return value, ok
+}
+
+// Min returns the smallest key along with its value, or false if the
+// Uint64Tree is empty.
+func (u *Uint64Tree) Min() (key, value uint64, ok bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.tree.min()
+}
+
+// Max returns the largest key along with its value, or false if the Uint64Tree
+// is empty.
+func (u *Uint64Tree) Max() (key, value uint64, ok bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.tree.max()
+}
+
+// Ascend calls fn for every key-value pair in ascending key order, under the
+// read lock. The traversal stops as soon as fn returns false.
+func (u *Uint64Tree) Ascend(fn func(key, value uint64) bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	u.tree.root.ascend(u.tree.cmp, nil, nil, fn)
+}
+
+// AscendRange calls fn for every key-value pair whose key k satisfies
+// from <= k < to, in ascending key order, under the read lock. The traversal
+// stops as soon as fn returns false.
+func (u *Uint64Tree) AscendRange(from, to uint64, fn func(key, value uint64) bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	u.tree.root.ascend(u.tree.cmp, &from, &to, fn)
+}
+
+// Descend calls fn for every key-value pair in descending key order, under the
+// read lock. The traversal stops as soon as fn returns false.
+func (u *Uint64Tree) Descend(fn func(key, value uint64) bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	u.tree.root.descend(u.tree.cmp, nil, nil, fn)
+}
+
+// Clear removes every key from the Uint64Tree.
+func (u *Uint64Tree) Clear() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.tree.root = &knode[uint64, uint64]{}
+	u.tree.size = 0
+}
@@ -0,0 +1,124 @@
+package btree_test
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUint64Tree(t *testing.T) {
+	_, err := btree.NewUint64Tree(1)
+	require.Error(t, err)
+
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			u, err := btree.NewUint64Tree(minDegree)
+			require.NoError(t, err)
+
+			_, _, ok := u.Min()
+			require.False(t, ok)
+
+			_, ok = u.Delete(1)
+			require.False(t, ok)
+
+			// random inserts, replacements and deletions against a reference map
+			const n = 2000
+			want := make(map[uint64]uint64, n)
+
+			for i := 0; i < 5*n; i++ {
+				k, v := uint64(rng.Intn(n)), rng.Uint64()
+				prev, existed := want[k]
+
+				if rng.Intn(3) == 0 {
+					old, ok := u.Delete(k)
+					require.Equal(t, existed, ok)
+					require.Equal(t, prev, old)
+					delete(want, k)
+				} else {
+					old, replaced := u.Set(k, v)
+					require.Equal(t, existed, replaced)
+					require.Equal(t, prev, old)
+					want[k] = v
+				}
+
+				require.Equal(t, len(want), u.Len())
+			}
+
+			require.NoError(t, u.Verify())
+
+			keys := make([]uint64, 0, len(want))
+			for k, v := range want {
+				got, ok := u.Get(k)
+				require.True(t, ok)
+				require.Equal(t, v, got)
+				keys = append(keys, k)
+			}
+
+			sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+			k, v, ok := u.Min()
+			require.True(t, ok)
+			require.Equal(t, keys[0], k)
+			require.Equal(t, want[k], v)
+
+			k, _, ok = u.Max()
+			require.True(t, ok)
+			require.Equal(t, keys[len(keys)-1], k)
+
+			var got []uint64
+			u.Ascend(func(k, v uint64) bool {
+				require.Equal(t, want[k], v)
+				got = append(got, k)
+				return true
+			})
+			require.Equal(t, keys, got)
+
+			got = got[:0]
+			u.Descend(func(k, _ uint64) bool {
+				got = append(got, k)
+				return true
+			})
+			for i, k := range keys {
+				require.Equal(t, k, got[len(got)-1-i])
+			}
+
+			got = got[:0]
+			u.AscendRange(500, 1500, func(k, _ uint64) bool {
+				got = append(got, k)
+				return true
+			})
+
+			lo := sort.Search(len(keys), func(i int) bool { return keys[i] >= 500 })
+			hi := sort.Search(len(keys), func(i int) bool { return keys[i] >= 1500 })
+			require.Equal(t, keys[lo:hi], got)
+
+			// drain the tree completely
+			for _, k := range keys {
+				_, ok := u.Delete(k)
+				require.True(t, ok)
+			}
+
+			require.Zero(t, u.Len())
+			require.NoError(t, u.Verify())
+
+			u.Set(1, 1)
+			u.Clear()
+			require.Zero(t, u.Len())
+			require.False(t, u.Has(1))
+		})
+	}
+}
+
+func BenchmarkUint64TreeSet(b *testing.B) {
+	u, err := btree.NewUint64Tree(17)
+	require.NoError(b, err)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		u.Set(rng.Uint64(), rng.Uint64())
+	}
+}
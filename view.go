@@ -0,0 +1,97 @@
+package btree
+
+// View implements a read-only window onto the entries e of a BTree, s.t.
+// from <= e < to, where a nil from or to leaves the respective end of the
+// window unbounded. A View holds no entries of its own: every method reads the
+// underlying BTree under its read lock, so a View always reflects the current
+// contents of the BTree and creating one is free.
+type View struct {
+	bt       *BTree
+	from, to Entry
+}
+
+// HeadView returns a View of the entries e of the BTree, s.t. e < to.
+func (bt *BTree) HeadView(to Entry) *View {
+	return &View{bt: bt, to: to}
+}
+
+// TailView returns a View of the entries e of the BTree, s.t. e >= from.
+func (bt *BTree) TailView(from Entry) *View {
+	return &View{bt: bt, from: from}
+}
+
+// SubView returns a View of the entries e of the BTree, s.t. from <= e < to.
+func (bt *BTree) SubView(from, to Entry) *View {
+	return &View{bt: bt, from: from, to: to}
+}
+
+// Size returns the number of entries within the View in logarithmic time.
+func (v *View) Size() int {
+	return v.bt.CountRange(v.from, v.to)
+}
+
+// Search returns the Entry equal to the provided Entry if it lies within the
+// View, or nil otherwise.
+func (v *View) Search(e Entry) Entry {
+	if e == nil || !v.contains(e) {
+		return nil
+	}
+
+	return v.bt.Search(e)
+}
+
+// Has returns true if an Entry equal to the provided Entry lies within the View.
+func (v *View) Has(e Entry) bool {
+	return v.Search(e) != nil
+}
+
+// Min returns the smallest Entry within the View, or nil if it is empty.
+func (v *View) Min() Entry {
+	bt := v.bt.rlock()
+	defer bt.mu.RUnlock()
+
+	e := bt.root.min()
+	if v.from != nil {
+		e = bt.root.ceil(bt.cmp, v.from, true)
+	}
+
+	if e == nil || !v.contains(e) {
+		return nil
+	}
+
+	return e
+}
+
+// Max returns the largest Entry within the View, or nil if it is empty.
+func (v *View) Max() Entry {
+	bt := v.bt.rlock()
+	defer bt.mu.RUnlock()
+
+	e := bt.root.max()
+	if v.to != nil {
+		e = bt.root.floor(bt.cmp, v.to, false)
+	}
+
+	if e == nil || !v.contains(e) {
+		return nil
+	}
+
+	return e
+}
+
+// Ascend calls fn for every Entry within the View in ascending order, under the
+// read lock of the BTree. The traversal stops as soon as fn returns false.
+func (v *View) Ascend(fn func(Entry) bool) {
+	v.bt.AscendRange(v.from, v.to, fn)
+}
+
+// Descend calls fn for every Entry within the View in descending order, under
+// the read lock of the BTree. The traversal stops as soon as fn returns false.
+func (v *View) Descend(fn func(Entry) bool) {
+	v.bt.DescendRange(v.from, v.to, fn)
+}
+
+// contains returns true if the given Entry lies within the bounds of the View.
+func (v *View) contains(e Entry) bool {
+	return (v.from == nil || v.bt.cmp(e, v.from) >= 0) && (v.to == nil || v.bt.cmp(e, v.to) < 0)
+}
@@ -0,0 +1,80 @@
+package btree_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alexanderbez/btree"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBTreeViews(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 4, 11, 17} {
+		t.Run(fmt.Sprintf("minimum degree %d", minDegree), func(t *testing.T) {
+			bt, entries := newTestBTree(t, minDegree, 1000)
+
+			for _, tc := range []struct {
+				view     *btree.View
+				from, to int
+			}{
+				{bt.HeadView(testEntry{key: 100}), 0, 100},
+				{bt.HeadView(testEntry{key: 0}), 0, 0},
+				{bt.TailView(testEntry{key: 900}), 900, 1000},
+				{bt.TailView(testEntry{key: 5000}), 1000, 1000},
+				{bt.SubView(testEntry{key: 250}, testEntry{key: 750}), 250, 750},
+				{bt.SubView(testEntry{key: 750}, testEntry{key: 250}), 250, 250},
+				{bt.SubView(nil, nil), 0, 1000},
+			} {
+				want := entries[tc.from:tc.to]
+				require.Equal(t, len(want), tc.view.Size())
+
+				if len(want) == 0 {
+					require.Nil(t, tc.view.Min())
+					require.Nil(t, tc.view.Max())
+				} else {
+					require.Equal(t, want[0], tc.view.Min())
+					require.Equal(t, want[len(want)-1], tc.view.Max())
+				}
+
+				for _, e := range entries {
+					inside := int(e.key) >= tc.from && int(e.key) < tc.to
+					require.Equal(t, inside, tc.view.Has(e))
+
+					if inside {
+						require.Equal(t, e, tc.view.Search(e))
+					} else {
+						require.Nil(t, tc.view.Search(e))
+					}
+				}
+
+				var got []testEntry
+				tc.view.Ascend(func(e btree.Entry) bool {
+					got = append(got, e.(testEntry))
+					return true
+				})
+
+				require.Equal(t, len(want), len(got))
+				for i, e := range want {
+					require.Equal(t, e, got[i])
+				}
+
+				got = got[:0]
+				tc.view.Descend(func(e btree.Entry) bool {
+					got = append(got, e.(testEntry))
+					return true
+				})
+
+				require.Equal(t, len(want), len(got))
+				for i, e := range want {
+					require.Equal(t, e, got[len(got)-1-i])
+				}
+			}
+
+			// views reflect subsequent writes to the tree
+			view := bt.SubView(testEntry{key: 250}, testEntry{key: 750})
+			bt.DeleteRange(testEntry{key: 0}, testEntry{key: 300})
+			require.Equal(t, 450, view.Size())
+			require.Equal(t, entries[300], view.Min())
+		})
+	}
+}